@@ -0,0 +1,261 @@
+package clients
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"time"
+
+	errx "github.com/rhuandantas/verifymy-test/internal/errors"
+	"github.com/rhuandantas/verifymy-test/internal/log"
+	"github.com/rhuandantas/verifymy-test/internal/models"
+	"github.com/rhuandantas/verifymy-test/internal/repo"
+)
+
+//go:generate mockgen -source=$GOFILE -package=mock_clients -destination=../../test/mock/clients/$GOFILE
+
+// Meter counts the usage of clients by day, UTC, and enforces the daily quotas of their plans
+type Meter interface {
+	// Check fails with errx.QuotaExceeded when the client used up the daily quota of the metric of its plan,
+	// otherwise it counts one use of the metric right away so that concurrent calls can't go over the quota.
+	// The use is given back with release, for calls that end up not counting
+	Check(ctx context.Context, client *models.Client, metric string) (release func(), err error)
+	// Record counts one use of the metric by the client
+	Record(clientId, metric string)
+	// Flush stores what was recorded since the last flush
+	Flush(ctx context.Context) error
+	// Report returns the usage of the client from one day to another, both included, what wasn't flushed yet too
+	Report(ctx context.Context, clientId string, from, to models.Date) (*models.UsageReport, error)
+}
+
+type usageKey struct {
+	clientId string
+	day      models.Date
+	metric   string
+}
+
+// usageCounter is the usage of a key, what's stored and what was recorded since. stored is only known
+// once loaded, before that it's whatever was flushed by this server
+type usageCounter struct {
+	stored  int64
+	pending int64
+	loaded  bool
+}
+
+// take counts one use unless the limit, when there's one, is used up, returning how much was used before.
+// mu must be held
+func (uc *usageCounter) take(limit int64) int64 {
+	used := uc.stored + uc.pending
+	if limit <= 0 || used < limit {
+		uc.pending++
+	}
+
+	return used
+}
+
+// MemoryMeter records usage in memory, flushed to the database every now and then. Quotas are checked
+// against what's stored plus what this server recorded, so with several servers a client may go over its
+// quota by what the others recorded since their last flush
+type MemoryMeter struct {
+	registry  Registry
+	usageRepo repo.UsageRepo
+	logger    log.SimpleLogger
+	mu        sync.Mutex
+	counters  map[usageKey]*usageCounter
+	now       func() time.Time
+}
+
+func NewMemoryMeter(registry Registry, usageRepo repo.UsageRepo, logger log.SimpleLogger) Meter {
+	return &MemoryMeter{
+		registry:  registry,
+		usageRepo: usageRepo,
+		logger:    logger,
+		counters:  make(map[usageKey]*usageCounter),
+		now:       time.Now,
+	}
+}
+
+func (mm *MemoryMeter) Check(ctx context.Context, client *models.Client, metric string) (func(), error) {
+	plan, err := mm.registry.Plan(client.Plan)
+	if err != nil {
+		return nil, err
+	}
+
+	now := mm.now().UTC()
+	key := usageKey{clientId: client.ClientId, day: models.DateOf(now), metric: metric}
+	limit := plan.Quotas[metric]
+	used, err := mm.reserve(ctx, key, limit)
+	if err != nil {
+		return nil, err
+	}
+
+	if limit > 0 && used >= limit {
+		return nil, errx.Localized(errx.QuotaExceeded, "clients.quota_exceeded", "client %s used its daily quota of %d %s on plan %s", client.ClientId, limit, metric, plan.Name).
+			WithProperty(errx.QuotaProperty, &errx.Quota{
+				Plan:     plan.Name,
+				Metric:   metric,
+				Limit:    limit,
+				Used:     used,
+				ResetsAt: key.day.AddDays(1).Time,
+			})
+	}
+
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			mm.mu.Lock()
+			defer mm.mu.Unlock()
+			mm.counter(key).pending--
+		})
+	}, nil
+}
+
+func (mm *MemoryMeter) Record(clientId, metric string) {
+	key := usageKey{clientId: clientId, day: models.DateOf(mm.now().UTC()), metric: metric}
+
+	mm.mu.Lock()
+	defer mm.mu.Unlock()
+	mm.counter(key).pending++
+}
+
+// Flush stores the pending usage of every key, keeping what fails for the next flush. Counters of past days
+// are dropped once flushed
+func (mm *MemoryMeter) Flush(ctx context.Context) error {
+	mm.mu.Lock()
+	pending := make(map[usageKey]int64)
+	for key, counter := range mm.counters {
+		// a use released after being flushed leaves pending below zero, which gives it back
+		if counter.pending != 0 {
+			pending[key] = counter.pending
+		}
+	}
+	mm.mu.Unlock()
+
+	var firstErr error
+	for key, count := range pending {
+		if err := mm.usageRepo.Add(ctx, key.clientId, key.day, key.metric, count); err != nil {
+			mm.logger.Errorf("flushing %d %s of client %s: %s", count, key.metric, key.clientId, err.Error())
+			if firstErr == nil {
+				firstErr = err
+			}
+			continue
+		}
+
+		mm.mu.Lock()
+		counter := mm.counter(key)
+		counter.pending -= count
+		counter.stored += count
+		mm.mu.Unlock()
+	}
+
+	today := models.DateOf(mm.now().UTC())
+	mm.mu.Lock()
+	for key, counter := range mm.counters {
+		if key.day.Before(today.Time) && counter.pending == 0 {
+			delete(mm.counters, key)
+		}
+	}
+	mm.mu.Unlock()
+
+	return firstErr
+}
+
+func (mm *MemoryMeter) Report(ctx context.Context, clientId string, from, to models.Date) (*models.UsageReport, error) {
+	client, err := mm.registry.Get(ctx, clientId)
+	if err != nil {
+		return nil, err
+	}
+
+	plan, err := mm.registry.Plan(client.Plan)
+	if err != nil {
+		return nil, err
+	}
+
+	usage, err := mm.usageRepo.Range(ctx, clientId, from, to)
+	if err != nil {
+		return nil, err
+	}
+
+	days := make(map[models.Date]map[string]int64)
+	add := func(day models.Date, metric string, count int64) {
+		if days[day] == nil {
+			days[day] = make(map[string]int64, len(models.Metrics))
+		}
+		days[day][metric] += count
+	}
+	for _, row := range usage {
+		add(row.Day, row.Metric, row.Count)
+	}
+
+	mm.mu.Lock()
+	for key, counter := range mm.counters {
+		if key.clientId == clientId && counter.pending != 0 && !key.day.Before(from.Time) && !key.day.After(to.Time) {
+			add(key.day, key.metric, counter.pending)
+		}
+	}
+	mm.mu.Unlock()
+
+	report := &models.UsageReport{
+		ClientId: clientId,
+		Plan:     plan,
+		From:     from,
+		To:       to,
+		Days:     make([]*models.DailyUsage, 0, len(days)),
+		Totals:   make(map[string]int64, len(models.Metrics)),
+	}
+	for _, metric := range models.Metrics {
+		report.Totals[metric] = 0
+	}
+	for day, counts := range days {
+		// every metric is listed, the ones unused that day with 0
+		daily := &models.DailyUsage{Day: day, Counts: make(map[string]int64, len(models.Metrics))}
+		for _, metric := range models.Metrics {
+			daily.Counts[metric] = counts[metric]
+			report.Totals[metric] += counts[metric]
+		}
+		report.Days = append(report.Days, daily)
+	}
+	sort.Slice(report.Days, func(i, j int) bool {
+		return report.Days[i].Day.Before(report.Days[j].Day.Time)
+	})
+
+	return report, nil
+}
+
+// reserve counts one use of the key unless the limit, when there's one, is used up, returning how much
+// was used before. What's stored is read the first time a limited key is checked
+func (mm *MemoryMeter) reserve(ctx context.Context, key usageKey, limit int64) (int64, error) {
+	mm.mu.Lock()
+	counter := mm.counter(key)
+	if limit <= 0 || counter.loaded {
+		defer mm.mu.Unlock()
+		return counter.take(limit), nil
+	}
+	mm.mu.Unlock()
+
+	stored, err := mm.usageRepo.Count(ctx, key.clientId, key.day, key.metric)
+	if err != nil {
+		return 0, err
+	}
+
+	mm.mu.Lock()
+	defer mm.mu.Unlock()
+	counter = mm.counter(key)
+	if !counter.loaded {
+		// what's stored already holds whatever this server flushed
+		counter.stored, counter.loaded = stored, true
+	}
+
+	return counter.take(limit), nil
+}
+
+// counter returns the counter of the key, creating it. mu must be held
+func (mm *MemoryMeter) counter(key usageKey) *usageCounter {
+	counter, ok := mm.counters[key]
+	if !ok {
+		counter = &usageCounter{}
+		mm.counters[key] = counter
+	}
+
+	return counter
+}
@@ -0,0 +1,225 @@
+package clients
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/base64"
+	"fmt"
+	"strings"
+
+	"github.com/joomcode/errorx"
+	"github.com/rhuandantas/verifymy-test/internal/config"
+	errx "github.com/rhuandantas/verifymy-test/internal/errors"
+	"github.com/rhuandantas/verifymy-test/internal/log"
+	"github.com/rhuandantas/verifymy-test/internal/models"
+	"github.com/rhuandantas/verifymy-test/internal/repo"
+	"github.com/rhuandantas/verifymy-test/internal/storage"
+)
+
+//go:generate mockgen -source=$GOFILE -package=mock_clients -destination=../../test/mock/clients/$GOFILE
+
+const defaultPlan = "free"
+
+// quotaKeys are the config keys of the daily quotas of a plan, by metric
+var quotaKeys = map[string]string{
+	models.MetricVerifications: "verifications",
+	models.MetricTokens:        "tokens",
+	models.MetricApiCalls:      "api-calls",
+}
+
+// Registry keeps the relying parties calling the API. Their secrets are generated here, returned once and
+// kept encrypted, never hashed, since checking an HMAC signature needs the secret itself
+type Registry interface {
+	// Register fails with errx.Conflict when the client id is taken, by a client or a configured partner
+	Register(ctx context.Context, registration models.ClientRegistration, actor string) (*models.ClientCredentials, error)
+	Get(ctx context.Context, clientId string) (*models.Client, error)
+	List(ctx context.Context) ([]*models.Client, error)
+	Update(ctx context.Context, clientId string, update models.ClientUpdate, actor string) (*models.Client, error)
+	// RotateSecret replaces the secret of the client, the previous one stops working right away
+	RotateSecret(ctx context.Context, clientId, actor string) (*models.ClientCredentials, error)
+	// Credentials returns the client along with its secret to check the signature of its requests, whatever
+	// its status
+	Credentials(ctx context.Context, clientId string) (*models.Client, string, error)
+	// Plan returns the plan of clients.plans with the name, failing with errx.BadRequest when there's none
+	Plan(name string) (*models.Plan, error)
+}
+
+type ClientRegistry struct {
+	config      config.ConfigProvider
+	clientRepo  repo.ClientRepo
+	envelope    storage.Envelope
+	logger      log.SimpleLogger
+	defaultPlan string
+}
+
+// NewClientRegistry registers clients on the plan of clients.default-plan unless they name one, free by default.
+// Plans are configured under clients.plans.{name} with a title and the daily quota of each metric
+func NewClientRegistry(config config.ConfigProvider, clientRepo repo.ClientRepo, envelope storage.Envelope, logger log.SimpleLogger) Registry {
+	return &ClientRegistry{
+		config:      config,
+		clientRepo:  clientRepo,
+		envelope:    envelope,
+		logger:      logger,
+		defaultPlan: config.GetStringOrDefault("clients.default-plan", defaultPlan),
+	}
+}
+
+func (cr *ClientRegistry) Register(ctx context.Context, registration models.ClientRegistration, actor string) (*models.ClientCredentials, error) {
+	if registration.Plan == "" {
+		registration.Plan = cr.defaultPlan
+	}
+	if _, err := cr.Plan(registration.Plan); err != nil {
+		return nil, err
+	}
+
+	// partners of the config sign with the same scheme and are looked up first, a client named like one
+	// could never authenticate
+	if cr.config.GetString(fmt.Sprintf("auth.hmac.partners.%s.secret-key", registration.ClientId)) != "" {
+		return nil, errx.Localized(errx.Conflict, "clients.partner_id", "client id %s is taken by a configured partner", registration.ClientId)
+	}
+
+	client := models.Client{
+		ClientId:       registration.ClientId,
+		Name:           registration.Name,
+		Plan:           registration.Plan,
+		Status:         models.ClientActive,
+		AllowedOrigins: nonNil(registration.AllowedOrigins),
+		RedirectUris:   nonNil(registration.RedirectUris),
+		Permissions:    nonNil(registration.Permissions),
+		CreatedBy:      actor,
+	}
+	secret, err := cr.seal(&client)
+	if err != nil {
+		return nil, err
+	}
+
+	created, err := cr.clientRepo.Create(ctx, client)
+	if err != nil {
+		if errorx.IsOfType(err, errx.Conflict) {
+			return nil, errx.LocalizedWrap(errx.Conflict, err, "clients.taken", "client %s is already registered", client.ClientId)
+		}
+		return nil, err
+	}
+	cr.logger.Infof("client %s registered on plan %s by %s", created.ClientId, created.Plan, actor)
+
+	return &models.ClientCredentials{Client: created, Secret: secret}, nil
+}
+
+func (cr *ClientRegistry) Get(ctx context.Context, clientId string) (*models.Client, error) {
+	return cr.clientRepo.Get(ctx, clientId)
+}
+
+func (cr *ClientRegistry) List(ctx context.Context) ([]*models.Client, error) {
+	return cr.clientRepo.List(ctx)
+}
+
+func (cr *ClientRegistry) Update(ctx context.Context, clientId string, update models.ClientUpdate, actor string) (*models.Client, error) {
+	if _, err := cr.Plan(update.Plan); err != nil {
+		return nil, err
+	}
+
+	client, err := cr.clientRepo.Get(ctx, clientId)
+	if err != nil {
+		return nil, err
+	}
+
+	client.Name = update.Name
+	client.Plan = update.Plan
+	client.Status = update.Status
+	client.AllowedOrigins = nonNil(update.AllowedOrigins)
+	client.RedirectUris = nonNil(update.RedirectUris)
+	client.Permissions = nonNil(update.Permissions)
+	updated, err := cr.clientRepo.Update(ctx, *client)
+	if err != nil {
+		return nil, err
+	}
+	cr.logger.Infof("client %s updated by %s, plan %s and status %s", clientId, actor, updated.Plan, updated.Status)
+
+	return updated, nil
+}
+
+func (cr *ClientRegistry) RotateSecret(ctx context.Context, clientId, actor string) (*models.ClientCredentials, error) {
+	client, err := cr.clientRepo.Get(ctx, clientId)
+	if err != nil {
+		return nil, err
+	}
+
+	secret, err := cr.seal(client)
+	if err != nil {
+		return nil, err
+	}
+
+	updated, err := cr.clientRepo.Update(ctx, *client)
+	if err != nil {
+		return nil, err
+	}
+	cr.logger.Infof("secret of client %s rotated by %s", clientId, actor)
+
+	return &models.ClientCredentials{Client: updated, Secret: secret}, nil
+}
+
+func (cr *ClientRegistry) Credentials(ctx context.Context, clientId string) (*models.Client, string, error) {
+	client, err := cr.clientRepo.Get(ctx, clientId)
+	if err != nil {
+		return nil, "", err
+	}
+
+	secret, err := cr.envelope.Open(storage.Sealed{Ciphertext: client.SecretCiphertext, WrappedKey: client.WrappedKey, KeyId: client.KeyId}, secretAad(clientId))
+	if err != nil {
+		return nil, "", fmt.Errorf("decrypting secret of client %s: %w", clientId, err)
+	}
+
+	return client, string(secret), nil
+}
+
+func (cr *ClientRegistry) Plan(name string) (*models.Plan, error) {
+	// plan names are config path segments
+	if name == "" || strings.Contains(name, ".") {
+		return nil, errx.Localized(errx.BadRequest, "clients.unknown_plan", "plan %q doesn't exist", name)
+	}
+
+	title := cr.config.GetString(fmt.Sprintf("clients.plans.%s.title", name))
+	if title == "" {
+		return nil, errx.Localized(errx.BadRequest, "clients.unknown_plan", "plan %q doesn't exist", name)
+	}
+
+	plan := &models.Plan{Name: name, Title: title, Quotas: make(map[string]int64, len(quotaKeys))}
+	for metric, key := range quotaKeys {
+		plan.Quotas[metric] = int64(cr.config.GetInt(fmt.Sprintf("clients.plans.%s.%s", name, key)))
+	}
+
+	return plan, nil
+}
+
+// seal generates a secret for the client, keeping it encrypted in the client, and returns it
+func (cr *ClientRegistry) seal(client *models.Client) (string, error) {
+	raw := make([]byte, 32)
+	if _, err := rand.Read(raw); err != nil {
+		return "", err
+	}
+	secret := base64.RawURLEncoding.EncodeToString(raw)
+
+	sealed, err := cr.envelope.Seal([]byte(secret), secretAad(client.ClientId))
+	if err != nil {
+		return "", err
+	}
+	client.SecretCiphertext = sealed.Ciphertext
+	client.WrappedKey = sealed.WrappedKey
+	client.KeyId = sealed.KeyId
+
+	return secret, nil
+}
+
+// secretAad binds the encrypted secret to its client, so it can't be copied over to another one
+func secretAad(clientId string) []byte {
+	return []byte("client:" + clientId)
+}
+
+// nonNil keeps lists stored as [] rather than null
+func nonNil(values []string) []string {
+	if values == nil {
+		return []string{}
+	}
+
+	return values
+}
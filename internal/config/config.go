@@ -5,6 +5,7 @@ import (
 	"github.com/joomcode/errorx"
 	"github.com/labstack/gommon/log"
 	"github.com/spf13/viper"
+	"strings"
 )
 
 //go:generate mockgen -source=$GOFILE -package=mock_config -destination=../../test/mock/config/$GOFILE
@@ -15,6 +16,7 @@ type ConfigProvider interface {
 	GetString(path string) string
 	GetInt(path string) int
 	GetBool(path string) bool
+	GetStringSlice(path string) []string
 	GetEnv(path string) string
 }
 
@@ -52,8 +54,18 @@ func (c *LocalConfigProvider) GetBool(path string) bool {
 	return c.config.GetBool(path)
 }
 
+func (c *LocalConfigProvider) GetStringSlice(path string) []string {
+	return c.config.GetStringSlice(path)
+}
+
+// GetEnv reads the env var, empty when it isn't set
 func (c *LocalConfigProvider) GetEnv(path string) string {
-	return fmt.Sprint(c.config.Get(path))
+	value := c.config.Get(path)
+	if value == nil {
+		return ""
+	}
+
+	return fmt.Sprint(value)
 }
 
 func (c *LocalConfigProvider) initConfig() {
@@ -68,5 +80,7 @@ func (c *LocalConfigProvider) initConfig() {
 		log.Warn(errorx.Decorate(err, "Failed to load configuration file."))
 	}
 
+	// nested keys are read from env vars too, verification.default-provider from VERIFICATION_DEFAULT_PROVIDER
+	c.config.SetEnvKeyReplacer(strings.NewReplacer(".", "_", "-", "_"))
 	c.config.AutomaticEnv()
 }
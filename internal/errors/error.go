@@ -1,11 +1,142 @@
 package errors
 
 import (
+	"fmt"
+	"time"
+
 	"github.com/joomcode/errorx"
 )
 
 var (
 	NotFound     = errorx.CommonErrors.NewType("not_found", errorx.NotFound())
 	BadRequest   = errorx.CommonErrors.NewType("bad_request")
+	Validation   = BadRequest.NewSubtype("validation")
 	Unauthorized = errorx.CommonErrors.NewType("unauthorized")
+	Forbidden    = errorx.CommonErrors.NewType("forbidden")
+	// AgeRestricted is a route the caller isn't verified to be old enough for
+	AgeRestricted = Forbidden.NewSubtype("age_restricted")
+	Conflict      = errorx.CommonErrors.NewType("conflict", errorx.Duplicate())
+	// InvalidTransition is a state change the current state of the resource doesn't allow
+	InvalidTransition = Conflict.NewSubtype("invalid_transition")
+	// NotVerified is asking for something only an age verified user gets
+	NotVerified         = Conflict.NewSubtype("not_verified")
+	ConstraintViolation = errorx.CommonErrors.NewType("constraint_violation")
+	PreconditionFailed  = errorx.CommonErrors.NewType("precondition_failed")
+	UnsupportedMedia    = errorx.CommonErrors.NewType("unsupported_media_type")
+	TooManyRequests     = errorx.CommonErrors.NewType("too_many_requests")
+	// QuotaExceeded is a client that used up the daily quota of its plan
+	QuotaExceeded = TooManyRequests.NewSubtype("quota_exceeded")
 )
+
+// FieldErrorsProperty carries the []FieldError of a Validation error
+var FieldErrorsProperty = errorx.RegisterProperty("field_errors")
+
+// ReasonProperty carries a machine readable reason of an AgeRestricted error
+var ReasonProperty = errorx.RegisterProperty("reason")
+
+// LinkProperty carries the path of the resource that helps with an error, e.g. where to start a verification
+var LinkProperty = errorx.RegisterProperty("link")
+
+// QuotaProperty carries the *Quota a QuotaExceeded error ran into
+var QuotaProperty = errorx.RegisterProperty("quota")
+
+// DetailProperty carries the *Detail describing an error in the catalog, see Localized
+var DetailProperty = errorx.RegisterProperty("detail")
+
+// FieldError describes a single field that failed validation, Field is the json name of the field
+type FieldError struct {
+	Field   string `json:"field"`
+	Rule    string `json:"rule"`
+	Param   string `json:"param,omitempty"`
+	Message string `json:"message"`
+}
+
+// Quota is the daily quota of the plan of a client for a metric, how much of it was used and when it resets
+type Quota struct {
+	Plan     string    `json:"plan"`
+	Metric   string    `json:"metric"`
+	Limit    int64     `json:"limit"`
+	Used     int64     `json:"used"`
+	ResetsAt time.Time `json:"resets_at"`
+}
+
+// Detail is the message of the catalog under details.<Key> describing an error, Params fill its placeholders
+// {0}, {1}... in order
+type Detail struct {
+	Key    string
+	Params []string
+}
+
+// Localized creates an error of type t described by the details.<key> message of the catalog. message formatted
+// with args is what gets logged and the detail of locales the catalog has no such message for, args are the params
+// of the catalog message as well
+func Localized(t *errorx.Type, key, message string, args ...interface{}) *errorx.Error {
+	return t.New(message, args...).WithProperty(DetailProperty, newDetail(key, args))
+}
+
+// LocalizedWrap is Localized keeping cause as the cause of the error
+func LocalizedWrap(t *errorx.Type, cause error, key, message string, args ...interface{}) *errorx.Error {
+	return t.Wrap(cause, message, args...).WithProperty(DetailProperty, newDetail(key, args))
+}
+
+// DetailOf returns the detail attached to err, if any
+func DetailOf(err *errorx.Error) *Detail {
+	if value, ok := err.Property(DetailProperty); ok {
+		if detail, ok := value.(*Detail); ok {
+			return detail
+		}
+	}
+
+	return nil
+}
+
+func newDetail(key string, args []interface{}) *Detail {
+	params := make([]string, 0, len(args))
+	for _, arg := range args {
+		params = append(params, fmt.Sprint(arg))
+	}
+
+	return &Detail{Key: key, Params: params}
+}
+
+// FieldErrors returns the field errors attached to err, if any
+func FieldErrors(err *errorx.Error) []FieldError {
+	if value, ok := err.Property(FieldErrorsProperty); ok {
+		if fields, ok := value.([]FieldError); ok {
+			return fields
+		}
+	}
+
+	return nil
+}
+
+// Reason returns the reason attached to err, if any
+func Reason(err *errorx.Error) string {
+	return stringProperty(err, ReasonProperty)
+}
+
+// Link returns the link attached to err, if any
+func Link(err *errorx.Error) string {
+	return stringProperty(err, LinkProperty)
+}
+
+// QuotaOf returns the quota attached to err, if any
+func QuotaOf(err *errorx.Error) *Quota {
+	if value, ok := err.Property(QuotaProperty); ok {
+		if quota, ok := value.(*Quota); ok {
+			return quota
+		}
+	}
+
+	return nil
+}
+
+func stringProperty(err *errorx.Error, property errorx.Property) string {
+	if value, ok := err.Property(property); ok {
+		if s, ok := value.(string); ok {
+			return s
+		}
+	}
+
+	return ""
+}
@@ -0,0 +1,165 @@
+package export
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/labstack/echo/v4"
+	errx "github.com/rhuandantas/verifymy-test/internal/errors"
+	"github.com/rhuandantas/verifymy-test/internal/models"
+)
+
+const (
+	FormatCSV    = "csv"
+	FormatNDJSON = "ndjson"
+	FormatJSON   = "json"
+
+	mimeTextCSV           = "text/csv"
+	mimeApplicationNDJSON = "application/x-ndjson"
+)
+
+// csvHeader are the columns of a csv export, passwords are never exported and age is computed when exported
+var csvHeader = []string{"user_id", "name", "date_of_birth", "age", "timezone", "email", "address", "status", "created_at", "updated_at", "version", "deleted_at"}
+
+// Encoder writes users one at a time, Close must be called once every user was written
+type Encoder interface {
+	ContentType() string
+	Encode(user *models.User) error
+	Close() error
+}
+
+// NewEncoder returns the encoder of the format, csv, ndjson or json
+func NewEncoder(format string, w io.Writer) (Encoder, error) {
+	switch format {
+	case FormatCSV:
+		return &csvEncoder{writer: csv.NewWriter(w)}, nil
+	case FormatNDJSON:
+		return &ndjsonEncoder{encoder: json.NewEncoder(w)}, nil
+	case FormatJSON:
+		return &jsonEncoder{writer: w, encoder: json.NewEncoder(w)}, nil
+	default:
+		return nil, errx.Localized(errx.BadRequest, "export.format", "format must be %s, %s or %s", FormatCSV, FormatNDJSON, FormatJSON)
+	}
+}
+
+type csvEncoder struct {
+	writer        *csv.Writer
+	headerWritten bool
+}
+
+func (ce *csvEncoder) ContentType() string {
+	return mimeTextCSV
+}
+
+func (ce *csvEncoder) Encode(user *models.User) error {
+	if !ce.headerWritten {
+		if err := ce.writer.Write(csvHeader); err != nil {
+			return err
+		}
+		ce.headerWritten = true
+	}
+
+	deletedAt := ""
+	if user.DeletedAt.Valid {
+		deletedAt = user.DeletedAt.Time.Format(time.RFC3339)
+	}
+
+	dateOfBirth, age := "", ""
+	if user.DateOfBirth != nil {
+		dateOfBirth = user.DateOfBirth.String()
+		age = strconv.Itoa(*user.AgeAt(time.Now()))
+	}
+
+	return ce.writer.Write([]string{
+		strconv.Itoa(user.UserId),
+		escapeFormula(user.Name),
+		dateOfBirth,
+		age,
+		escapeFormula(user.Timezone),
+		escapeFormula(user.Email),
+		escapeFormula(user.Address),
+		user.Status,
+		user.CreatedAt.Format(time.RFC3339),
+		user.UpdatedAt.Format(time.RFC3339),
+		strconv.Itoa(user.Version),
+		deletedAt,
+	})
+}
+
+// escapeFormula prefixes with ' the values a spreadsheet would take for a formula, so that opening an export
+// doesn't run what users typed in
+func escapeFormula(value string) string {
+	if value != "" && strings.ContainsRune("=+-@\t\r", rune(value[0])) {
+		return "'" + value
+	}
+
+	return value
+}
+
+func (ce *csvEncoder) Close() error {
+	if !ce.headerWritten {
+		if err := ce.writer.Write(csvHeader); err != nil {
+			return err
+		}
+	}
+
+	ce.writer.Flush()
+	return ce.writer.Error()
+}
+
+type ndjsonEncoder struct {
+	encoder *json.Encoder
+}
+
+func (ne *ndjsonEncoder) ContentType() string {
+	return mimeApplicationNDJSON
+}
+
+func (ne *ndjsonEncoder) Encode(user *models.User) error {
+	user.Password = ""
+	return ne.encoder.Encode(user)
+}
+
+func (ne *ndjsonEncoder) Close() error {
+	return nil
+}
+
+// jsonEncoder writes a single json array, the brackets are only written along with the first and last users
+// so the array is never held in memory
+type jsonEncoder struct {
+	writer  io.Writer
+	encoder *json.Encoder
+	count   int
+}
+
+func (je *jsonEncoder) ContentType() string {
+	return echo.MIMEApplicationJSON
+}
+
+func (je *jsonEncoder) Encode(user *models.User) error {
+	separator := ","
+	if je.count == 0 {
+		separator = "["
+	}
+	if _, err := io.WriteString(je.writer, separator); err != nil {
+		return err
+	}
+	je.count++
+
+	user.Password = ""
+	return je.encoder.Encode(user)
+}
+
+func (je *jsonEncoder) Close() error {
+	closing := "]\n"
+	if je.count == 0 {
+		closing = "[]\n"
+	}
+
+	_, err := io.WriteString(je.writer, closing)
+	return err
+}
@@ -0,0 +1,323 @@
+package guardian
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"strings"
+	"time"
+
+	"github.com/rhuandantas/verifymy-test/internal/config"
+	errx "github.com/rhuandantas/verifymy-test/internal/errors"
+	"github.com/rhuandantas/verifymy-test/internal/log"
+	"github.com/rhuandantas/verifymy-test/internal/models"
+	"github.com/rhuandantas/verifymy-test/internal/repo"
+	"github.com/rhuandantas/verifymy-test/internal/verification"
+)
+
+//go:generate mockgen -source=$GOFILE -package=mock_guardian -destination=../../test/mock/guardian/$GOFILE
+
+const (
+	defaultMinimumAge         = 16
+	defaultGuardianMinimumAge = 18
+	defaultInvitationHours    = 72
+)
+
+// Consents links minors to the guardians consenting for them. A user under users.consent.minimum-age is
+// restricted from registration on, until a guardian accepts an invitation; revoking the last consent
+// restricts them again. Guardians are age verified users of at least users.consent.guardian-minimum-age
+type Consents interface {
+	// Restrict sets the status of a user about to be registered, telling whether it's a restricted minor.
+	// Users without a date of birth aren't taken for minors
+	Restrict(user *models.User) bool
+	// Reassess sets the status of a registered user whose date of birth or timezone may have changed, as Restrict
+	// does, except that a minor a guardian consents for stays active
+	Reassess(ctx context.Context, user *models.User) error
+	// Invite invites a guardian to consent for the restricted minor on behalf of actor
+	Invite(ctx context.Context, minorId int, invite models.GuardianInvite, actor string) (*models.GuardianInvitation, error)
+	// Accept consents for the minor of the invitation, guardianEmail is the email the caller is registered with
+	Accept(ctx context.Context, acceptance models.GuardianAcceptance, guardianEmail string) (*models.GuardianLink, error)
+	// Revoke withdraws a consent or an invitation, only its guardian or an admin may
+	Revoke(ctx context.Context, minorId, linkId int, caller string, admin bool) (*models.GuardianLink, error)
+	// Withdraw revokes every consent of the guardian on behalf of actor, as Revoke does, so the minors are
+	// restricted again before the guardian is gone for good
+	Withdraw(ctx context.Context, guardianId int, actor string) error
+	List(ctx context.Context, minorId int) ([]*models.GuardianLink, error)
+}
+
+type ConsentService struct {
+	userRepo           repo.UserRepo
+	guardianRepo       repo.GuardianRepo
+	verification       verification.Service
+	logger             log.SimpleLogger
+	minimumAge         int
+	guardianMinimumAge int
+	invitationTTL      time.Duration
+	now                func() time.Time
+}
+
+// NewConsentService reads the age under which users need consent from users.consent.minimum-age, 16 by
+// default, the age of guardians from users.consent.guardian-minimum-age, 18 by default, and how long an
+// invitation lasts from users.consent.invitation-hours, three days by default
+func NewConsentService(config config.ConfigProvider, userRepo repo.UserRepo, guardianRepo repo.GuardianRepo, service verification.Service, logger log.SimpleLogger) Consents {
+	minimumAge := config.GetInt("users.consent.minimum-age")
+	if minimumAge <= 0 {
+		minimumAge = defaultMinimumAge
+	}
+	guardianMinimumAge := config.GetInt("users.consent.guardian-minimum-age")
+	if guardianMinimumAge <= 0 {
+		guardianMinimumAge = defaultGuardianMinimumAge
+	}
+	invitationHours := config.GetInt("users.consent.invitation-hours")
+	if invitationHours <= 0 {
+		invitationHours = defaultInvitationHours
+	}
+
+	return &ConsentService{
+		userRepo:           userRepo,
+		guardianRepo:       guardianRepo,
+		verification:       service,
+		logger:             logger,
+		minimumAge:         minimumAge,
+		guardianMinimumAge: guardianMinimumAge,
+		invitationTTL:      time.Duration(invitationHours) * time.Hour,
+		now:                time.Now,
+	}
+}
+
+func (cs *ConsentService) Restrict(user *models.User) bool {
+	if age := user.AgeAt(cs.now()); age != nil && *age < cs.minimumAge {
+		user.Status = models.UserRestricted
+		return true
+	}
+
+	user.Status = models.UserActive
+	return false
+}
+
+func (cs *ConsentService) Reassess(ctx context.Context, user *models.User) error {
+	if !cs.Restrict(user) {
+		return nil
+	}
+
+	consented, err := cs.guardianRepo.CountConsented(ctx, user.UserId)
+	if err != nil {
+		return err
+	}
+
+	if consented > 0 {
+		user.Status = models.UserActive
+	}
+
+	return nil
+}
+
+func (cs *ConsentService) Invite(ctx context.Context, minorId int, invite models.GuardianInvite, actor string) (*models.GuardianInvitation, error) {
+	minor, err := cs.userRepo.GetByID(ctx, minorId, "email", "status")
+	if err != nil {
+		return nil, err
+	}
+
+	if minor.Status != models.UserRestricted {
+		return nil, errx.Localized(errx.InvalidTransition, "guardians.not_needed", "user %d doesn't need a guardian's consent", minorId)
+	}
+
+	guardianEmail := strings.TrimSpace(invite.GuardianEmail)
+	if err = ValidateGuardianEmail(minor.Email, guardianEmail); err != nil {
+		return nil, err
+	}
+
+	token, tokenHash, err := newToken()
+	if err != nil {
+		return nil, err
+	}
+
+	link, err := cs.guardianRepo.Create(ctx, models.GuardianLink{
+		MinorId:       minorId,
+		GuardianEmail: guardianEmail,
+		Status:        models.GuardianInvited,
+		TokenHash:     tokenHash,
+		InvitedBy:     actor,
+		ExpiresAt:     cs.now().UTC().Add(cs.invitationTTL),
+	})
+	if err != nil {
+		return nil, err
+	}
+	cs.logger.Infof("guardian %s invited to consent for user %d by %s", guardianEmail, minorId, actor)
+
+	return &models.GuardianInvitation{GuardianLink: link, Token: token}, nil
+}
+
+func (cs *ConsentService) Accept(ctx context.Context, acceptance models.GuardianAcceptance, guardianEmail string) (*models.GuardianLink, error) {
+	link, err := cs.guardianRepo.GetByToken(ctx, hashToken(acceptance.Token))
+	if err != nil {
+		return nil, err
+	}
+
+	now := cs.now().UTC()
+	switch {
+	case link.Status != models.GuardianInvited:
+		return nil, errx.Localized(errx.InvalidTransition, "guardians.invitation_used", "invitation was %s already", link.Status)
+	case now.After(link.ExpiresAt):
+		return nil, errx.Localized(errx.InvalidTransition, "guardians.invitation_expired", "invitation expired on %s", link.ExpiresAt.Format(time.RFC3339))
+	case guardianEmail == "" || !strings.EqualFold(link.GuardianEmail, guardianEmail):
+		return nil, errx.Localized(errx.Forbidden, "guardians.invitation_other", "invitation is for another guardian")
+	}
+
+	guardian, err := cs.eligibleGuardian(ctx, guardianEmail)
+	if err != nil {
+		return nil, err
+	}
+
+	link.Status = models.GuardianConsented
+	link.GuardianId = &guardian.UserId
+	link.ConsentedAt = &now
+	if link, err = cs.guardianRepo.Update(ctx, *link); err != nil {
+		return nil, err
+	}
+
+	if _, err = cs.userRepo.Patch(ctx, link.MinorId, map[string]interface{}{"status": models.UserActive}, 0); err != nil {
+		return nil, err
+	}
+	cs.logger.Infof("guardian %d consented for user %d", guardian.UserId, link.MinorId)
+
+	return link, nil
+}
+
+func (cs *ConsentService) Revoke(ctx context.Context, minorId, linkId int, caller string, admin bool) (*models.GuardianLink, error) {
+	link, err := cs.guardianRepo.Get(ctx, minorId, linkId)
+	if err != nil {
+		return nil, err
+	}
+
+	if !admin && !strings.EqualFold(link.GuardianEmail, caller) {
+		return nil, errx.Localized(errx.Forbidden, "guardians.revoke_forbidden", "only the guardian may revoke their consent")
+	}
+	if link.Status == models.GuardianRevoked {
+		return nil, errx.Localized(errx.InvalidTransition, "guardians.revoked", "guardian link %d was revoked already", linkId)
+	}
+
+	if link, err = cs.revoke(ctx, *link); err != nil {
+		return nil, err
+	}
+	cs.logger.Infof("guardian link %d of user %d revoked by %s", linkId, minorId, caller)
+
+	return link, nil
+}
+
+func (cs *ConsentService) Withdraw(ctx context.Context, guardianId int, actor string) error {
+	links, err := cs.guardianRepo.ListConsentedBy(ctx, guardianId)
+	if err != nil {
+		return err
+	}
+
+	for _, link := range links {
+		if _, err = cs.revoke(ctx, *link); err != nil {
+			return err
+		}
+		cs.logger.Infof("guardian link %d of user %d revoked by %s", link.LinkId, link.MinorId, actor)
+	}
+
+	return nil
+}
+
+// revoke revokes the link, restricting its minor again when it was the last consent left
+func (cs *ConsentService) revoke(ctx context.Context, link models.GuardianLink) (*models.GuardianLink, error) {
+	consented := link.Status == models.GuardianConsented
+	now := cs.now().UTC()
+	link.Status = models.GuardianRevoked
+	link.RevokedAt = &now
+	revoked, err := cs.guardianRepo.Update(ctx, link)
+	if err != nil {
+		return nil, err
+	}
+
+	if consented {
+		remaining, err := cs.guardianRepo.CountConsented(ctx, link.MinorId)
+		if err != nil {
+			return nil, err
+		}
+
+		if remaining == 0 {
+			if _, err = cs.userRepo.Patch(ctx, link.MinorId, map[string]interface{}{"status": models.UserRestricted}, 0); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	return revoked, nil
+}
+
+func (cs *ConsentService) List(ctx context.Context, minorId int) ([]*models.GuardianLink, error) {
+	if _, err := cs.userRepo.GetByID(ctx, minorId, "user_id"); err != nil {
+		return nil, err
+	}
+
+	return cs.guardianRepo.List(ctx, minorId)
+}
+
+// eligibleGuardian returns the user registered with the email when they may be a guardian: an unrestricted,
+// age verified user old enough
+func (cs *ConsentService) eligibleGuardian(ctx context.Context, email string) (*models.User, error) {
+	guardian, err := cs.userRepo.GetByEmail(ctx, email)
+	if err != nil {
+		return nil, err
+	}
+
+	if guardian.Status == models.UserRestricted {
+		return nil, errx.Localized(errx.Forbidden, "guardians.restricted", "a restricted user can't be a guardian")
+	}
+	if age := guardian.AgeAt(cs.now()); age == nil || *age < cs.guardianMinimumAge {
+		return nil, errx.Localized(errx.Forbidden, "guardians.too_young", "guardians must be at least %d years old", cs.guardianMinimumAge)
+	}
+
+	status, err := cs.verification.Get(ctx, guardian.UserId)
+	if err != nil {
+		return nil, err
+	}
+	if status.State != models.VerificationVerified {
+		return nil, errx.Localized(errx.NotVerified, "guardians.not_verified", "guardian %d is %s", guardian.UserId, status.State)
+	}
+
+	return guardian, nil
+}
+
+// ValidateGuardianEmail checks the email a restricted minor names as guardian, which is required and can't
+// be their own
+func ValidateGuardianEmail(minorEmail, guardianEmail string) error {
+	switch {
+	case guardianEmail == "":
+		return errx.Localized(errx.Validation, "guardians.required", "minors need a guardian to consent for them").WithProperty(errx.FieldErrorsProperty, []errx.FieldError{{
+			Field:   "guardian_email",
+			Rule:    "required",
+			Message: "guardian_email is required for minors",
+		}})
+	case strings.EqualFold(guardianEmail, minorEmail):
+		return errx.Localized(errx.Validation, "guardians.self", "a minor can't be their own guardian").WithProperty(errx.FieldErrorsProperty, []errx.FieldError{{
+			Field:   "guardian_email",
+			Rule:    "nefield",
+			Param:   "email",
+			Message: "guardian_email must not be the email of the minor",
+		}})
+	}
+
+	return nil
+}
+
+// newToken returns a random invitation token and the hash it's stored as
+func newToken() (string, string, error) {
+	raw := make([]byte, 32)
+	if _, err := rand.Read(raw); err != nil {
+		return "", "", err
+	}
+
+	token := base64.RawURLEncoding.EncodeToString(raw)
+	return token, hashToken(token), nil
+}
+
+func hashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
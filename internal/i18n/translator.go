@@ -0,0 +1,184 @@
+package i18n
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/go-playground/locales"
+	"github.com/go-playground/locales/en"
+	"github.com/go-playground/locales/es"
+	"github.com/go-playground/locales/pt"
+	"github.com/go-playground/locales/pt_BR"
+	ut "github.com/go-playground/universal-translator"
+	"github.com/joomcode/errorx"
+	"github.com/rhuandantas/verifymy-test/internal/config"
+	"golang.org/x/text/language"
+	"gopkg.in/yaml.v3"
+)
+
+//go:generate mockgen -source=$GOFILE -package=mock_i18n -destination=../../test/mock/i18n/$GOFILE
+
+const (
+	HeaderAcceptLanguage = "Accept-Language"
+	defaultCatalogPath   = "./resources/i18n"
+	defaultLocale        = "en"
+)
+
+// supportedLocales holds the plural and formatting rules of the locales a catalog can be written for,
+// a catalog file for a locale that is not here needs its rules added first
+var supportedLocales = map[string]func() locales.Translator{
+	"en":    en.New,
+	"es":    es.New,
+	"pt":    pt.New,
+	"pt_BR": pt_BR.New,
+}
+
+type Translator interface {
+	// Locale picks the first requested locale that has a catalog, or the default locale
+	Locale(requested ...string) string
+	// Translate formats the message registered under key for the locale, falling back to the default locale,
+	// placeholders {0}, {1}... are replaced by params in order
+	Translate(locale, key string, params ...string) (string, bool)
+}
+
+type CatalogTranslator struct {
+	universal *ut.UniversalTranslator
+}
+
+// NewCatalogTranslator loads every <locale>.yml catalog from the i18n.path directory
+func NewCatalogTranslator(config config.ConfigProvider) (Translator, error) {
+	fallback := config.GetStringOrDefault("i18n.default-locale", defaultLocale)
+	newFallback, ok := supportedLocales[fallback]
+	if !ok {
+		return nil, errorx.IllegalArgument.New("default locale %s is not supported", fallback)
+	}
+
+	universal := ut.New(newFallback())
+	files, err := filepath.Glob(filepath.Join(config.GetStringOrDefault("i18n.path", defaultCatalogPath), "*.yml"))
+	if err != nil {
+		return nil, err
+	}
+
+	for _, file := range files {
+		locale := strings.TrimSuffix(filepath.Base(file), filepath.Ext(file))
+		newLocale, ok := supportedLocales[locale]
+		if !ok {
+			return nil, errorx.IllegalArgument.New("catalog %s is for an unsupported locale", file)
+		}
+
+		if err = universal.AddTranslator(newLocale(), true); err != nil {
+			return nil, err
+		}
+
+		if err = loadCatalog(universal, locale, file); err != nil {
+			return nil, errorx.Decorate(err, "failed to load catalog %s", file)
+		}
+	}
+
+	return &CatalogTranslator{
+		universal: universal,
+	}, nil
+}
+
+func (ct *CatalogTranslator) Locale(requested ...string) string {
+	trans, _ := ct.universal.FindTranslator(requested...)
+	return trans.Locale()
+}
+
+func (ct *CatalogTranslator) Translate(locale, key string, params ...string) (string, bool) {
+	trans, _ := ct.universal.GetTranslator(locale)
+	if message, ok := translate(trans, key, params); ok {
+		return message, true
+	}
+
+	return translate(ct.universal.GetFallback(), key, params)
+}
+
+// AcceptLanguage lists the locales of an Accept-Language header by preference,
+// each regional locale is followed by its base language, e.g. pt-BR;q=0.9 gives pt_BR and pt
+func AcceptLanguage(header string) []string {
+	tags, _, err := language.ParseAcceptLanguage(header)
+	if err != nil {
+		return nil
+	}
+
+	requested := make([]string, 0, len(tags)*2)
+	for _, tag := range tags {
+		requested = append(requested, strings.ReplaceAll(tag.String(), "-", "_"))
+		if base, confidence := tag.Base(); confidence != language.No {
+			requested = append(requested, base.String())
+		}
+	}
+
+	return requested
+}
+
+func translate(trans ut.Translator, key string, params []string) (message string, ok bool) {
+	// universal-translator panics when a message has more placeholders than params
+	defer func() {
+		if recover() != nil {
+			message, ok = "", false
+		}
+	}()
+
+	message, err := trans.T(key, params...)
+	return message, err == nil
+}
+
+func loadCatalog(universal *ut.UniversalTranslator, locale, file string) error {
+	content, err := os.ReadFile(file)
+	if err != nil {
+		return err
+	}
+
+	catalog := make(map[string]interface{})
+	if err = yaml.Unmarshal(content, &catalog); err != nil {
+		return err
+	}
+
+	trans, _ := universal.GetTranslator(locale)
+	for key, message := range flatten("", catalog) {
+		if err = trans.Add(key, message, true); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// flatten turns nested catalog sections into dotted keys, e.g. validation: {required: ...} into validation.required
+func flatten(prefix string, section map[string]interface{}) map[string]string {
+	messages := make(map[string]string)
+	for key, value := range section {
+		if prefix != "" {
+			key = prefix + "." + key
+		}
+
+		switch v := value.(type) {
+		case map[string]interface{}:
+			for k, message := range flatten(key, v) {
+				messages[k] = message
+			}
+		default:
+			messages[key] = fmt.Sprint(v)
+		}
+	}
+
+	return messages
+}
+
+// FieldMessage translates the failure of a validation rule on a field, rules without a message of their own
+// use validation.default
+func FieldMessage(t Translator, locale, field, rule, param string) string {
+	if message, ok := t.Translate(locale, "validation."+rule, field, param); ok {
+		return message
+	}
+
+	if message, ok := t.Translate(locale, "validation.default", field, rule); ok {
+		return message
+	}
+
+	return fmt.Sprintf("%s failed on the %s rule", field, rule)
+}
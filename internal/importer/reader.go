@@ -0,0 +1,213 @@
+package importer
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"io"
+	"strings"
+
+	errx "github.com/rhuandantas/verifymy-test/internal/errors"
+	"github.com/rhuandantas/verifymy-test/internal/models"
+)
+
+const (
+	// MIMETextCSV is the media type of csv imports, the first line must be a header
+	MIMETextCSV = "text/csv"
+	// MIMEApplicationNDJSON is the media type of newline delimited json imports, one user object per line
+	MIMEApplicationNDJSON = "application/x-ndjson"
+	// SkipColumn maps a csv header to no field, its values are ignored
+	SkipColumn = "-"
+
+	maxLineSize = 1024 * 1024
+)
+
+// Row is a user read from an import file, Err is set when the line couldn't be read into a user
+type Row struct {
+	Line int
+	User models.User
+	Err  error
+}
+
+// setters are the user fields an import can set, by json name
+var setters = map[string]func(user *models.User, value string) error{
+	"name": func(user *models.User, value string) error {
+		user.Name = value
+		return nil
+	},
+	"date_of_birth": func(user *models.User, value string) error {
+		if value == "" {
+			return nil
+		}
+
+		date, err := models.ParseDate(value)
+		if err != nil {
+			return errx.Localized(errx.BadRequest, "import.date_of_birth", "date_of_birth must be a date like %s, not %q", models.DateLayout, value)
+		}
+		user.DateOfBirth = &date
+		return nil
+	},
+	"timezone": func(user *models.User, value string) error {
+		user.Timezone = value
+		return nil
+	},
+	"email": func(user *models.User, value string) error {
+		user.Email = value
+		return nil
+	},
+	"password": func(user *models.User, value string) error {
+		user.Password = value
+		return nil
+	},
+	"address": func(user *models.User, value string) error {
+		user.Address = value
+		return nil
+	},
+	"guardian_email": func(user *models.User, value string) error {
+		user.GuardianEmail = strings.TrimSpace(value)
+		return nil
+	},
+}
+
+// importedUser holds the fields an ndjson line can have, anything else is rejected
+type importedUser struct {
+	Name          string       `json:"name"`
+	DateOfBirth   *models.Date `json:"date_of_birth"`
+	Timezone      string       `json:"timezone"`
+	Email         string       `json:"email"`
+	Password      string       `json:"password"`
+	Address       string       `json:"address"`
+	GuardianEmail string       `json:"guardian_email"`
+}
+
+// ReadCSV reads the users of a csv file. mapping gives the user field of a header, headers that aren't
+// mapped are matched to the user fields by name ignoring case, and a header that matches nothing fails the import
+func ReadCSV(r io.Reader, mapping map[string]string, maxRows int) ([]Row, error) {
+	reader := csv.NewReader(r)
+	reader.TrimLeadingSpace = true
+
+	header, err := reader.Read()
+	if errors.Is(err, io.EOF) {
+		return nil, errx.Localized(errx.BadRequest, "import.no_header", "csv must start with a header line")
+	}
+	if err != nil {
+		return nil, errx.LocalizedWrap(errx.BadRequest, err, "import.header_invalid", "csv header is not valid")
+	}
+
+	columns, err := columnSetters(header, mapping)
+	if err != nil {
+		return nil, err
+	}
+
+	rows := make([]Row, 0)
+	for {
+		record, err := reader.Read()
+		if errors.Is(err, io.EOF) {
+			return rows, nil
+		}
+
+		if len(rows) == maxRows {
+			return nil, errx.Localized(errx.BadRequest, "import.too_large", "import can't have more than %d rows", maxRows)
+		}
+
+		var parseErr *csv.ParseError
+		if errors.As(err, &parseErr) {
+			rows = append(rows, Row{Line: parseErr.StartLine, Err: errx.BadRequest.New(parseErr.Err.Error())})
+			continue
+		}
+		if err != nil {
+			return nil, errx.LocalizedWrap(errx.BadRequest, err, "import.csv_invalid", "csv is not valid")
+		}
+
+		line, _ := reader.FieldPos(0)
+		row := Row{Line: line}
+		for i, value := range record {
+			if columns[i] == nil {
+				continue
+			}
+
+			if err = columns[i](&row.User, strings.TrimSpace(value)); err != nil {
+				row.Err = err
+				break
+			}
+		}
+		rows = append(rows, row)
+	}
+}
+
+// ReadNDJSON reads the users of a newline delimited json file, blank lines are skipped
+func ReadNDJSON(r io.Reader, maxRows int) ([]Row, error) {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, bufio.MaxScanTokenSize), maxLineSize)
+
+	rows := make([]Row, 0)
+	for line := 1; scanner.Scan(); line++ {
+		content := bytes.TrimSpace(scanner.Bytes())
+		if len(content) == 0 {
+			continue
+		}
+
+		if len(rows) == maxRows {
+			return nil, errx.Localized(errx.BadRequest, "import.too_large", "import can't have more than %d rows", maxRows)
+		}
+
+		var user importedUser
+		decoder := json.NewDecoder(bytes.NewReader(content))
+		decoder.DisallowUnknownFields()
+		if err := decoder.Decode(&user); err != nil {
+			rows = append(rows, Row{Line: line, Err: errx.BadRequest.New(err.Error())})
+			continue
+		}
+
+		rows = append(rows, Row{Line: line, User: models.User{
+			Name:          user.Name,
+			DateOfBirth:   user.DateOfBirth,
+			Timezone:      user.Timezone,
+			Email:         strings.TrimSpace(user.Email),
+			Password:      user.Password,
+			Address:       user.Address,
+			GuardianEmail: strings.TrimSpace(user.GuardianEmail),
+		}})
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, errx.LocalizedWrap(errx.BadRequest, err, "import.ndjson_invalid", "ndjson is not valid")
+	}
+
+	return rows, nil
+}
+
+func columnSetters(header []string, mapping map[string]string) ([]func(user *models.User, value string) error, error) {
+	columns := make([]func(user *models.User, value string) error, len(header))
+	seen := make(map[string]bool)
+	for i, name := range header {
+		name = strings.TrimSpace(name)
+		field, ok := mapping[name]
+		if !ok {
+			field = strings.ToLower(name)
+		}
+
+		if field == SkipColumn {
+			continue
+		}
+
+		setter, ok := setters[field]
+		if !ok {
+			return nil, errx.Localized(errx.BadRequest, "import.unknown_column", "csv column %q doesn't map to a user field", name)
+		}
+
+		if seen[field] {
+			return nil, errx.Localized(errx.BadRequest, "import.duplicate_column", "more than one csv column maps to %s", field)
+		}
+		seen[field] = true
+		columns[i] = setter
+	}
+
+	if !seen["email"] {
+		return nil, errx.Localized(errx.BadRequest, "import.no_email", "no csv column maps to email")
+	}
+
+	return columns, nil
+}
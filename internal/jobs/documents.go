@@ -0,0 +1,42 @@
+package jobs
+
+import (
+	"context"
+	"time"
+
+	"github.com/rhuandantas/verifymy-test/internal/config"
+	"github.com/rhuandantas/verifymy-test/internal/log"
+	"github.com/rhuandantas/verifymy-test/internal/verification"
+)
+
+// DocumentPurge deletes the verification documents whose retention ended, content and record alike
+type DocumentPurge struct {
+	documents verification.Documents
+	logger    log.SimpleLogger
+	interval  time.Duration
+}
+
+func NewDocumentPurge(config config.ConfigProvider, documents verification.Documents, logger log.SimpleLogger) *DocumentPurge {
+	return &DocumentPurge{
+		documents: documents,
+		logger:    logger,
+		interval:  time.Duration(config.GetInt("verification.documents.purge-interval-minutes")) * time.Minute,
+	}
+}
+
+func (dp *DocumentPurge) Name() string {
+	return "document-purge"
+}
+
+func (dp *DocumentPurge) Interval() time.Duration {
+	return dp.interval
+}
+
+func (dp *DocumentPurge) Run(ctx context.Context) error {
+	purged, err := dp.documents.Purge(ctx, time.Now())
+	if purged > 0 {
+		dp.logger.Infof("purged %d verification documents past their retention", purged)
+	}
+
+	return err
+}
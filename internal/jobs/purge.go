@@ -0,0 +1,71 @@
+package jobs
+
+import (
+	"context"
+	"time"
+
+	"github.com/rhuandantas/verifymy-test/internal/config"
+	"github.com/rhuandantas/verifymy-test/internal/guardian"
+	"github.com/rhuandantas/verifymy-test/internal/log"
+	"github.com/rhuandantas/verifymy-test/internal/repo"
+	"github.com/rhuandantas/verifymy-test/internal/verification"
+)
+
+// UserPurge hard deletes users that have been soft deleted for longer than the retention period. The consents
+// of a purged guardian are withdrawn first, so their minors are restricted again, and the documents of a
+// purged user are deleted from the blob store, which the database can't cascade to
+type UserPurge struct {
+	userRepo  repo.UserRepo
+	consents  guardian.Consents
+	documents verification.Documents
+	logger    log.SimpleLogger
+	retention time.Duration
+	interval  time.Duration
+}
+
+func NewUserPurge(config config.ConfigProvider, userRepo repo.UserRepo, consents guardian.Consents, documents verification.Documents, logger log.SimpleLogger) *UserPurge {
+	return &UserPurge{
+		userRepo:  userRepo,
+		consents:  consents,
+		documents: documents,
+		logger:    logger,
+		retention: time.Duration(config.GetInt("users.purge.retention-hours")) * time.Hour,
+		interval:  time.Duration(config.GetInt("users.purge.interval-minutes")) * time.Minute,
+	}
+}
+
+func (up *UserPurge) Name() string {
+	return "user-purge"
+}
+
+func (up *UserPurge) Interval() time.Duration {
+	return up.interval
+}
+
+func (up *UserPurge) Run(ctx context.Context) error {
+	deletedBefore := time.Now().Add(-up.retention)
+	userIds, err := up.userRepo.Purgeable(ctx, deletedBefore)
+	if err != nil {
+		return err
+	}
+
+	for _, userId := range userIds {
+		if err = up.consents.Withdraw(ctx, userId, verification.ActorSystem); err != nil {
+			return err
+		}
+		if _, err = up.documents.DeleteAll(ctx, userId, verification.ActorSystem); err != nil {
+			return err
+		}
+	}
+
+	purged, err := up.userRepo.Purge(ctx, deletedBefore)
+	if err != nil {
+		return err
+	}
+
+	if purged > 0 {
+		up.logger.Infof("purged %d users deleted more than %s ago", purged, up.retention)
+	}
+
+	return nil
+}
@@ -0,0 +1,34 @@
+package jobs
+
+import (
+	"context"
+	"time"
+
+	"github.com/rhuandantas/verifymy-test/internal/config"
+	"github.com/rhuandantas/verifymy-test/internal/rules"
+)
+
+// RulesReload picks up changes of the minimum age rules file without restarting
+type RulesReload struct {
+	engine   rules.Engine
+	interval time.Duration
+}
+
+func NewRulesReload(config config.ConfigProvider, engine rules.Engine) *RulesReload {
+	return &RulesReload{
+		engine:   engine,
+		interval: time.Duration(config.GetInt("rules.reload-seconds")) * time.Second,
+	}
+}
+
+func (rr *RulesReload) Name() string {
+	return "rules-reload"
+}
+
+func (rr *RulesReload) Interval() time.Duration {
+	return rr.interval
+}
+
+func (rr *RulesReload) Run(ctx context.Context) error {
+	return rr.engine.Reload(ctx)
+}
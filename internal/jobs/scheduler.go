@@ -0,0 +1,89 @@
+package jobs
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/rhuandantas/verifymy-test/internal/log"
+)
+
+//go:generate mockgen -source=$GOFILE -package=mock_jobs -destination=../../test/mock/jobs/$GOFILE
+
+// Job is a task the Scheduler runs in background every Interval
+type Job interface {
+	Name() string
+	Interval() time.Duration
+	Run(ctx context.Context) error
+}
+
+// Finalizer is a Job that has to run one last time when the Scheduler stops, so that nothing it holds
+// in memory is lost
+type Finalizer interface {
+	Finalize(ctx context.Context) error
+}
+
+type Scheduler struct {
+	jobs   []Job
+	logger log.SimpleLogger
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+}
+
+func NewScheduler(logger log.SimpleLogger, userPurge *UserPurge, rulesReload *RulesReload, documentPurge *DocumentPurge, usageFlush *UsageFlush) *Scheduler {
+	return &Scheduler{
+		jobs:   []Job{userPurge, rulesReload, documentPurge, usageFlush},
+		logger: logger,
+	}
+}
+
+// Start runs every job with an interval greater than zero until Stop is called
+func (s *Scheduler) Start(ctx context.Context) {
+	ctx, s.cancel = context.WithCancel(ctx)
+	for _, job := range s.jobs {
+		if job.Interval() <= 0 {
+			s.logger.Infof("job %s is disabled", job.Name())
+			continue
+		}
+
+		s.wg.Add(1)
+		go s.loop(ctx, job)
+	}
+}
+
+// Stop cancels the running jobs, waits for them to return and then finalizes the jobs that are Finalizer
+func (s *Scheduler) Stop(ctx context.Context) {
+	if s.cancel != nil {
+		s.cancel()
+	}
+	s.wg.Wait()
+
+	for _, job := range s.jobs {
+		finalizer, ok := job.(Finalizer)
+		if !ok {
+			continue
+		}
+
+		if err := finalizer.Finalize(ctx); err != nil {
+			s.logger.Errorf("job %s failed to finalize: %v", job.Name(), err)
+		}
+	}
+}
+
+func (s *Scheduler) loop(ctx context.Context, job Job) {
+	defer s.wg.Done()
+
+	ticker := time.NewTicker(job.Interval())
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := job.Run(ctx); err != nil {
+				s.logger.Errorf("job %s failed: %v", job.Name(), err)
+			}
+		}
+	}
+}
@@ -0,0 +1,48 @@
+package jobs
+
+import (
+	"context"
+	"time"
+
+	"github.com/rhuandantas/verifymy-test/internal/clients"
+	"github.com/rhuandantas/verifymy-test/internal/config"
+)
+
+const defaultUsageFlushSeconds = 60
+
+// UsageFlush stores the usage the meter counted in memory, it can't be disabled since the usage of the
+// clients would never reach the database
+type UsageFlush struct {
+	meter    clients.Meter
+	interval time.Duration
+}
+
+// NewUsageFlush reads how often usage is flushed from clients.usage.flush-seconds, every minute by default
+func NewUsageFlush(config config.ConfigProvider, meter clients.Meter) *UsageFlush {
+	seconds := config.GetInt("clients.usage.flush-seconds")
+	if seconds <= 0 {
+		seconds = defaultUsageFlushSeconds
+	}
+
+	return &UsageFlush{
+		meter:    meter,
+		interval: time.Duration(seconds) * time.Second,
+	}
+}
+
+func (uf *UsageFlush) Name() string {
+	return "usage-flush"
+}
+
+func (uf *UsageFlush) Interval() time.Duration {
+	return uf.interval
+}
+
+func (uf *UsageFlush) Run(ctx context.Context) error {
+	return uf.meter.Flush(ctx)
+}
+
+// Finalize flushes what was counted since the last run, otherwise it's lost when the server stops
+func (uf *UsageFlush) Finalize(ctx context.Context) error {
+	return uf.meter.Flush(ctx)
+}
@@ -0,0 +1,26 @@
+package models
+
+import "time"
+
+// address types, a user has at most one address of each
+const (
+	AddressHome    = "home"
+	AddressBilling = "billing"
+)
+
+// Address is a postal address of a user. Country is an ISO 3166-1 alpha-2 code and the postal code is
+// checked against its format. Addresses go away along with their user when it's purged
+type Address struct {
+	AddressId  int       `json:"address_id" db:"address_id" gorm:"primaryKey;autoIncrement:true"`
+	UserId     int       `json:"user_id" db:"user_id" gorm:"not null;index:idx_address_user_type,unique"`
+	Type       string    `json:"type" validate:"required,oneof=home billing" db:"type" gorm:"size:16;not null;index:idx_address_user_type,unique"`
+	Line1      string    `json:"line1" validate:"required,max=255" db:"line1" gorm:"size:255;not null"`
+	Line2      string    `json:"line2" validate:"max=255" db:"line2" gorm:"size:255"`
+	City       string    `json:"city" validate:"required,max=128" db:"city" gorm:"size:128;not null"`
+	Region     string    `json:"region" validate:"max=128" db:"region" gorm:"size:128"`
+	PostalCode string    `json:"postal_code" validate:"postal_code=Country" db:"postal_code" gorm:"size:16"`
+	Country    string    `json:"country" validate:"required,iso3166_1_alpha2" db:"country" gorm:"size:2;not null"`
+	CreatedAt  time.Time `json:"created_at" db:"created_at"`
+	UpdatedAt  time.Time `json:"updated_at" db:"updated_at"`
+	User       *User     `json:"-" gorm:"constraint:OnDelete:CASCADE"`
+}
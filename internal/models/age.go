@@ -0,0 +1,33 @@
+package models
+
+import "time"
+
+// MaxAge is the oldest age a date of birth may give, anything older is taken as a typo
+const MaxAge = 150
+
+// AgeOn is how many years old someone born on birth is on today. Someone born on February 29 turns a
+// year older on March 1 when the year isn't a leap year
+func AgeOn(birth, today Date) int {
+	age := today.Year() - birth.Year()
+	if today.Month() < birth.Month() || (today.Month() == birth.Month() && today.Day() < birth.Day()) {
+		age--
+	}
+
+	return age
+}
+
+// LatestBirthDate is the last date someone may have been born on to be at least age years old on today,
+// the same day age years back or the last day of the month when that year has no such day
+func LatestBirthDate(age int, today Date) Date {
+	year, month, day := today.Year()-age, today.Month(), today.Day()
+	if last := daysIn(year, month); day > last {
+		day = last
+	}
+
+	return NewDate(year, month, day)
+}
+
+func daysIn(year int, month time.Month) int {
+	// day 0 of the next month is the last day of this one
+	return time.Date(year, month+1, 0, 0, 0, 0, 0, time.UTC).Day()
+}
@@ -0,0 +1,113 @@
+package models
+
+import "time"
+
+// client statuses, a suspended client can't authenticate
+const (
+	ClientActive    = "active"
+	ClientSuspended = "suspended"
+)
+
+// usage metrics counted for every client, by day
+const (
+	// MetricVerifications counts the provider checks a client started
+	MetricVerifications = "verifications"
+	// MetricTokens counts the age attestations issued to a client
+	MetricTokens = "tokens"
+	// MetricApiCalls counts every signed request of a client, refused ones included
+	MetricApiCalls = "api_calls"
+)
+
+// Metrics are every usage metric, in the order reports list them
+var Metrics = []string{MetricVerifications, MetricTokens, MetricApiCalls}
+
+// Client is a relying party, a website sending its users to be age verified. It signs its requests with
+// the HMAC scheme using ClientId as credential and a secret kept encrypted, WrappedKey and KeyId are the
+// envelope of SecretCiphertext. Browsers may only call on its behalf from AllowedOrigins and its users may
+// only be sent back to RedirectUris
+type Client struct {
+	ClientId         string    `json:"client_id" db:"client_id" gorm:"primaryKey;size:64"`
+	Name             string    `json:"name" db:"name" gorm:"size:255;not null"`
+	Plan             string    `json:"plan" db:"plan" gorm:"size:32;not null"`
+	Status           string    `json:"status" db:"status" gorm:"size:16;not null;default:active"`
+	AllowedOrigins   []string  `json:"allowed_origins" db:"allowed_origins" gorm:"serializer:json;type:text"`
+	RedirectUris     []string  `json:"redirect_uris" db:"redirect_uris" gorm:"serializer:json;type:text"`
+	Permissions      []string  `json:"permissions" db:"permissions" gorm:"serializer:json;type:text"`
+	SecretCiphertext []byte    `json:"-" db:"secret_ciphertext" gorm:"size:128;not null"`
+	WrappedKey       []byte    `json:"-" db:"wrapped_key" gorm:"size:128;not null"`
+	KeyId            string    `json:"-" db:"key_id" gorm:"size:32;not null"`
+	CreatedBy        string    `json:"created_by" db:"created_by" gorm:"size:128;not null"`
+	CreatedAt        time.Time `json:"created_at" db:"created_at"`
+	UpdatedAt        time.Time `json:"updated_at" db:"updated_at"`
+}
+
+// AllowsOrigin tells whether a browser at origin may call on behalf of the client
+func (c *Client) AllowsOrigin(origin string) bool {
+	for _, allowed := range c.AllowedOrigins {
+		if allowed == origin {
+			return true
+		}
+	}
+
+	return false
+}
+
+// ClientCredentials is a client along with its secret, which is only ever returned here, when the client
+// is registered or its secret rotated
+type ClientCredentials struct {
+	*Client
+	Secret string `json:"secret"`
+}
+
+// ClientRegistration registers a client, on the default plan when none is named
+type ClientRegistration struct {
+	ClientId       string   `json:"client_id" validate:"required,min=3,max=64,slug"`
+	Name           string   `json:"name" validate:"required,max=255"`
+	Plan           string   `json:"plan" validate:"omitempty,max=32"`
+	AllowedOrigins []string `json:"allowed_origins" validate:"max=20,dive,origin,max=255"`
+	RedirectUris   []string `json:"redirect_uris" validate:"max=20,dive,redirect_uri,max=2048"`
+	Permissions    []string `json:"permissions" validate:"dive,oneof=users:export users:verify users:attest users:review"`
+}
+
+// ClientUpdate replaces what may change of a client, every field is required as it is
+type ClientUpdate struct {
+	Name           string   `json:"name" validate:"required,max=255"`
+	Plan           string   `json:"plan" validate:"required,max=32"`
+	Status         string   `json:"status" validate:"required,oneof=active suspended"`
+	AllowedOrigins []string `json:"allowed_origins" validate:"max=20,dive,origin,max=255"`
+	RedirectUris   []string `json:"redirect_uris" validate:"max=20,dive,redirect_uri,max=2048"`
+	Permissions    []string `json:"permissions" validate:"dive,oneof=users:export users:verify users:attest users:review"`
+}
+
+// Plan is what a client is entitled to, its daily quota of each metric. A quota of 0 is unlimited
+type Plan struct {
+	Name   string           `json:"name"`
+	Title  string           `json:"title"`
+	Quotas map[string]int64 `json:"quotas"`
+}
+
+// ClientUsage is how much of a metric a client used on a day, UTC
+type ClientUsage struct {
+	ClientId string  `json:"client_id" db:"client_id" gorm:"primaryKey;size:64"`
+	Day      Date    `json:"day" db:"day" gorm:"primaryKey"`
+	Metric   string  `json:"metric" db:"metric" gorm:"primaryKey;size:32"`
+	Count    int64   `json:"count" db:"count" gorm:"not null"`
+	Client   *Client `json:"-" gorm:"constraint:OnDelete:CASCADE"`
+}
+
+// DailyUsage is the count of every metric a client used on a day
+type DailyUsage struct {
+	Day    Date             `json:"day"`
+	Counts map[string]int64 `json:"counts"`
+}
+
+// UsageReport is the usage of a client from From to To, both included, by day and in total. Days without
+// any usage are left out
+type UsageReport struct {
+	ClientId string           `json:"client_id"`
+	Plan     *Plan            `json:"plan"`
+	From     Date             `json:"from"`
+	To       Date             `json:"to"`
+	Days     []*DailyUsage    `json:"days"`
+	Totals   map[string]int64 `json:"totals"`
+}
@@ -0,0 +1,108 @@
+package models
+
+import (
+	"bytes"
+	"database/sql/driver"
+	"fmt"
+	"time"
+)
+
+// DateLayout is how dates are written in json, query params and csv files
+const DateLayout = "2006-01-02"
+
+// Date is a calendar date without time of day nor timezone, stored in a DATE column. The embedded time
+// is midnight UTC of the date
+type Date struct {
+	time.Time
+}
+
+func NewDate(year int, month time.Month, day int) Date {
+	return Date{time.Date(year, month, day, 0, 0, 0, 0, time.UTC)}
+}
+
+func ParseDate(value string) (Date, error) {
+	t, err := time.Parse(DateLayout, value)
+	if err != nil {
+		return Date{}, fmt.Errorf("%q is not a date like %s", value, DateLayout)
+	}
+
+	return Date{t}, nil
+}
+
+// Today is the current date in loc
+func Today(loc *time.Location) Date {
+	return DateOf(time.Now().In(loc))
+}
+
+// DateOf is the date of t in its own location
+func DateOf(t time.Time) Date {
+	return NewDate(t.Date())
+}
+
+// AddDays returns the date days later, or earlier when negative
+func (d Date) AddDays(days int) Date {
+	return Date{d.AddDate(0, 0, days)}
+}
+
+func (d Date) String() string {
+	return d.Format(DateLayout)
+}
+
+func (d Date) MarshalJSON() ([]byte, error) {
+	return []byte(`"` + d.String() + `"`), nil
+}
+
+func (d *Date) UnmarshalJSON(data []byte) error {
+	if bytes.Equal(data, []byte("null")) {
+		return nil
+	}
+
+	if len(data) < 2 || data[0] != '"' || data[len(data)-1] != '"' {
+		return fmt.Errorf("%s is not a date like %s", data, DateLayout)
+	}
+
+	date, err := ParseDate(string(data[1 : len(data)-1]))
+	if err != nil {
+		return err
+	}
+	*d = date
+
+	return nil
+}
+
+func (d Date) Value() (driver.Value, error) {
+	return d.String(), nil
+}
+
+func (d *Date) Scan(value interface{}) error {
+	switch v := value.(type) {
+	case time.Time:
+		*d = DateOf(v)
+		return nil
+	case []byte:
+		return d.scanString(string(v))
+	case string:
+		return d.scanString(v)
+	default:
+		return fmt.Errorf("can't scan %T into a date", value)
+	}
+}
+
+// scanString reads a date column without parseTime, mysql may add a time of day to it
+func (d *Date) scanString(value string) error {
+	if len(value) > len(DateLayout) {
+		value = value[:len(DateLayout)]
+	}
+
+	date, err := ParseDate(value)
+	if err != nil {
+		return err
+	}
+	*d = date
+
+	return nil
+}
+
+func (Date) GormDataType() string {
+	return "date"
+}
@@ -0,0 +1,29 @@
+package models
+
+import (
+	"strconv"
+	"time"
+)
+
+// VerificationDocument is evidence backing the verification of a user, e.g. an ID scan. The content is
+// encrypted in the blob store under BlobKey with a data key of its own, kept here wrapped by the master key
+// KeyId names, and both are deleted at ExpiresAt. Sha256 is the digest of the content before encryption
+type VerificationDocument struct {
+	DocumentId   int           `json:"document_id" db:"document_id" gorm:"primaryKey;autoIncrement:true"`
+	UserId       int           `json:"user_id" db:"user_id" gorm:"not null;index"`
+	ContentType  string        `json:"content_type" db:"content_type" gorm:"size:64;not null"`
+	Size         int64         `json:"size" db:"size" gorm:"not null"`
+	Sha256       string        `json:"sha256" db:"sha256" gorm:"size:64;not null"`
+	BlobKey      string        `json:"-" db:"blob_key" gorm:"size:255;not null;uniqueIndex"`
+	WrappedKey   []byte        `json:"-" db:"wrapped_key" gorm:"size:128;not null"`
+	KeyId        string        `json:"-" db:"key_id" gorm:"size:32;not null"`
+	UploadedBy   string        `json:"uploaded_by" db:"uploaded_by" gorm:"size:128;not null"`
+	ExpiresAt    time.Time     `json:"expires_at" db:"expires_at" gorm:"not null;index"`
+	CreatedAt    time.Time     `json:"created_at" db:"created_at"`
+	Verification *Verification `json:"-" gorm:"foreignKey:UserId;references:UserId;constraint:OnDelete:CASCADE"`
+}
+
+// EvidenceRef is how a verification transition refers to the document
+func (vd *VerificationDocument) EvidenceRef() string {
+	return "document:" + strconv.Itoa(vd.DocumentId)
+}
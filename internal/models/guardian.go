@@ -0,0 +1,54 @@
+package models
+
+import "time"
+
+// user statuses, a minor is restricted until a guardian consents for them
+const (
+	UserActive     = "active"
+	UserRestricted = "restricted"
+)
+
+// guardian link statuses, an invitation the guardian accepted is consented until they revoke it
+const (
+	GuardianInvited   = "invited"
+	GuardianConsented = "consented"
+	GuardianRevoked   = "revoked"
+)
+
+// GuardianLink links a minor to the guardian consenting for them. It starts as an invitation to an email,
+// accepted by the verified adult registered with it before ExpiresAt. Only the hash of the invitation token
+// is kept. Version is bumped by every change so an acceptance and a revocation can't race
+type GuardianLink struct {
+	LinkId        int        `json:"link_id" db:"link_id" gorm:"primaryKey;autoIncrement:true"`
+	MinorId       int        `json:"minor_id" db:"minor_id" gorm:"not null;index"`
+	GuardianEmail string     `json:"guardian_email" db:"guardian_email" gorm:"size:255;not null;index"`
+	GuardianId    *int       `json:"guardian_id" db:"guardian_id" gorm:"index"`
+	Status        string     `json:"status" db:"status" gorm:"size:16;not null"`
+	TokenHash     string     `json:"-" db:"token_hash" gorm:"size:64;not null;uniqueIndex"`
+	InvitedBy     string     `json:"invited_by" db:"invited_by" gorm:"size:128;not null"`
+	ExpiresAt     time.Time  `json:"expires_at" db:"expires_at"`
+	ConsentedAt   *time.Time `json:"consented_at" db:"consented_at"`
+	RevokedAt     *time.Time `json:"revoked_at" db:"revoked_at"`
+	CreatedAt     time.Time  `json:"created_at" db:"created_at"`
+	UpdatedAt     time.Time  `json:"updated_at" db:"updated_at"`
+	Version       int        `json:"version" db:"version" gorm:"not null;default:1"`
+	Minor         *User      `json:"-" gorm:"foreignKey:MinorId;constraint:OnDelete:CASCADE"`
+	Guardian      *User      `json:"-" gorm:"foreignKey:GuardianId;constraint:OnDelete:CASCADE"`
+}
+
+// GuardianInvitation is a new link along with its token, which is only ever returned here and has to reach
+// the guardian, e.g. by email
+type GuardianInvitation struct {
+	*GuardianLink
+	Token string `json:"token"`
+}
+
+// GuardianInvite invites a guardian to consent for a minor
+type GuardianInvite struct {
+	GuardianEmail string `json:"guardian_email" validate:"required,email,max=255"`
+}
+
+// GuardianAcceptance accepts an invitation with the token it came with
+type GuardianAcceptance struct {
+	Token string `json:"token" validate:"required,max=128"`
+}
@@ -1,6 +1,21 @@
 package models
 
+// Pagination selects a page of a listing, by page number counting from 0 or, for keyset pagination,
+// by the cursor of the row right before (After) or right after (Before) the page
 type Pagination struct {
-	Page int `query:"page" validate:"min=0"`
-	Size int `query:"size" validate:"min=10"`
+	Page   int    `query:"page" validate:"min=0"`
+	Size   int    `query:"size" validate:"min=10"`
+	After  string `query:"after"`
+	Before string `query:"before"`
+	// Total asks for the count of every row of the listing
+	Total bool `query:"total"`
+}
+
+// UserPage is a page of a user listing. Next and Prev are the cursors of the pages around it,
+// empty when there's none, and Total is only set when asked for
+type UserPage struct {
+	Items []*User `json:"items"`
+	Next  string  `json:"next,omitempty"`
+	Prev  string  `json:"prev,omitempty"`
+	Total *int64  `json:"total,omitempty"`
 }
@@ -0,0 +1,73 @@
+package models
+
+import "time"
+
+// review case statuses, a case is open until a reviewer claims it and decided once approved or rejected
+const (
+	ReviewOpen     = "open"
+	ReviewClaimed  = "claimed"
+	ReviewApproved = "approved"
+	ReviewRejected = "rejected"
+)
+
+// ReviewCase is a verification a human has to decide on, because automated checks were inconclusive or
+// the user disputes their outcome. It's due by DueAt, and a claim lasts until ClaimExpiresAt, after which
+// another reviewer may claim it. Version is bumped by every change so two reviewers can't race
+type ReviewCase struct {
+	CaseId         int        `json:"case_id" db:"case_id" gorm:"primaryKey;autoIncrement:true"`
+	UserId         int        `json:"user_id" db:"user_id" gorm:"not null;index"`
+	Status         string     `json:"status" db:"status" gorm:"size:16;not null;index"`
+	Reason         string     `json:"reason" db:"reason" gorm:"size:255;not null"`
+	OpenedBy       string     `json:"opened_by" db:"opened_by" gorm:"size:128;not null"`
+	Reviewer       string     `json:"reviewer" db:"reviewer" gorm:"size:128;index"`
+	ClaimedAt      *time.Time `json:"claimed_at" db:"claimed_at"`
+	ClaimExpiresAt *time.Time `json:"claim_expires_at" db:"claim_expires_at"`
+	DueAt          time.Time  `json:"due_at" db:"due_at" gorm:"not null;index"`
+	DecidedAt      *time.Time `json:"decided_at" db:"decided_at"`
+	DecisionReason string     `json:"decision_reason" db:"decision_reason" gorm:"size:255"`
+	Overdue        bool       `json:"overdue" gorm:"-" readonly:"true"`
+	CreatedAt      time.Time  `json:"created_at" db:"created_at"`
+	UpdatedAt      time.Time  `json:"updated_at" db:"updated_at"`
+	Version        int        `json:"version" db:"version" gorm:"not null;default:1"`
+	User           *User      `json:"-" gorm:"constraint:OnDelete:CASCADE"`
+}
+
+// ReviewNote is a note a reviewer left on a case, notes are never edited nor removed
+type ReviewNote struct {
+	NoteId    int         `json:"note_id" db:"note_id" gorm:"primaryKey;autoIncrement:true"`
+	CaseId    int         `json:"case_id" db:"case_id" gorm:"not null;index"`
+	Author    string      `json:"author" db:"author" gorm:"size:128;not null"`
+	Body      string      `json:"body" db:"body" gorm:"type:text;not null"`
+	CreatedAt time.Time   `json:"created_at" db:"created_at"`
+	Case      *ReviewCase `json:"-" gorm:"foreignKey:CaseId;constraint:OnDelete:CASCADE"`
+}
+
+// ReviewCaseDetail is a case along with its notes, oldest first
+type ReviewCaseDetail struct {
+	*ReviewCase
+	Notes []*ReviewNote `json:"notes"`
+}
+
+// Decided tells whether the case was approved or rejected already
+func (rc *ReviewCase) Decided() bool {
+	return rc.Status == ReviewApproved || rc.Status == ReviewRejected
+}
+
+// ReviewRequest opens a review case of the verification of a user
+type ReviewRequest struct {
+	UserId int    `json:"user_id" validate:"required,min=1"`
+	Reason string `json:"reason" validate:"required,max=255"`
+}
+
+// ReviewNoteRequest is the note a reviewer leaves on a case
+type ReviewNoteRequest struct {
+	Body string `json:"body" validate:"required,max=2000"`
+}
+
+// ReviewDecision approves or rejects the verification under review. An approval says how sure the
+// reviewer is
+type ReviewDecision struct {
+	Decision       string `json:"decision" validate:"required,oneof=approved rejected"`
+	AssuranceLevel string `json:"assurance_level" validate:"required_if=Decision approved,omitempty,oneof=low substantial high"`
+	Reason         string `json:"reason" validate:"required,max=255"`
+}
@@ -1,17 +1,42 @@
 package models
 
 import (
+	"encoding/json"
 	"golang.org/x/crypto/bcrypt"
 	"gorm.io/gorm"
+	"time"
 )
 
+// User is a registered user. Version is bumped by every update and backs the user ETag.
+// DeletedKey is 0 while the user is active and takes the user id once it's soft deleted,
+// so the email unique index only holds for active users. idx_users_search is the fulltext index user searches rank with.
+// Age isn't stored, it's computed from DateOfBirth in the user's timezone whenever the user is written as json.
+// Minors are restricted until a guardian consents, GuardianEmail is who they name when registering and
+// GuardianInvitation the invitation it got, neither is stored on the user.
 type User struct {
-	UserId   int    `json:"user_id" query:"user_id"  db:"user_id" gorm:"primaryKey;autoIncrement:true"`
-	Name     string `json:"name" query:"name"  db:"name"`
-	Age      int    `json:"age" query:"age"  db:"age"`
-	Email    string `json:"email" validate:"required" query:"email"  db:"email" gorm:"size:255;index:idx_email,unique"`
-	Password string `json:"password,omitempty" query:"password" db:"password"`
-	Address  string `json:"address" db:"address"`
+	UserId      int            `json:"user_id" query:"user_id"  db:"user_id" gorm:"primaryKey;autoIncrement:true"`
+	Name        string         `json:"name" query:"name"  db:"name" gorm:"index:idx_users_search,class:FULLTEXT"`
+	DateOfBirth *Date          `json:"date_of_birth" validate:"omitempty,date_of_birth=Timezone" db:"date_of_birth" swaggertype:"string" format:"date"`
+	Age         *int           `json:"age" gorm:"-" readonly:"true"`
+	Timezone    string         `json:"timezone" validate:"omitempty,timezone" db:"timezone" gorm:"size:64"`
+	Email       string         `json:"email" validate:"required" query:"email"  db:"email" gorm:"size:255;index:idx_email_deleted,unique;index:idx_users_search,class:FULLTEXT"`
+	Password    string         `json:"password,omitempty" query:"password" db:"password"`
+	Address     string         `json:"address" db:"address" gorm:"index:idx_users_search,class:FULLTEXT"`
+	Status      string         `json:"status" db:"status" gorm:"size:16;not null;default:active;index" readonly:"true"`
+	CreatedAt   time.Time      `json:"created_at" db:"created_at"`
+	UpdatedAt   time.Time      `json:"updated_at" db:"updated_at"`
+	Version     int            `json:"version" db:"version" gorm:"not null;default:1"`
+	DeletedAt   gorm.DeletedAt `json:"deleted_at" db:"deleted_at" gorm:"index" swaggertype:"string" format:"date-time"`
+	DeletedKey  int            `json:"-" db:"deleted_key" gorm:"not null;default:0;index:idx_email_deleted,unique"`
+
+	GuardianEmail      string              `json:"guardian_email,omitempty" validate:"omitempty,email,max=255" gorm:"-"`
+	GuardianInvitation *GuardianInvitation `json:"guardian_invitation,omitempty" gorm:"-" readonly:"true"`
+}
+
+// Credentials are what a user logs in with to get a token
+type Credentials struct {
+	Email    string `json:"email" validate:"required,email"`
+	Password string `json:"password" validate:"required"`
 }
 
 func Hash(password string) ([]byte, error) {
@@ -31,3 +56,36 @@ func (u *User) BeforeCreate(tx *gorm.DB) error {
 
 	return nil
 }
+
+// Location is the timezone of the user, UTC when it has none
+func (u *User) Location() *time.Location {
+	if u.Timezone == "" {
+		return time.UTC
+	}
+
+	loc, err := time.LoadLocation(u.Timezone)
+	if err != nil {
+		return time.UTC
+	}
+
+	return loc
+}
+
+// AgeAt is the age of the user at the instant now in its timezone, nil without a date of birth
+func (u *User) AgeAt(now time.Time) *int {
+	if u.DateOfBirth == nil {
+		return nil
+	}
+
+	age := AgeOn(*u.DateOfBirth, DateOf(now.In(u.Location())))
+	return &age
+}
+
+// MarshalJSON writes the user with its current age, whatever Age holds
+func (u User) MarshalJSON() ([]byte, error) {
+	type user User
+	out := user(u)
+	out.Age = u.AgeAt(time.Now())
+
+	return json.Marshal(out)
+}
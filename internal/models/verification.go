@@ -0,0 +1,105 @@
+package models
+
+import "time"
+
+// verification states, a user without a verification record is unverified
+const (
+	VerificationUnverified = "unverified"
+	VerificationPending    = "pending"
+	VerificationVerified   = "verified"
+	VerificationRejected   = "rejected"
+	VerificationExpired    = "expired"
+)
+
+// verification methods, how the age of the user was checked
+const (
+	MethodDocument   = "document"
+	MethodCreditCard = "credit_card"
+	MethodDatabase   = "database"
+	MethodEstimation = "estimation"
+	MethodManual     = "manual"
+)
+
+// assurance levels of a verification, as the eIDAS levels
+const (
+	AssuranceLow         = "low"
+	AssuranceSubstantial = "substantial"
+	AssuranceHigh        = "high"
+)
+
+// Verification is where the age verification of a user stands. Method and EvidenceRef come with the
+// pending request and the outcome, AssuranceLevel and VerifiedAt only with a verified outcome, which lasts
+// until ExpiresAt. DateOfBirth and Timezone are the ones of the user when verified, later changes to the user
+// don't change the verified age. Version is bumped by every transition so two of them can't race
+type Verification struct {
+	UserId         int        `json:"user_id" db:"user_id" gorm:"primaryKey;autoIncrement:false"`
+	State          string     `json:"state" db:"state" gorm:"size:16;not null;index"`
+	Method         string     `json:"method" db:"method" gorm:"size:32"`
+	AssuranceLevel string     `json:"assurance_level" db:"assurance_level" gorm:"size:16"`
+	EvidenceRef    string     `json:"evidence_ref" db:"evidence_ref" gorm:"size:255;index"`
+	RequestedAt    *time.Time `json:"requested_at" db:"requested_at"`
+	VerifiedAt     *time.Time `json:"verified_at" db:"verified_at"`
+	ExpiresAt      *time.Time `json:"expires_at" db:"expires_at"`
+	DateOfBirth    *Date      `json:"date_of_birth,omitempty" db:"date_of_birth" swaggertype:"string" format:"date"`
+	Timezone       string     `json:"timezone,omitempty" db:"timezone" gorm:"size:64"`
+	CreatedAt      time.Time  `json:"created_at" db:"created_at"`
+	UpdatedAt      time.Time  `json:"updated_at" db:"updated_at"`
+	Version        int        `json:"version" db:"version" gorm:"not null;default:1"`
+	User           *User      `json:"-" gorm:"constraint:OnDelete:CASCADE"`
+}
+
+// AgeAt is the verified age at the instant now in the verified timezone, nil when the verification holds no
+// date of birth
+func (v *Verification) AgeAt(now time.Time) *int {
+	return (&User{DateOfBirth: v.DateOfBirth, Timezone: v.Timezone}).AgeAt(now)
+}
+
+// VerificationEvent is a transition of the verification of a user, kept as its history. Actor is who
+// asked for it, system for the ones the service makes on its own like expiring
+type VerificationEvent struct {
+	EventId        int       `json:"event_id" db:"event_id" gorm:"primaryKey;autoIncrement:true"`
+	UserId         int       `json:"user_id" db:"user_id" gorm:"not null;index"`
+	From           string    `json:"from" db:"from_state" gorm:"column:from_state;size:16;not null"`
+	To             string    `json:"to" db:"to_state" gorm:"column:to_state;size:16;not null"`
+	Method         string    `json:"method" db:"method" gorm:"size:32"`
+	AssuranceLevel string    `json:"assurance_level" db:"assurance_level" gorm:"size:16"`
+	EvidenceRef    string    `json:"evidence_ref" db:"evidence_ref" gorm:"size:255"`
+	Reason         string    `json:"reason" db:"reason" gorm:"size:255"`
+	Actor          string    `json:"actor" db:"actor" gorm:"size:128;not null"`
+	CreatedAt      time.Time `json:"created_at" db:"created_at"`
+	User           *User     `json:"-" gorm:"constraint:OnDelete:CASCADE"`
+}
+
+// VerificationStatus is the verification of a user along with every transition it went through, oldest first
+type VerificationStatus struct {
+	*Verification
+	History []*VerificationEvent `json:"history"`
+}
+
+// VerificationTransition asks for the verification of a user to move to State. Which of the other
+// fields are required depends on the state
+type VerificationTransition struct {
+	State          string `json:"state" validate:"required,oneof=pending verified rejected expired"`
+	Method         string `json:"method" validate:"omitempty,oneof=document credit_card database estimation manual"`
+	AssuranceLevel string `json:"assurance_level" validate:"omitempty,oneof=low substantial high"`
+	EvidenceRef    string `json:"evidence_ref" validate:"max=255"`
+	Reason         string `json:"reason" validate:"max=255"`
+}
+
+// CheckRequest asks for a provider check of the age of a user, with the default provider when none is named
+type CheckRequest struct {
+	Provider string `json:"provider" validate:"max=32"`
+}
+
+// AttestationRequest asks for an attestation of whether a verified user is over each of the ages, for the
+// relying party named by Audience, if any
+type AttestationRequest struct {
+	AgeOver  []int  `json:"age_over" validate:"required,min=1,max=5,dive,min=1,max=150"`
+	Audience string `json:"audience" validate:"max=255"`
+}
+
+// Attestation is a signed token saying whether a user is over some ages without saying who they are
+type Attestation struct {
+	Token     string    `json:"token"`
+	ExpiresAt time.Time `json:"expires_at"`
+}
@@ -0,0 +1,351 @@
+package patch
+
+import (
+	"bytes"
+	"encoding/json"
+	"reflect"
+	"strconv"
+	"strings"
+
+	"github.com/joomcode/errorx"
+	errx "github.com/rhuandantas/verifymy-test/internal/errors"
+)
+
+const (
+	// MIMEApplicationMergePatchJSON is the media type of RFC 7396 JSON Merge Patch documents
+	MIMEApplicationMergePatchJSON = "application/merge-patch+json"
+	// MIMEApplicationJSONPatchJSON is the media type of RFC 6902 JSON Patch documents
+	MIMEApplicationJSONPatchJSON = "application/json-patch+json"
+)
+
+// operation is a single RFC 6902 operation, Value is kept raw so a null value, which decodes to the
+// literal null, can be told apart from a missing one
+type operation struct {
+	Op    string          `json:"op"`
+	Path  string          `json:"path"`
+	From  string          `json:"from"`
+	Value json.RawMessage `json:"value"`
+}
+
+// MergePatch applies an RFC 7396 JSON Merge Patch to doc
+func MergePatch(doc, patch []byte) ([]byte, error) {
+	target, err := decode(doc)
+	if err != nil {
+		return nil, errx.LocalizedWrap(errx.BadRequest, err, "patch.document_json", "document is not valid json")
+	}
+
+	mergePatch, err := decode(patch)
+	if err != nil {
+		return nil, errx.LocalizedWrap(errx.BadRequest, err, "patch.merge_json", "merge patch is not valid json")
+	}
+
+	return json.Marshal(merge(target, mergePatch))
+}
+
+// JSONPatch applies an RFC 6902 JSON Patch to doc, operations are applied in order and
+// nothing is applied when one of them fails
+func JSONPatch(doc, patch []byte) ([]byte, error) {
+	target, err := decode(doc)
+	if err != nil {
+		return nil, errx.LocalizedWrap(errx.BadRequest, err, "patch.document_json", "document is not valid json")
+	}
+
+	var operations []operation
+	if err = json.Unmarshal(patch, &operations); err != nil {
+		return nil, errx.LocalizedWrap(errx.BadRequest, err, "patch.operations", "json patch must be an array of operations")
+	}
+
+	for i, op := range operations {
+		if target, err = apply(target, op); err != nil {
+			if e := errorx.Cast(err); e != nil {
+				return nil, e.Type().New("operation %d (%s %s): %s", i, op.Op, op.Path, e.Message())
+			}
+			return nil, err
+		}
+	}
+
+	return json.Marshal(target)
+}
+
+func merge(target, patch interface{}) interface{} {
+	patchObject, ok := patch.(map[string]interface{})
+	if !ok {
+		return patch
+	}
+
+	targetObject, ok := target.(map[string]interface{})
+	if !ok {
+		targetObject = make(map[string]interface{})
+	}
+
+	for key, value := range patchObject {
+		if value == nil {
+			delete(targetObject, key)
+		} else {
+			targetObject[key] = merge(targetObject[key], value)
+		}
+	}
+
+	return targetObject
+}
+
+func apply(doc interface{}, op operation) (interface{}, error) {
+	path, err := parsePointer(op.Path)
+	if err != nil {
+		return nil, err
+	}
+
+	switch op.Op {
+	case "add", "replace", "test":
+		value, err := operationValue(op)
+		if err != nil {
+			return nil, err
+		}
+
+		if op.Op == "add" {
+			return add(doc, path, value)
+		}
+
+		current, err := get(doc, path)
+		if err != nil {
+			return nil, err
+		}
+
+		if op.Op == "test" {
+			if !reflect.DeepEqual(current, value) {
+				return nil, errx.Localized(errx.Conflict, "patch.test_failed", "test failed, %s has a different value", op.Path)
+			}
+			return doc, nil
+		}
+
+		if doc, _, err = remove(doc, path); err != nil {
+			return nil, err
+		}
+		return add(doc, path, value)
+	case "remove":
+		doc, _, err = remove(doc, path)
+		return doc, err
+	case "move", "copy":
+		from, err := parsePointer(op.From)
+		if err != nil {
+			return nil, err
+		}
+
+		if op.Op == "move" {
+			if strings.HasPrefix(op.Path+"/", op.From+"/") && op.Path != op.From {
+				return nil, errx.Localized(errx.BadRequest, "patch.move_into_child", "can't move %s into one of its children", op.From)
+			}
+
+			var value interface{}
+			if doc, value, err = remove(doc, from); err != nil {
+				return nil, err
+			}
+			return add(doc, path, value)
+		}
+
+		value, err := get(doc, from)
+		if err != nil {
+			return nil, err
+		}
+
+		// the copy must not share maps or slices with the source
+		if value, err = deepCopy(value); err != nil {
+			return nil, err
+		}
+		return add(doc, path, value)
+	default:
+		return nil, errx.Localized(errx.BadRequest, "patch.unknown_op", "unknown operation %q", op.Op)
+	}
+}
+
+func operationValue(op operation) (interface{}, error) {
+	if len(op.Value) == 0 {
+		return nil, errx.Localized(errx.BadRequest, "patch.value_required", "%s requires a value", op.Op)
+	}
+
+	return decode(op.Value)
+}
+
+// parsePointer splits an RFC 6901 JSON Pointer into its unescaped reference tokens
+func parsePointer(pointer string) ([]string, error) {
+	if pointer == "" {
+		return []string{}, nil
+	}
+
+	if !strings.HasPrefix(pointer, "/") {
+		return nil, errx.Localized(errx.BadRequest, "patch.path_slash", "path %q must start with /", pointer)
+	}
+
+	tokens := strings.Split(pointer[1:], "/")
+	for i, token := range tokens {
+		tokens[i] = strings.ReplaceAll(strings.ReplaceAll(token, "~1", "/"), "~0", "~")
+	}
+
+	return tokens, nil
+}
+
+func get(doc interface{}, path []string) (interface{}, error) {
+	for _, token := range path {
+		switch node := doc.(type) {
+		case map[string]interface{}:
+			child, ok := node[token]
+			if !ok {
+				return nil, errx.Localized(errx.ConstraintViolation, "patch.missing_member", "path member %q doesn't exist", token)
+			}
+			doc = child
+		case []interface{}:
+			i, err := arrayIndex(token, len(node)-1)
+			if err != nil {
+				return nil, err
+			}
+			doc = node[i]
+		default:
+			return nil, errx.Localized(errx.ConstraintViolation, "patch.missing_member", "path member %q doesn't exist", token)
+		}
+	}
+
+	return doc, nil
+}
+
+func add(doc interface{}, path []string, value interface{}) (interface{}, error) {
+	if len(path) == 0 {
+		return value, nil
+	}
+
+	token, last := path[0], len(path) == 1
+	switch node := doc.(type) {
+	case map[string]interface{}:
+		if last {
+			node[token] = value
+			return node, nil
+		}
+
+		child, ok := node[token]
+		if !ok {
+			return nil, errx.Localized(errx.ConstraintViolation, "patch.missing_member", "path member %q doesn't exist", token)
+		}
+
+		updated, err := add(child, path[1:], value)
+		if err != nil {
+			return nil, err
+		}
+		node[token] = updated
+
+		return node, nil
+	case []interface{}:
+		if last {
+			if token == "-" {
+				return append(node, value), nil
+			}
+
+			i, err := arrayIndex(token, len(node))
+			if err != nil {
+				return nil, err
+			}
+
+			node = append(node, nil)
+			copy(node[i+1:], node[i:])
+			node[i] = value
+
+			return node, nil
+		}
+
+		i, err := arrayIndex(token, len(node)-1)
+		if err != nil {
+			return nil, err
+		}
+
+		updated, err := add(node[i], path[1:], value)
+		if err != nil {
+			return nil, err
+		}
+		node[i] = updated
+
+		return node, nil
+	default:
+		return nil, errx.Localized(errx.ConstraintViolation, "patch.missing_member", "path member %q doesn't exist", token)
+	}
+}
+
+// remove takes out the value at path and returns the updated document along with the removed value
+func remove(doc interface{}, path []string) (interface{}, interface{}, error) {
+	if len(path) == 0 {
+		return nil, nil, errx.Localized(errx.BadRequest, "patch.remove_root", "the whole document can't be removed")
+	}
+
+	token, last := path[0], len(path) == 1
+	switch node := doc.(type) {
+	case map[string]interface{}:
+		child, ok := node[token]
+		if !ok {
+			return nil, nil, errx.Localized(errx.ConstraintViolation, "patch.missing_member", "path member %q doesn't exist", token)
+		}
+
+		if last {
+			delete(node, token)
+			return node, child, nil
+		}
+
+		updated, removed, err := remove(child, path[1:])
+		if err != nil {
+			return nil, nil, err
+		}
+		node[token] = updated
+
+		return node, removed, nil
+	case []interface{}:
+		i, err := arrayIndex(token, len(node)-1)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		if last {
+			removed := node[i]
+			return append(node[:i], node[i+1:]...), removed, nil
+		}
+
+		updated, removed, err := remove(node[i], path[1:])
+		if err != nil {
+			return nil, nil, err
+		}
+		node[i] = updated
+
+		return node, removed, nil
+	default:
+		return nil, nil, errx.Localized(errx.ConstraintViolation, "patch.missing_member", "path member %q doesn't exist", token)
+	}
+}
+
+func arrayIndex(token string, max int) (int, error) {
+	i, err := strconv.Atoi(token)
+	if err != nil || i < 0 || (len(token) > 1 && token[0] == '0') {
+		return 0, errx.Localized(errx.BadRequest, "patch.index_invalid", "%q is not a valid array index", token)
+	}
+
+	if i > max {
+		return 0, errx.Localized(errx.ConstraintViolation, "patch.index_bounds", "array index %d is out of bounds", i)
+	}
+
+	return i, nil
+}
+
+func deepCopy(value interface{}) (interface{}, error) {
+	raw, err := json.Marshal(value)
+	if err != nil {
+		return nil, err
+	}
+
+	return decode(raw)
+}
+
+// decode keeps numbers as json.Number so big integers don't lose precision on the way back
+func decode(raw []byte) (interface{}, error) {
+	var value interface{}
+	decoder := json.NewDecoder(bytes.NewReader(raw))
+	decoder.UseNumber()
+	if err := decoder.Decode(&value); err != nil {
+		return nil, err
+	}
+
+	return value, nil
+}
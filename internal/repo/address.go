@@ -0,0 +1,116 @@
+package repo
+
+import (
+	"context"
+	"errors"
+
+	errx "github.com/rhuandantas/verifymy-test/internal/errors"
+	"github.com/rhuandantas/verifymy-test/internal/log"
+	"github.com/rhuandantas/verifymy-test/internal/models"
+	"gorm.io/gorm"
+)
+
+//go:generate mockgen -source=$GOFILE -package=mock_repo -destination=../../test/mock/repo/$GOFILE
+
+type AddressRepo interface {
+	// List returns the addresses of the user by type
+	List(ctx context.Context, userId int) ([]*models.Address, error)
+	// ListByUsers returns the addresses of the users keyed by user id, users without any are left out
+	ListByUsers(ctx context.Context, userIds []int) (map[int][]*models.Address, error)
+	Get(ctx context.Context, userId, addressId int) (*models.Address, error)
+	// Create fails with errx.Conflict when the user already has an address of the type
+	Create(ctx context.Context, address models.Address) (*models.Address, error)
+	// Update replaces the address, which must belong to the user of address
+	Update(ctx context.Context, address models.Address) (*models.Address, error)
+	Delete(ctx context.Context, userId, addressId int) error
+}
+
+type AddressRepoImpl struct {
+	db     DBConnection
+	logger log.SimpleLogger
+}
+
+func NewAddressRepo(db DBConnection, logger log.SimpleLogger) AddressRepo {
+	return &AddressRepoImpl{
+		db:     db,
+		logger: logger,
+	}
+}
+
+func (ari *AddressRepoImpl) List(ctx context.Context, userId int) ([]*models.Address, error) {
+	addresses := make([]*models.Address, 0)
+	query := NewQuery().Where("user_id", Equal, userId).OrderBy("type", StringValue, false)
+	if result := ari.db.Find(ctx, &addresses, query, 0, 0); result.Error != nil {
+		return nil, translateError(result.Error)
+	}
+
+	return addresses, nil
+}
+
+func (ari *AddressRepoImpl) ListByUsers(ctx context.Context, userIds []int) (map[int][]*models.Address, error) {
+	byUser := make(map[int][]*models.Address)
+	if len(userIds) == 0 {
+		return byUser, nil
+	}
+
+	var addresses []*models.Address
+	query := NewQuery().Where("user_id", In, userIds).OrderBy("user_id", IntValue, false).OrderBy("type", StringValue, false)
+	if result := ari.db.Find(ctx, &addresses, query, 0, 0); result.Error != nil {
+		return nil, translateError(result.Error)
+	}
+
+	for _, address := range addresses {
+		byUser[address.UserId] = append(byUser[address.UserId], address)
+	}
+
+	return byUser, nil
+}
+
+func (ari *AddressRepoImpl) Get(ctx context.Context, userId, addressId int) (*models.Address, error) {
+	address := &models.Address{}
+	if result := ari.db.First(ctx, address, "address_id = ? AND user_id = ?", addressId, userId); result.Error != nil {
+		if errors.Is(result.Error, gorm.ErrRecordNotFound) {
+			return nil, errx.LocalizedWrap(errx.NotFound, result.Error, "addresses.not_found", "Address %d not found for user %d", addressId, userId)
+		}
+
+		return nil, translateError(result.Error)
+	}
+
+	return address, nil
+}
+
+func (ari *AddressRepoImpl) Create(ctx context.Context, address models.Address) (*models.Address, error) {
+	address.AddressId = 0
+	if result := ari.db.Insert(ctx, &address); result.Error != nil {
+		return nil, translateError(result.Error)
+	}
+
+	return &address, nil
+}
+
+func (ari *AddressRepoImpl) Update(ctx context.Context, address models.Address) (*models.Address, error) {
+	current, err := ari.Get(ctx, address.UserId, address.AddressId)
+	if err != nil {
+		return nil, err
+	}
+
+	address.CreatedAt = current.CreatedAt
+	if result := ari.db.Update(ctx, &address); result.Error != nil {
+		return nil, translateError(result.Error)
+	}
+
+	return &address, nil
+}
+
+func (ari *AddressRepoImpl) Delete(ctx context.Context, userId, addressId int) error {
+	result := ari.db.Delete(ctx, &models.Address{}, "address_id = ? AND user_id = ?", addressId, userId)
+	if result.Error != nil {
+		return translateError(result.Error)
+	}
+
+	if result.RowsAffected == 0 {
+		return errx.Localized(errx.NotFound, "addresses.not_found", "Address %d not found for user %d", addressId, userId)
+	}
+
+	return nil
+}
@@ -0,0 +1,74 @@
+package repo
+
+import (
+	"context"
+	"errors"
+
+	errx "github.com/rhuandantas/verifymy-test/internal/errors"
+	"github.com/rhuandantas/verifymy-test/internal/log"
+	"github.com/rhuandantas/verifymy-test/internal/models"
+	"gorm.io/gorm"
+)
+
+//go:generate mockgen -source=$GOFILE -package=mock_repo -destination=../../test/mock/repo/$GOFILE
+
+type ClientRepo interface {
+	// Create fails with errx.Conflict when the client id is taken
+	Create(ctx context.Context, client models.Client) (*models.Client, error)
+	// Get fails with errx.NotFound when there's no such client
+	Get(ctx context.Context, clientId string) (*models.Client, error)
+	// List returns every client by id
+	List(ctx context.Context) ([]*models.Client, error)
+	// Update stores the whole client
+	Update(ctx context.Context, client models.Client) (*models.Client, error)
+}
+
+type ClientRepoImpl struct {
+	db     DBConnection
+	logger log.SimpleLogger
+}
+
+func NewClientRepo(db DBConnection, logger log.SimpleLogger) ClientRepo {
+	return &ClientRepoImpl{
+		db:     db,
+		logger: logger,
+	}
+}
+
+func (cri *ClientRepoImpl) Create(ctx context.Context, client models.Client) (*models.Client, error) {
+	if result := cri.db.Insert(ctx, &client); result.Error != nil {
+		return nil, translateError(result.Error)
+	}
+
+	return &client, nil
+}
+
+func (cri *ClientRepoImpl) Get(ctx context.Context, clientId string) (*models.Client, error) {
+	client := &models.Client{}
+	if result := cri.db.First(ctx, client, "client_id = ?", clientId); result.Error != nil {
+		if errors.Is(result.Error, gorm.ErrRecordNotFound) {
+			return nil, errx.LocalizedWrap(errx.NotFound, result.Error, "clients.not_found", "Client %s not found", clientId)
+		}
+
+		return nil, translateError(result.Error)
+	}
+
+	return client, nil
+}
+
+func (cri *ClientRepoImpl) List(ctx context.Context) ([]*models.Client, error) {
+	clients := make([]*models.Client, 0)
+	if result := cri.db.Find(ctx, &clients, NewQuery().OrderBy("client_id", StringValue, false), 0, 0); result.Error != nil {
+		return nil, translateError(result.Error)
+	}
+
+	return clients, nil
+}
+
+func (cri *ClientRepoImpl) Update(ctx context.Context, client models.Client) (*models.Client, error) {
+	if result := cri.db.Update(ctx, &client); result.Error != nil {
+		return nil, translateError(result.Error)
+	}
+
+	return &client, nil
+}
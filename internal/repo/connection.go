@@ -15,10 +15,31 @@ import (
 type DBConnection interface {
 	GetDB() *gorm.DB
 	First(ctx context.Context, dest interface{}, conds ...interface{}) *gorm.DB
-	FindAll(ctx context.Context, offset, page int, query interface{}, dest interface{}, args ...interface{}) *gorm.DB
+	// FindAll reads into dest up to limit rows, skipping offset of them, selecting the query columns
+	FindAll(ctx context.Context, limit, offset int, query interface{}, dest interface{}, args ...interface{}) *gorm.DB
+	// Find reads into dest up to limit rows, skipping offset of them, matching and ordered by the query,
+	// only the given columns are read when there are any. A limit of 0 reads every row
+	Find(ctx context.Context, dest interface{}, query *Query, limit, offset int, columns ...string) *gorm.DB
+	// FindInBatches fills dest with batchSize rows matching the query at a time, calling fn after each batch.
+	// Batches always go in primary key order so the query ordering is ignored, only the given columns are
+	// read when there are any
+	FindInBatches(ctx context.Context, dest interface{}, query *Query, batchSize int, fn func() error, columns ...string) *gorm.DB
+	// Count counts the rows of model matching the query
+	Count(ctx context.Context, model interface{}, query *Query, count *int64) *gorm.DB
+	// Pluck reads a single column of the rows of model matching conds into dest
+	Pluck(ctx context.Context, model interface{}, column string, dest interface{}, conds ...interface{}) *gorm.DB
 	Insert(ctx context.Context, value interface{}) *gorm.DB
+	// InsertInBatches inserts a slice with one statement per batchSize rows
+	InsertInBatches(ctx context.Context, value interface{}, batchSize int) *gorm.DB
 	Update(ctx context.Context, value interface{}) *gorm.DB
+	Updates(ctx context.Context, model interface{}, values interface{}, conds ...interface{}) *gorm.DB
 	Delete(ctx context.Context, value interface{}, conds ...interface{}) *gorm.DB
+	// Unscoped returns a connection whose operations also reach soft deleted rows,
+	// Delete through it removes rows for good
+	Unscoped() DBConnection
+	// Transaction runs fn with a connection bound to a transaction, which is committed when fn
+	// returns nil and rolled back otherwise
+	Transaction(ctx context.Context, fn func(tx DBConnection) error) error
 }
 
 type MysqlORMConnection struct {
@@ -27,7 +48,56 @@ type MysqlORMConnection struct {
 }
 
 func (conn *MysqlORMConnection) Delete(ctx context.Context, value interface{}, conds ...interface{}) *gorm.DB {
-	return conn.GetDB().WithContext(ctx).Delete(value, conds)
+	return conn.GetDB().WithContext(ctx).Delete(value, conds...)
+}
+
+func (conn *MysqlORMConnection) Updates(ctx context.Context, model interface{}, values interface{}, conds ...interface{}) *gorm.DB {
+	db := conn.GetDB().WithContext(ctx).Model(model)
+	if len(conds) > 0 {
+		db = db.Where(conds[0], conds[1:]...)
+	}
+
+	return db.Updates(values)
+}
+
+func (conn *MysqlORMConnection) FindInBatches(ctx context.Context, dest interface{}, query *Query, batchSize int, fn func() error, columns ...string) *gorm.DB {
+	db := query.Filter(conn.GetDB().WithContext(ctx))
+	if len(columns) > 0 {
+		db = db.Select(columns)
+	}
+
+	return db.FindInBatches(dest, batchSize, func(_ *gorm.DB, _ int) error {
+		return fn()
+	})
+}
+
+func (conn *MysqlORMConnection) Count(ctx context.Context, model interface{}, query *Query, count *int64) *gorm.DB {
+	return query.Filter(conn.GetDB().WithContext(ctx).Model(model)).Count(count)
+}
+
+func (conn *MysqlORMConnection) Pluck(ctx context.Context, model interface{}, column string, dest interface{}, conds ...interface{}) *gorm.DB {
+	db := conn.GetDB().WithContext(ctx).Model(model)
+	if len(conds) > 0 {
+		db = db.Where(conds[0], conds[1:]...)
+	}
+
+	return db.Pluck(column, dest)
+}
+
+func (conn *MysqlORMConnection) Unscoped() DBConnection {
+	return &MysqlORMConnection{
+		db:     conn.GetDB().Unscoped(),
+		config: conn.config,
+	}
+}
+
+func (conn *MysqlORMConnection) Transaction(ctx context.Context, fn func(tx DBConnection) error) error {
+	return conn.GetDB().WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		return fn(&MysqlORMConnection{
+			db:     tx,
+			config: conn.config,
+		})
+	})
 }
 
 func (conn *MysqlORMConnection) Update(ctx context.Context, value interface{}) *gorm.DB {
@@ -38,30 +108,49 @@ func (conn *MysqlORMConnection) Insert(ctx context.Context, value interface{}) *
 	return conn.GetDB().WithContext(ctx).Create(value)
 }
 
-func (conn *MysqlORMConnection) FindAll(ctx context.Context, offset, page int, query interface{}, dest interface{}, args ...interface{}) *gorm.DB {
+func (conn *MysqlORMConnection) InsertInBatches(ctx context.Context, value interface{}, batchSize int) *gorm.DB {
+	return conn.GetDB().WithContext(ctx).CreateInBatches(value, batchSize)
+}
+
+func (conn *MysqlORMConnection) FindAll(ctx context.Context, limit, offset int, query interface{}, dest interface{}, args ...interface{}) *gorm.DB {
 	return conn.GetDB().
 		WithContext(ctx).
-		Limit(offset).
-		Offset(page).
+		Limit(limit).
+		Offset(offset).
 		Select(query, args...).Find(dest)
 }
 
+func (conn *MysqlORMConnection) Find(ctx context.Context, dest interface{}, query *Query, limit, offset int, columns ...string) *gorm.DB {
+	db := query.Apply(conn.GetDB().WithContext(ctx))
+	if len(columns) > 0 {
+		db = db.Select(columns)
+	}
+
+	return db.Limit(limit).Offset(offset).Find(dest)
+}
+
 func (conn *MysqlORMConnection) First(ctx context.Context, dest interface{}, conds ...interface{}) *gorm.DB {
-	return conn.GetDB().WithContext(ctx).First(dest, conds)
+	return conn.GetDB().WithContext(ctx).First(dest, conds...)
 }
 
 func (conn *MysqlORMConnection) GetDB() *gorm.DB {
 	return conn.db
 }
 
-func createConnection(config config.ConfigProvider) (db *sql.DB, err error) {
-	var user, password string
+// DataSourceName is the dsn of the mysql database of the config. DATETIME and DATE columns are read as
+// time.Time in UTC, without parseTime the driver hands them as []byte which can't be scanned into a time.Time
+func DataSourceName(config config.ConfigProvider) string {
 	host := config.GetString("db.mysql.host")
 	port := config.GetString("db.mysql.port")
 	database := config.GetString("db.mysql.database")
-	user = config.GetEnv(config.GetString("db.mysql.user-key"))
-	password = config.GetEnv(config.GetString("db.mysql.password-key"))
-	if db, err = sql.Open("mysql", fmt.Sprintf("%s:%s@tcp(%s:%s)/%s", user, password, host, port, database)); err != nil {
+	user := config.GetEnv(config.GetString("db.mysql.user-key"))
+	password := config.GetEnv(config.GetString("db.mysql.password-key"))
+
+	return fmt.Sprintf("%s:%s@tcp(%s:%s)/%s?parseTime=true&loc=UTC", user, password, host, port, database)
+}
+
+func createConnection(config config.ConfigProvider) (db *sql.DB, err error) {
+	if db, err = sql.Open("mysql", DataSourceName(config)); err != nil {
 		return nil, err
 	}
 
@@ -85,11 +174,26 @@ func NewMysqlORMConn(config config.ConfigProvider) (DBConnection, error) {
 		return nil, err
 	}
 
-	if err = gormDB.AutoMigrate(&models.User{}); err != nil {
+	if err = gormDB.AutoMigrate(&models.User{}, &models.Address{}, &models.Verification{}, &models.VerificationEvent{}, &models.ReviewCase{}, &models.ReviewNote{}, &models.GuardianLink{}, &models.VerificationDocument{}, &models.Client{}, &models.ClientUsage{}); err != nil {
+		return nil, err
+	}
+
+	if err = migrate(gormDB); err != nil {
 		return nil, err
 	}
 
 	return &MysqlORMConnection{
-		db: gormDB,
+		db:     gormDB,
+		config: config,
 	}, nil
 }
+
+// migrate applies the schema changes AutoMigrate can't do on its own
+func migrate(db *gorm.DB) error {
+	// idx_email was replaced by idx_email_deleted so soft deleted users don't hold on to their email
+	if db.Migrator().HasIndex(&models.User{}, "idx_email") {
+		return db.Migrator().DropIndex(&models.User{}, "idx_email")
+	}
+
+	return nil
+}
@@ -0,0 +1,127 @@
+package repo
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"strings"
+
+	errx "github.com/rhuandantas/verifymy-test/internal/errors"
+	"gorm.io/gorm/clause"
+)
+
+// cursor is the position of a row in a listing, the values of the columns the listing is sorted by.
+// It also holds the ordering it was made for since its keys mean nothing with another one. The key of a
+// nullable column is nil for NULL
+type cursor struct {
+	Order string    `json:"o"`
+	Keys  []*string `json:"k"`
+}
+
+// Cursor returns the opaque cursor of a row, key reads the value of a column of the row as
+// the query param of its kind would have it, empty for NULL in a nullable column
+func (q *Query) Cursor(key func(column string) string) string {
+	c := cursor{Order: q.orderKey(), Keys: make([]*string, 0, len(q.orders))}
+	for _, order := range q.orders {
+		value := key(order.Column.Name)
+		if order.nullable && value == "" {
+			c.Keys = append(c.Keys, nil)
+			continue
+		}
+		c.Keys = append(c.Keys, &value)
+	}
+
+	content, _ := json.Marshal(c)
+	return base64.RawURLEncoding.EncodeToString(content)
+}
+
+// Seek returns a copy of the query for the rows after the cursor, or before it when backward. A backward
+// query is sorted the other way round so its first rows are the ones right before the cursor
+func (q *Query) Seek(raw string, backward bool) (*Query, error) {
+	content, err := base64.RawURLEncoding.DecodeString(raw)
+	if err != nil {
+		return nil, errx.Localized(errx.BadRequest, "cursor.invalid", "cursor is not valid")
+	}
+
+	var c cursor
+	if err = json.Unmarshal(content, &c); err != nil {
+		return nil, errx.Localized(errx.BadRequest, "cursor.invalid", "cursor is not valid")
+	}
+
+	if c.Order != q.orderKey() || len(c.Keys) != len(q.orders) {
+		return nil, errx.Localized(errx.BadRequest, "cursor.other_sort", "cursor belongs to a listing with another sort")
+	}
+
+	// a nil value stands for NULL, Eq renders it as IS NULL
+	values := make([]interface{}, 0, len(c.Keys))
+	for i, order := range q.orders {
+		if c.Keys[i] == nil {
+			if !order.nullable {
+				return nil, errx.Localized(errx.BadRequest, "cursor.invalid", "cursor is not valid")
+			}
+			values = append(values, nil)
+			continue
+		}
+
+		value, fieldErr := parseValue(order.Column.Name, *c.Keys[i], order.kind)
+		if fieldErr != nil {
+			return nil, errx.Localized(errx.BadRequest, "cursor.invalid", "cursor is not valid")
+		}
+		values = append(values, value)
+	}
+
+	// rows past (a, b) are the ones with a past a, or with a at a and b past b. NULL comes before any
+	// value, so nothing is below it and every value is above it
+	alternatives := make([]clause.Expression, 0, len(q.orders))
+	for i, order := range q.orders {
+		conditions := make([]clause.Expression, 0, i+1)
+		for j := 0; j < i; j++ {
+			conditions = append(conditions, clause.Eq{Column: q.orders[j].Column, Value: values[j]})
+		}
+
+		switch {
+		case order.Desc != backward && values[i] == nil:
+			continue
+		case order.Desc != backward && order.nullable:
+			conditions = append(conditions, clause.Or(
+				clause.Lt{Column: order.Column, Value: values[i]},
+				clause.Eq{Column: order.Column, Value: nil},
+			))
+		case order.Desc != backward:
+			conditions = append(conditions, clause.Lt{Column: order.Column, Value: values[i]})
+		case values[i] == nil:
+			conditions = append(conditions, clause.Neq{Column: order.Column, Value: nil})
+		default:
+			conditions = append(conditions, clause.Gt{Column: order.Column, Value: values[i]})
+		}
+		alternatives = append(alternatives, clause.And(conditions...))
+	}
+
+	seek := q.clone()
+	if len(alternatives) == 0 {
+		// the cursor stands on the last NULL, there's nothing past it
+		seek.conditions = append(seek.conditions, clause.Expr{SQL: "1 = 0"})
+	} else {
+		seek.conditions = append(seek.conditions, clause.Or(alternatives...))
+	}
+	if backward {
+		for i := range seek.orders {
+			seek.orders[i].Desc = !seek.orders[i].Desc
+		}
+	}
+
+	return seek, nil
+}
+
+// orderKey names the ordering of the query, like the sort param would
+func (q *Query) orderKey() string {
+	columns := make([]string, 0, len(q.orders))
+	for _, order := range q.orders {
+		column := order.Column.Name
+		if order.Desc {
+			column = "-" + column
+		}
+		columns = append(columns, column)
+	}
+
+	return strings.Join(columns, ",")
+}
@@ -0,0 +1,86 @@
+package repo
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	errx "github.com/rhuandantas/verifymy-test/internal/errors"
+	"github.com/rhuandantas/verifymy-test/internal/log"
+	"github.com/rhuandantas/verifymy-test/internal/models"
+	"gorm.io/gorm"
+)
+
+//go:generate mockgen -source=$GOFILE -package=mock_repo -destination=../../test/mock/repo/$GOFILE
+
+type DocumentRepo interface {
+	Create(ctx context.Context, document models.VerificationDocument) (*models.VerificationDocument, error)
+	// Get fails with errx.NotFound when the user has no such document
+	Get(ctx context.Context, userId, documentId int) (*models.VerificationDocument, error)
+	// List returns the documents of the user, oldest first
+	List(ctx context.Context, userId int) ([]*models.VerificationDocument, error)
+	// Expired returns up to limit documents that expired by now, the ones expired longest first
+	Expired(ctx context.Context, now time.Time, limit int) ([]*models.VerificationDocument, error)
+	Delete(ctx context.Context, documentId int) error
+}
+
+type DocumentRepoImpl struct {
+	db     DBConnection
+	logger log.SimpleLogger
+}
+
+func NewDocumentRepo(db DBConnection, logger log.SimpleLogger) DocumentRepo {
+	return &DocumentRepoImpl{
+		db:     db,
+		logger: logger,
+	}
+}
+
+func (dri *DocumentRepoImpl) Create(ctx context.Context, document models.VerificationDocument) (*models.VerificationDocument, error) {
+	if result := dri.db.Insert(ctx, &document); result.Error != nil {
+		return nil, translateError(result.Error)
+	}
+
+	return &document, nil
+}
+
+func (dri *DocumentRepoImpl) Get(ctx context.Context, userId, documentId int) (*models.VerificationDocument, error) {
+	document := &models.VerificationDocument{}
+	if result := dri.db.First(ctx, document, "document_id = ? AND user_id = ?", documentId, userId); result.Error != nil {
+		if errors.Is(result.Error, gorm.ErrRecordNotFound) {
+			return nil, errx.LocalizedWrap(errx.NotFound, result.Error, "documents.not_found", "Document %d not found for user %d", documentId, userId)
+		}
+
+		return nil, translateError(result.Error)
+	}
+
+	return document, nil
+}
+
+func (dri *DocumentRepoImpl) List(ctx context.Context, userId int) ([]*models.VerificationDocument, error) {
+	documents := make([]*models.VerificationDocument, 0)
+	query := NewQuery().Where("user_id", Equal, userId).OrderBy("document_id", IntValue, false)
+	if result := dri.db.Find(ctx, &documents, query, 0, 0); result.Error != nil {
+		return nil, translateError(result.Error)
+	}
+
+	return documents, nil
+}
+
+func (dri *DocumentRepoImpl) Expired(ctx context.Context, now time.Time, limit int) ([]*models.VerificationDocument, error) {
+	documents := make([]*models.VerificationDocument, 0)
+	query := NewQuery().Where("expires_at", LessOrEqual, now).OrderBy("expires_at", TimeValue, false)
+	if result := dri.db.Find(ctx, &documents, query, limit, 0); result.Error != nil {
+		return nil, translateError(result.Error)
+	}
+
+	return documents, nil
+}
+
+func (dri *DocumentRepoImpl) Delete(ctx context.Context, documentId int) error {
+	if result := dri.db.Delete(ctx, &models.VerificationDocument{}, "document_id = ?", documentId); result.Error != nil {
+		return translateError(result.Error)
+	}
+
+	return nil
+}
@@ -0,0 +1,57 @@
+package repo
+
+import (
+	"errors"
+	"strings"
+
+	"github.com/go-sql-driver/mysql"
+	errx "github.com/rhuandantas/verifymy-test/internal/errors"
+	"gorm.io/gorm"
+)
+
+// mysql server error codes, see https://dev.mysql.com/doc/mysql-errors/5.7/en/server-error-reference.html
+const (
+	mysqlDuplicateEntry       = 1062
+	mysqlColumnCannotBeNull   = 1048
+	mysqlDataTooLong          = 1406
+	mysqlRowIsReferenced      = 1451
+	mysqlNoReferencedRow      = 1452
+	mysqlCheckConstraintFails = 3819
+)
+
+// uniqueIndexDetails maps unique index names to the detail returned on duplicate entries, its catalog key and message
+var uniqueIndexDetails = map[string]struct{ key, message string }{
+	"idx_email_deleted":     {"users.email_taken", "email is already registered"},
+	"idx_address_user_type": {"addresses.type_taken", "user already has an address of this type"},
+}
+
+// translateError turns gorm and mysql driver errors into the typed errors from internal/errors,
+// anything it doesn't know about is returned untouched
+func translateError(err error) error {
+	if err == nil {
+		return nil
+	}
+
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return errx.LocalizedWrap(errx.NotFound, err, "records.not_found", "record not found")
+	}
+
+	var mysqlErr *mysql.MySQLError
+	if !errors.As(err, &mysqlErr) {
+		return err
+	}
+
+	switch mysqlErr.Number {
+	case mysqlDuplicateEntry:
+		for index, detail := range uniqueIndexDetails {
+			if strings.Contains(mysqlErr.Message, index) {
+				return errx.LocalizedWrap(errx.Conflict, err, detail.key, detail.message)
+			}
+		}
+		return errx.LocalizedWrap(errx.Conflict, err, "records.exists", "record already exists")
+	case mysqlColumnCannotBeNull, mysqlDataTooLong, mysqlRowIsReferenced, mysqlNoReferencedRow, mysqlCheckConstraintFails:
+		return errx.ConstraintViolation.Wrap(err, mysqlErr.Message)
+	default:
+		return err
+	}
+}
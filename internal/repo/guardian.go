@@ -0,0 +1,127 @@
+package repo
+
+import (
+	"context"
+	"errors"
+
+	errx "github.com/rhuandantas/verifymy-test/internal/errors"
+	"github.com/rhuandantas/verifymy-test/internal/log"
+	"github.com/rhuandantas/verifymy-test/internal/models"
+	"gorm.io/gorm"
+)
+
+//go:generate mockgen -source=$GOFILE -package=mock_repo -destination=../../test/mock/repo/$GOFILE
+
+type GuardianRepo interface {
+	Create(ctx context.Context, link models.GuardianLink) (*models.GuardianLink, error)
+	// Get fails with errx.NotFound when the minor has no such link
+	Get(ctx context.Context, minorId, linkId int) (*models.GuardianLink, error)
+	// GetByToken returns the link invited with the token of the hash, failing with errx.NotFound when none was
+	GetByToken(ctx context.Context, tokenHash string) (*models.GuardianLink, error)
+	// List returns the links of the minor, oldest first
+	List(ctx context.Context, minorId int) ([]*models.GuardianLink, error)
+	// ListConsentedBy returns the links the guardian currently consents through
+	ListConsentedBy(ctx context.Context, guardianId int) ([]*models.GuardianLink, error)
+	// CountConsented counts the guardians currently consenting for the minor
+	CountConsented(ctx context.Context, minorId int) (int64, error)
+	// Update stores the link, failing with errx.Conflict when its version changed since it was read
+	Update(ctx context.Context, link models.GuardianLink) (*models.GuardianLink, error)
+}
+
+type GuardianRepoImpl struct {
+	db     DBConnection
+	logger log.SimpleLogger
+}
+
+func NewGuardianRepo(db DBConnection, logger log.SimpleLogger) GuardianRepo {
+	return &GuardianRepoImpl{
+		db:     db,
+		logger: logger,
+	}
+}
+
+func (gri *GuardianRepoImpl) Create(ctx context.Context, link models.GuardianLink) (*models.GuardianLink, error) {
+	link.Version = 1
+	if result := gri.db.Insert(ctx, &link); result.Error != nil {
+		return nil, translateError(result.Error)
+	}
+
+	return &link, nil
+}
+
+func (gri *GuardianRepoImpl) Get(ctx context.Context, minorId, linkId int) (*models.GuardianLink, error) {
+	link := &models.GuardianLink{}
+	if result := gri.db.First(ctx, link, "link_id = ? AND minor_id = ?", linkId, minorId); result.Error != nil {
+		if errors.Is(result.Error, gorm.ErrRecordNotFound) {
+			return nil, errx.LocalizedWrap(errx.NotFound, result.Error, "guardians.link_not_found", "Guardian link %d not found for user %d", linkId, minorId)
+		}
+
+		return nil, translateError(result.Error)
+	}
+
+	return link, nil
+}
+
+func (gri *GuardianRepoImpl) GetByToken(ctx context.Context, tokenHash string) (*models.GuardianLink, error) {
+	link := &models.GuardianLink{}
+	if result := gri.db.First(ctx, link, "token_hash = ?", tokenHash); result.Error != nil {
+		if errors.Is(result.Error, gorm.ErrRecordNotFound) {
+			return nil, errx.LocalizedWrap(errx.NotFound, result.Error, "guardians.invitation_not_found", "Guardian invitation not found")
+		}
+
+		return nil, translateError(result.Error)
+	}
+
+	return link, nil
+}
+
+func (gri *GuardianRepoImpl) List(ctx context.Context, minorId int) ([]*models.GuardianLink, error) {
+	links := make([]*models.GuardianLink, 0)
+	query := NewQuery().Where("minor_id", Equal, minorId).OrderBy("link_id", IntValue, false)
+	if result := gri.db.Find(ctx, &links, query, 0, 0); result.Error != nil {
+		return nil, translateError(result.Error)
+	}
+
+	return links, nil
+}
+
+func (gri *GuardianRepoImpl) ListConsentedBy(ctx context.Context, guardianId int) ([]*models.GuardianLink, error) {
+	links := make([]*models.GuardianLink, 0)
+	query := NewQuery().Where("guardian_id", Equal, guardianId).Where("status", Equal, models.GuardianConsented).OrderBy("link_id", IntValue, false)
+	if result := gri.db.Find(ctx, &links, query, 0, 0); result.Error != nil {
+		return nil, translateError(result.Error)
+	}
+
+	return links, nil
+}
+
+func (gri *GuardianRepoImpl) CountConsented(ctx context.Context, minorId int) (int64, error) {
+	query := NewQuery().Where("minor_id", Equal, minorId).Where("status", Equal, models.GuardianConsented)
+	var count int64
+	if result := gri.db.Count(ctx, &models.GuardianLink{}, query, &count); result.Error != nil {
+		return 0, translateError(result.Error)
+	}
+
+	return count, nil
+}
+
+func (gri *GuardianRepoImpl) Update(ctx context.Context, link models.GuardianLink) (*models.GuardianLink, error) {
+	result := gri.db.Updates(ctx, &models.GuardianLink{}, map[string]interface{}{
+		"status":       link.Status,
+		"guardian_id":  link.GuardianId,
+		"consented_at": link.ConsentedAt,
+		"revoked_at":   link.RevokedAt,
+		"version":      gorm.Expr("version + 1"),
+	}, "link_id = ? AND version = ?", link.LinkId, link.Version)
+	if result.Error != nil {
+		return nil, translateError(result.Error)
+	}
+
+	// the version check happens in the update itself, nothing updated means the link changed meanwhile
+	if result.RowsAffected == 0 {
+		return nil, errx.Localized(errx.Conflict, "guardians.changed", "guardian link %d changed meanwhile, read it again", link.LinkId)
+	}
+	link.Version++
+
+	return &link, nil
+}
@@ -0,0 +1,400 @@
+package repo
+
+import (
+	"fmt"
+	"net/url"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	errx "github.com/rhuandantas/verifymy-test/internal/errors"
+	"github.com/rhuandantas/verifymy-test/internal/models"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// Operator compares a column with the value of a filter
+type Operator int
+
+const (
+	Equal Operator = iota
+	GreaterOrEqual
+	LessOrEqual
+	// Prefix matches values starting with the filter value, LIKE wildcards in it are escaped
+	Prefix
+	// In matches any of the values of a slice
+	In
+)
+
+// ValueKind tells how the value of a filter param is parsed
+type ValueKind int
+
+const (
+	StringValue ValueKind = iota
+	IntValue
+	// TimeValue is parsed as RFC 3339
+	TimeValue
+	// DateValue is parsed as a models.Date like 2006-01-02
+	DateValue
+)
+
+const (
+	// SortParam is the query param listing the sort fields, a field prefixed with - sorts descending
+	SortParam = "sort"
+	// FieldsParam is the query param listing the fields to read, every field is read without it
+	FieldsParam = "fields"
+)
+
+// Query holds the conditions and ordering of a listing, built by a QueryBuilder or by hand
+type Query struct {
+	conditions []clause.Expression
+	orders     []order
+	columns    []string
+}
+
+// order is a column the query sorts by, kind tells how its values are read back from a cursor and nullable
+// whether the column may be NULL, which MySQL sorts before any value
+type order struct {
+	clause.OrderByColumn
+	kind     ValueKind
+	nullable bool
+}
+
+func NewQuery() *Query {
+	return &Query{}
+}
+
+// clone copies the query so adding to the copy leaves q as it is, a nil q gives an empty query
+func (q *Query) clone() *Query {
+	if q == nil {
+		return NewQuery()
+	}
+
+	return &Query{
+		conditions: append([]clause.Expression(nil), q.conditions...),
+		orders:     append([]order(nil), q.orders...),
+		columns:    append([]string(nil), q.columns...),
+	}
+}
+
+func (q *Query) Where(column string, operator Operator, value interface{}) *Query {
+	col := clause.Column{Name: column}
+	switch operator {
+	case GreaterOrEqual:
+		q.conditions = append(q.conditions, clause.Gte{Column: col, Value: value})
+	case LessOrEqual:
+		q.conditions = append(q.conditions, clause.Lte{Column: col, Value: value})
+	case Prefix:
+		q.conditions = append(q.conditions, clause.Like{Column: col, Value: EscapeLike(fmt.Sprint(value)) + "%"})
+	case In:
+		slice := reflect.ValueOf(value)
+		values := make([]interface{}, 0, slice.Len())
+		for i := 0; i < slice.Len(); i++ {
+			values = append(values, slice.Index(i).Interface())
+		}
+		q.conditions = append(q.conditions, clause.IN{Column: col, Values: values})
+	default:
+		q.conditions = append(q.conditions, clause.Eq{Column: col, Value: value})
+	}
+
+	return q
+}
+
+func (q *Query) OrderBy(column string, kind ValueKind, desc bool) *Query {
+	q.orders = append(q.orders, order{OrderByColumn: clause.OrderByColumn{Column: clause.Column{Name: column}, Desc: desc}, kind: kind})
+	return q
+}
+
+// OrderByNullable sorts by a column that may be NULL, so that cursors can stand on its NULL rows too
+func (q *Query) OrderByNullable(column string, kind ValueKind, desc bool) *Query {
+	q.orders = append(q.orders, order{OrderByColumn: clause.OrderByColumn{Column: clause.Column{Name: column}, Desc: desc}, kind: kind, nullable: true})
+	return q
+}
+
+// Select restricts the columns the query reads
+func (q *Query) Select(columns ...string) *Query {
+	q.columns = append(q.columns, columns...)
+	return q
+}
+
+// Selected returns the columns the query is restricted to, none means every column
+func (q *Query) Selected() []string {
+	if q == nil {
+		return nil
+	}
+
+	return q.columns
+}
+
+// Ordered tells whether the query sorts by column
+func (q *Query) Ordered(column string) bool {
+	for _, order := range q.orders {
+		if order.Column.Name == column {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Filter applies only the conditions of the query
+func (q *Query) Filter(db *gorm.DB) *gorm.DB {
+	if q == nil || len(q.conditions) == 0 {
+		return db
+	}
+
+	return db.Where(clause.And(q.conditions...))
+}
+
+// Apply applies the conditions and the ordering of the query
+func (q *Query) Apply(db *gorm.DB) *gorm.DB {
+	db = q.Filter(db)
+	if q == nil {
+		return db
+	}
+
+	for _, order := range q.orders {
+		db = db.Order(order.OrderByColumn)
+	}
+
+	return db
+}
+
+type filter struct {
+	column   string
+	operator Operator
+	kind     ValueKind
+	// convert turns the parsed value into the one the column is compared with, nil keeps it
+	convert func(value interface{}) interface{}
+}
+
+// QueryBuilder turns query params into a Query, only the declared filters and sort fields are accepted,
+// params it doesn't know about are left to the caller
+type QueryBuilder struct {
+	filters    map[string]filter
+	sortable   map[string]sortColumn
+	selectable map[string][]string
+	nullable   map[string]bool
+}
+
+type sortColumn struct {
+	column string
+	kind   ValueKind
+	// reverse sorts by the column the other way round
+	reverse bool
+}
+
+func NewQueryBuilder() *QueryBuilder {
+	return &QueryBuilder{
+		filters:    make(map[string]filter),
+		sortable:   make(map[string]sortColumn),
+		selectable: make(map[string][]string),
+		nullable:   make(map[string]bool),
+	}
+}
+
+// Filter declares the param filtering column with the operator
+func (qb *QueryBuilder) Filter(param, column string, operator Operator, kind ValueKind) *QueryBuilder {
+	qb.filters[param] = filter{column: column, operator: operator, kind: kind}
+	return qb
+}
+
+// FilterBy declares the param filtering column with the operator, comparing the column with the value
+// convert turns the param value into, e.g. an age into a date of birth
+func (qb *QueryBuilder) FilterBy(param, column string, operator Operator, kind ValueKind, convert func(value interface{}) interface{}) *QueryBuilder {
+	qb.filters[param] = filter{column: column, operator: operator, kind: kind, convert: convert}
+	return qb
+}
+
+// Sortable declares a field the sort param accepts, the column it sorts by and the kind of its values
+func (qb *QueryBuilder) Sortable(field, column string, kind ValueKind) *QueryBuilder {
+	qb.sortable[field] = sortColumn{column: column, kind: kind}
+	return qb
+}
+
+// SortableReversed declares a field sorted by a column going the other way round, e.g. age by date of birth
+func (qb *QueryBuilder) SortableReversed(field, column string, kind ValueKind) *QueryBuilder {
+	qb.sortable[field] = sortColumn{column: column, kind: kind, reverse: true}
+	return qb
+}
+
+// Nullable declares a column of the sortable fields that may be NULL
+func (qb *QueryBuilder) Nullable(column string) *QueryBuilder {
+	qb.nullable[column] = true
+	return qb
+}
+
+// Selectable declares a field the fields param accepts and the columns it's read from, more than one
+// for a field computed out of others
+func (qb *QueryBuilder) Selectable(field string, columns ...string) *QueryBuilder {
+	qb.selectable[field] = columns
+	return qb
+}
+
+// Build parses the declared params, every invalid one is reported as an errx.FieldError of a single
+// errx.Validation error
+func (qb *QueryBuilder) Build(params url.Values) (*Query, error) {
+	query := NewQuery()
+	fields := make([]errx.FieldError, 0)
+
+	names := make([]string, 0, len(qb.filters))
+	for name := range qb.filters {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		raw := params.Get(name)
+		if raw == "" {
+			continue
+		}
+
+		f := qb.filters[name]
+		value, fieldErr := parseValue(name, raw, f.kind)
+		if fieldErr != nil {
+			fields = append(fields, *fieldErr)
+			continue
+		}
+		if f.convert != nil {
+			value = f.convert(value)
+		}
+		query.Where(f.column, f.operator, value)
+	}
+
+	if raw := params.Get(SortParam); raw != "" {
+		for _, field := range strings.Split(raw, ",") {
+			field = strings.TrimSpace(field)
+			desc := strings.HasPrefix(field, "-")
+			sortable, ok := qb.sortable[strings.TrimPrefix(field, "-")]
+			if !ok {
+				fields = append(fields, errx.FieldError{
+					Field:   SortParam,
+					Rule:    "oneof",
+					Param:   qb.sortFields(),
+					Message: fmt.Sprintf("%s must be one of [%s]", SortParam, qb.sortFields()),
+				})
+				break
+			}
+
+			if query.Ordered(sortable.column) {
+				continue
+			}
+			if qb.nullable[sortable.column] {
+				query.OrderByNullable(sortable.column, sortable.kind, desc != sortable.reverse)
+			} else {
+				query.OrderBy(sortable.column, sortable.kind, desc != sortable.reverse)
+			}
+		}
+	}
+
+	if fieldErr := qb.project(query, params); fieldErr != nil {
+		fields = append(fields, *fieldErr)
+	}
+
+	if len(fields) > 0 {
+		return nil, errx.Localized(errx.Validation, "query.invalid", "query validation failed").WithProperty(errx.FieldErrorsProperty, fields)
+	}
+
+	return query, nil
+}
+
+// Projection parses only the fields param, for reads that neither filter nor sort
+func (qb *QueryBuilder) Projection(params url.Values) (*Query, error) {
+	query := NewQuery()
+	if fieldErr := qb.project(query, params); fieldErr != nil {
+		return nil, errx.Localized(errx.Validation, "query.invalid", "query validation failed").WithProperty(errx.FieldErrorsProperty, []errx.FieldError{*fieldErr})
+	}
+
+	return query, nil
+}
+
+// project restricts the query to the columns of the fields param
+func (qb *QueryBuilder) project(query *Query, params url.Values) *errx.FieldError {
+	raw := params.Get(FieldsParam)
+	if raw == "" {
+		return nil
+	}
+
+	for _, field := range strings.Split(raw, ",") {
+		columns, ok := qb.selectable[strings.TrimSpace(field)]
+		if !ok {
+			names := sortedKeys(qb.selectable)
+			return &errx.FieldError{
+				Field:   FieldsParam,
+				Rule:    "oneof",
+				Param:   names,
+				Message: fmt.Sprintf("%s must be one of [%s]", FieldsParam, names),
+			}
+		}
+
+		for _, column := range columns {
+			if !contains(query.columns, column) {
+				query.Select(column)
+			}
+		}
+	}
+
+	return nil
+}
+
+func (qb *QueryBuilder) sortFields() string {
+	fields := make([]string, 0, len(qb.sortable))
+	for field := range qb.sortable {
+		fields = append(fields, field)
+	}
+	sort.Strings(fields)
+
+	return strings.Join(fields, " ")
+}
+
+func sortedKeys(values map[string][]string) string {
+	keys := make([]string, 0, len(values))
+	for key := range values {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	return strings.Join(keys, " ")
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+
+	return false
+}
+
+func parseValue(param, raw string, kind ValueKind) (interface{}, *errx.FieldError) {
+	switch kind {
+	case IntValue:
+		value, err := strconv.Atoi(raw)
+		if err != nil {
+			return nil, &errx.FieldError{Field: param, Rule: "number", Message: fmt.Sprintf("%s must be a number", param)}
+		}
+		return value, nil
+	case TimeValue:
+		value, err := time.Parse(time.RFC3339, raw)
+		if err != nil {
+			return nil, &errx.FieldError{Field: param, Rule: "datetime", Param: time.RFC3339, Message: fmt.Sprintf("%s must be an RFC 3339 date time", param)}
+		}
+		return value, nil
+	case DateValue:
+		value, err := models.ParseDate(raw)
+		if err != nil {
+			return nil, &errx.FieldError{Field: param, Rule: "datetime", Param: models.DateLayout, Message: fmt.Sprintf("%s must be a date like %s", param, models.DateLayout)}
+		}
+		return value, nil
+	default:
+		return raw, nil
+	}
+}
+
+// EscapeLike escapes the LIKE wildcards so they match literally, backslash is the mysql default escape character
+func EscapeLike(value string) string {
+	return strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`).Replace(value)
+}
@@ -0,0 +1,143 @@
+package repo
+
+import (
+	"context"
+	"errors"
+
+	errx "github.com/rhuandantas/verifymy-test/internal/errors"
+	"github.com/rhuandantas/verifymy-test/internal/log"
+	"github.com/rhuandantas/verifymy-test/internal/models"
+	"gorm.io/gorm"
+)
+
+//go:generate mockgen -source=$GOFILE -package=mock_repo -destination=../../test/mock/repo/$GOFILE
+
+// ReviewQuery builds the queries of review case listings out of their query params, the queue is
+// ordered by due date unless sorted otherwise
+var ReviewQuery = NewQueryBuilder().
+	Filter("status", "status", Equal, StringValue).
+	Filter("reviewer", "reviewer", Equal, StringValue).
+	Filter("user_id", "user_id", Equal, IntValue).
+	Filter("due_before", "due_at", LessOrEqual, TimeValue).
+	Sortable("case_id", "case_id", IntValue).
+	Sortable("due_at", "due_at", TimeValue).
+	Sortable("created_at", "created_at", TimeValue)
+
+type ReviewRepo interface {
+	Create(ctx context.Context, reviewCase models.ReviewCase) (*models.ReviewCase, error)
+	// Get fails with errx.NotFound when there is no such case
+	Get(ctx context.Context, caseId int) (*models.ReviewCase, error)
+	// GetUndecided returns the case of the user still open or claimed, failing with errx.NotFound when none is
+	GetUndecided(ctx context.Context, userId int) (*models.ReviewCase, error)
+	List(ctx context.Context, query *Query, limit int) ([]*models.ReviewCase, error)
+	// Update stores the case, failing with errx.Conflict when its version changed since it was read
+	Update(ctx context.Context, reviewCase models.ReviewCase) (*models.ReviewCase, error)
+	AddNote(ctx context.Context, note models.ReviewNote) (*models.ReviewNote, error)
+	// Notes returns the notes of the case, oldest first
+	Notes(ctx context.Context, caseId int) ([]*models.ReviewNote, error)
+}
+
+type ReviewRepoImpl struct {
+	db     DBConnection
+	logger log.SimpleLogger
+}
+
+func NewReviewRepo(db DBConnection, logger log.SimpleLogger) ReviewRepo {
+	return &ReviewRepoImpl{
+		db:     db,
+		logger: logger,
+	}
+}
+
+func (rri *ReviewRepoImpl) Create(ctx context.Context, reviewCase models.ReviewCase) (*models.ReviewCase, error) {
+	reviewCase.Version = 1
+	if result := rri.db.Insert(ctx, &reviewCase); result.Error != nil {
+		return nil, translateError(result.Error)
+	}
+
+	return &reviewCase, nil
+}
+
+func (rri *ReviewRepoImpl) Get(ctx context.Context, caseId int) (*models.ReviewCase, error) {
+	reviewCase := &models.ReviewCase{}
+	if result := rri.db.First(ctx, reviewCase, "case_id = ?", caseId); result.Error != nil {
+		if errors.Is(result.Error, gorm.ErrRecordNotFound) {
+			return nil, errx.LocalizedWrap(errx.NotFound, result.Error, "reviews.not_found", "Review case not found with id %d", caseId)
+		}
+
+		return nil, translateError(result.Error)
+	}
+
+	return reviewCase, nil
+}
+
+func (rri *ReviewRepoImpl) GetUndecided(ctx context.Context, userId int) (*models.ReviewCase, error) {
+	reviewCase := &models.ReviewCase{}
+	result := rri.db.First(ctx, reviewCase, "user_id = ? AND status IN ?", userId, []string{models.ReviewOpen, models.ReviewClaimed})
+	if result.Error != nil {
+		if errors.Is(result.Error, gorm.ErrRecordNotFound) {
+			return nil, errx.LocalizedWrap(errx.NotFound, result.Error, "reviews.none_under_way", "User %d has no review case under way", userId)
+		}
+
+		return nil, translateError(result.Error)
+	}
+
+	return reviewCase, nil
+}
+
+func (rri *ReviewRepoImpl) List(ctx context.Context, query *Query, limit int) ([]*models.ReviewCase, error) {
+	if !query.Ordered("due_at") {
+		query.OrderBy("due_at", TimeValue, false)
+	}
+	if !query.Ordered("case_id") {
+		query.OrderBy("case_id", IntValue, false)
+	}
+
+	cases := make([]*models.ReviewCase, 0)
+	if result := rri.db.Find(ctx, &cases, query, limit, 0); result.Error != nil {
+		return nil, translateError(result.Error)
+	}
+
+	return cases, nil
+}
+
+func (rri *ReviewRepoImpl) Update(ctx context.Context, reviewCase models.ReviewCase) (*models.ReviewCase, error) {
+	result := rri.db.Updates(ctx, &models.ReviewCase{}, map[string]interface{}{
+		"status":           reviewCase.Status,
+		"reviewer":         reviewCase.Reviewer,
+		"claimed_at":       reviewCase.ClaimedAt,
+		"claim_expires_at": reviewCase.ClaimExpiresAt,
+		"decided_at":       reviewCase.DecidedAt,
+		"decision_reason":  reviewCase.DecisionReason,
+		"version":          gorm.Expr("version + 1"),
+	}, "case_id = ? AND version = ?", reviewCase.CaseId, reviewCase.Version)
+	if result.Error != nil {
+		return nil, translateError(result.Error)
+	}
+
+	// the version check happens in the update itself, nothing updated means another reviewer won
+	if result.RowsAffected == 0 {
+		return nil, errx.Localized(errx.Conflict, "reviews.changed", "review case %d changed meanwhile, read it again", reviewCase.CaseId)
+	}
+	reviewCase.Version++
+
+	return &reviewCase, nil
+}
+
+func (rri *ReviewRepoImpl) AddNote(ctx context.Context, note models.ReviewNote) (*models.ReviewNote, error) {
+	if result := rri.db.Insert(ctx, &note); result.Error != nil {
+		return nil, translateError(result.Error)
+	}
+
+	return &note, nil
+}
+
+func (rri *ReviewRepoImpl) Notes(ctx context.Context, caseId int) ([]*models.ReviewNote, error) {
+	notes := make([]*models.ReviewNote, 0)
+	query := NewQuery().Where("case_id", Equal, caseId).OrderBy("note_id", IntValue, false)
+	if result := rri.db.Find(ctx, &notes, query, 0, 0); result.Error != nil {
+		return nil, translateError(result.Error)
+	}
+
+	return notes, nil
+}
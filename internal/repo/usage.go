@@ -0,0 +1,83 @@
+package repo
+
+import (
+	"context"
+	"errors"
+
+	"github.com/joomcode/errorx"
+	errx "github.com/rhuandantas/verifymy-test/internal/errors"
+	"github.com/rhuandantas/verifymy-test/internal/log"
+	"github.com/rhuandantas/verifymy-test/internal/models"
+	"gorm.io/gorm"
+)
+
+//go:generate mockgen -source=$GOFILE -package=mock_repo -destination=../../test/mock/repo/$GOFILE
+
+type UsageRepo interface {
+	// Count is how much of the metric the client used on the day, 0 when nothing was recorded
+	Count(ctx context.Context, clientId string, day models.Date, metric string) (int64, error)
+	// Add adds count to what the client used of the metric on the day
+	Add(ctx context.Context, clientId string, day models.Date, metric string, count int64) error
+	// Range returns the usage of the client from one day to another, both included, by day
+	Range(ctx context.Context, clientId string, from, to models.Date) ([]*models.ClientUsage, error)
+}
+
+type UsageRepoImpl struct {
+	db     DBConnection
+	logger log.SimpleLogger
+}
+
+func NewUsageRepo(db DBConnection, logger log.SimpleLogger) UsageRepo {
+	return &UsageRepoImpl{
+		db:     db,
+		logger: logger,
+	}
+}
+
+func (uri *UsageRepoImpl) Count(ctx context.Context, clientId string, day models.Date, metric string) (int64, error) {
+	usage := &models.ClientUsage{}
+	if result := uri.db.First(ctx, usage, "client_id = ? AND day = ? AND metric = ?", clientId, day, metric); result.Error != nil {
+		if errors.Is(result.Error, gorm.ErrRecordNotFound) {
+			return 0, nil
+		}
+
+		return 0, translateError(result.Error)
+	}
+
+	return usage.Count, nil
+}
+
+// Add increments the row of the day in place, inserting it when there's none yet. Another server may insert
+// it meanwhile, in which case the increment is tried once more
+func (uri *UsageRepoImpl) Add(ctx context.Context, clientId string, day models.Date, metric string, count int64) error {
+	for attempt := 0; ; attempt++ {
+		result := uri.db.Updates(ctx, &models.ClientUsage{}, map[string]interface{}{
+			"count": gorm.Expr("count + ?", count),
+		}, "client_id = ? AND day = ? AND metric = ?", clientId, day, metric)
+		if result.Error != nil {
+			return translateError(result.Error)
+		}
+		if result.RowsAffected > 0 {
+			return nil
+		}
+
+		result = uri.db.Insert(ctx, &models.ClientUsage{ClientId: clientId, Day: day, Metric: metric, Count: count})
+		if err := translateError(result.Error); err == nil || attempt > 0 || !errorx.IsOfType(err, errx.Conflict) {
+			return err
+		}
+	}
+}
+
+func (uri *UsageRepoImpl) Range(ctx context.Context, clientId string, from, to models.Date) ([]*models.ClientUsage, error) {
+	usage := make([]*models.ClientUsage, 0)
+	query := NewQuery().
+		Where("client_id", Equal, clientId).
+		Where("day", GreaterOrEqual, from).
+		Where("day", LessOrEqual, to).
+		OrderBy("day", DateValue, false)
+	if result := uri.db.Find(ctx, &usage, query, 0, 0); result.Error != nil {
+		return nil, translateError(result.Error)
+	}
+
+	return usage, nil
+}
@@ -3,23 +3,86 @@ package repo
 import (
 	"context"
 	"errors"
-	"fmt"
+	errx "github.com/rhuandantas/verifymy-test/internal/errors"
 	"github.com/rhuandantas/verifymy-test/internal/log"
 	"github.com/rhuandantas/verifymy-test/internal/models"
+	"gorm.io/gorm"
+	"strconv"
+	"strings"
+	"time"
 )
 
 //go:generate mockgen -source=$GOFILE -package=mock_repo -destination=../../test/mock/repo/$GOFILE
-var (
-	RecordNotFoundErr = errors.New("record not found")
-)
+
+// UserColumns are the columns read when listing or searching users, the password hash is left out
+var UserColumns = []string{"user_id", "name", "date_of_birth", "timezone", "email", "address", "status", "created_at", "updated_at", "version", "deleted_at"}
+
+// UserQuery builds the queries of user listings and exports out of their query params. Ages are
+// computed from the date of birth, the age filters go by today's date in UTC
+var UserQuery = NewQueryBuilder().
+	Filter("email", "email", Equal, StringValue).
+	Filter("email_prefix", "email", Prefix, StringValue).
+	Filter("name", "name", Equal, StringValue).
+	Filter("name_prefix", "name", Prefix, StringValue).
+	Filter("status", "status", Equal, StringValue).
+	FilterBy("age_gte", "date_of_birth", LessOrEqual, IntValue, bornAtLeastYearsAgo).
+	FilterBy("age_lte", "date_of_birth", GreaterOrEqual, IntValue, bornAtMostYearsAgo).
+	Filter("created_at_gte", "created_at", GreaterOrEqual, TimeValue).
+	Filter("created_at_lte", "created_at", LessOrEqual, TimeValue).
+	Sortable("user_id", "user_id", IntValue).
+	Sortable("name", "name", StringValue).
+	Sortable("email", "email", StringValue).
+	SortableReversed("age", "date_of_birth", DateValue).
+	Sortable("date_of_birth", "date_of_birth", DateValue).
+	Nullable("date_of_birth").
+	Sortable("created_at", "created_at", TimeValue).
+	Sortable("updated_at", "updated_at", TimeValue).
+	Selectable("user_id", "user_id").
+	Selectable("name", "name").
+	Selectable("date_of_birth", "date_of_birth").
+	Selectable("age", "date_of_birth", "timezone").
+	Selectable("timezone", "timezone").
+	Selectable("email", "email").
+	Selectable("address", "address").
+	Selectable("status", "status").
+	Selectable("created_at", "created_at").
+	Selectable("updated_at", "updated_at").
+	Selectable("version", "version").
+	Selectable("deleted_at", "deleted_at")
+
+// identityColumns are read along with any projection so a user keeps its id and ETag
+var identityColumns = []string{"user_id", "version"}
 
 type UserRepo interface {
 	Create(ctx context.Context, user models.User) (*models.User, error)
-	Update(ctx context.Context, userId int, user models.User) (*models.User, error)
+	// CreateBatch inserts every user or none of them, batchSize users per insert statement
+	CreateBatch(ctx context.Context, users []models.User, batchSize int) ([]*models.User, error)
+	// Update replaces the user as long as it's still at version, a version of 0 skips the check. The status
+	// is only replaced when user has one
+	Update(ctx context.Context, userId int, user models.User, version int) (*models.User, error)
+	// Patch updates only the given columns, with the same version check as Update
+	Patch(ctx context.Context, userId int, columns map[string]interface{}, version int) (*models.User, error)
 	Delete(ctx context.Context, userId int) (bool, error)
-	GetByID(ctx context.Context, userId int) (*models.User, error)
+	Restore(ctx context.Context, userId int) (*models.User, error)
+	// Purgeable lists the ids of the users Purge would delete
+	Purgeable(ctx context.Context, deletedBefore time.Time) ([]int, error)
+	// Purge hard deletes the users soft deleted before deletedBefore, except the ones with a verification history
+	// or review cases, which are kept for audit
+	Purge(ctx context.Context, deletedBefore time.Time) (int64, error)
+	// GetByID reads only the given columns, along with the user id and version, when there are any
+	GetByID(ctx context.Context, userId int, columns ...string) (*models.User, error)
 	GetByEmail(ctx context.Context, email string) (*models.User, error)
-	GetUsers(ctx context.Context, offset, page int) (users []*models.User, err error)
+	// ExistingEmails tells which of the emails are registered to active users, keyed by lower case email
+	ExistingEmails(ctx context.Context, emails []string) (map[string]bool, error)
+	// GetUsers lists a page of the users matching the query, by user id when the query has no ordering.
+	// Users only have the selected columns of the query, besides the user id, version and sort columns. A cursor of the pagination that doesn't belong to the query ordering is an errx.BadRequest
+	GetUsers(ctx context.Context, query *Query, pagination models.Pagination, includeDeleted bool) (*models.UserPage, error)
+	// Export calls fn with up to batchSize users at a time, in id order, until every user was read or fn fails,
+	// passwords are not read
+	Export(ctx context.Context, query *Query, includeDeleted bool, batchSize int, fn func(users []*models.User) error) error
+	// Transaction runs fn with a repo whose operations are committed together when fn returns nil
+	// and rolled back otherwise
+	Transaction(ctx context.Context, fn func(userRepo UserRepo) error) error
 }
 
 type UserRepoImpl struct {
@@ -35,24 +98,82 @@ func NewUserRepo(db DBConnection, logger log.SimpleLogger) UserRepo {
 }
 
 func (uri *UserRepoImpl) Create(ctx context.Context, user models.User) (*models.User, error) {
+	user.DeletedAt = gorm.DeletedAt{}
+	user.DeletedKey = 0
+	user.Version = 1
+	if user.Status == "" {
+		user.Status = models.UserActive
+	}
 	if result := uri.db.Insert(ctx, &user); result.Error != nil {
-		return nil, result.Error
+		return nil, translateError(result.Error)
 	}
 
 	return &user, nil
 }
 
-func (uri *UserRepoImpl) Update(ctx context.Context, userId int, newUser models.User) (user *models.User, err error) {
-	if user, err = uri.GetByID(ctx, userId); err != nil {
+func (uri *UserRepoImpl) CreateBatch(ctx context.Context, users []models.User, batchSize int) ([]*models.User, error) {
+	for i := range users {
+		users[i].DeletedAt = gorm.DeletedAt{}
+		users[i].DeletedKey = 0
+		users[i].Version = 1
+		if users[i].Status == "" {
+			users[i].Status = models.UserActive
+		}
+	}
+
+	// CreateInBatches wraps the statements in a transaction when there's more than one
+	if result := uri.db.InsertInBatches(ctx, &users, batchSize); result.Error != nil {
+		return nil, translateError(result.Error)
+	}
+
+	created := make([]*models.User, 0, len(users))
+	for i := range users {
+		created = append(created, &users[i])
+	}
+
+	return created, nil
+}
+
+func (uri *UserRepoImpl) Update(ctx context.Context, userId int, newUser models.User, version int) (*models.User, error) {
+	columns := map[string]interface{}{
+		"name":          newUser.Name,
+		"address":       newUser.Address,
+		"date_of_birth": newUser.DateOfBirth,
+		"timezone":      newUser.Timezone,
+		"email":         newUser.Email,
+	}
+	if newUser.Status != "" {
+		columns["status"] = newUser.Status
+	}
+
+	return uri.Patch(ctx, userId, columns, version)
+}
+
+func (uri *UserRepoImpl) Patch(ctx context.Context, userId int, columns map[string]interface{}, version int) (*models.User, error) {
+	query, args := "user_id = ?", []interface{}{userId}
+	if version > 0 {
+		query, args = "user_id = ? AND version = ?", append(args, version)
+	}
+
+	values := make(map[string]interface{}, len(columns)+1)
+	for column, value := range columns {
+		values[column] = value
+	}
+	values["version"] = gorm.Expr("version + 1")
+
+	result := uri.db.Updates(ctx, &models.User{}, values, append([]interface{}{query}, args...)...)
+	if result.Error != nil {
+		return nil, translateError(result.Error)
+	}
+
+	user, err := uri.GetByID(ctx, userId)
+	if err != nil {
 		return nil, err
 	}
 
-	user.Name = newUser.Name
-	user.Address = newUser.Address
-	user.Age = newUser.Age
-	user.Email = newUser.Email
-	if result := uri.db.Update(ctx, user); result.Error != nil {
-		return nil, result.Error
+	// the version check happens in the update itself, nothing updated on an existing user means it's stale
+	if result.RowsAffected == 0 {
+		return nil, errx.Localized(errx.PreconditionFailed, "users.version_mismatch", "User %d is at version %d, not %d", userId, user.Version, version)
 	}
 
 	return user, nil
@@ -63,21 +184,86 @@ func (uri *UserRepoImpl) Delete(ctx context.Context, userId int) (bool, error) {
 		return false, err
 	}
 
-	if result := uri.db.Delete(ctx, &models.User{}, userId); result.Error != nil {
-		return false, result.Error
+	// soft deletes the user, deleted_key takes the user id to free the email for a new registration
+	if result := uri.db.Updates(ctx, &models.User{UserId: userId}, map[string]interface{}{
+		"deleted_at":  time.Now(),
+		"deleted_key": userId,
+	}); result.Error != nil {
+		return false, translateError(result.Error)
 	}
 
 	return true, nil
 }
 
-func (uri *UserRepoImpl) GetByID(ctx context.Context, userId int) (*models.User, error) {
+func (uri *UserRepoImpl) Restore(ctx context.Context, userId int) (*models.User, error) {
+	user := &models.User{UserId: userId}
+	if result := uri.db.Unscoped().First(ctx, user, "deleted_at IS NOT NULL"); result.Error != nil {
+		if errors.Is(result.Error, gorm.ErrRecordNotFound) {
+			return nil, errx.LocalizedWrap(errx.NotFound, result.Error, "users.deleted_not_found", "Deleted user not found with id %d", userId)
+		}
+
+		return nil, translateError(result.Error)
+	}
+
+	if result := uri.db.Unscoped().Updates(ctx, user, map[string]interface{}{
+		"deleted_at":  nil,
+		"deleted_key": 0,
+	}); result.Error != nil {
+		return nil, translateError(result.Error)
+	}
+	user.DeletedAt = gorm.DeletedAt{}
+	user.DeletedKey = 0
+
+	return user, nil
+}
+
+// purgeable are the soft deleted users nothing audited refers to, deleting them would cascade to their
+// verification events and review cases
+const purgeable = "deleted_at IS NOT NULL AND deleted_at < ?" +
+	" AND NOT EXISTS (SELECT 1 FROM verification_events WHERE verification_events.user_id = users.user_id)" +
+	" AND NOT EXISTS (SELECT 1 FROM review_cases WHERE review_cases.user_id = users.user_id)"
+
+func (uri *UserRepoImpl) Purgeable(ctx context.Context, deletedBefore time.Time) ([]int, error) {
+	userIds := make([]int, 0)
+	if result := uri.db.Unscoped().Pluck(ctx, &models.User{}, "user_id", &userIds, purgeable, deletedBefore); result.Error != nil {
+		return nil, translateError(result.Error)
+	}
+
+	return userIds, nil
+}
+
+// Purge removes for good the users soft deleted before deletedBefore
+func (uri *UserRepoImpl) Purge(ctx context.Context, deletedBefore time.Time) (int64, error) {
+	result := uri.db.Unscoped().Delete(ctx, &models.User{}, purgeable, deletedBefore)
+	if result.Error != nil {
+		return 0, translateError(result.Error)
+	}
+
+	return result.RowsAffected, nil
+}
+
+func (uri *UserRepoImpl) GetByID(ctx context.Context, userId int, columns ...string) (*models.User, error) {
+	if len(columns) > 0 {
+		var users []*models.User
+		query := NewQuery().Where("user_id", Equal, userId)
+		if result := uri.db.Find(ctx, &users, query, 1, 0, withColumns(columns, identityColumns...)...); result.Error != nil {
+			return nil, translateError(result.Error)
+		}
+
+		if len(users) == 0 {
+			return nil, errx.Localized(errx.NotFound, "users.not_found", "User not found with id %d", userId)
+		}
+
+		return users[0], nil
+	}
+
 	user := &models.User{UserId: userId}
 	if result := uri.db.First(ctx, user); result.Error != nil {
-		if result.Error.Error() == RecordNotFoundErr.Error() {
-			return nil, errors.New(fmt.Sprintf("User not found with id %d", userId))
+		if errors.Is(result.Error, gorm.ErrRecordNotFound) {
+			return nil, errx.LocalizedWrap(errx.NotFound, result.Error, "users.not_found", "User not found with id %d", userId)
 		}
 
-		return nil, result.Error
+		return nil, translateError(result.Error)
 	}
 
 	return user, nil
@@ -87,16 +273,177 @@ func (uri *UserRepoImpl) GetByEmail(ctx context.Context, email string) (*models.
 	if result := uri.db.GetDB().WithContext(ctx).
 		Where("email = ?", email).
 		First(user); result.Error != nil {
-		return nil, result.Error
+		if errors.Is(result.Error, gorm.ErrRecordNotFound) {
+			return nil, errx.LocalizedWrap(errx.NotFound, result.Error, "users.email_not_found", "User not found with email %s", email)
+		}
+
+		return nil, translateError(result.Error)
 	}
 
 	return user, nil
 }
 
-func (uri *UserRepoImpl) GetUsers(ctx context.Context, offset, page int) (users []*models.User, err error) {
-	if result := uri.db.FindAll(ctx, offset, page, "user_id", &users, "name", "age", "email", "address"); result.Error != nil {
-		return nil, result.Error
+func (uri *UserRepoImpl) ExistingEmails(ctx context.Context, emails []string) (map[string]bool, error) {
+	existing := make(map[string]bool)
+	if len(emails) == 0 {
+		return existing, nil
 	}
 
-	return users, nil
+	var found []string
+	if result := uri.db.Pluck(ctx, &models.User{}, "email", &found, "email IN ?", emails); result.Error != nil {
+		return nil, translateError(result.Error)
+	}
+
+	for _, email := range found {
+		existing[strings.ToLower(email)] = true
+	}
+
+	return existing, nil
+}
+
+func (uri *UserRepoImpl) GetUsers(ctx context.Context, query *Query, pagination models.Pagination, includeDeleted bool) (*models.UserPage, error) {
+	db := uri.db
+	if includeDeleted {
+		db = db.Unscoped()
+	}
+
+	// the caller's query is left as it is, it may be reused for another page
+	query = query.clone()
+	// user_id breaks ties so pages don't overlap and every user has a cursor of its own
+	if !query.Ordered("user_id") {
+		query.OrderBy("user_id", IntValue, false)
+	}
+
+	page := &models.UserPage{Items: make([]*models.User, 0)}
+	if pagination.Total {
+		var total int64
+		if result := db.Count(ctx, &models.User{}, query, &total); result.Error != nil {
+			return nil, translateError(result.Error)
+		}
+		page.Total = &total
+	}
+
+	listing := query
+	offset := pagination.Page * pagination.Size
+	forward, backward := pagination.After != "", pagination.Before != ""
+	if forward && backward {
+		return nil, errx.Localized(errx.BadRequest, "cursor.after_and_before", "after and before can't be used together")
+	}
+	if forward || backward {
+		var err error
+		if listing, err = query.Seek(pagination.After+pagination.Before, backward); err != nil {
+			return nil, err
+		}
+		offset = 0
+	}
+
+	// the sort columns are read whatever the projection, the cursors are made of them
+	columns := UserColumns
+	if selected := query.Selected(); len(selected) > 0 {
+		columns = withColumns(selected, identityColumns...)
+		for _, order := range query.orders {
+			columns = withColumns(columns, order.Column.Name)
+		}
+	}
+
+	// the extra user tells whether there's a page past this one
+	var users []*models.User
+	if result := db.Find(ctx, &users, listing, pagination.Size+1, offset, columns...); result.Error != nil {
+		return nil, translateError(result.Error)
+	}
+
+	more := len(users) > pagination.Size
+	if more {
+		users = users[:pagination.Size]
+	}
+	if len(users) == 0 {
+		return page, nil
+	}
+
+	if backward {
+		for i, j := 0, len(users)-1; i < j; i, j = i+1, j-1 {
+			users[i], users[j] = users[j], users[i]
+		}
+	}
+	page.Items = users
+
+	// paging back from a cursor there's always the cursor user after the page, and paging forward from one
+	// or past the first page there's always a user before it
+	if more || backward {
+		page.Next = query.Cursor(userKey(users[len(users)-1]))
+	}
+	if (more && backward) || forward || offset > 0 {
+		page.Prev = query.Cursor(userKey(users[0]))
+	}
+
+	return page, nil
+}
+
+// withColumns adds the missing columns to the projection
+func withColumns(projection []string, columns ...string) []string {
+	merged := append(make([]string, 0, len(projection)+len(columns)), projection...)
+	for _, column := range columns {
+		if !contains(merged, column) {
+			merged = append(merged, column)
+		}
+	}
+
+	return merged
+}
+
+// bornAtLeastYearsAgo turns the age of the age_gte filter into the latest date of birth it allows
+func bornAtLeastYearsAgo(age interface{}) interface{} {
+	return models.LatestBirthDate(age.(int), models.Today(time.UTC))
+}
+
+// bornAtMostYearsAgo turns the age of the age_lte filter into the earliest date of birth it allows,
+// the day after the latest one of a year older
+func bornAtMostYearsAgo(age interface{}) interface{} {
+	return models.LatestBirthDate(age.(int)+1, models.Today(time.UTC)).AddDays(1)
+}
+
+// userKey reads the columns users can be sorted by, as their query params would have them, a missing date
+// of birth is empty
+func userKey(user *models.User) func(column string) string {
+	return func(column string) string {
+		switch column {
+		case "user_id":
+			return strconv.Itoa(user.UserId)
+		case "name":
+			return user.Name
+		case "email":
+			return user.Email
+		case "date_of_birth":
+			if user.DateOfBirth == nil {
+				return ""
+			}
+			return user.DateOfBirth.String()
+		case "created_at":
+			return user.CreatedAt.Format(time.RFC3339Nano)
+		case "updated_at":
+			return user.UpdatedAt.Format(time.RFC3339Nano)
+		default:
+			return ""
+		}
+	}
+}
+
+func (uri *UserRepoImpl) Export(ctx context.Context, query *Query, includeDeleted bool, batchSize int, fn func(users []*models.User) error) error {
+	db := uri.db
+	if includeDeleted {
+		db = db.Unscoped()
+	}
+
+	var users []*models.User
+	result := db.FindInBatches(ctx, &users, query, batchSize, func() error {
+		return fn(users)
+	}, UserColumns...)
+
+	return translateError(result.Error)
+}
+
+func (uri *UserRepoImpl) Transaction(ctx context.Context, fn func(userRepo UserRepo) error) error {
+	return uri.db.Transaction(ctx, func(tx DBConnection) error {
+		return fn(NewUserRepo(tx, uri.logger))
+	})
 }
@@ -0,0 +1,139 @@
+package repo
+
+import (
+	"context"
+	"errors"
+
+	errx "github.com/rhuandantas/verifymy-test/internal/errors"
+	"github.com/rhuandantas/verifymy-test/internal/log"
+	"github.com/rhuandantas/verifymy-test/internal/models"
+	"gorm.io/gorm"
+)
+
+//go:generate mockgen -source=$GOFILE -package=mock_repo -destination=../../test/mock/repo/$GOFILE
+
+type VerificationRepo interface {
+	// Get fails with errx.NotFound when the user never started a verification
+	Get(ctx context.Context, userId int) (*models.Verification, error)
+	// GetByEvidence returns the verification backed by evidenceRef, failing with errx.NotFound when none is
+	GetByEvidence(ctx context.Context, evidenceRef string) (*models.Verification, error)
+	// ListByUsers returns the verifications of the users keyed by user id, users that never started one are left out
+	ListByUsers(ctx context.Context, userIds []int) (map[int]*models.Verification, error)
+	// History returns the transitions of the verification of the user, oldest first
+	History(ctx context.Context, userId int) ([]*models.VerificationEvent, error)
+	// Save stores the verification along with the event of its transition. The version of verification is
+	// the one it was read at, 0 for a new one, and Save fails with errx.Conflict when it changed meanwhile
+	Save(ctx context.Context, verification models.Verification, event models.VerificationEvent) (*models.Verification, error)
+}
+
+type VerificationRepoImpl struct {
+	db     DBConnection
+	logger log.SimpleLogger
+}
+
+func NewVerificationRepo(db DBConnection, logger log.SimpleLogger) VerificationRepo {
+	return &VerificationRepoImpl{
+		db:     db,
+		logger: logger,
+	}
+}
+
+func (vri *VerificationRepoImpl) Get(ctx context.Context, userId int) (*models.Verification, error) {
+	verification := &models.Verification{}
+	if result := vri.db.First(ctx, verification, "user_id = ?", userId); result.Error != nil {
+		if errors.Is(result.Error, gorm.ErrRecordNotFound) {
+			return nil, errx.LocalizedWrap(errx.NotFound, result.Error, "verification.not_found", "User %d has no verification", userId)
+		}
+
+		return nil, translateError(result.Error)
+	}
+
+	return verification, nil
+}
+
+func (vri *VerificationRepoImpl) GetByEvidence(ctx context.Context, evidenceRef string) (*models.Verification, error) {
+	verification := &models.Verification{}
+	if result := vri.db.First(ctx, verification, "evidence_ref = ?", evidenceRef); result.Error != nil {
+		if errors.Is(result.Error, gorm.ErrRecordNotFound) {
+			return nil, errx.LocalizedWrap(errx.NotFound, result.Error, "verification.evidence_not_found", "No verification with evidence %s", evidenceRef)
+		}
+
+		return nil, translateError(result.Error)
+	}
+
+	return verification, nil
+}
+
+func (vri *VerificationRepoImpl) ListByUsers(ctx context.Context, userIds []int) (map[int]*models.Verification, error) {
+	byUser := make(map[int]*models.Verification)
+	if len(userIds) == 0 {
+		return byUser, nil
+	}
+
+	var verifications []*models.Verification
+	query := NewQuery().Where("user_id", In, userIds).OrderBy("user_id", IntValue, false)
+	if result := vri.db.Find(ctx, &verifications, query, 0, 0); result.Error != nil {
+		return nil, translateError(result.Error)
+	}
+
+	for _, verification := range verifications {
+		byUser[verification.UserId] = verification
+	}
+
+	return byUser, nil
+}
+
+func (vri *VerificationRepoImpl) History(ctx context.Context, userId int) ([]*models.VerificationEvent, error) {
+	events := make([]*models.VerificationEvent, 0)
+	query := NewQuery().Where("user_id", Equal, userId).OrderBy("event_id", IntValue, false)
+	if result := vri.db.Find(ctx, &events, query, 0, 0); result.Error != nil {
+		return nil, translateError(result.Error)
+	}
+
+	return events, nil
+}
+
+func (vri *VerificationRepoImpl) Save(ctx context.Context, verification models.Verification, event models.VerificationEvent) (*models.Verification, error) {
+	err := vri.db.Transaction(ctx, func(tx DBConnection) error {
+		if verification.Version == 0 {
+			verification.Version = 1
+			if result := tx.Insert(ctx, &verification); result.Error != nil {
+				return translateError(result.Error)
+			}
+		} else {
+			result := tx.Updates(ctx, &models.Verification{}, map[string]interface{}{
+				"state":           verification.State,
+				"method":          verification.Method,
+				"assurance_level": verification.AssuranceLevel,
+				"evidence_ref":    verification.EvidenceRef,
+				"requested_at":    verification.RequestedAt,
+				"verified_at":     verification.VerifiedAt,
+				"expires_at":      verification.ExpiresAt,
+				"date_of_birth":   verification.DateOfBirth,
+				"timezone":        verification.Timezone,
+				"version":         gorm.Expr("version + 1"),
+			}, "user_id = ? AND version = ?", verification.UserId, verification.Version)
+			if result.Error != nil {
+				return translateError(result.Error)
+			}
+
+			// the version check happens in the update itself, nothing updated means another transition won
+			if result.RowsAffected == 0 {
+				return errx.Localized(errx.Conflict, "verification.changed", "verification of user %d changed meanwhile, read it again", verification.UserId)
+			}
+			verification.Version++
+		}
+
+		event.UserId = verification.UserId
+		if result := tx.Insert(ctx, &event); result.Error != nil {
+			return translateError(result.Error)
+		}
+
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &verification, nil
+}
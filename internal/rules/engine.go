@@ -0,0 +1,88 @@
+package rules
+
+import (
+	"context"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/joomcode/errorx"
+	"github.com/rhuandantas/verifymy-test/internal/config"
+	"github.com/rhuandantas/verifymy-test/internal/log"
+	"gopkg.in/yaml.v3"
+)
+
+//go:generate mockgen -source=$GOFILE -package=mock_rules -destination=../../test/mock/rules/$GOFILE
+
+const defaultRulesPath = "./resources/rules.yml"
+
+type Engine interface {
+	Evaluate(subject Subject, context Context) Decision
+	// Reload reads the rules file again when it changed since it was last read. A file that fails to load
+	// leaves the rules as they were
+	Reload(ctx context.Context) error
+}
+
+type FileEngine struct {
+	path    string
+	logger  log.SimpleLogger
+	mu      sync.RWMutex
+	rules   *RuleSet
+	modTime time.Time
+	size    int64
+}
+
+// NewFileEngine loads the rules file at rules.path, failing when it can't be loaded
+func NewFileEngine(config config.ConfigProvider, logger log.SimpleLogger) (Engine, error) {
+	engine := &FileEngine{
+		path:   config.GetStringOrDefault("rules.path", defaultRulesPath),
+		logger: logger,
+	}
+
+	if err := engine.Reload(context.Background()); err != nil {
+		return nil, err
+	}
+
+	return engine, nil
+}
+
+func (fe *FileEngine) Evaluate(subject Subject, context Context) Decision {
+	fe.mu.RLock()
+	defer fe.mu.RUnlock()
+
+	return fe.rules.Evaluate(subject, context)
+}
+
+func (fe *FileEngine) Reload(_ context.Context) error {
+	info, err := os.Stat(fe.path)
+	if err != nil {
+		return errorx.Decorate(err, "failed to read rules %s", fe.path)
+	}
+
+	fe.mu.RLock()
+	unchanged := fe.rules != nil && info.ModTime().Equal(fe.modTime) && info.Size() == fe.size
+	fe.mu.RUnlock()
+	if unchanged {
+		return nil
+	}
+
+	content, err := os.ReadFile(fe.path)
+	if err != nil {
+		return errorx.Decorate(err, "failed to read rules %s", fe.path)
+	}
+
+	rules := &RuleSet{}
+	if err = yaml.Unmarshal(content, rules); err != nil {
+		return errorx.Decorate(err, "failed to parse rules %s", fe.path)
+	}
+	if err = rules.validate(); err != nil {
+		return errorx.IllegalArgument.Wrap(err, "rules %s are not valid", fe.path)
+	}
+
+	fe.mu.Lock()
+	fe.rules, fe.modTime, fe.size = rules, info.ModTime(), info.Size()
+	fe.mu.Unlock()
+	fe.logger.Infof("loaded %d rules from %s", len(rules.Rules), fe.path)
+
+	return nil
+}
@@ -0,0 +1,186 @@
+package rules
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/rhuandantas/verifymy-test/internal/models"
+)
+
+// Wildcard matches any jurisdiction or category
+const Wildcard = "*"
+
+// jurisdictionPattern is an ISO 3166-1 alpha-2 country, optionally followed by an ISO 3166-2 subdivision
+var jurisdictionPattern = regexp.MustCompile(`^[A-Z]{2}(-[A-Z0-9]{1,3})?$`)
+
+var knownMethods = map[string]bool{
+	models.MethodDocument:   true,
+	models.MethodCreditCard: true,
+	models.MethodDatabase:   true,
+	models.MethodEstimation: true,
+	models.MethodManual:     true,
+}
+
+// Rule is the minimum age to access a category of content in a jurisdiction, and the verification methods
+// accepted as proof of it. Jurisdiction is a country like GB, a subdivision like US-UT or *, Category a
+// content category like alcohol or *. No methods accepts any
+type Rule struct {
+	Jurisdiction string   `yaml:"jurisdiction" json:"jurisdiction"`
+	Category     string   `yaml:"category" json:"category"`
+	MinimumAge   int      `yaml:"minimum-age" json:"minimum_age"`
+	Methods      []string `yaml:"methods" json:"methods,omitempty"`
+}
+
+// RuleSet is the content of the rules file
+type RuleSet struct {
+	Rules []Rule `yaml:"rules"`
+}
+
+// Context is where and for what the age of a user is evaluated
+type Context struct {
+	Jurisdiction string `query:"jurisdiction" json:"jurisdiction" validate:"required,max=6"`
+	Category     string `query:"category" json:"category" validate:"required,max=64"`
+}
+
+// Subject is what the rules need to know of a user: their age, nil when unknown, their verification and
+// whether they're a minor restricted until a guardian consents
+type Subject struct {
+	Age               *int
+	VerificationState string
+	Method            string
+	Restricted        bool
+}
+
+// why a user is allowed or denied
+const (
+	ReasonAllowed           = "allowed"
+	ReasonNoRule            = "no_rule"
+	ReasonConsentRequired   = "consent_required"
+	ReasonNotVerified       = "not_verified"
+	ReasonMethodNotAccepted = "method_not_accepted"
+	ReasonUnknownAge        = "unknown_age"
+	ReasonUnderAge          = "under_age"
+)
+
+// Decision tells whether a user is allowed in a context, Reason and Message tell why. Rule is the rule
+// that applied, nil when none did
+type Decision struct {
+	Allowed bool    `json:"allowed"`
+	Reason  string  `json:"reason"`
+	Message string  `json:"message"`
+	Context Context `json:"context"`
+	Rule    *Rule   `json:"rule,omitempty"`
+}
+
+// Normalize upper cases the jurisdiction and lower cases the category
+func (c Context) Normalize() Context {
+	return Context{
+		Jurisdiction: strings.ToUpper(strings.TrimSpace(c.Jurisdiction)),
+		Category:     strings.ToLower(strings.TrimSpace(c.Category)),
+	}
+}
+
+// country is the country of the jurisdiction, the jurisdiction itself when it's one
+func (c Context) country() string {
+	country, _, _ := strings.Cut(c.Jurisdiction, "-")
+	return country
+}
+
+// Evaluate decides on the subject with the rules. Rules of the category come before the ones for any
+// category, and among them the subdivision comes before its country and the country before any jurisdiction
+func (rs *RuleSet) Evaluate(subject Subject, context Context) Decision {
+	context = context.Normalize()
+	rule := rs.match(context)
+	if rule == nil {
+		return Decision{Reason: ReasonNoRule, Message: fmt.Sprintf("no rule for %s in %s", context.Category, context.Jurisdiction), Context: context}
+	}
+
+	decision := Decision{Context: context, Rule: rule}
+	switch {
+	case subject.Restricted:
+		decision.Reason = ReasonConsentRequired
+		decision.Message = "user is a minor without a guardian's consent"
+	case subject.VerificationState != models.VerificationVerified:
+		decision.Reason = ReasonNotVerified
+		decision.Message = fmt.Sprintf("age is not verified, verification is %s", subject.VerificationState)
+	case !rule.accepts(subject.Method):
+		decision.Reason = ReasonMethodNotAccepted
+		decision.Message = fmt.Sprintf("%s verification is not accepted, only %s", subject.Method, strings.Join(rule.Methods, ", "))
+	case subject.Age == nil:
+		decision.Reason = ReasonUnknownAge
+		decision.Message = "date of birth is unknown"
+	case *subject.Age < rule.MinimumAge:
+		decision.Reason = ReasonUnderAge
+		decision.Message = fmt.Sprintf("%d is under the minimum age of %d", *subject.Age, rule.MinimumAge)
+	default:
+		decision.Allowed = true
+		decision.Reason = ReasonAllowed
+		decision.Message = fmt.Sprintf("%d is at least the minimum age of %d", *subject.Age, rule.MinimumAge)
+	}
+
+	return decision
+}
+
+func (rs *RuleSet) match(context Context) *Rule {
+	jurisdictions := []string{context.Jurisdiction, context.country(), Wildcard}
+	for _, category := range []string{context.Category, Wildcard} {
+		for _, jurisdiction := range jurisdictions {
+			for i := range rs.Rules {
+				if rs.Rules[i].Jurisdiction == jurisdiction && rs.Rules[i].Category == category {
+					rule := rs.Rules[i]
+					return &rule
+				}
+			}
+		}
+	}
+
+	return nil
+}
+
+func (r Rule) accepts(method string) bool {
+	if len(r.Methods) == 0 {
+		return true
+	}
+
+	for _, accepted := range r.Methods {
+		if accepted == method {
+			return true
+		}
+	}
+
+	return false
+}
+
+// validate normalizes the rules and checks them, two rules for the same jurisdiction and category are an error
+func (rs *RuleSet) validate() error {
+	seen := make(map[string]bool, len(rs.Rules))
+	for i := range rs.Rules {
+		rule := &rs.Rules[i]
+		normalized := Context{Jurisdiction: rule.Jurisdiction, Category: rule.Category}.Normalize()
+		rule.Jurisdiction, rule.Category = normalized.Jurisdiction, normalized.Category
+
+		if rule.Jurisdiction != Wildcard && !jurisdictionPattern.MatchString(rule.Jurisdiction) {
+			return fmt.Errorf("rule %d: jurisdiction %q is not a country, a subdivision nor *", i+1, rule.Jurisdiction)
+		}
+		if rule.Category == "" {
+			return fmt.Errorf("rule %d: category is required, * for any", i+1)
+		}
+		if rule.MinimumAge < 0 || rule.MinimumAge > models.MaxAge {
+			return fmt.Errorf("rule %d: minimum age %d is not between 0 and %d", i+1, rule.MinimumAge, models.MaxAge)
+		}
+		for _, method := range rule.Methods {
+			if !knownMethods[method] {
+				return fmt.Errorf("rule %d: %q is not a verification method", i+1, method)
+			}
+		}
+
+		key := rule.Jurisdiction + "/" + rule.Category
+		if seen[key] {
+			return fmt.Errorf("rule %d: %s already has a rule for %s", i+1, rule.Jurisdiction, rule.Category)
+		}
+		seen[key] = true
+	}
+
+	return nil
+}
@@ -0,0 +1,195 @@
+package search
+
+import (
+	"html"
+	"sort"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/rhuandantas/verifymy-test/internal/models"
+)
+
+const (
+	highlightStart = "<em>"
+	highlightEnd   = "</em>"
+)
+
+// fields are the user fields a search looks into, by json name
+var fields = []struct {
+	name  string
+	value func(user *models.User) string
+}{
+	{name: "name", value: func(user *models.User) string { return user.Name }},
+	{name: "email", value: func(user *models.User) string { return user.Email }},
+	{name: "address", value: func(user *models.User) string { return user.Address }},
+}
+
+// tokenize splits text into lower case words, anything but letters and digits separates them
+func tokenize(text string) []string {
+	return strings.FieldsFunc(strings.ToLower(text), isSeparator)
+}
+
+func isSeparator(r rune) bool {
+	return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+}
+
+// maxTypos is how many edits a word can be away from term and still match it on a fuzzy search,
+// short terms only match exactly
+func maxTypos(term string) int {
+	switch n := utf8.RuneCountInString(term); {
+	case n < 4:
+		return 0
+	case n < 8:
+		return 1
+	default:
+		return 2
+	}
+}
+
+// wordScore is 1 when word is term, on a fuzzy search a word within maxTypos of term scores less for each typo
+func wordScore(term, word string, fuzzy bool) float64 {
+	if term == word {
+		return 1
+	}
+
+	if !fuzzy {
+		return 0
+	}
+
+	typos := levenshtein(term, word)
+	if typos > maxTypos(term) {
+		return 0
+	}
+
+	return 1 - float64(typos)/float64(utf8.RuneCountInString(term)+1)
+}
+
+// levenshtein is the least number of rune insertions, deletions and substitutions turning a into b
+func levenshtein(a, b string) int {
+	ra, rb := []rune(a), []rune(b)
+	previous := make([]int, len(rb)+1)
+	current := make([]int, len(rb)+1)
+	for j := range previous {
+		previous[j] = j
+	}
+
+	for i := 1; i <= len(ra); i++ {
+		current[0] = i
+		for j := 1; j <= len(rb); j++ {
+			cost := 1
+			if ra[i-1] == rb[j-1] {
+				cost = 0
+			}
+			current[j] = min(previous[j]+1, current[j-1]+1, previous[j-1]+cost)
+		}
+		previous, current = current, previous
+	}
+
+	return previous[len(rb)]
+}
+
+func min(values ...int) int {
+	least := values[0]
+	for _, value := range values[1:] {
+		if value < least {
+			least = value
+		}
+	}
+
+	return least
+}
+
+// match scores user against the terms, the score is the mean of the best word score of each term so a user
+// with every term scores higher than one with only some of them
+func match(user *models.User, terms []string, fuzzy bool) (float64, map[string]string) {
+	best := make([]float64, len(terms))
+	highlights := make(map[string]string)
+	for _, field := range fields {
+		highlighted, found := highlight(field.value(user), func(word string) bool {
+			matched := false
+			for i, term := range terms {
+				if score := wordScore(term, word, fuzzy); score > 0 {
+					matched = true
+					if score > best[i] {
+						best[i] = score
+					}
+				}
+			}
+			return matched
+		})
+
+		if found {
+			highlights[field.name] = highlighted
+		}
+	}
+
+	total := 0.0
+	for _, score := range best {
+		total += score
+	}
+
+	return total / float64(len(terms)), highlights
+}
+
+// highlight wraps the words of text matches accepts in <em>, it gets them in lower case. The rest of the text
+// is html escaped so only the highlight tags are markup
+func highlight(text string, matches func(word string) bool) (string, bool) {
+	var b strings.Builder
+	found := false
+	for i := 0; i < len(text); {
+		r, _ := utf8.DecodeRuneInString(text[i:])
+		separator := isSeparator(r)
+		j := i
+		for j < len(text) {
+			r, size := utf8.DecodeRuneInString(text[j:])
+			if isSeparator(r) != separator {
+				break
+			}
+			j += size
+		}
+
+		part := html.EscapeString(text[i:j])
+		if !separator && matches(strings.ToLower(text[i:j])) {
+			found = true
+			part = highlightStart + part + highlightEnd
+		}
+		b.WriteString(part)
+		i = j
+	}
+
+	return b.String(), found
+}
+
+// rank matches the users against the terms and returns the ones matching any of them, best first
+func rank(users []*models.User, terms []string, fuzzy bool) []Hit {
+	hits := make([]Hit, 0)
+	for _, user := range users {
+		score, highlights := match(user, terms, fuzzy)
+		if score == 0 {
+			continue
+		}
+		hits = append(hits, Hit{User: user, Score: score, Fuzzy: fuzzy, Highlights: highlights})
+	}
+
+	sortHits(hits)
+	return hits
+}
+
+// sortHits orders the hits by score, ties by user id
+func sortHits(hits []Hit) {
+	sort.SliceStable(hits, func(i, j int) bool {
+		if hits[i].Score != hits[j].Score {
+			return hits[i].Score > hits[j].Score
+		}
+		return hits[i].User.UserId < hits[j].User.UserId
+	})
+}
+
+func truncate(hits []Hit, limit int) []Hit {
+	if limit > 0 && len(hits) > limit {
+		return hits[:limit]
+	}
+
+	return hits
+}
@@ -0,0 +1,63 @@
+package search
+
+import (
+	"context"
+	"sync"
+
+	"github.com/rhuandantas/verifymy-test/internal/models"
+)
+
+// MemoryUserSearcher searches the users it was given, for tests and local runs without mysql. It matches
+// whole words like the mysql searcher, with the same typo tolerant fallback
+type MemoryUserSearcher struct {
+	mu    sync.RWMutex
+	users map[int]*models.User
+}
+
+func NewMemoryUserSearcher(users ...*models.User) *MemoryUserSearcher {
+	ms := &MemoryUserSearcher{users: make(map[int]*models.User)}
+	ms.Index(users...)
+
+	return ms
+}
+
+// Index adds the users to the searcher, replacing the ones with the same user id
+func (ms *MemoryUserSearcher) Index(users ...*models.User) {
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
+
+	for _, user := range users {
+		ms.users[user.UserId] = user
+	}
+}
+
+// Remove drops the user from the searcher
+func (ms *MemoryUserSearcher) Remove(userId int) {
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
+
+	delete(ms.users, userId)
+}
+
+func (ms *MemoryUserSearcher) Search(_ context.Context, text string, limit int) ([]Hit, error) {
+	terms := tokenize(text)
+	if len(terms) == 0 {
+		return []Hit{}, nil
+	}
+
+	ms.mu.RLock()
+	users := make([]*models.User, 0, len(ms.users))
+	for _, user := range ms.users {
+		if !user.DeletedAt.Valid {
+			users = append(users, user)
+		}
+	}
+	ms.mu.RUnlock()
+
+	hits := rank(users, terms, false)
+	if len(hits) == 0 {
+		hits = rank(users, terms, true)
+	}
+
+	return truncate(hits, limit), nil
+}
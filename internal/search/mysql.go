@@ -0,0 +1,115 @@
+package search
+
+import (
+	"context"
+	"strings"
+
+	"github.com/rhuandantas/verifymy-test/internal/config"
+	"github.com/rhuandantas/verifymy-test/internal/models"
+	"github.com/rhuandantas/verifymy-test/internal/repo"
+	"gorm.io/gorm/clause"
+)
+
+const (
+	defaultFuzzyCandidates = 500
+
+	// matchAgainst must name the columns of the idx_users_search fulltext index
+	matchAgainst = "MATCH (name, email, address) AGAINST (? IN NATURAL LANGUAGE MODE)"
+)
+
+// scoredUser is a user read along with its fulltext relevance
+type scoredUser struct {
+	models.User `gorm:"embedded"`
+	Score       float64
+}
+
+// MysqlUserSearcher ranks the users with the fulltext index on name, email and address. Mysql can't match
+// misspelled words, so when nothing matches it reads up to fuzzyCandidates users having a word that starts
+// like one of the search words and matches them within a few typos
+type MysqlUserSearcher struct {
+	db              repo.DBConnection
+	fuzzyCandidates int
+}
+
+// NewMysqlUserSearcher reads the candidate set size of the fuzzy search from users.search.fuzzy-candidates,
+// 500 by default
+func NewMysqlUserSearcher(config config.ConfigProvider, db repo.DBConnection) UserSearcher {
+	fuzzyCandidates := config.GetInt("users.search.fuzzy-candidates")
+	if fuzzyCandidates <= 0 {
+		fuzzyCandidates = defaultFuzzyCandidates
+	}
+
+	return &MysqlUserSearcher{
+		db:              db,
+		fuzzyCandidates: fuzzyCandidates,
+	}
+}
+
+func (ms *MysqlUserSearcher) Search(ctx context.Context, text string, limit int) ([]Hit, error) {
+	terms := tokenize(text)
+	if len(terms) == 0 {
+		return []Hit{}, nil
+	}
+
+	// the terms are searched rather than text so mysql and the highlights split the words the same way
+	against := strings.Join(terms, " ")
+	rows := make([]scoredUser, 0)
+	result := ms.db.GetDB().WithContext(ctx).
+		Model(&models.User{}).
+		Select(strings.Join(repo.UserColumns, ", ")+", "+matchAgainst+" AS score", against).
+		Where(matchAgainst, against).
+		Order("score DESC").
+		Order("user_id").
+		Limit(limit).
+		Find(&rows)
+	if result.Error != nil {
+		return nil, result.Error
+	}
+
+	if len(rows) > 0 {
+		hits := make([]Hit, 0, len(rows))
+		for i := range rows {
+			_, highlights := match(&rows[i].User, terms, false)
+			hits = append(hits, Hit{User: &rows[i].User, Score: rows[i].Score, Highlights: highlights})
+		}
+		return hits, nil
+	}
+
+	return ms.fuzzySearch(ctx, terms, limit)
+}
+
+// fuzzySearch ranks the users having a word starting with the first two letters of a term, a typo in those
+// letters goes unmatched but the candidate set stays small enough to be matched in memory
+func (ms *MysqlUserSearcher) fuzzySearch(ctx context.Context, terms []string, limit int) ([]Hit, error) {
+	conditions := make([]clause.Expression, 0)
+	for _, term := range terms {
+		runes := []rune(term)
+		if len(runes) < 2 {
+			continue
+		}
+
+		prefix := repo.EscapeLike(string(runes[:2]))
+		for _, column := range []string{"name", "email", "address"} {
+			conditions = append(conditions,
+				clause.Like{Column: clause.Column{Name: column}, Value: prefix + "%"},
+				clause.Like{Column: clause.Column{Name: column}, Value: "% " + prefix + "%"})
+		}
+	}
+
+	if len(conditions) == 0 {
+		return []Hit{}, nil
+	}
+
+	candidates := make([]*models.User, 0)
+	result := ms.db.GetDB().WithContext(ctx).
+		Select(repo.UserColumns).
+		Where(clause.Or(conditions...)).
+		Order("user_id").
+		Limit(ms.fuzzyCandidates).
+		Find(&candidates)
+	if result.Error != nil {
+		return nil, result.Error
+	}
+
+	return truncate(rank(candidates, terms, true), limit), nil
+}
@@ -0,0 +1,25 @@
+package search
+
+import (
+	"context"
+
+	"github.com/rhuandantas/verifymy-test/internal/models"
+)
+
+//go:generate mockgen -source=$GOFILE -package=mock_search -destination=../../test/mock/search/$GOFILE
+
+// Hit is a user matching a search. Highlights has the name, email and address of the user with the matched
+// words wrapped in <em>, only for the fields with a match, and Fuzzy tells the match is within a few typos
+// rather than exact
+type Hit struct {
+	User       *models.User      `json:"user"`
+	Score      float64           `json:"score"`
+	Fuzzy      bool              `json:"fuzzy"`
+	Highlights map[string]string `json:"highlights,omitempty"`
+}
+
+type UserSearcher interface {
+	// Search returns up to limit active users matching the words of text, most relevant first. When no user
+	// has any of the words, the users with words a few typos away from them are returned instead
+	Search(ctx context.Context, text string, limit int) ([]Hit, error)
+}
@@ -2,30 +2,45 @@ package server
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"github.com/joomcode/errorx"
 	"github.com/labstack/echo/v4"
 	"github.com/labstack/echo/v4/middleware"
 	_ "github.com/rhuandantas/verifymy-test/docs"
 	"github.com/rhuandantas/verifymy-test/internal/config"
+	"github.com/rhuandantas/verifymy-test/internal/i18n"
+	"github.com/rhuandantas/verifymy-test/internal/jobs"
 	"github.com/rhuandantas/verifymy-test/internal/log"
+	serverErr "github.com/rhuandantas/verifymy-test/internal/server/error"
 	"github.com/rhuandantas/verifymy-test/internal/server/handlers"
 	echoSwagger "github.com/swaggo/echo-swagger"
 	"go.uber.org/zap"
+	"net/http"
 )
 
 type HttpServer struct {
-	appName       *string
-	host          string
-	Server        *echo.Echo
-	config        config.ConfigProvider
-	logger        log.SimpleLogger
-	userHandler   *handlers.UserHandler
-	healthHandler *handlers.HealthCheck
+	appName             *string
+	host                string
+	Server              *echo.Echo
+	config              config.ConfigProvider
+	logger              log.SimpleLogger
+	scheduler           *jobs.Scheduler
+	userHandler         *handlers.UserHandler
+	searchHandler       *handlers.SearchHandler
+	addressHandler      *handlers.AddressHandler
+	verificationHandler *handlers.VerificationHandler
+	attestationHandler  *handlers.AttestationHandler
+	eligibilityHandler  *handlers.EligibilityHandler
+	reviewHandler       *handlers.ReviewHandler
+	guardianHandler     *handlers.GuardianHandler
+	documentHandler     *handlers.DocumentHandler
+	clientHandler       *handlers.ClientHandler
+	healthHandler       *handlers.HealthCheck
 }
 
 // NewAPIServer creates the main server with all configurations necessary
-func NewAPIServer(config config.ConfigProvider, logger log.SimpleLogger, userHandler *handlers.UserHandler, healthHandler *handlers.HealthCheck) *HttpServer {
+func NewAPIServer(config config.ConfigProvider, logger log.SimpleLogger, translator i18n.Translator, scheduler *jobs.Scheduler, userHandler *handlers.UserHandler, searchHandler *handlers.SearchHandler, addressHandler *handlers.AddressHandler, verificationHandler *handlers.VerificationHandler, attestationHandler *handlers.AttestationHandler, eligibilityHandler *handlers.EligibilityHandler, reviewHandler *handlers.ReviewHandler, guardianHandler *handlers.GuardianHandler, documentHandler *handlers.DocumentHandler, clientHandler *handlers.ClientHandler, healthHandler *handlers.HealthCheck) *HttpServer {
 	appName := config.GetStringOrDefault("app.name", "verify-my-service")
 	host := config.GetStringOrDefault("server.host", "0.0.0.0:8080")
 
@@ -35,9 +50,12 @@ func NewAPIServer(config config.ConfigProvider, logger log.SimpleLogger, userHan
 	app.HidePort = true
 
 	app.Pre(middleware.RemoveTrailingSlash())
+	app.Use(serverErr.Localize(translator))
 	app.Use(middleware.GzipWithConfig(middleware.GzipConfig{Level: 5}))
 	app.Use(middleware.Recover())
-	app.Use(middleware.CORS())
+	app.Use(middleware.CORSWithConfig(middleware.CORSConfig{
+		ExposeHeaders: []string{"ETag", "Link"},
+	}))
 	app.Use(middleware.RateLimiter(middleware.NewRateLimiterMemoryStore(20)))
 	app.Use(middleware.RequestLoggerWithConfig(middleware.RequestLoggerConfig{
 		LogURI:    true,
@@ -55,28 +73,57 @@ func NewAPIServer(config config.ConfigProvider, logger log.SimpleLogger, userHan
 	app.GET("/swagger/*", echoSwagger.WrapHandler)
 
 	return &HttpServer{
-		appName:       &appName,
-		host:          host,
-		Server:        app,
-		config:        config,
-		logger:        logger,
-		userHandler:   userHandler,
-		healthHandler: healthHandler,
+		appName:             &appName,
+		host:                host,
+		Server:              app,
+		config:              config,
+		logger:              logger,
+		scheduler:           scheduler,
+		userHandler:         userHandler,
+		searchHandler:       searchHandler,
+		addressHandler:      addressHandler,
+		verificationHandler: verificationHandler,
+		attestationHandler:  attestationHandler,
+		eligibilityHandler:  eligibilityHandler,
+		reviewHandler:       reviewHandler,
+		guardianHandler:     guardianHandler,
+		documentHandler:     documentHandler,
+		clientHandler:       clientHandler,
+		healthHandler:       healthHandler,
 	}
 }
 
 func (hs *HttpServer) RegisterHandlers() {
 	hs.userHandler.RegisterRoutes(hs.Server)
+	hs.searchHandler.RegisterRoutes(hs.Server)
+	hs.addressHandler.RegisterRoutes(hs.Server)
+	hs.verificationHandler.RegisterRoutes(hs.Server)
+	hs.attestationHandler.RegisterRoutes(hs.Server)
+	hs.eligibilityHandler.RegisterRoutes(hs.Server)
+	hs.reviewHandler.RegisterRoutes(hs.Server)
+	hs.guardianHandler.RegisterRoutes(hs.Server)
+	hs.documentHandler.RegisterRoutes(hs.Server)
+	hs.clientHandler.RegisterRoutes(hs.Server)
 	hs.healthHandler.RegisterHealth(hs.Server)
 }
 
-// Start starts an application on specific port
+// Start starts the background jobs and an application on specific port, until Shutdown is called
 func (hs *HttpServer) Start() {
 	ctx := context.Background()
+	hs.scheduler.Start(ctx)
+
 	hs.logger.Info(ctx, fmt.Sprintf("Starting a server at http://%s", hs.host))
 	err := hs.Server.Start(hs.host)
-	if err != nil {
+	if err != nil && !errors.Is(err, http.ErrServerClosed) {
 		hs.logger.Error(ctx, errorx.Decorate(err, "Failed to start the server"))
 		return
 	}
 }
+
+// Shutdown stops the application once the requests in flight are answered, then the background jobs
+func (hs *HttpServer) Shutdown(ctx context.Context) {
+	if err := hs.Server.Shutdown(ctx); err != nil {
+		hs.logger.Error(ctx, errorx.Decorate(err, "Failed to shutdown the server"))
+	}
+	hs.scheduler.Stop(ctx)
+}
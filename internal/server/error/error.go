@@ -5,28 +5,95 @@ import (
 	jsoniter "github.com/json-iterator/go"
 	"github.com/labstack/echo/v4"
 	"github.com/rhuandantas/verifymy-test/internal/errors"
+	"github.com/rhuandantas/verifymy-test/internal/i18n"
 	"net/http"
+	"strings"
 	"time"
 )
 
-const layout = "2006-01-02T15:04:05.999999Z07:00"
+const (
+	layout = "2006-01-02T15:04:05.999999Z07:00"
+	// MIMEApplicationProblemJSON is the media type of RFC 7807 problem details
+	MIMEApplicationProblemJSON = "application/problem+json"
+	problemTypePrefix          = "/problems/"
+)
 
+// ErrorResponse is an RFC 7807 problem details object, Code, Timestamp, Reason, Link and Quota are extension
+// members. Reason, Link and Quota are only set by the errors carrying them, e.g. an age restricted route tells
+// why the caller was denied and where to start a verification, an exceeded quota tells the plan and its limit
 type ErrorResponse struct {
-	StatusCode int    `json:"-"`
-	Status     string `json:"status"`
-	Message    string `json:"message"`
-	Timestamp  string `json:"timestamp"`
+	Type      string              `json:"type"`
+	Title     string              `json:"title"`
+	Status    int                 `json:"status"`
+	Detail    string              `json:"detail"`
+	Instance  string              `json:"instance,omitempty"`
+	Code      string              `json:"code"`
+	Timestamp string              `json:"timestamp"`
+	Errors    []errors.FieldError `json:"errors,omitempty"`
+	Reason    string              `json:"reason,omitempty"`
+	Link      string              `json:"link,omitempty"`
+	Quota     *errors.Quota       `json:"quota,omitempty"`
 }
 
-func NewErrorResponse(error *errorx.Error) ErrorResponse {
+// TranslatorContextKey holds the translator localizing the error responses of a request, see Localize
+const TranslatorContextKey = "translator"
+
+// Localize has translator localize the error responses of every request
+func Localize(translator i18n.Translator) echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			c.Set(TranslatorContextKey, translator)
+			return next(c)
+		}
+	}
+}
+
+// NewErrorResponse builds the problem details of the error, title, detail and field messages are localized by
+// translator to the first of the requested locales it supports. They stay in english without a translator
+func NewErrorResponse(translator i18n.Translator, error *errorx.Error, locales ...string) ErrorResponse {
 	status := getHttpCode(error)
+	code := error.Type().FullName()
+	errResponse := ErrorResponse{
+		Type:      problemTypePrefix + code,
+		Title:     http.StatusText(status),
+		Status:    status,
+		Detail:    error.Message(),
+		Code:      code,
+		Timestamp: time.Now().Format(layout),
+		Errors:    errors.FieldErrors(error),
+		Reason:    errors.Reason(error),
+		Link:      errors.Link(error),
+		Quota:     errors.QuotaOf(error),
+	}
 
-	return ErrorResponse{
-		StatusCode: status,
-		Status:     http.StatusText(status),
-		Message:    error.Message(),
-		Timestamp:  time.Now().Format(layout),
+	if translator == nil {
+		return errResponse
 	}
+
+	locale := translator.Locale(locales...)
+	if title, ok := translator.Translate(locale, "errors."+code); ok {
+		errResponse.Title = title
+	}
+
+	if detail := errors.DetailOf(error); detail != nil {
+		if message, ok := translator.Translate(locale, "details."+detail.Key, detail.Params...); ok {
+			errResponse.Detail = message
+		}
+	}
+
+	fields := make([]errors.FieldError, 0, len(errResponse.Errors))
+	for _, field := range errResponse.Errors {
+		field.Message = i18n.FieldMessage(translator, locale, lastSegment(field.Field), field.Rule, field.Param)
+		fields = append(fields, field)
+	}
+	errResponse.Errors = fields
+
+	return errResponse
+}
+
+// lastSegment gives the field name of a nested path, e.g. city for address.city
+func lastSegment(path string) string {
+	return path[strings.LastIndex(path, ".")+1:]
 }
 
 func getHttpCode(err *errorx.Error) int {
@@ -37,14 +104,55 @@ func getHttpCode(err *errorx.Error) int {
 		return 404
 	case err.IsOfType(errors.Unauthorized):
 		return 401
+	case err.IsOfType(errors.Forbidden):
+		return 403
+	case err.IsOfType(errors.Conflict):
+		return 409
+	case err.IsOfType(errors.ConstraintViolation):
+		return 422
+	case err.IsOfType(errors.PreconditionFailed):
+		return 412
+	case err.IsOfType(errors.UnsupportedMedia):
+		return 415
+	case err.IsOfType(errors.TooManyRequests):
+		return 429
 	default:
 		return 500
 	}
 }
 
+// FromError keeps typed errors as they are and treats everything else as an internal error
+func FromError(err error) *errorx.Error {
+	if e := errorx.Cast(err); e != nil {
+		return e
+	}
+
+	return errorx.InternalError.New(err.Error())
+}
+
+// FromValidationError keeps typed errors as they are and treats everything else as a bad request
+func FromValidationError(err error) *errorx.Error {
+	if e := errorx.Cast(err); e != nil {
+		return e
+	}
+
+	return errors.BadRequest.New(err.Error())
+}
+
+// RequestErrorResponse builds the problem details of the error for the request, localized by the translator of
+// the request to its Accept-Language
+func RequestErrorResponse(ctx echo.Context, err *errorx.Error) ErrorResponse {
+	translator, _ := ctx.Get(TranslatorContextKey).(i18n.Translator)
+	errResponse := NewErrorResponse(translator, err, i18n.AcceptLanguage(ctx.Request().Header.Get(i18n.HeaderAcceptLanguage))...)
+	errResponse.Instance = ctx.Request().URL.Path
+
+	return errResponse
+}
+
 func HandleError(ctx echo.Context, err *errorx.Error) error {
-	errResponse := NewErrorResponse(err)
-	return ctx.JSON(errResponse.StatusCode, errResponse)
+	errResponse := RequestErrorResponse(ctx, err)
+	ctx.Response().Header().Set(echo.HeaderContentType, MIMEApplicationProblemJSON)
+	return ctx.JSON(errResponse.Status, errResponse)
 }
 
 func ResponseJson(ctx echo.Context, o interface{}) error {
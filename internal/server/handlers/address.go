@@ -0,0 +1,252 @@
+package handlers
+
+import (
+	"context"
+	"strconv"
+	"strings"
+
+	"github.com/labstack/echo/v4"
+	errx "github.com/rhuandantas/verifymy-test/internal/errors"
+	"github.com/rhuandantas/verifymy-test/internal/models"
+	"github.com/rhuandantas/verifymy-test/internal/repo"
+	serverErr "github.com/rhuandantas/verifymy-test/internal/server/error"
+	"github.com/rhuandantas/verifymy-test/internal/server/middlewares/auth"
+	"github.com/rhuandantas/verifymy-test/internal/util"
+)
+
+// expandAddresses embeds the addresses of the users with ?expand=addresses
+const expandAddresses = "addresses"
+
+type AddressHandler struct {
+	validator   util.Validator
+	userRepo    repo.UserRepo
+	addressRepo repo.AddressRepo
+	token       auth.Token
+	signature   auth.Signature
+}
+
+func NewAddressHandler(validator util.Validator, userRepo repo.UserRepo, addressRepo repo.AddressRepo, jwt auth.Token, signature auth.Signature) *AddressHandler {
+	return &AddressHandler{
+		validator:   validator,
+		userRepo:    userRepo,
+		addressRepo: addressRepo,
+		token:       jwt,
+		signature:   signature,
+	}
+}
+
+func (ah *AddressHandler) RegisterRoutes(server *echo.Echo) {
+	g := server.Group("/users/:id/addresses", auth.Authenticate(ah.token, ah.signature))
+	g.GET("", ah.List)
+	g.POST("", ah.Create)
+	g.GET("/:address_id", ah.Get)
+	g.PUT("/:address_id", ah.Update)
+	g.DELETE("/:address_id", ah.Delete)
+}
+
+// List godoc
+// @Summary      List the addresses of a user
+// @Tags         Addresses
+// @Produce      json
+// @Param        id   path      int  true  "user id"
+// @Security JWT
+// @Success      200  {array}  models.Address
+// @Failure      400,401,404,500  {object}  error.ErrorResponse
+// @Router       /users/{id}/addresses [get]
+func (ah *AddressHandler) List(ctx echo.Context) error {
+	userId, err := ah.userParam(ctx)
+	if err != nil {
+		return serverErr.HandleError(ctx, serverErr.FromError(err))
+	}
+
+	res, err := ah.addressRepo.List(ctx.Request().Context(), userId)
+	if err != nil {
+		return serverErr.HandleError(ctx, serverErr.FromError(err))
+	}
+
+	return serverErr.ResponseJson(ctx, res)
+}
+
+// Create godoc
+// @Summary      Add an address to a user
+// @Description  A user has at most one address of each type, home or billing. The postal code must follow the format of the country
+// @Tags         Addresses
+// @Accept       json
+// @Produce      json
+// @Param        id   path      int  true  "user id"
+// @Param        address body models.Address true "address struct"
+// @Security JWT
+// @Success      200  {object}  models.Address
+// @Failure      400,401,404,409,500  {object}  error.ErrorResponse
+// @Router       /users/{id}/addresses [post]
+func (ah *AddressHandler) Create(ctx echo.Context) error {
+	userId, err := ah.userParam(ctx)
+	if err != nil {
+		return serverErr.HandleError(ctx, serverErr.FromError(err))
+	}
+
+	address, err := ah.bindAddress(ctx)
+	if err != nil {
+		return serverErr.HandleError(ctx, serverErr.FromValidationError(err))
+	}
+	address.UserId = userId
+
+	res, err := ah.addressRepo.Create(ctx.Request().Context(), *address)
+	if err != nil {
+		return serverErr.HandleError(ctx, serverErr.FromError(err))
+	}
+
+	return serverErr.ResponseJson(ctx, res)
+}
+
+// Get godoc
+// @Summary      Retrieve an address of a user
+// @Tags         Addresses
+// @Produce      json
+// @Param        id   path      int  true  "user id"
+// @Param        address_id   path      int  true  "address id"
+// @Security JWT
+// @Success      200  {object}  models.Address
+// @Failure      400,401,404,500  {object}  error.ErrorResponse
+// @Router       /users/{id}/addresses/{address_id} [get]
+func (ah *AddressHandler) Get(ctx echo.Context) error {
+	userId, addressId, err := ah.addressParams(ctx)
+	if err != nil {
+		return serverErr.HandleError(ctx, serverErr.FromError(err))
+	}
+
+	res, err := ah.addressRepo.Get(ctx.Request().Context(), userId, addressId)
+	if err != nil {
+		return serverErr.HandleError(ctx, serverErr.FromError(err))
+	}
+
+	return serverErr.ResponseJson(ctx, res)
+}
+
+// Update godoc
+// @Summary      Replace an address of a user
+// @Tags         Addresses
+// @Accept       json
+// @Produce      json
+// @Param        id   path      int  true  "user id"
+// @Param        address_id   path      int  true  "address id"
+// @Param        address body models.Address true "address struct"
+// @Security JWT
+// @Success      200  {object}  models.Address
+// @Failure      400,401,404,409,500  {object}  error.ErrorResponse
+// @Router       /users/{id}/addresses/{address_id} [put]
+func (ah *AddressHandler) Update(ctx echo.Context) error {
+	userId, addressId, err := ah.addressParams(ctx)
+	if err != nil {
+		return serverErr.HandleError(ctx, serverErr.FromError(err))
+	}
+
+	address, err := ah.bindAddress(ctx)
+	if err != nil {
+		return serverErr.HandleError(ctx, serverErr.FromValidationError(err))
+	}
+	address.UserId = userId
+	address.AddressId = addressId
+
+	res, err := ah.addressRepo.Update(ctx.Request().Context(), *address)
+	if err != nil {
+		return serverErr.HandleError(ctx, serverErr.FromError(err))
+	}
+
+	return serverErr.ResponseJson(ctx, res)
+}
+
+// Delete godoc
+// @Summary      Remove an address of a user
+// @Tags         Addresses
+// @Produce      json
+// @Param        id   path      int  true  "user id"
+// @Param        address_id   path      int  true  "address id"
+// @Security JWT
+// @Success      200  {string}  "address deleted"
+// @Failure      400,401,404,500  {object}  error.ErrorResponse
+// @Router       /users/{id}/addresses/{address_id} [delete]
+func (ah *AddressHandler) Delete(ctx echo.Context) error {
+	userId, addressId, err := ah.addressParams(ctx)
+	if err != nil {
+		return serverErr.HandleError(ctx, serverErr.FromError(err))
+	}
+
+	if err = ah.addressRepo.Delete(ctx.Request().Context(), userId, addressId); err != nil {
+		return serverErr.HandleError(ctx, serverErr.FromError(err))
+	}
+
+	return serverErr.ResponseJson(ctx, echo.Map{
+		"deleted": true,
+	})
+}
+
+// userParam reads the user id of the path and checks the user exists
+func (ah *AddressHandler) userParam(ctx echo.Context) (int, error) {
+	userId, err := strconv.Atoi(ctx.Param("id"))
+	if err != nil {
+		return 0, errx.BadRequest.New(err.Error())
+	}
+
+	if _, err = ah.userRepo.GetByID(ctx.Request().Context(), userId, "user_id"); err != nil {
+		return 0, err
+	}
+
+	return userId, nil
+}
+
+func (ah *AddressHandler) addressParams(ctx echo.Context) (int, int, error) {
+	userId, err := ah.userParam(ctx)
+	if err != nil {
+		return 0, 0, err
+	}
+
+	addressId, err := strconv.Atoi(ctx.Param("address_id"))
+	if err != nil {
+		return 0, 0, errx.BadRequest.New(err.Error())
+	}
+
+	return userId, addressId, nil
+}
+
+// bindAddress reads and validates the address of the body, country codes and postal codes are upper cased
+func (ah *AddressHandler) bindAddress(ctx echo.Context) (*models.Address, error) {
+	var address models.Address
+	if err := ctx.Bind(&address); err != nil {
+		return nil, errx.BadRequest.New(err.Error())
+	}
+
+	address.Country = strings.ToUpper(strings.TrimSpace(address.Country))
+	address.PostalCode = strings.ToUpper(strings.TrimSpace(address.PostalCode))
+	if err := ah.validator.ValidateStruct(address); err != nil {
+		return nil, err
+	}
+
+	return &address, nil
+}
+
+// addressesExpander embeds the addresses of each user, an empty list for users without any
+func addressesExpander(addressRepo repo.AddressRepo) expander {
+	return func(ctx context.Context, users []*models.User) (map[int]interface{}, error) {
+		userIds := make([]int, 0, len(users))
+		for _, user := range users {
+			userIds = append(userIds, user.UserId)
+		}
+
+		byUser, err := addressRepo.ListByUsers(ctx, userIds)
+		if err != nil {
+			return nil, err
+		}
+
+		addresses := make(map[int]interface{}, len(users))
+		for _, userId := range userIds {
+			if list, ok := byUser[userId]; ok {
+				addresses[userId] = list
+			} else {
+				addresses[userId] = []*models.Address{}
+			}
+		}
+
+		return addresses, nil
+	}
+}
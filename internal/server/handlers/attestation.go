@@ -0,0 +1,166 @@
+package handlers
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"strconv"
+	"time"
+
+	"github.com/golang-jwt/jwt/v4"
+	"github.com/labstack/echo/v4"
+	"github.com/rhuandantas/verifymy-test/internal/clients"
+	"github.com/rhuandantas/verifymy-test/internal/config"
+	errx "github.com/rhuandantas/verifymy-test/internal/errors"
+	"github.com/rhuandantas/verifymy-test/internal/models"
+	"github.com/rhuandantas/verifymy-test/internal/repo"
+	serverErr "github.com/rhuandantas/verifymy-test/internal/server/error"
+	"github.com/rhuandantas/verifymy-test/internal/server/middlewares/auth"
+	"github.com/rhuandantas/verifymy-test/internal/util"
+	"github.com/rhuandantas/verifymy-test/internal/verification"
+	"github.com/rhuandantas/verifymy-test/pkg/attestation"
+)
+
+const defaultAttestationTTLSeconds = 300
+
+type AttestationHandler struct {
+	validator util.Validator
+	userRepo  repo.UserRepo
+	service   verification.Service
+	attester  auth.Attester
+	meter     clients.Meter
+	token     auth.Token
+	signature auth.Signature
+	ttl       time.Duration
+	now       func() time.Time
+}
+
+// NewAttestationHandler reads how long attestations last from auth.attestation.ttl-seconds, five minutes by default
+func NewAttestationHandler(config config.ConfigProvider, validator util.Validator, userRepo repo.UserRepo, service verification.Service, attester auth.Attester, meter clients.Meter, jwt auth.Token, signature auth.Signature) *AttestationHandler {
+	ttlSeconds := config.GetInt("auth.attestation.ttl-seconds")
+	if ttlSeconds <= 0 {
+		ttlSeconds = defaultAttestationTTLSeconds
+	}
+
+	return &AttestationHandler{
+		validator: validator,
+		userRepo:  userRepo,
+		service:   service,
+		attester:  attester,
+		meter:     meter,
+		token:     jwt,
+		signature: signature,
+		ttl:       time.Duration(ttlSeconds) * time.Second,
+		now:       time.Now,
+	}
+}
+
+func (ah *AttestationHandler) RegisterRoutes(server *echo.Echo) {
+	server.POST("/users/:id/attestations", ah.Issue, auth.Authenticate(ah.token, ah.signature), auth.RequirePermission(auth.PermissionAttestUsers), auth.RequireQuota(ah.meter, models.MetricTokens))
+	server.GET(attestation.KeySetPath, ah.KeySet)
+}
+
+// Issue godoc
+// @Summary      Issue an age attestation of a verified user
+// @Description  Signs a short lived ES256 token telling whether the user is over each of the ages and how sure the verification is, without the user id nor any other personal data. Relying parties check it offline against the published key set, e.g. with the pkg/attestation package. Minors without a guardian's consent get none. Needs the users:attest permission, counts as a token of the daily quota of a client
+// @Tags         Verification
+// @Accept       json
+// @Produce      json
+// @Param        id   path      int  true  "user id"
+// @Param        attestation body models.AttestationRequest true "ages to attest"
+// @Security JWT
+// @Success      200  {object}  models.Attestation
+// @Failure      400,401,403,404,409,429,500  {object}  error.ErrorResponse
+// @Router       /users/{id}/attestations [post]
+func (ah *AttestationHandler) Issue(ctx echo.Context) error {
+	userId, err := strconv.Atoi(ctx.Param("id"))
+	if err != nil {
+		return serverErr.HandleError(ctx, errx.BadRequest.New(err.Error()))
+	}
+
+	var request models.AttestationRequest
+	if err = ctx.Bind(&request); err != nil {
+		return serverErr.HandleError(ctx, errx.BadRequest.New(err.Error()))
+	}
+
+	if err = ah.validator.ValidateStruct(request); err != nil {
+		return serverErr.HandleError(ctx, serverErr.FromValidationError(err))
+	}
+
+	res, err := ah.issue(ctx, userId, request)
+	if err != nil {
+		return serverErr.HandleError(ctx, serverErr.FromError(err))
+	}
+
+	return serverErr.ResponseJson(ctx, res)
+}
+
+// KeySet godoc
+// @Summary      Key set verifying the age attestations
+// @Description  JSON Web Key Set of the public keys signing the attestations, keys are identified by the kid header of the tokens
+// @Tags         Verification
+// @Produce      json
+// @Success      200  {object}  attestation.KeySet
+// @Router       /.well-known/jwks.json [get]
+func (ah *AttestationHandler) KeySet(ctx echo.Context) error {
+	ctx.Response().Header().Set(echo.HeaderCacheControl, "public, max-age=3600")
+	return serverErr.ResponseJson(ctx, ah.attester.KeySet())
+}
+
+// issue attests the ages of the user as of today, from the date of birth and timezone they were verified with.
+// The attestation never outlives the verification behind it
+func (ah *AttestationHandler) issue(ctx echo.Context, userId int, request models.AttestationRequest) (*models.Attestation, error) {
+	status, err := ah.service.Get(ctx.Request().Context(), userId)
+	if err != nil {
+		return nil, err
+	}
+	if status.State != models.VerificationVerified {
+		return nil, errx.Localized(errx.NotVerified, "verification.user_not_verified", "user %d is %s", userId, status.State)
+	}
+
+	user, err := ah.userRepo.GetByID(ctx.Request().Context(), userId, "status")
+	if err != nil {
+		return nil, err
+	}
+	if user.Status == models.UserRestricted {
+		return nil, errx.Localized(errx.Forbidden, "guardians.consent_missing", "user %d is a minor without a guardian's consent", userId)
+	}
+
+	now := ah.now()
+	verifiedAge := status.AgeAt(now)
+	if verifiedAge == nil {
+		return nil, errx.Localized(errx.NotVerified, "verification.no_date_of_birth", "verification of user %d holds no date of birth", userId)
+	}
+
+	age := *verifiedAge
+	claims := &attestation.Claims{
+		AgeOver:        make(map[int]bool, len(request.AgeOver)),
+		AssuranceLevel: status.AssuranceLevel,
+	}
+	for _, over := range request.AgeOver {
+		claims.AgeOver[over] = age >= over
+	}
+
+	expiresAt := now.Add(ah.ttl)
+	if status.ExpiresAt != nil && status.ExpiresAt.Before(expiresAt) {
+		expiresAt = *status.ExpiresAt
+	}
+
+	id := make([]byte, 16)
+	if _, err = rand.Read(id); err != nil {
+		return nil, err
+	}
+	claims.ID = hex.EncodeToString(id)
+	claims.IssuedAt = jwt.NewNumericDate(now)
+	claims.NotBefore = jwt.NewNumericDate(now)
+	claims.ExpiresAt = jwt.NewNumericDate(expiresAt)
+	if request.Audience != "" {
+		claims.Audience = jwt.ClaimStrings{request.Audience}
+	}
+
+	token, err := ah.attester.Sign(claims)
+	if err != nil {
+		return nil, err
+	}
+
+	return &models.Attestation{Token: token, ExpiresAt: claims.ExpiresAt.Time}, nil
+}
@@ -0,0 +1,360 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/joomcode/errorx"
+	"github.com/labstack/echo/v4"
+	errx "github.com/rhuandantas/verifymy-test/internal/errors"
+	"github.com/rhuandantas/verifymy-test/internal/guardian"
+	"github.com/rhuandantas/verifymy-test/internal/models"
+	"github.com/rhuandantas/verifymy-test/internal/patch"
+	"github.com/rhuandantas/verifymy-test/internal/repo"
+	serverErr "github.com/rhuandantas/verifymy-test/internal/server/error"
+)
+
+const (
+	// BatchTransactional applies every item of a batch or none of them
+	BatchTransactional = "transactional"
+	// BatchBestEffort applies every item it can and reports the ones that failed
+	BatchBestEffort = "best_effort"
+
+	defaultBatchMaxSize    = 1000
+	defaultBatchInsertSize = 100
+)
+
+type BatchCreateRequest struct {
+	// Mode is transactional, the default, or best_effort
+	Mode  string        `json:"mode"`
+	Items []models.User `json:"items"`
+}
+
+type BatchUpdateItem struct {
+	UserId int `json:"user_id"`
+	// Version works as If-Match does for a single update, 0 skips the check
+	Version int `json:"version"`
+	// Patch is a JSON Merge Patch of the user
+	Patch json.RawMessage `json:"patch" swaggertype:"object"`
+}
+
+type BatchUpdateRequest struct {
+	Mode  string            `json:"mode"`
+	Items []BatchUpdateItem `json:"items"`
+}
+
+type BatchDeleteRequest struct {
+	Mode    string `json:"mode"`
+	UserIds []int  `json:"user_ids"`
+}
+
+// BatchResult is the outcome of a single item, Status is the http status the item would have had on its own,
+// items of a failed transactional batch that weren't the cause of the failure get 424
+type BatchResult struct {
+	Index  int                      `json:"index"`
+	Status int                      `json:"status"`
+	UserId int                      `json:"user_id,omitempty"`
+	User   *models.User             `json:"user,omitempty"`
+	Error  *serverErr.ErrorResponse `json:"error,omitempty"`
+}
+
+type BatchResponse struct {
+	Mode      string        `json:"mode"`
+	Succeeded int           `json:"succeeded"`
+	Failed    int           `json:"failed"`
+	Results   []BatchResult `json:"results"`
+}
+
+// BatchCreate godoc
+// @Summary Create users in batch.
+// @Description In transactional mode either every user is created or none is, in best_effort mode each user is created on its own
+// @Tags Users
+// @Accept json
+// @Produce json
+// @Param batch body BatchCreateRequest true "users to create"
+// @Security JWT
+// @Success 	 200,207  {object} BatchResponse
+// @Failure      400,401,409,422,500  {object}  BatchResponse
+// @Router /users:batchCreate [post]
+func (uh *UserHandler) BatchCreate(ctx echo.Context) error {
+	var request BatchCreateRequest
+	if err := ctx.Bind(&request); err != nil {
+		return serverErr.HandleError(ctx, errx.BadRequest.New(err.Error()))
+	}
+
+	if err := uh.validateBatch(request.Mode, len(request.Items)); err != nil {
+		return serverErr.HandleError(ctx, err)
+	}
+
+	batch := newBatch(ctx, request.Mode, len(request.Items))
+	valid := make([]int, 0, len(request.Items))
+	minors := make([]int, 0)
+	for i, user := range request.Items {
+		if err := uh.validator.ValidateStruct(user); err != nil {
+			batch.fail(i, 0, serverErr.FromValidationError(err))
+			continue
+		}
+		if uh.consents.Restrict(&request.Items[i]) {
+			if err := guardian.ValidateGuardianEmail(user.Email, user.GuardianEmail); err != nil {
+				batch.fail(i, 0, serverErr.FromError(err))
+				continue
+			}
+			minors = append(minors, i)
+		}
+		valid = append(valid, i)
+	}
+
+	if batch.mode == BatchTransactional {
+		if batch.failed == 0 {
+			batch.createAll(uh.userRepo, request.Items, valid, uh.batchInsertSize)
+		}
+	} else {
+		for start := 0; start < len(valid); start += uh.batchInsertSize {
+			end := start + uh.batchInsertSize
+			if end > len(valid) {
+				end = len(valid)
+			}
+			batch.createChunk(uh.userRepo, request.Items, valid[start:end])
+		}
+	}
+
+	// like on their own, the minors created get their guardian invited
+	for _, i := range minors {
+		if created := batch.results[i].User; created != nil {
+			created.GuardianInvitation = uh.inviteGuardian(ctx, created.UserId, request.Items[i].GuardianEmail)
+		}
+	}
+
+	return batch.respond()
+}
+
+// BatchUpdate godoc
+// @Summary Update users in batch.
+// @Description Each item carries a JSON Merge Patch of a user, in transactional mode either every user is updated or none is
+// @Tags Users
+// @Accept json
+// @Produce json
+// @Param batch body BatchUpdateRequest true "patches to apply"
+// @Security JWT
+// @Success 	 200,207  {object} BatchResponse
+// @Failure      400,401,404,409,412,422,500  {object}  BatchResponse
+// @Router /users:batchUpdate [patch]
+func (uh *UserHandler) BatchUpdate(ctx echo.Context) error {
+	var request BatchUpdateRequest
+	if err := ctx.Bind(&request); err != nil {
+		return serverErr.HandleError(ctx, errx.BadRequest.New(err.Error()))
+	}
+
+	if err := uh.validateBatch(request.Mode, len(request.Items)); err != nil {
+		return serverErr.HandleError(ctx, err)
+	}
+
+	batch := newBatch(ctx, request.Mode, len(request.Items))
+	batch.run(uh.userRepo, func(userRepo repo.UserRepo, i int) (int, *models.User, error) {
+		item := request.Items[i]
+		user, err := uh.patchUser(ctx.Request().Context(), userRepo, item.UserId, item.Version, patch.MIMEApplicationMergePatchJSON, item.Patch)
+		return item.UserId, user, err
+	})
+
+	return batch.respond()
+}
+
+// BatchDelete godoc
+// @Summary Delete users in batch.
+// @Description In transactional mode either every user is deleted or none is
+// @Tags Users
+// @Accept json
+// @Produce json
+// @Param batch body BatchDeleteRequest true "ids of the users to delete"
+// @Security JWT
+// @Success 	 200,207  {object} BatchResponse
+// @Failure      400,401,404,500  {object}  BatchResponse
+// @Router /users:batchDelete [post]
+func (uh *UserHandler) BatchDelete(ctx echo.Context) error {
+	var request BatchDeleteRequest
+	if err := ctx.Bind(&request); err != nil {
+		return serverErr.HandleError(ctx, errx.BadRequest.New(err.Error()))
+	}
+
+	if err := uh.validateBatch(request.Mode, len(request.UserIds)); err != nil {
+		return serverErr.HandleError(ctx, err)
+	}
+
+	batch := newBatch(ctx, request.Mode, len(request.UserIds))
+	batch.run(uh.userRepo, func(userRepo repo.UserRepo, i int) (int, *models.User, error) {
+		_, err := userRepo.Delete(ctx.Request().Context(), request.UserIds[i])
+		return request.UserIds[i], nil, err
+	})
+
+	return batch.respond()
+}
+
+func (uh *UserHandler) validateBatch(mode string, size int) *errorx.Error {
+	if mode != "" && mode != BatchTransactional && mode != BatchBestEffort {
+		return errx.Localized(errx.BadRequest, "batch.mode", "mode must be %s or %s", BatchTransactional, BatchBestEffort)
+	}
+
+	if size == 0 {
+		return errx.Localized(errx.BadRequest, "batch.empty", "batch must have at least one item")
+	}
+
+	if size > uh.batchMaxSize {
+		return errx.Localized(errx.BadRequest, "batch.too_large", "batch can't have more than %d items", uh.batchMaxSize)
+	}
+
+	return nil
+}
+
+// batch collects the results of a batch request
+type batch struct {
+	ctx     echo.Context
+	mode    string
+	results []BatchResult
+	failed  int
+	// status is the one of the first failure, which is the response status of a failed transactional batch
+	status int
+}
+
+func newBatch(ctx echo.Context, mode string, size int) *batch {
+	if mode == "" {
+		mode = BatchTransactional
+	}
+
+	return &batch{
+		ctx:     ctx,
+		mode:    mode,
+		results: make([]BatchResult, size),
+	}
+}
+
+func (b *batch) succeed(i, userId int, user *models.User) {
+	if user != nil {
+		user.Password = ""
+		userId = user.UserId
+	}
+
+	b.results[i] = BatchResult{Index: i, Status: http.StatusOK, UserId: userId, User: user}
+}
+
+func (b *batch) fail(i, userId int, err *errorx.Error) {
+	problem := serverErr.RequestErrorResponse(b.ctx, err)
+	b.results[i] = BatchResult{Index: i, Status: problem.Status, UserId: userId, Error: &problem}
+	b.failed++
+	if b.status == 0 {
+		b.status = problem.Status
+	}
+}
+
+// run applies op to every item, in transactional mode inside a single transaction that stops at the first failure
+func (b *batch) run(userRepo repo.UserRepo, op func(userRepo repo.UserRepo, i int) (int, *models.User, error)) {
+	if b.mode == BatchBestEffort {
+		for i := range b.results {
+			userId, user, err := op(userRepo, i)
+			if err != nil {
+				b.fail(i, userId, serverErr.FromError(err))
+				continue
+			}
+			b.succeed(i, userId, user)
+		}
+		return
+	}
+
+	failed := -1
+	err := userRepo.Transaction(b.ctx.Request().Context(), func(tx repo.UserRepo) error {
+		for i := range b.results {
+			userId, user, err := op(tx, i)
+			if err != nil {
+				failed = i
+				b.fail(i, userId, serverErr.FromError(err))
+				return err
+			}
+			b.succeed(i, userId, user)
+		}
+		return nil
+	})
+
+	if err != nil && failed < 0 {
+		// the commit itself failed, no item in particular is to blame
+		for i := range b.results {
+			b.fail(i, b.results[i].UserId, serverErr.FromError(err))
+		}
+	}
+}
+
+// createAll inserts the users at the valid indexes all together, a failure can't be tied to a single user
+// so every one of them gets it
+func (b *batch) createAll(userRepo repo.UserRepo, users []models.User, valid []int, insertSize int) {
+	created, err := userRepo.CreateBatch(b.ctx.Request().Context(), pick(users, valid), insertSize)
+	if err != nil {
+		for _, i := range valid {
+			b.fail(i, 0, serverErr.FromError(err))
+		}
+		return
+	}
+
+	for n, i := range valid {
+		b.succeed(i, 0, created[n])
+	}
+}
+
+// createChunk inserts the users at the given indexes with a single statement, when it fails they are created
+// one by one to find out which of them can't be
+func (b *batch) createChunk(userRepo repo.UserRepo, users []models.User, indexes []int) {
+	created, err := userRepo.CreateBatch(b.ctx.Request().Context(), pick(users, indexes), len(indexes))
+	if err == nil {
+		for n, i := range indexes {
+			b.succeed(i, 0, created[n])
+		}
+		return
+	}
+
+	for _, i := range indexes {
+		user, err := userRepo.Create(b.ctx.Request().Context(), users[i])
+		if err != nil {
+			b.fail(i, 0, serverErr.FromError(err))
+			continue
+		}
+		b.succeed(i, 0, user)
+	}
+}
+
+// respond writes the results, a failed transactional batch takes the status of the failure and a best effort
+// batch with failures gets 207
+func (b *batch) respond() error {
+	status := http.StatusOK
+	if b.failed > 0 {
+		if b.mode == BatchTransactional {
+			status = b.status
+			b.markAborted()
+		} else {
+			status = http.StatusMultiStatus
+		}
+	}
+
+	return b.ctx.JSON(status, BatchResponse{
+		Mode:      b.mode,
+		Succeeded: len(b.results) - b.failed,
+		Failed:    b.failed,
+		Results:   b.results,
+	})
+}
+
+// markAborted flags every item of a failed transactional batch that didn't fail itself, they were rolled back
+// or never tried
+func (b *batch) markAborted() {
+	for i, result := range b.results {
+		if result.Error == nil {
+			b.results[i] = BatchResult{Index: i, Status: http.StatusFailedDependency, UserId: result.UserId}
+		}
+	}
+	b.failed = len(b.results)
+}
+
+func pick(users []models.User, indexes []int) []models.User {
+	picked := make([]models.User, 0, len(indexes))
+	for _, i := range indexes {
+		picked = append(picked, users[i])
+	}
+
+	return picked
+}
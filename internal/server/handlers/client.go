@@ -0,0 +1,228 @@
+package handlers
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/joomcode/errorx"
+	"github.com/labstack/echo/v4"
+	"github.com/rhuandantas/verifymy-test/internal/clients"
+	errx "github.com/rhuandantas/verifymy-test/internal/errors"
+	"github.com/rhuandantas/verifymy-test/internal/models"
+	serverErr "github.com/rhuandantas/verifymy-test/internal/server/error"
+	"github.com/rhuandantas/verifymy-test/internal/server/middlewares/auth"
+	"github.com/rhuandantas/verifymy-test/internal/util"
+)
+
+// maxUsageDays is the longest period a usage report may cover
+const maxUsageDays = 366
+
+type ClientHandler struct {
+	validator util.Validator
+	registry  clients.Registry
+	meter     clients.Meter
+	token     auth.Token
+	signature auth.Signature
+}
+
+func NewClientHandler(validator util.Validator, registry clients.Registry, meter clients.Meter, jwt auth.Token, signature auth.Signature) *ClientHandler {
+	return &ClientHandler{
+		validator: validator,
+		registry:  registry,
+		meter:     meter,
+		token:     jwt,
+		signature: signature,
+	}
+}
+
+func (ch *ClientHandler) RegisterRoutes(server *echo.Echo) {
+	authenticate := auth.Authenticate(ch.token, ch.signature)
+	manage := auth.RequirePermission(auth.PermissionManageClients)
+	g := server.Group("/clients", authenticate)
+	g.POST("", ch.Register, manage)
+	g.GET("", ch.List, manage)
+	g.GET("/:client_id", ch.Get, manage)
+	g.PUT("/:client_id", ch.Update, manage)
+	g.POST("/:client_id/secret", ch.RotateSecret, manage)
+	// clients read their own usage too
+	g.GET("/:client_id/usage", ch.Usage)
+}
+
+// Register godoc
+// @Summary      Register a relying party client
+// @Description  Registers a client signing its requests with the HMAC scheme, its id as credential. The secret is only ever returned here and when rotated. Needs the clients:manage permission
+// @Tags         Clients
+// @Accept       json
+// @Produce      json
+// @Param        client body models.ClientRegistration true "client"
+// @Security JWT
+// @Success      200  {object}  models.ClientCredentials
+// @Failure      400,401,403,409,500  {object}  error.ErrorResponse
+// @Router       /clients [post]
+func (ch *ClientHandler) Register(ctx echo.Context) error {
+	var registration models.ClientRegistration
+	if err := ctx.Bind(&registration); err != nil {
+		return serverErr.HandleError(ctx, errx.BadRequest.New(err.Error()))
+	}
+
+	if err := ch.validator.ValidateStruct(registration); err != nil {
+		return serverErr.HandleError(ctx, serverErr.FromValidationError(err))
+	}
+
+	res, err := ch.registry.Register(ctx.Request().Context(), registration, auth.Caller(ctx))
+	if err != nil {
+		return serverErr.HandleError(ctx, serverErr.FromError(err))
+	}
+
+	return serverErr.ResponseJson(ctx, res)
+}
+
+// List godoc
+// @Summary      List the relying party clients
+// @Description  Needs the clients:manage permission
+// @Tags         Clients
+// @Produce      json
+// @Security JWT
+// @Success      200  {array}  models.Client
+// @Failure      401,403,500  {object}  error.ErrorResponse
+// @Router       /clients [get]
+func (ch *ClientHandler) List(ctx echo.Context) error {
+	res, err := ch.registry.List(ctx.Request().Context())
+	if err != nil {
+		return serverErr.HandleError(ctx, serverErr.FromError(err))
+	}
+
+	return serverErr.ResponseJson(ctx, res)
+}
+
+// Get godoc
+// @Summary      Retrieve a relying party client
+// @Description  Needs the clients:manage permission
+// @Tags         Clients
+// @Produce      json
+// @Param        client_id   path      string  true  "client id"
+// @Security JWT
+// @Success      200  {object}  models.Client
+// @Failure      401,403,404,500  {object}  error.ErrorResponse
+// @Router       /clients/{client_id} [get]
+func (ch *ClientHandler) Get(ctx echo.Context) error {
+	res, err := ch.registry.Get(ctx.Request().Context(), ctx.Param("client_id"))
+	if err != nil {
+		return serverErr.HandleError(ctx, serverErr.FromError(err))
+	}
+
+	return serverErr.ResponseJson(ctx, res)
+}
+
+// Update godoc
+// @Summary      Update a relying party client
+// @Description  Replaces the name, plan, status, allowed origins, redirect uris and permissions of the client. A suspended client can't authenticate. Needs the clients:manage permission
+// @Tags         Clients
+// @Accept       json
+// @Produce      json
+// @Param        client_id   path      string  true  "client id"
+// @Param        client body models.ClientUpdate true "client"
+// @Security JWT
+// @Success      200  {object}  models.Client
+// @Failure      400,401,403,404,500  {object}  error.ErrorResponse
+// @Router       /clients/{client_id} [put]
+func (ch *ClientHandler) Update(ctx echo.Context) error {
+	var update models.ClientUpdate
+	if err := ctx.Bind(&update); err != nil {
+		return serverErr.HandleError(ctx, errx.BadRequest.New(err.Error()))
+	}
+
+	if err := ch.validator.ValidateStruct(update); err != nil {
+		return serverErr.HandleError(ctx, serverErr.FromValidationError(err))
+	}
+
+	res, err := ch.registry.Update(ctx.Request().Context(), ctx.Param("client_id"), update, auth.Caller(ctx))
+	if err != nil {
+		return serverErr.HandleError(ctx, serverErr.FromError(err))
+	}
+
+	return serverErr.ResponseJson(ctx, res)
+}
+
+// RotateSecret godoc
+// @Summary      Rotate the secret of a relying party client
+// @Description  Generates a new secret, the previous one stops working right away. Needs the clients:manage permission
+// @Tags         Clients
+// @Produce      json
+// @Param        client_id   path      string  true  "client id"
+// @Security JWT
+// @Success      200  {object}  models.ClientCredentials
+// @Failure      401,403,404,500  {object}  error.ErrorResponse
+// @Router       /clients/{client_id}/secret [post]
+func (ch *ClientHandler) RotateSecret(ctx echo.Context) error {
+	res, err := ch.registry.RotateSecret(ctx.Request().Context(), ctx.Param("client_id"), auth.Caller(ctx))
+	if err != nil {
+		return serverErr.HandleError(ctx, serverErr.FromError(err))
+	}
+
+	return serverErr.ResponseJson(ctx, res)
+}
+
+// Usage godoc
+// @Summary      Usage report of a relying party client
+// @Description  Verifications, tokens and api calls of the client by day, UTC, and in total, along with its plan and daily quotas. From defaults to the first day of the month and to today, covering at most 366 days. Readable by the client itself or with the clients:manage permission
+// @Tags         Clients
+// @Produce      json
+// @Param        client_id   path      string  true  "client id"
+// @Param        from   query      string  false  "first day, like 2006-01-02"
+// @Param        to   query      string  false  "last day, like 2006-01-02"
+// @Security JWT
+// @Success      200  {object}  models.UsageReport
+// @Failure      400,401,403,404,500  {object}  error.ErrorResponse
+// @Router       /clients/{client_id}/usage [get]
+func (ch *ClientHandler) Usage(ctx echo.Context) error {
+	clientId := ctx.Param("client_id")
+	if client, ok := ctx.Get(auth.ClientContextKey).(*models.Client); (!ok || client.ClientId != clientId) && !auth.HasPermission(ctx, auth.PermissionManageClients) {
+		return serverErr.HandleError(ctx, errx.Localized(errx.Forbidden, "clients.usage_forbidden", "%s permission is required for the usage of another client", auth.PermissionManageClients))
+	}
+
+	today := models.Today(time.UTC)
+	from, paramErr := dateParam(ctx, "from", today.AddDays(1-today.Day()))
+	if paramErr != nil {
+		return serverErr.HandleError(ctx, paramErr)
+	}
+	to, paramErr := dateParam(ctx, "to", today)
+	if paramErr != nil {
+		return serverErr.HandleError(ctx, paramErr)
+	}
+	if to.Before(from.Time) || to.Sub(from.Time) >= maxUsageDays*24*time.Hour {
+		return serverErr.HandleError(ctx, errx.Localized(errx.Validation, "query.invalid", "query validation failed").WithProperty(errx.FieldErrorsProperty, []errx.FieldError{{
+			Field:   "to",
+			Rule:    "max",
+			Param:   fmt.Sprint(maxUsageDays),
+			Message: fmt.Sprintf("to must be on or after from, covering at most %d days", maxUsageDays),
+		}}))
+	}
+
+	res, err := ch.meter.Report(ctx.Request().Context(), clientId, from, to)
+	if err != nil {
+		return serverErr.HandleError(ctx, serverErr.FromError(err))
+	}
+
+	return serverErr.ResponseJson(ctx, res)
+}
+
+// dateParam parses the query param as a date, fallback when it's missing
+func dateParam(ctx echo.Context, name string, fallback models.Date) (models.Date, *errorx.Error) {
+	raw := ctx.QueryParam(name)
+	if raw == "" {
+		return fallback, nil
+	}
+
+	date, err := models.ParseDate(raw)
+	if err != nil {
+		return models.Date{}, errx.Localized(errx.Validation, "query.invalid", "query validation failed").WithProperty(errx.FieldErrorsProperty, []errx.FieldError{{
+			Field:   name,
+			Rule:    "datetime",
+			Param:   models.DateLayout,
+			Message: err.Error(),
+		}})
+	}
+
+	return date, nil
+}
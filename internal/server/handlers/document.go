@@ -0,0 +1,193 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+
+	"github.com/labstack/echo/v4"
+	errx "github.com/rhuandantas/verifymy-test/internal/errors"
+	serverErr "github.com/rhuandantas/verifymy-test/internal/server/error"
+	"github.com/rhuandantas/verifymy-test/internal/server/middlewares/auth"
+	"github.com/rhuandantas/verifymy-test/internal/verification"
+)
+
+// multipartOverhead is what the form around a document may add to its size
+const multipartOverhead = 64 << 10
+
+type DocumentHandler struct {
+	documents verification.Documents
+	token     auth.Token
+	signature auth.Signature
+}
+
+func NewDocumentHandler(documents verification.Documents, jwt auth.Token, signature auth.Signature) *DocumentHandler {
+	return &DocumentHandler{
+		documents: documents,
+		token:     jwt,
+		signature: signature,
+	}
+}
+
+func (dh *DocumentHandler) RegisterRoutes(server *echo.Echo) {
+	g := server.Group("/users/:id/verification/documents", auth.Authenticate(dh.token, dh.signature), auth.RequirePermission(auth.PermissionVerifyUsers))
+	g.GET("", dh.List)
+	g.POST("", dh.Upload)
+	g.GET("/:document_id", dh.Download)
+	g.DELETE("/:document_id", dh.Delete)
+}
+
+// Upload godoc
+// @Summary      Upload evidence of the verification of a user
+// @Description  Stores a document, e.g. an ID scan, encrypted and linked to the verification of the user, which must have been started. Up to verification.documents.max-bytes of a type in verification.documents.allowed-types, sniffed from the content. Documents are deleted after verification.documents.retention-days. Needs the users:verify permission
+// @Tags         Verification
+// @Accept       multipart/form-data
+// @Produce      json
+// @Param        id   path      int  true  "user id"
+// @Param        file formData file true "document"
+// @Security JWT
+// @Success      200  {object}  models.VerificationDocument
+// @Failure      400,401,403,404,409,415,500  {object}  error.ErrorResponse
+// @Router       /users/{id}/verification/documents [post]
+func (dh *DocumentHandler) Upload(ctx echo.Context) error {
+	userId, err := strconv.Atoi(ctx.Param("id"))
+	if err != nil {
+		return serverErr.HandleError(ctx, errx.BadRequest.New(err.Error()))
+	}
+
+	content, err := dh.readDocument(ctx)
+	if err != nil {
+		return serverErr.HandleError(ctx, serverErr.FromError(err))
+	}
+
+	res, err := dh.documents.Upload(ctx.Request().Context(), userId, content, auth.Caller(ctx))
+	if err != nil {
+		return serverErr.HandleError(ctx, serverErr.FromError(err))
+	}
+
+	return serverErr.ResponseJson(ctx, res)
+}
+
+// List godoc
+// @Summary      List the evidence of the verification of a user
+// @Tags         Verification
+// @Produce      json
+// @Param        id   path      int  true  "user id"
+// @Security JWT
+// @Success      200  {array}  models.VerificationDocument
+// @Failure      400,401,403,500  {object}  error.ErrorResponse
+// @Router       /users/{id}/verification/documents [get]
+func (dh *DocumentHandler) List(ctx echo.Context) error {
+	userId, err := strconv.Atoi(ctx.Param("id"))
+	if err != nil {
+		return serverErr.HandleError(ctx, errx.BadRequest.New(err.Error()))
+	}
+
+	res, err := dh.documents.List(ctx.Request().Context(), userId)
+	if err != nil {
+		return serverErr.HandleError(ctx, serverErr.FromError(err))
+	}
+
+	return serverErr.ResponseJson(ctx, res)
+}
+
+// Download godoc
+// @Summary      Download a document of the verification of a user
+// @Description  The decrypted content, never cached
+// @Tags         Verification
+// @Produce      octet-stream
+// @Param        id   path      int  true  "user id"
+// @Param        document_id   path      int  true  "document id"
+// @Security JWT
+// @Success      200  {file}  binary
+// @Failure      400,401,403,404,500  {object}  error.ErrorResponse
+// @Router       /users/{id}/verification/documents/{document_id} [get]
+func (dh *DocumentHandler) Download(ctx echo.Context) error {
+	userId, err := strconv.Atoi(ctx.Param("id"))
+	if err != nil {
+		return serverErr.HandleError(ctx, errx.BadRequest.New(err.Error()))
+	}
+
+	documentId, err := strconv.Atoi(ctx.Param("document_id"))
+	if err != nil {
+		return serverErr.HandleError(ctx, errx.BadRequest.New(err.Error()))
+	}
+
+	document, content, err := dh.documents.Open(ctx.Request().Context(), userId, documentId)
+	if err != nil {
+		return serverErr.HandleError(ctx, serverErr.FromError(err))
+	}
+
+	ctx.Response().Header().Set(echo.HeaderCacheControl, "no-store")
+	ctx.Response().Header().Set(echo.HeaderContentDisposition, fmt.Sprintf("attachment; filename=\"document-%d\"", document.DocumentId))
+	return ctx.Blob(http.StatusOK, document.ContentType, content)
+}
+
+// Delete godoc
+// @Summary      Delete a document of the verification of a user
+// @Description  Deletes the document ahead of its retention, content and record alike
+// @Tags         Verification
+// @Produce      json
+// @Param        id   path      int  true  "user id"
+// @Param        document_id   path      int  true  "document id"
+// @Security JWT
+// @Success      200  {string}  "document deleted"
+// @Failure      400,401,403,404,500  {object}  error.ErrorResponse
+// @Router       /users/{id}/verification/documents/{document_id} [delete]
+func (dh *DocumentHandler) Delete(ctx echo.Context) error {
+	userId, err := strconv.Atoi(ctx.Param("id"))
+	if err != nil {
+		return serverErr.HandleError(ctx, errx.BadRequest.New(err.Error()))
+	}
+
+	documentId, err := strconv.Atoi(ctx.Param("document_id"))
+	if err != nil {
+		return serverErr.HandleError(ctx, errx.BadRequest.New(err.Error()))
+	}
+
+	if err := dh.documents.Delete(ctx.Request().Context(), userId, documentId, auth.Caller(ctx)); err != nil {
+		return serverErr.HandleError(ctx, serverErr.FromError(err))
+	}
+
+	return serverErr.ResponseJson(ctx, echo.Map{
+		"deleted": true,
+	})
+}
+
+// readDocument reads the file of the multipart form. The body is cut a little past the largest document
+// accepted and the file one byte past it, so the size check of the documents sees it's too large without
+// reading it whole
+func (dh *DocumentHandler) readDocument(ctx echo.Context) ([]byte, error) {
+	maxBytes := dh.documents.MaxBytes()
+	request := ctx.Request()
+	request.Body = http.MaxBytesReader(ctx.Response(), request.Body, maxBytes+multipartOverhead)
+
+	header, err := ctx.FormFile("file")
+	switch {
+	case errors.Is(err, http.ErrMissingFile):
+		return nil, errx.Localized(errx.Validation, "documents.missing", "document is missing").WithProperty(errx.FieldErrorsProperty, []errx.FieldError{{
+			Field:   "file",
+			Rule:    "required",
+			Message: "file is required",
+		}})
+	case err != nil && err.Error() == "http: request body too large":
+		return nil, errx.Localized(errx.Validation, "documents.too_large", "document is larger than %d bytes", maxBytes).WithProperty(errx.FieldErrorsProperty, []errx.FieldError{{
+			Field:   "file",
+			Rule:    "max",
+			Param:   fmt.Sprint(maxBytes),
+			Message: fmt.Sprintf("file must be at most %d bytes", maxBytes),
+		}})
+	case err != nil:
+		return nil, errx.BadRequest.New(err.Error())
+	}
+
+	file, err := header.Open()
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	return io.ReadAll(io.LimitReader(file, maxBytes+1))
+}
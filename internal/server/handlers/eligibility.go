@@ -0,0 +1,85 @@
+package handlers
+
+import (
+	"strconv"
+	"time"
+
+	"github.com/labstack/echo/v4"
+	errx "github.com/rhuandantas/verifymy-test/internal/errors"
+	"github.com/rhuandantas/verifymy-test/internal/models"
+	"github.com/rhuandantas/verifymy-test/internal/repo"
+	"github.com/rhuandantas/verifymy-test/internal/rules"
+	serverErr "github.com/rhuandantas/verifymy-test/internal/server/error"
+	"github.com/rhuandantas/verifymy-test/internal/server/middlewares/auth"
+	"github.com/rhuandantas/verifymy-test/internal/util"
+	"github.com/rhuandantas/verifymy-test/internal/verification"
+)
+
+type EligibilityHandler struct {
+	validator util.Validator
+	userRepo  repo.UserRepo
+	service   verification.Service
+	engine    rules.Engine
+	token     auth.Token
+	signature auth.Signature
+}
+
+func NewEligibilityHandler(validator util.Validator, userRepo repo.UserRepo, service verification.Service, engine rules.Engine, jwt auth.Token, signature auth.Signature) *EligibilityHandler {
+	return &EligibilityHandler{
+		validator: validator,
+		userRepo:  userRepo,
+		service:   service,
+		engine:    engine,
+		token:     jwt,
+		signature: signature,
+	}
+}
+
+func (eh *EligibilityHandler) RegisterRoutes(server *echo.Echo) {
+	server.GET("/users/:id/eligibility", eh.Evaluate, auth.Authenticate(eh.token, eh.signature))
+}
+
+// Evaluate godoc
+// @Summary      Whether a user meets the minimum age of a jurisdiction and content category
+// @Description  Applies the rule of the category and jurisdiction, falling back to any category and to the country of a subdivision or any jurisdiction. The user must be verified with a method the rule accepts and be at least its minimum age, by the date of birth and timezone they were verified with. Minors restricted until a guardian consents are denied. The reason is one of allowed, no_rule, consent_required, not_verified, method_not_accepted, unknown_age or under_age
+// @Tags         Verification
+// @Produce      json
+// @Param        id   path      int  true  "user id"
+// @Param        jurisdiction   query      string  true  "ISO 3166 country or subdivision, e.g. GB or US-UT"
+// @Param        category   query      string  true  "content category, e.g. alcohol"
+// @Security JWT
+// @Success      200  {object}  rules.Decision
+// @Failure      400,401,404,500  {object}  error.ErrorResponse
+// @Router       /users/{id}/eligibility [get]
+func (eh *EligibilityHandler) Evaluate(ctx echo.Context) error {
+	userId, err := strconv.Atoi(ctx.Param("id"))
+	if err != nil {
+		return serverErr.HandleError(ctx, errx.BadRequest.New(err.Error()))
+	}
+
+	var context rules.Context
+	if err = ctx.Bind(&context); err != nil {
+		return serverErr.HandleError(ctx, errx.BadRequest.New(err.Error()))
+	}
+
+	if err = eh.validator.ValidateStruct(context); err != nil {
+		return serverErr.HandleError(ctx, serverErr.FromValidationError(err))
+	}
+
+	status, err := eh.service.Get(ctx.Request().Context(), userId)
+	if err != nil {
+		return serverErr.HandleError(ctx, serverErr.FromError(err))
+	}
+
+	user, err := eh.userRepo.GetByID(ctx.Request().Context(), userId, "status")
+	if err != nil {
+		return serverErr.HandleError(ctx, serverErr.FromError(err))
+	}
+
+	return serverErr.ResponseJson(ctx, eh.engine.Evaluate(rules.Subject{
+		Age:               status.AgeAt(time.Now()),
+		VerificationState: status.State,
+		Method:            status.Method,
+		Restricted:        user.Status == models.UserRestricted,
+	}, context))
+}
@@ -0,0 +1,45 @@
+package handlers
+
+import (
+	"strconv"
+	"strings"
+
+	"github.com/labstack/echo/v4"
+	errx "github.com/rhuandantas/verifymy-test/internal/errors"
+)
+
+const (
+	headerETag    = "ETag"
+	headerIfMatch = "If-Match"
+)
+
+// setETag sets the ETag header from a resource version
+func setETag(ctx echo.Context, version int) {
+	ctx.Response().Header().Set(headerETag, strconv.Quote(strconv.Itoa(version)))
+}
+
+// ifMatchVersion reads the version the client expects from the If-Match header,
+// 0 means there is no precondition, either because the header is missing or it is *
+func ifMatchVersion(ctx echo.Context) (int, error) {
+	ifMatch := strings.TrimSpace(ctx.Request().Header.Get(headerIfMatch))
+	if ifMatch == "" || ifMatch == "*" {
+		return 0, nil
+	}
+
+	// weak validators can't be used for If-Match, see RFC 7232 section 3.1
+	if strings.HasPrefix(ifMatch, "W/") {
+		return 0, errx.Localized(errx.PreconditionFailed, "etag.weak", "If-Match requires a strong ETag")
+	}
+
+	unquoted, err := strconv.Unquote(ifMatch)
+	if err != nil {
+		return 0, errx.Localized(errx.BadRequest, "etag.unquoted", "If-Match must be a quoted ETag")
+	}
+
+	version, err := strconv.Atoi(unquoted)
+	if err != nil || version <= 0 {
+		return 0, errx.Localized(errx.PreconditionFailed, "etag.mismatch", "If-Match %s doesn't match any version", ifMatch)
+	}
+
+	return version, nil
+}
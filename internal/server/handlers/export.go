@@ -0,0 +1,97 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/joomcode/errorx"
+	"github.com/labstack/echo/v4"
+	errx "github.com/rhuandantas/verifymy-test/internal/errors"
+	"github.com/rhuandantas/verifymy-test/internal/export"
+	"github.com/rhuandantas/verifymy-test/internal/models"
+	"github.com/rhuandantas/verifymy-test/internal/repo"
+	serverErr "github.com/rhuandantas/verifymy-test/internal/server/error"
+)
+
+const defaultExportBatchSize = 500
+
+// Export godoc
+// @Summary      Export all users
+// @Description  Streams the users, without passwords and by user id, in csv, ndjson or a json array. Takes the filters of the listing and requires the users:export permission
+// @Tags         Users
+// @Produce      json,text/csv,application/x-ndjson
+// @Param        format   query      string  false  "csv, ndjson or json, the default"  Enums(csv, ndjson, json)
+// @Param        include_deleted   query      bool  false  "include soft deleted users, admin only"
+// @Param        email   query      string  false  "exact email"
+// @Param        email_prefix   query      string  false  "email starting with"
+// @Param        name   query      string  false  "exact name"
+// @Param        name_prefix   query      string  false  "name starting with"
+// @Param        age_gte   query      int  false  "minimum age, computed from the date of birth on today's UTC date"
+// @Param        age_lte   query      int  false  "maximum age, computed from the date of birth on today's UTC date"
+// @Param        created_at_gte   query      string  false  "created at or after, RFC 3339"
+// @Param        created_at_lte   query      string  false  "created at or before, RFC 3339"
+// @Security JWT
+// @Success      200  {array}  models.User
+// @Failure      400,401,403,500  {object}  error.ErrorResponse
+// @Router       /users/export [get]
+func (uh *UserHandler) Export(ctx echo.Context) error {
+	format := ctx.QueryParam("format")
+	if format == "" {
+		format = export.FormatJSON
+	}
+
+	includeDeleted, err := includeDeletedParam(ctx)
+	if err != nil {
+		return serverErr.HandleError(ctx, serverErr.FromError(err))
+	}
+
+	// exports go in user id order so they can be read in batches without holding every user
+	if ctx.QueryParam(repo.SortParam) != "" {
+		return serverErr.HandleError(ctx, errx.Localized(errx.BadRequest, "export.sorted", "exports are in user_id order and can't be sorted"))
+	}
+
+	if ctx.QueryParam(repo.FieldsParam) != "" {
+		return serverErr.HandleError(ctx, errx.Localized(errx.BadRequest, "export.fields", "exports have every field and can't select them"))
+	}
+
+	query, err := repo.UserQuery.Build(ctx.QueryParams())
+	if err != nil {
+		return serverErr.HandleError(ctx, serverErr.FromValidationError(err))
+	}
+
+	res := ctx.Response()
+	encoder, err := export.NewEncoder(format, res)
+	if err != nil {
+		return serverErr.HandleError(ctx, serverErr.FromError(err))
+	}
+
+	// the status is only sent along with the first users, so a failure before that still gets a proper error
+	res.Header().Set(echo.HeaderContentType, encoder.ContentType())
+	res.Header().Set(echo.HeaderContentDisposition, fmt.Sprintf("attachment; filename=users.%s", format))
+	err = uh.userRepo.Export(ctx.Request().Context(), query, includeDeleted, uh.exportBatchSize, func(users []*models.User) error {
+		for _, user := range users {
+			if err := encoder.Encode(user); err != nil {
+				return err
+			}
+		}
+		res.Flush()
+
+		return nil
+	})
+	if err == nil {
+		err = encoder.Close()
+	}
+
+	if err == nil {
+		return nil
+	}
+
+	if !res.Committed {
+		res.Header().Del(echo.HeaderContentDisposition)
+		return serverErr.HandleError(ctx, serverErr.FromError(err))
+	}
+
+	// part of the export was already sent, aborting the connection is the only way to tell the client it's incomplete
+	uh.logger.Error(ctx.Request().Context(), errorx.Decorate(err, "users export failed"))
+	panic(http.ErrAbortHandler)
+}
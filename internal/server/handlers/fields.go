@@ -0,0 +1,139 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/labstack/echo/v4"
+	errx "github.com/rhuandantas/verifymy-test/internal/errors"
+	"github.com/rhuandantas/verifymy-test/internal/models"
+	"github.com/rhuandantas/verifymy-test/internal/repo"
+)
+
+const expandParam = "expand"
+
+// expander loads a resource related to the users for ?expand=, keyed by user id. Users without one
+// get null
+type expander func(ctx context.Context, users []*models.User) (map[int]interface{}, error)
+
+// representation is what a response of users has, only the fields the client asked for and the related
+// resources it expanded. Without either the users are rendered whole
+type representation struct {
+	fields []string
+	expand []string
+}
+
+// resourcePage is a models.UserPage whose items are rendered by a representation
+type resourcePage struct {
+	Items []map[string]interface{} `json:"items"`
+	Next  string                   `json:"next,omitempty"`
+	Prev  string                   `json:"prev,omitempty"`
+	Total *int64                   `json:"total,omitempty"`
+}
+
+// representationParams reads the fields and expand query params, fields must already have been checked
+// by repo.UserQuery
+func (uh *UserHandler) representationParams(ctx echo.Context) (*representation, error) {
+	rep := &representation{
+		fields: splitParam(ctx.QueryParam(repo.FieldsParam)),
+		expand: splitParam(ctx.QueryParam(expandParam)),
+	}
+
+	for _, name := range rep.expand {
+		if _, ok := uh.expanders[name]; !ok {
+			names := make([]string, 0, len(uh.expanders))
+			for name := range uh.expanders {
+				names = append(names, name)
+			}
+			sort.Strings(names)
+
+			return nil, errx.Localized(errx.Validation, "query.invalid", "query validation failed").WithProperty(errx.FieldErrorsProperty, []errx.FieldError{{
+				Field:   expandParam,
+				Rule:    "oneof",
+				Param:   strings.Join(names, " "),
+				Message: fmt.Sprintf("%s must be one of [%s]", expandParam, strings.Join(names, " ")),
+			}})
+		}
+	}
+
+	return rep, nil
+}
+
+func (rep *representation) whole() bool {
+	return len(rep.fields) == 0 && len(rep.expand) == 0
+}
+
+// render turns the users into their representation, loading each expansion once for every user
+func (uh *UserHandler) render(ctx context.Context, rep *representation, users []*models.User) ([]map[string]interface{}, error) {
+	expanded := make(map[string]map[int]interface{}, len(rep.expand))
+	for _, name := range rep.expand {
+		values, err := uh.expanders[name](ctx, users)
+		if err != nil {
+			return nil, err
+		}
+		expanded[name] = values
+	}
+
+	resources := make([]map[string]interface{}, 0, len(users))
+	for _, user := range users {
+		resource, err := project(user, rep.fields)
+		if err != nil {
+			return nil, err
+		}
+
+		for name, values := range expanded {
+			resource[name] = values[user.UserId]
+		}
+		resources = append(resources, resource)
+	}
+
+	return resources, nil
+}
+
+// project keeps the json fields of the user that are listed, every one of them when none is
+func project(user *models.User, fields []string) (map[string]interface{}, error) {
+	user.Password = ""
+	content, err := json.Marshal(user)
+	if err != nil {
+		return nil, err
+	}
+
+	var all map[string]json.RawMessage
+	if err = json.Unmarshal(content, &all); err != nil {
+		return nil, err
+	}
+
+	resource := make(map[string]interface{}, len(all))
+	for field, value := range all {
+		if len(fields) == 0 || containsString(fields, field) {
+			resource[field] = value
+		}
+	}
+
+	return resource, nil
+}
+
+// splitParam splits a comma separated query param, leaving blank items out
+func splitParam(param string) []string {
+	items := make([]string, 0)
+	for _, item := range strings.Split(param, ",") {
+		if item = strings.TrimSpace(item); item != "" && !containsString(items, item) {
+			items = append(items, item)
+		}
+	}
+
+	return items
+}
+
+func containsString(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+
+	return false
+}
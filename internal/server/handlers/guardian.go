@@ -0,0 +1,156 @@
+package handlers
+
+import (
+	"strconv"
+
+	"github.com/labstack/echo/v4"
+	errx "github.com/rhuandantas/verifymy-test/internal/errors"
+	"github.com/rhuandantas/verifymy-test/internal/guardian"
+	"github.com/rhuandantas/verifymy-test/internal/models"
+	serverErr "github.com/rhuandantas/verifymy-test/internal/server/error"
+	"github.com/rhuandantas/verifymy-test/internal/server/middlewares/auth"
+	"github.com/rhuandantas/verifymy-test/internal/util"
+)
+
+type GuardianHandler struct {
+	validator util.Validator
+	consents  guardian.Consents
+	token     auth.Token
+	signature auth.Signature
+}
+
+func NewGuardianHandler(validator util.Validator, consents guardian.Consents, jwt auth.Token, signature auth.Signature) *GuardianHandler {
+	return &GuardianHandler{
+		validator: validator,
+		consents:  consents,
+		token:     jwt,
+		signature: signature,
+	}
+}
+
+func (gh *GuardianHandler) RegisterRoutes(server *echo.Echo) {
+	authenticate := auth.Authenticate(gh.token, gh.signature)
+	g := server.Group("/users/:id/guardians", authenticate)
+	g.GET("", gh.List)
+	g.POST("", gh.Invite)
+	g.DELETE("/:link_id", gh.Revoke)
+	server.POST("/guardian-invitations/accept", gh.Accept, authenticate)
+}
+
+// List godoc
+// @Summary      List the guardians of a minor
+// @Description  Every invitation and consent of the user, revoked ones included
+// @Tags         Guardians
+// @Produce      json
+// @Param        id   path      int  true  "user id"
+// @Security JWT
+// @Success      200  {array}  models.GuardianLink
+// @Failure      400,401,404,500  {object}  error.ErrorResponse
+// @Router       /users/{id}/guardians [get]
+func (gh *GuardianHandler) List(ctx echo.Context) error {
+	id, err := strconv.Atoi(ctx.Param("id"))
+	if err != nil {
+		return serverErr.HandleError(ctx, errx.BadRequest.New(err.Error()))
+	}
+
+	res, err := gh.consents.List(ctx.Request().Context(), id)
+	if err != nil {
+		return serverErr.HandleError(ctx, serverErr.FromError(err))
+	}
+
+	return serverErr.ResponseJson(ctx, res)
+}
+
+// Invite godoc
+// @Summary      Invite a guardian to consent for a minor
+// @Description  Only restricted minors can invite guardians. The token of the invitation is only returned here, the guardian accepts with it within users.consent.invitation-hours
+// @Tags         Guardians
+// @Accept       json
+// @Produce      json
+// @Param        id   path      int  true  "user id"
+// @Param        invite body models.GuardianInvite true "guardian to invite"
+// @Security JWT
+// @Success      200  {object}  models.GuardianInvitation
+// @Failure      400,401,404,409,500  {object}  error.ErrorResponse
+// @Router       /users/{id}/guardians [post]
+func (gh *GuardianHandler) Invite(ctx echo.Context) error {
+	id, err := strconv.Atoi(ctx.Param("id"))
+	if err != nil {
+		return serverErr.HandleError(ctx, errx.BadRequest.New(err.Error()))
+	}
+
+	var request models.GuardianInvite
+	if err = ctx.Bind(&request); err != nil {
+		return serverErr.HandleError(ctx, errx.BadRequest.New(err.Error()))
+	}
+
+	if err = gh.validator.ValidateStruct(request); err != nil {
+		return serverErr.HandleError(ctx, serverErr.FromValidationError(err))
+	}
+
+	res, err := gh.consents.Invite(ctx.Request().Context(), id, request, auth.Caller(ctx))
+	if err != nil {
+		return serverErr.HandleError(ctx, serverErr.FromError(err))
+	}
+
+	return serverErr.ResponseJson(ctx, res)
+}
+
+// Revoke godoc
+// @Summary      Revoke a guardian's consent or invitation
+// @Description  Only the guardian or an admin may revoke. A minor left without consenting guardians is restricted again
+// @Tags         Guardians
+// @Produce      json
+// @Param        id   path      int  true  "user id"
+// @Param        link_id   path      int  true  "guardian link id"
+// @Security JWT
+// @Success      200  {object}  models.GuardianLink
+// @Failure      400,401,403,404,409,500  {object}  error.ErrorResponse
+// @Router       /users/{id}/guardians/{link_id} [delete]
+func (gh *GuardianHandler) Revoke(ctx echo.Context) error {
+	id, err := strconv.Atoi(ctx.Param("id"))
+	if err != nil {
+		return serverErr.HandleError(ctx, errx.BadRequest.New(err.Error()))
+	}
+
+	linkId, err := strconv.Atoi(ctx.Param("link_id"))
+	if err != nil {
+		return serverErr.HandleError(ctx, errx.BadRequest.New(err.Error()))
+	}
+
+	res, err := gh.consents.Revoke(ctx.Request().Context(), id, linkId, auth.Caller(ctx), auth.IsAdmin(ctx))
+	if err != nil {
+		return serverErr.HandleError(ctx, serverErr.FromError(err))
+	}
+
+	return serverErr.ResponseJson(ctx, res)
+}
+
+// Accept godoc
+// @Summary      Accept an invitation to consent for a minor
+// @Description  The caller must be the invited guardian, registered with the invited email, age verified and at least users.consent.guardian-minimum-age. Accepting lifts the restriction of the minor
+// @Tags         Guardians
+// @Accept       json
+// @Produce      json
+// @Param        acceptance body models.GuardianAcceptance true "invitation token"
+// @Security JWT
+// @Success      200  {object}  models.GuardianLink
+// @Failure      400,401,403,404,409,500  {object}  error.ErrorResponse
+// @Router       /guardian-invitations/accept [post]
+func (gh *GuardianHandler) Accept(ctx echo.Context) error {
+	var request models.GuardianAcceptance
+	if err := ctx.Bind(&request); err != nil {
+		return serverErr.HandleError(ctx, errx.BadRequest.New(err.Error()))
+	}
+
+	if err := gh.validator.ValidateStruct(request); err != nil {
+		return serverErr.HandleError(ctx, serverErr.FromValidationError(err))
+	}
+
+	res, err := gh.consents.Accept(ctx.Request().Context(), request, auth.Caller(ctx))
+	if err != nil {
+		return serverErr.HandleError(ctx, serverErr.FromError(err))
+	}
+
+	return serverErr.ResponseJson(ctx, res)
+}
@@ -0,0 +1,249 @@
+package handlers
+
+import (
+	"mime"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/joomcode/errorx"
+	"github.com/labstack/echo/v4"
+	errx "github.com/rhuandantas/verifymy-test/internal/errors"
+	"github.com/rhuandantas/verifymy-test/internal/guardian"
+	"github.com/rhuandantas/verifymy-test/internal/importer"
+	"github.com/rhuandantas/verifymy-test/internal/models"
+	"github.com/rhuandantas/verifymy-test/internal/repo"
+	serverErr "github.com/rhuandantas/verifymy-test/internal/server/error"
+)
+
+const defaultImportMaxRows = 10000
+
+// ImportRow is a line of the import file, Error says why it was skipped or failed
+type ImportRow struct {
+	Line   int                      `json:"line"`
+	UserId int                      `json:"user_id,omitempty"`
+	Email  string                   `json:"email,omitempty"`
+	Error  *serverErr.ErrorResponse `json:"error,omitempty"`
+}
+
+// ImportReport lists the rows of an import by outcome, on a dry run Created holds the rows that would be created
+type ImportReport struct {
+	DryRun  bool        `json:"dry_run"`
+	Total   int         `json:"total"`
+	Created []ImportRow `json:"created"`
+	Skipped []ImportRow `json:"skipped"`
+	Failed  []ImportRow `json:"failed"`
+}
+
+// Import godoc
+// @Summary Import users from a csv or ndjson file.
+// @Description Rows whose email is already registered, or appears on an earlier row, are skipped. A csv file must start with a header, headers are matched to user fields by name unless mapped with map=header:field, map=header:- ignores a column
+// @Tags Users
+// @Accept text/csv,application/x-ndjson
+// @Produce json
+// @Param file body string true "csv or ndjson file"
+// @Param        dry_run   query      bool  false  "validate without creating users"
+// @Param        map   query      []string  false  "csv header to user field mapping, header:field" collectionFormat(multi)
+// @Security JWT
+// @Success 	 200  {object} ImportReport
+// @Failure      400,401,415,500  {object}  error.ErrorResponse
+// @Router /users/import [post]
+func (uh *UserHandler) Import(ctx echo.Context) error {
+	dryRun := false
+	if param := ctx.QueryParam("dry_run"); param != "" {
+		var err error
+		if dryRun, err = strconv.ParseBool(param); err != nil {
+			return serverErr.HandleError(ctx, errx.Localized(errx.BadRequest, "import.dry_run_boolean", "dry_run must be a boolean"))
+		}
+	}
+
+	rows, err := uh.readImport(ctx)
+	if err != nil {
+		return serverErr.HandleError(ctx, serverErr.FromError(err))
+	}
+
+	imp := &userImport{
+		ctx: ctx,
+		report: ImportReport{
+			DryRun:  dryRun,
+			Total:   len(rows),
+			Created: make([]ImportRow, 0),
+			Skipped: make([]ImportRow, 0),
+			Failed:  make([]ImportRow, 0),
+		},
+	}
+
+	firstLines := make(map[string]int)
+	guardians := make(map[int]string)
+	valid := make([]importer.Row, 0, len(rows))
+	for _, row := range rows {
+		if err = uh.validateImportRow(row); err != nil {
+			imp.fail(row, serverErr.FromValidationError(err))
+			continue
+		}
+		if uh.consents.Restrict(&row.User) {
+			if err = guardian.ValidateGuardianEmail(row.User.Email, row.User.GuardianEmail); err != nil {
+				imp.fail(row, serverErr.FromError(err))
+				continue
+			}
+			guardians[row.Line] = row.User.GuardianEmail
+		}
+
+		email := strings.ToLower(row.User.Email)
+		if line, ok := firstLines[email]; ok {
+			imp.skip(row, errx.Localized(errx.Conflict, "import.duplicate_email", "email already appears on line %d", line))
+			continue
+		}
+		firstLines[email] = row.Line
+		valid = append(valid, row)
+	}
+
+	emails := make([]string, 0, len(valid))
+	for _, row := range valid {
+		emails = append(emails, row.User.Email)
+	}
+
+	existing, err := uh.userRepo.ExistingEmails(ctx.Request().Context(), emails)
+	if err != nil {
+		return serverErr.HandleError(ctx, serverErr.FromError(err))
+	}
+
+	pending := make([]importer.Row, 0, len(valid))
+	for _, row := range valid {
+		if existing[strings.ToLower(row.User.Email)] {
+			imp.skip(row, errx.Localized(errx.Conflict, "users.email_taken", "email is already registered"))
+			continue
+		}
+		pending = append(pending, row)
+	}
+
+	for start := 0; start < len(pending); start += uh.batchInsertSize {
+		end := start + uh.batchInsertSize
+		if end > len(pending) {
+			end = len(pending)
+		}
+
+		if dryRun {
+			for _, row := range pending[start:end] {
+				imp.create(row, nil)
+			}
+			continue
+		}
+		imp.createChunk(ctx, uh.userRepo, pending[start:end])
+	}
+
+	// like on their own, the minors created get their guardian invited
+	for _, created := range imp.report.Created {
+		if guardianEmail, ok := guardians[created.Line]; ok && created.UserId != 0 {
+			uh.inviteGuardian(ctx, created.UserId, guardianEmail)
+		}
+	}
+
+	imp.sort()
+	return serverErr.ResponseJson(ctx, imp.report)
+}
+
+// readImport reads the rows of the request body according to its content type
+func (uh *UserHandler) readImport(ctx echo.Context) ([]importer.Row, error) {
+	contentType := ctx.Request().Header.Get(echo.HeaderContentType)
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return nil, errx.Localized(errx.UnsupportedMedia, "content_type.invalid", "content type %q is not valid", contentType)
+	}
+
+	switch mediaType {
+	case importer.MIMETextCSV:
+		mapping := make(map[string]string)
+		for _, param := range ctx.QueryParams()["map"] {
+			i := strings.LastIndex(param, ":")
+			if i < 0 {
+				return nil, errx.Localized(errx.BadRequest, "import.map_invalid", "map must be header:field, not %q", param)
+			}
+			mapping[strings.TrimSpace(param[:i])] = strings.TrimSpace(param[i+1:])
+		}
+
+		return importer.ReadCSV(ctx.Request().Body, mapping, uh.importMaxRows)
+	case importer.MIMEApplicationNDJSON:
+		return importer.ReadNDJSON(ctx.Request().Body, uh.importMaxRows)
+	default:
+		return nil, errx.Localized(errx.UnsupportedMedia, "content_type.unsupported", "content type must be %s or %s", importer.MIMETextCSV, importer.MIMEApplicationNDJSON)
+	}
+}
+
+// validateImportRow checks the user of a row with the validator and the password policy, a row without a
+// password breaks the policy like any other short password
+func (uh *UserHandler) validateImportRow(row importer.Row) error {
+	if row.Err != nil {
+		return row.Err
+	}
+
+	if err := uh.validator.ValidateStruct(row.User); err != nil {
+		return err
+	}
+
+	return uh.passwordPolicy.Check(row.User.Password)
+}
+
+// userImport collects the report of an import
+type userImport struct {
+	ctx    echo.Context
+	report ImportReport
+}
+
+func (ui *userImport) create(row importer.Row, user *models.User) {
+	created := ImportRow{Line: row.Line, Email: row.User.Email}
+	if user != nil {
+		created.UserId = user.UserId
+	}
+	ui.report.Created = append(ui.report.Created, created)
+}
+
+func (ui *userImport) skip(row importer.Row, err *errorx.Error) {
+	ui.report.Skipped = append(ui.report.Skipped, ui.row(row, err))
+}
+
+func (ui *userImport) fail(row importer.Row, err *errorx.Error) {
+	ui.report.Failed = append(ui.report.Failed, ui.row(row, err))
+}
+
+func (ui *userImport) row(row importer.Row, err *errorx.Error) ImportRow {
+	problem := serverErr.RequestErrorResponse(ui.ctx, err)
+	return ImportRow{Line: row.Line, Email: row.User.Email, Error: &problem}
+}
+
+// createChunk inserts the rows with a single statement, when it fails they are created one by one to find out
+// which of them can't be, a row whose email got registered meanwhile is skipped
+func (ui *userImport) createChunk(ctx echo.Context, userRepo repo.UserRepo, rows []importer.Row) {
+	users := make([]models.User, 0, len(rows))
+	for _, row := range rows {
+		users = append(users, row.User)
+	}
+
+	created, err := userRepo.CreateBatch(ctx.Request().Context(), users, len(users))
+	if err == nil {
+		for i, row := range rows {
+			ui.create(row, created[i])
+		}
+		return
+	}
+
+	for _, row := range rows {
+		user, err := userRepo.Create(ctx.Request().Context(), row.User)
+		switch {
+		case errorx.IsOfType(err, errx.Conflict):
+			ui.skip(row, serverErr.FromError(err))
+		case err != nil:
+			ui.fail(row, serverErr.FromError(err))
+		default:
+			ui.create(row, user)
+		}
+	}
+}
+
+func (ui *userImport) sort() {
+	for _, rows := range [][]ImportRow{ui.report.Created, ui.report.Skipped, ui.report.Failed} {
+		sort.SliceStable(rows, func(i, j int) bool {
+			return rows[i].Line < rows[j].Line
+		})
+	}
+}
@@ -0,0 +1,39 @@
+package handlers
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/labstack/echo/v4"
+	"github.com/rhuandantas/verifymy-test/internal/models"
+)
+
+const headerLink = "Link"
+
+// setPageLinks sets the Link header of a page, see RFC 8288. The links keep the query params of the request,
+// the next and prev ones page from the cursors of the page and first goes back to page 0
+func setPageLinks(ctx echo.Context, page *models.UserPage) {
+	url := ctx.Request().URL
+	link := func(rel, param, value string) string {
+		query := url.Query()
+		for _, name := range []string{"page", "after", "before"} {
+			query.Del(name)
+		}
+		if param != "" {
+			query.Set(param, value)
+		}
+
+		return fmt.Sprintf(`<%s?%s>; rel="%s"`, url.Path, query.Encode(), rel)
+	}
+
+	links := make([]string, 0, 3)
+	if page.Next != "" {
+		links = append(links, link("next", "after", page.Next))
+	}
+	if page.Prev != "" {
+		links = append(links, link("prev", "before", page.Prev))
+	}
+	links = append(links, link("first", "", ""))
+
+	ctx.Response().Header().Set(headerLink, strings.Join(links, ", "))
+}
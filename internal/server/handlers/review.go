@@ -0,0 +1,234 @@
+package handlers
+
+import (
+	"fmt"
+	"strconv"
+
+	"github.com/labstack/echo/v4"
+	errx "github.com/rhuandantas/verifymy-test/internal/errors"
+	"github.com/rhuandantas/verifymy-test/internal/models"
+	"github.com/rhuandantas/verifymy-test/internal/repo"
+	serverErr "github.com/rhuandantas/verifymy-test/internal/server/error"
+	"github.com/rhuandantas/verifymy-test/internal/server/middlewares/auth"
+	"github.com/rhuandantas/verifymy-test/internal/util"
+	"github.com/rhuandantas/verifymy-test/internal/verification"
+)
+
+const (
+	defaultReviewLimit = 50
+	maxReviewLimit     = 200
+)
+
+type ReviewHandler struct {
+	validator util.Validator
+	reviews   verification.Reviews
+	token     auth.Token
+	signature auth.Signature
+}
+
+func NewReviewHandler(validator util.Validator, reviews verification.Reviews, jwt auth.Token, signature auth.Signature) *ReviewHandler {
+	return &ReviewHandler{
+		validator: validator,
+		reviews:   reviews,
+		token:     jwt,
+		signature: signature,
+	}
+}
+
+func (rh *ReviewHandler) RegisterRoutes(server *echo.Echo) {
+	g := server.Group("/reviews", auth.Authenticate(rh.token, rh.signature), auth.RequirePermission(auth.PermissionReviewUsers))
+	g.GET("", rh.List)
+	g.POST("", rh.Open)
+	g.GET("/:case_id", rh.Get)
+	g.POST("/:case_id/claim", rh.Claim)
+	g.POST("/:case_id/notes", rh.Note)
+	g.POST("/:case_id/decision", rh.Decide)
+}
+
+// List godoc
+// @Summary      List the review queue
+// @Description  Review cases by due date, the ones past it are flagged overdue. Needs the users:review permission
+// @Tags         Reviews
+// @Produce      json
+// @Param        status   query      string  false  "open, claimed, approved or rejected"
+// @Param        reviewer   query      string  false  "reviewer who claimed the case"
+// @Param        user_id   query      int  false  "user under review"
+// @Param        due_before   query      string  false  "cases due by then, RFC 3339"
+// @Param        sort   query      string  false  "case_id, due_at or created_at, - for descending"
+// @Param        limit   query      int  false  "most cases returned"
+// @Security JWT
+// @Success      200  {array}  models.ReviewCase
+// @Failure      400,401,403,500  {object}  error.ErrorResponse
+// @Router       /reviews [get]
+func (rh *ReviewHandler) List(ctx echo.Context) error {
+	limit := defaultReviewLimit
+	if raw := ctx.QueryParam("limit"); raw != "" {
+		var err error
+		if limit, err = strconv.Atoi(raw); err != nil || limit < 1 || limit > maxReviewLimit {
+			return serverErr.HandleError(ctx, errx.Localized(errx.Validation, "query.invalid", "query validation failed").WithProperty(errx.FieldErrorsProperty, []errx.FieldError{{
+				Field:   "limit",
+				Rule:    "max",
+				Param:   strconv.Itoa(maxReviewLimit),
+				Message: fmt.Sprintf("limit must be a number between 1 and %d", maxReviewLimit),
+			}}))
+		}
+	}
+
+	query, err := repo.ReviewQuery.Build(ctx.QueryParams())
+	if err != nil {
+		return serverErr.HandleError(ctx, serverErr.FromError(err))
+	}
+
+	res, err := rh.reviews.List(ctx.Request().Context(), query, limit)
+	if err != nil {
+		return serverErr.HandleError(ctx, serverErr.FromError(err))
+	}
+
+	return serverErr.ResponseJson(ctx, res)
+}
+
+// Open godoc
+// @Summary      Open a review case
+// @Description  Hands the verification of a user over to a reviewer, moving it to pending when it isn't already. A user has at most one case under way. Needs the users:review permission
+// @Tags         Reviews
+// @Accept       json
+// @Produce      json
+// @Param        review body models.ReviewRequest true "user and why"
+// @Security JWT
+// @Success      200  {object}  models.ReviewCaseDetail
+// @Failure      400,401,403,404,409,500  {object}  error.ErrorResponse
+// @Router       /reviews [post]
+func (rh *ReviewHandler) Open(ctx echo.Context) error {
+	var request models.ReviewRequest
+	if err := ctx.Bind(&request); err != nil {
+		return serverErr.HandleError(ctx, errx.BadRequest.New(err.Error()))
+	}
+
+	if err := rh.validator.ValidateStruct(request); err != nil {
+		return serverErr.HandleError(ctx, serverErr.FromValidationError(err))
+	}
+
+	res, err := rh.reviews.Open(ctx.Request().Context(), request, auth.Caller(ctx))
+	if err != nil {
+		return serverErr.HandleError(ctx, serverErr.FromError(err))
+	}
+
+	return serverErr.ResponseJson(ctx, res)
+}
+
+// Get godoc
+// @Summary      Retrieve a review case with its notes
+// @Tags         Reviews
+// @Produce      json
+// @Param        case_id   path      int  true  "case id"
+// @Security JWT
+// @Success      200  {object}  models.ReviewCaseDetail
+// @Failure      400,401,403,404,500  {object}  error.ErrorResponse
+// @Router       /reviews/{case_id} [get]
+func (rh *ReviewHandler) Get(ctx echo.Context) error {
+	caseId, err := strconv.Atoi(ctx.Param("case_id"))
+	if err != nil {
+		return serverErr.HandleError(ctx, errx.BadRequest.New(err.Error()))
+	}
+
+	res, err := rh.reviews.Get(ctx.Request().Context(), caseId)
+	if err != nil {
+		return serverErr.HandleError(ctx, serverErr.FromError(err))
+	}
+
+	return serverErr.ResponseJson(ctx, res)
+}
+
+// Claim godoc
+// @Summary      Claim a review case
+// @Description  Assigns the case to the caller for verification.review.claim-minutes, claiming it again extends the claim. Cases claimed by another reviewer can only be claimed once their claim ran out
+// @Tags         Reviews
+// @Produce      json
+// @Param        case_id   path      int  true  "case id"
+// @Security JWT
+// @Success      200  {object}  models.ReviewCaseDetail
+// @Failure      400,401,403,404,409,500  {object}  error.ErrorResponse
+// @Router       /reviews/{case_id}/claim [post]
+func (rh *ReviewHandler) Claim(ctx echo.Context) error {
+	caseId, err := strconv.Atoi(ctx.Param("case_id"))
+	if err != nil {
+		return serverErr.HandleError(ctx, errx.BadRequest.New(err.Error()))
+	}
+
+	res, err := rh.reviews.Claim(ctx.Request().Context(), caseId, auth.Caller(ctx))
+	if err != nil {
+		return serverErr.HandleError(ctx, serverErr.FromError(err))
+	}
+
+	return serverErr.ResponseJson(ctx, res)
+}
+
+// Note godoc
+// @Summary      Leave a note on a review case
+// @Description  Notes are kept along with the case and can't be edited nor removed
+// @Tags         Reviews
+// @Accept       json
+// @Produce      json
+// @Param        case_id   path      int  true  "case id"
+// @Param        note body models.ReviewNoteRequest true "note"
+// @Security JWT
+// @Success      200  {object}  models.ReviewNote
+// @Failure      400,401,403,404,500  {object}  error.ErrorResponse
+// @Router       /reviews/{case_id}/notes [post]
+func (rh *ReviewHandler) Note(ctx echo.Context) error {
+	caseId, err := strconv.Atoi(ctx.Param("case_id"))
+	if err != nil {
+		return serverErr.HandleError(ctx, errx.BadRequest.New(err.Error()))
+	}
+
+	var request models.ReviewNoteRequest
+	if err = ctx.Bind(&request); err != nil {
+		return serverErr.HandleError(ctx, errx.BadRequest.New(err.Error()))
+	}
+
+	if err = rh.validator.ValidateStruct(request); err != nil {
+		return serverErr.HandleError(ctx, serverErr.FromValidationError(err))
+	}
+
+	res, err := rh.reviews.Note(ctx.Request().Context(), caseId, auth.Caller(ctx), request)
+	if err != nil {
+		return serverErr.HandleError(ctx, serverErr.FromError(err))
+	}
+
+	return serverErr.ResponseJson(ctx, res)
+}
+
+// Decide godoc
+// @Summary      Approve or reject the verification under review
+// @Description  Only the reviewer who claimed the case decides. Approving moves the verification to verified with the manual method and the assurance level given, rejecting moves it to rejected; either way the transition is recorded in its history on behalf of the reviewer
+// @Tags         Reviews
+// @Accept       json
+// @Produce      json
+// @Param        case_id   path      int  true  "case id"
+// @Param        decision body models.ReviewDecision true "decision"
+// @Security JWT
+// @Success      200  {object}  models.ReviewCaseDetail
+// @Failure      400,401,403,404,409,500  {object}  error.ErrorResponse
+// @Router       /reviews/{case_id}/decision [post]
+func (rh *ReviewHandler) Decide(ctx echo.Context) error {
+	caseId, err := strconv.Atoi(ctx.Param("case_id"))
+	if err != nil {
+		return serverErr.HandleError(ctx, errx.BadRequest.New(err.Error()))
+	}
+
+	var decision models.ReviewDecision
+	if err = ctx.Bind(&decision); err != nil {
+		return serverErr.HandleError(ctx, errx.BadRequest.New(err.Error()))
+	}
+
+	if err = rh.validator.ValidateStruct(decision); err != nil {
+		return serverErr.HandleError(ctx, serverErr.FromValidationError(err))
+	}
+
+	res, err := rh.reviews.Decide(ctx.Request().Context(), caseId, auth.Caller(ctx), decision)
+	if err != nil {
+		return serverErr.HandleError(ctx, serverErr.FromError(err))
+	}
+
+	return serverErr.ResponseJson(ctx, res)
+}
@@ -0,0 +1,102 @@
+package handlers
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/labstack/echo/v4"
+	"github.com/rhuandantas/verifymy-test/internal/config"
+	errx "github.com/rhuandantas/verifymy-test/internal/errors"
+	"github.com/rhuandantas/verifymy-test/internal/search"
+	serverErr "github.com/rhuandantas/verifymy-test/internal/server/error"
+	"github.com/rhuandantas/verifymy-test/internal/server/middlewares/auth"
+)
+
+const (
+	defaultSearchLimit    = 20
+	defaultSearchMaxLimit = 100
+)
+
+type SearchHandler struct {
+	searcher     search.UserSearcher
+	token        auth.Token
+	signature    auth.Signature
+	defaultLimit int
+	maxLimit     int
+}
+
+func NewSearchHandler(config config.ConfigProvider, searcher search.UserSearcher, jwt auth.Token, signature auth.Signature) *SearchHandler {
+	maxLimit := config.GetInt("users.search.max-limit")
+	if maxLimit <= 0 {
+		maxLimit = defaultSearchMaxLimit
+	}
+
+	defaultLimit := config.GetInt("users.search.default-limit")
+	if defaultLimit <= 0 {
+		defaultLimit = defaultSearchLimit
+	}
+	if defaultLimit > maxLimit {
+		defaultLimit = maxLimit
+	}
+
+	return &SearchHandler{
+		searcher:     searcher,
+		token:        jwt,
+		signature:    signature,
+		defaultLimit: defaultLimit,
+		maxLimit:     maxLimit,
+	}
+}
+
+func (sh *SearchHandler) RegisterRoutes(server *echo.Echo) {
+	server.GET("/users/search", sh.Search, auth.Authenticate(sh.token, sh.signature))
+}
+
+// Search godoc
+// @Summary      Search users
+// @Description  Looks the words of q up in the name, email and address of active users, most relevant first. When no user has any of the words, users with words a few typos away are returned flagged as fuzzy. Matched words are wrapped in <em> in the highlights
+// @Tags         Users
+// @Produce      json
+// @Param        q   query      string  true  "words to search for"
+// @Param        limit   query      int  false  "most hits returned"
+// @Security JWT
+// @Success      200  {array}  search.Hit
+// @Failure      400,401,500  {object}  error.ErrorResponse
+// @Router       /users/search [get]
+func (sh *SearchHandler) Search(ctx echo.Context) error {
+	text := strings.TrimSpace(ctx.QueryParam("q"))
+	fields := make([]errx.FieldError, 0)
+	if text == "" {
+		fields = append(fields, errx.FieldError{Field: "q", Rule: "required", Message: "q is required"})
+	}
+
+	limit := sh.defaultLimit
+	if raw := ctx.QueryParam("limit"); raw != "" {
+		var err error
+		switch limit, err = strconv.Atoi(raw); {
+		case err != nil:
+			fields = append(fields, errx.FieldError{Field: "limit", Rule: "number", Message: "limit must be a number"})
+		case limit < 1:
+			fields = append(fields, errx.FieldError{Field: "limit", Rule: "min", Param: "1", Message: "limit must be at least 1"})
+		case limit > sh.maxLimit:
+			fields = append(fields, errx.FieldError{
+				Field:   "limit",
+				Rule:    "max",
+				Param:   strconv.Itoa(sh.maxLimit),
+				Message: fmt.Sprintf("limit must be at most %d", sh.maxLimit),
+			})
+		}
+	}
+
+	if len(fields) > 0 {
+		return serverErr.HandleError(ctx, errx.Localized(errx.Validation, "search.invalid", "search validation failed").WithProperty(errx.FieldErrorsProperty, fields))
+	}
+
+	hits, err := sh.searcher.Search(ctx.Request().Context(), text, limit)
+	if err != nil {
+		return serverErr.HandleError(ctx, serverErr.FromError(err))
+	}
+
+	return serverErr.ResponseJson(ctx, hits)
+}
@@ -1,42 +1,97 @@
 package handlers
 
 import (
+	"context"
+	"encoding/json"
 	"github.com/joomcode/errorx"
 	"github.com/labstack/echo/v4"
+	"github.com/rhuandantas/verifymy-test/internal/config"
 	errx "github.com/rhuandantas/verifymy-test/internal/errors"
+	"github.com/rhuandantas/verifymy-test/internal/guardian"
 	"github.com/rhuandantas/verifymy-test/internal/log"
 	"github.com/rhuandantas/verifymy-test/internal/models"
+	"github.com/rhuandantas/verifymy-test/internal/patch"
 	"github.com/rhuandantas/verifymy-test/internal/repo"
 	serverErr "github.com/rhuandantas/verifymy-test/internal/server/error"
 	"github.com/rhuandantas/verifymy-test/internal/server/middlewares/auth"
 	"github.com/rhuandantas/verifymy-test/internal/util"
+	"io"
+	"mime"
+	"reflect"
 	"strconv"
 )
 
 type UserHandler struct {
-	validator util.Validator
-	userRepo  repo.UserRepo
-	token     auth.Token
-	logger    log.SimpleLogger
+	validator       util.Validator
+	passwordPolicy  util.PasswordPolicy
+	userRepo        repo.UserRepo
+	consents        guardian.Consents
+	token           auth.Token
+	signature       auth.Signature
+	logger          log.SimpleLogger
+	batchMaxSize    int
+	batchInsertSize int
+	importMaxRows   int
+	exportBatchSize int
+	expanders       map[string]expander
 }
 
-func NewUserHandler(validator util.Validator, userRepo repo.UserRepo, jwt auth.Token, logger log.SimpleLogger) *UserHandler {
+func NewUserHandler(config config.ConfigProvider, validator util.Validator, passwordPolicy util.PasswordPolicy, userRepo repo.UserRepo, addressRepo repo.AddressRepo, verificationRepo repo.VerificationRepo, consents guardian.Consents, jwt auth.Token, signature auth.Signature, logger log.SimpleLogger) *UserHandler {
+	batchMaxSize := config.GetInt("users.batch.max-size")
+	if batchMaxSize <= 0 {
+		batchMaxSize = defaultBatchMaxSize
+	}
+
+	batchInsertSize := config.GetInt("users.batch.insert-size")
+	if batchInsertSize <= 0 {
+		batchInsertSize = defaultBatchInsertSize
+	}
+
+	importMaxRows := config.GetInt("users.import.max-rows")
+	if importMaxRows <= 0 {
+		importMaxRows = defaultImportMaxRows
+	}
+
+	exportBatchSize := config.GetInt("users.export.batch-size")
+	if exportBatchSize <= 0 {
+		exportBatchSize = defaultExportBatchSize
+	}
+
 	return &UserHandler{
-		validator: validator,
-		userRepo:  userRepo,
-		token:     jwt,
-		logger:    logger,
+		validator:       validator,
+		passwordPolicy:  passwordPolicy,
+		userRepo:        userRepo,
+		consents:        consents,
+		token:           jwt,
+		signature:       signature,
+		logger:          logger,
+		batchMaxSize:    batchMaxSize,
+		batchInsertSize: batchInsertSize,
+		importMaxRows:   importMaxRows,
+		exportBatchSize: exportBatchSize,
+		expanders: map[string]expander{
+			expandAddresses:    addressesExpander(addressRepo),
+			expandVerification: verificationExpander(verificationRepo),
+		},
 	}
 }
 
 func (uh *UserHandler) RegisterRoutes(server *echo.Echo) {
-	g := server.Group("/users", uh.token.VerifyToken)
+	g := server.Group("/users", auth.Authenticate(uh.token, uh.signature))
 	g.POST("", uh.Create)
+	g.POST("\\:batchCreate", uh.BatchCreate)
+	g.PATCH("\\:batchUpdate", uh.BatchUpdate)
+	g.POST("\\:batchDelete", uh.BatchDelete)
+	g.POST("/import", uh.Import)
+	g.GET("/export", uh.Export, auth.RequirePermission(auth.PermissionExportUsers))
 	g.PUT("/:id", uh.Update)
+	g.PATCH("/:id", uh.Patch)
 	g.DELETE("/:id", uh.Delete)
+	g.POST("/:id/restore", uh.Restore)
 	g.GET("/:id", uh.GetById)
 	g.GET("", uh.GetUsers)
 	server.GET("/token", uh.Token)
+	server.POST("/token", uh.Token)
 }
 
 // Create godoc
@@ -47,7 +102,7 @@ func (uh *UserHandler) RegisterRoutes(server *echo.Echo) {
 // @Param user body models.User true "user struct"
 // @Security JWT
 // @Success 	 200  {object} models.User
-// @Failure      400,401,404,500  {object}  error.ErrorResponse
+// @Failure      400,401,409,422,500  {object}  error.ErrorResponse
 // @Router /users [post]
 func (uh *UserHandler) Create(ctx echo.Context) error {
 	var (
@@ -60,31 +115,57 @@ func (uh *UserHandler) Create(ctx echo.Context) error {
 	}
 
 	if err = uh.validator.ValidateStruct(user); err != nil {
-		return serverErr.HandleError(ctx, errx.BadRequest.New(err.Error()))
+		return serverErr.HandleError(ctx, serverErr.FromValidationError(err))
+	}
+
+	minor := uh.consents.Restrict(&user)
+	if minor {
+		if err = guardian.ValidateGuardianEmail(user.Email, user.GuardianEmail); err != nil {
+			return serverErr.HandleError(ctx, serverErr.FromError(err))
+		}
 	}
 
 	res, err := uh.userRepo.Create(ctx.Request().Context(), user)
 	if err != nil {
-		return serverErr.HandleError(ctx, errorx.InternalError.New(err.Error()))
+		return serverErr.HandleError(ctx, serverErr.FromError(err))
 	}
 
 	if res != nil {
 		res.Password = ""
+		setETag(ctx, res.Version)
+	}
+
+	if minor && res != nil {
+		res.GuardianInvitation = uh.inviteGuardian(ctx, res.UserId, user.GuardianEmail)
 	}
 
 	return serverErr.ResponseJson(ctx, res)
 }
 
+// inviteGuardian invites the guardian of a minor just created. The user exists already, a failed invitation
+// leaves it restricted until a guardian is invited again
+func (uh *UserHandler) inviteGuardian(ctx echo.Context, userId int, guardianEmail string) *models.GuardianInvitation {
+	invite := models.GuardianInvite{GuardianEmail: guardianEmail}
+	invitation, err := uh.consents.Invite(ctx.Request().Context(), userId, invite, auth.Caller(ctx))
+	if err != nil {
+		uh.logger.Errorf("inviting guardian of user %d: %s", userId, err.Error())
+	}
+
+	return invitation
+}
+
 // Update godoc
 // @Summary Update a user.
 // @Tags Users
 // @Accept json
 // @Produce json
 // @Param        id   path      int  true  "user id"
+// @Param        If-Match   header      string  false  "ETag the user is expected to be at"
 // @Param user body models.User true "user struct"
 // @Security JWT
 // @Success 	 200  {object} models.User
-// @Failure      400,401,404,500  {object}  error.ErrorResponse
+// @Header       200  {string}  ETag  "version of the updated user"
+// @Failure      400,401,404,409,412,422,500  {object}  error.ErrorResponse
 // @Router /users/{id} [put]
 func (uh *UserHandler) Update(ctx echo.Context) error {
 	var (
@@ -97,20 +178,72 @@ func (uh *UserHandler) Update(ctx echo.Context) error {
 	}
 
 	if err = uh.validator.ValidateStruct(user); err != nil {
+		return serverErr.HandleError(ctx, serverErr.FromValidationError(err))
+	}
+
+	id, err := strconv.Atoi(ctx.Param("id"))
+	if err != nil {
 		return serverErr.HandleError(ctx, errx.BadRequest.New(err.Error()))
 	}
 
+	version, err := ifMatchVersion(ctx)
+	if err != nil {
+		return serverErr.HandleError(ctx, serverErr.FromError(err))
+	}
+
+	// the date of birth or timezone may make the user a minor, or no longer one
+	user.UserId = id
+	if err = uh.consents.Reassess(ctx.Request().Context(), &user); err != nil {
+		return serverErr.HandleError(ctx, serverErr.FromError(err))
+	}
+
+	res, err := uh.userRepo.Update(ctx.Request().Context(), id, user, version)
+	if err != nil {
+		return serverErr.HandleError(ctx, serverErr.FromError(err))
+	}
+
+	res.Password = ""
+	setETag(ctx, res.Version)
+	return serverErr.ResponseJson(ctx, res)
+}
+
+// Patch godoc
+// @Summary Partially update a user.
+// @Description Accepts a JSON Merge Patch (RFC 7396) or a JSON Patch (RFC 6902), only name, date_of_birth, timezone, email and address can change, age is computed from the date of birth
+// @Tags Users
+// @Accept application/merge-patch+json,application/json-patch+json
+// @Produce json
+// @Param        id   path      int  true  "user id"
+// @Param        If-Match   header      string  false  "ETag the user is expected to be at"
+// @Param patch body object true "merge patch object or json patch operations"
+// @Security JWT
+// @Success 	 200  {object} models.User
+// @Header       200  {string}  ETag  "version of the updated user"
+// @Failure      400,401,404,409,412,415,422,500  {object}  error.ErrorResponse
+// @Router /users/{id} [patch]
+func (uh *UserHandler) Patch(ctx echo.Context) error {
 	id, err := strconv.Atoi(ctx.Param("id"))
 	if err != nil {
 		return serverErr.HandleError(ctx, errx.BadRequest.New(err.Error()))
 	}
 
-	res, err := uh.userRepo.Update(ctx.Request().Context(), id, user)
+	version, err := ifMatchVersion(ctx)
 	if err != nil {
-		return serverErr.HandleError(ctx, errorx.InternalError.New(err.Error()))
+		return serverErr.HandleError(ctx, serverErr.FromError(err))
+	}
+
+	body, err := io.ReadAll(ctx.Request().Body)
+	if err != nil {
+		return serverErr.HandleError(ctx, errx.BadRequest.New(err.Error()))
+	}
+
+	res, err := uh.patchUser(ctx.Request().Context(), uh.userRepo, id, version, ctx.Request().Header.Get(echo.HeaderContentType), body)
+	if err != nil {
+		return serverErr.HandleError(ctx, serverErr.FromError(err))
 	}
 
 	res.Password = ""
+	setETag(ctx, res.Version)
 	return serverErr.ResponseJson(ctx, res)
 }
 
@@ -132,7 +265,7 @@ func (uh *UserHandler) Delete(ctx echo.Context) error {
 
 	res, err := uh.userRepo.Delete(ctx.Request().Context(), id)
 	if err != nil {
-		return serverErr.HandleError(ctx, errorx.InternalError.New(err.Error()))
+		return serverErr.HandleError(ctx, serverErr.FromError(err))
 	}
 
 	return serverErr.ResponseJson(ctx, echo.Map{
@@ -140,14 +273,42 @@ func (uh *UserHandler) Delete(ctx echo.Context) error {
 	})
 }
 
+// Restore godoc
+// @Summary      Restore a deleted user by id
+// @Description  Undo the deletion of a user, as long as it wasn't purged yet
+// @Tags         Users
+// @Produce      json
+// @Param        id   path      int  true  "user id"
+// @Security JWT
+// @Success      200  {object}  models.User
+// @Failure      400,401,404,409,500  {object}  error.ErrorResponse
+// @Router       /users/{id}/restore [post]
+func (uh *UserHandler) Restore(ctx echo.Context) error {
+	id, err := strconv.Atoi(ctx.Param("id"))
+	if err != nil {
+		return serverErr.HandleError(ctx, errx.BadRequest.New(err.Error()))
+	}
+
+	res, err := uh.userRepo.Restore(ctx.Request().Context(), id)
+	if err != nil {
+		return serverErr.HandleError(ctx, serverErr.FromError(err))
+	}
+	res.Password = ""
+
+	return serverErr.ResponseJson(ctx, res)
+}
+
 // GetById godoc
 // @Summary      Retrieve a user by id
 // @Description  get user by ID
 // @Tags         Users
 // @Produce      json
 // @Param        id   path      int  true  "user id"
+// @Param        fields   query      string  false  "comma separated fields to return, all of them by default"
+// @Param        expand   query      string  false  "comma separated related resources to embed, addresses or verification"
 // @Security JWT
 // @Success      200  {object}  models.User
+// @Header       200  {string}  ETag  "version of the user"
 // @Failure      400,401,404,500  {object}  error.ErrorResponse
 // @Router       /users/{id} [get]
 func (uh *UserHandler) GetById(ctx echo.Context) error {
@@ -156,53 +317,137 @@ func (uh *UserHandler) GetById(ctx echo.Context) error {
 		return serverErr.HandleError(ctx, errx.BadRequest.New(err.Error()))
 	}
 
-	res, err := uh.userRepo.GetByID(ctx.Request().Context(), id)
+	query, err := repo.UserQuery.Projection(ctx.QueryParams())
 	if err != nil {
-		return serverErr.HandleError(ctx, errorx.InternalError.New(err.Error()))
+		return serverErr.HandleError(ctx, serverErr.FromValidationError(err))
+	}
+
+	rep, err := uh.representationParams(ctx)
+	if err != nil {
+		return serverErr.HandleError(ctx, serverErr.FromValidationError(err))
+	}
+
+	res, err := uh.userRepo.GetByID(ctx.Request().Context(), id, query.Selected()...)
+	if err != nil {
+		return serverErr.HandleError(ctx, serverErr.FromError(err))
 	}
 	res.Password = ""
+	setETag(ctx, res.Version)
 
-	return serverErr.ResponseJson(ctx, res)
+	if rep.whole() {
+		return serverErr.ResponseJson(ctx, res)
+	}
+
+	resources, err := uh.render(ctx.Request().Context(), rep, []*models.User{res})
+	if err != nil {
+		return serverErr.HandleError(ctx, serverErr.FromError(err))
+	}
+
+	return serverErr.ResponseJson(ctx, resources[0])
 }
 
 // GetUsers godoc
 // @Summary      Retrieve all users
-// @Description  get all users
+// @Description  get a page of the users, by page number or after or before the cursor of a previous page. The Link header has the next, prev and first pages
 // @Tags         Users
 // @Produce      json
-// @Param        page   query      int  true  "page number"
+// @Param        page   query      int  false  "page number, from 0"
 // @Param        size   query      int  true  "size number"
+// @Param        after   query      string  false  "next cursor of a previous page"
+// @Param        before   query      string  false  "prev cursor of a previous page"
+// @Param        total   query      bool  false  "count every user of the listing"
+// @Param        include_deleted   query      bool  false  "include soft deleted users, admin only"
+// @Param        email   query      string  false  "exact email"
+// @Param        email_prefix   query      string  false  "email starting with"
+// @Param        name   query      string  false  "exact name"
+// @Param        name_prefix   query      string  false  "name starting with"
+// @Param        age_gte   query      int  false  "minimum age, computed from the date of birth on today's UTC date"
+// @Param        age_lte   query      int  false  "maximum age, computed from the date of birth on today's UTC date"
+// @Param        created_at_gte   query      string  false  "created at or after, RFC 3339"
+// @Param        created_at_lte   query      string  false  "created at or before, RFC 3339"
+// @Param        sort   query      string  false  "comma separated user_id, name, email, age, date_of_birth, created_at or updated_at, - prefix sorts descending"
+// @Param        fields   query      string  false  "comma separated fields to return, all of them by default"
+// @Param        expand   query      string  false  "comma separated related resources to embed, addresses or verification"
 // @Security JWT
-// @Success      200  {array}  models.User
-// @Failure      400,401,404,500  {object}  error.ErrorResponse
+// @Success      200  {object}  models.UserPage
+// @Header       200  {string}  Link  "next, prev and first pages, RFC 8288"
+// @Failure      400,401,403,404,500  {object}  error.ErrorResponse
 // @Router       /users [get]
 func (uh *UserHandler) GetUsers(ctx echo.Context) error {
 	pagination, err := uh.getPagination(ctx)
 	if err != nil {
-		return err
+		return serverErr.HandleError(ctx, serverErr.FromValidationError(err))
 	}
 
-	res, err := uh.userRepo.GetUsers(ctx.Request().Context(), pagination.Size, pagination.Page)
+	includeDeleted, err := includeDeletedParam(ctx)
 	if err != nil {
-		return serverErr.HandleError(ctx, errorx.InternalError.New(err.Error()))
+		return serverErr.HandleError(ctx, serverErr.FromError(err))
 	}
 
-	return serverErr.ResponseJson(ctx, res)
+	query, err := repo.UserQuery.Build(ctx.QueryParams())
+	if err != nil {
+		return serverErr.HandleError(ctx, serverErr.FromValidationError(err))
+	}
+
+	rep, err := uh.representationParams(ctx)
+	if err != nil {
+		return serverErr.HandleError(ctx, serverErr.FromValidationError(err))
+	}
+
+	page, err := uh.userRepo.GetUsers(ctx.Request().Context(), query, *pagination, includeDeleted)
+	if err != nil {
+		return serverErr.HandleError(ctx, serverErr.FromError(err))
+	}
+
+	setPageLinks(ctx, page)
+	if rep.whole() {
+		return serverErr.ResponseJson(ctx, page)
+	}
+
+	resources, err := uh.render(ctx.Request().Context(), rep, page.Items)
+	if err != nil {
+		return serverErr.HandleError(ctx, serverErr.FromError(err))
+	}
+
+	return serverErr.ResponseJson(ctx, resourcePage{Items: resources, Next: page.Next, Prev: page.Prev, Total: page.Total})
 }
 
 // Token godoc
 // @Summary      Token
+// @Description  Logs a user in with their email and password, sent as basic auth or in the body. Only the users of auth.jwt.admins get admin tokens
 // @Tags         Auth
+// @Accept       json
 // @Produce      json
+// @Param        credentials body models.Credentials false "credentials, unless sent as basic auth"
+// @Security     BasicAuth
 // @Success      200  {string} token ""
-// @Failure      400,401,404,500  {object}  error.ErrorResponse
+// @Failure      400,401,500  {object}  error.ErrorResponse
 // @Router       /token [get]
+// @Router       /token [post]
 func (uh *UserHandler) Token(ctx echo.Context) error {
-	var (
-		err error
-	)
+	var credentials models.Credentials
+	if email, password, ok := ctx.Request().BasicAuth(); ok {
+		credentials = models.Credentials{Email: email, Password: password}
+	} else if err := ctx.Bind(&credentials); err != nil {
+		return serverErr.HandleError(ctx, errx.BadRequest.New(err.Error()))
+	}
 
-	token, err := uh.token.GenerateToken("admin")
+	if err := uh.validator.ValidateStruct(credentials); err != nil {
+		return serverErr.HandleError(ctx, serverErr.FromValidationError(err))
+	}
+
+	user, err := uh.userRepo.GetByEmail(ctx.Request().Context(), credentials.Email)
+	if err != nil && !errorx.IsOfType(err, errx.NotFound) {
+		return serverErr.HandleError(ctx, serverErr.FromError(err))
+	}
+
+	// unknown emails and wrong passwords look the same so logging in doesn't tell which emails are registered
+	login := models.User{Password: credentials.Password}
+	if user == nil || login.VerifyPassword(user.Password) != nil {
+		return serverErr.HandleError(ctx, errx.Localized(errx.Unauthorized, "auth.credentials", "email or password is not valid"))
+	}
+
+	token, err := uh.token.GenerateToken(user.Email)
 	if err != nil {
 		return serverErr.HandleError(ctx, errorx.InternalError.New(err.Error()))
 	}
@@ -210,6 +455,141 @@ func (uh *UserHandler) Token(ctx echo.Context) error {
 	return serverErr.ResponseJson(ctx, echo.Map{"token": token})
 }
 
+// patchUser applies the patch to the user as it's stored, a version other than 0 must match the stored one
+func (uh *UserHandler) patchUser(ctx context.Context, userRepo repo.UserRepo, id, version int, contentType string, body []byte) (*models.User, error) {
+	current, err := userRepo.GetByID(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	if version > 0 && version != current.Version {
+		return nil, errx.Localized(errx.PreconditionFailed, "users.version_mismatch", "User %d is at version %d, not %d", id, current.Version, version)
+	}
+
+	current.Password = ""
+	original, err := json.Marshal(current)
+	if err != nil {
+		return nil, err
+	}
+
+	patched, err := applyPatch(contentType, original, body)
+	if err != nil {
+		return nil, err
+	}
+
+	var user models.User
+	if err = json.Unmarshal(patched, &user); err != nil {
+		return nil, errx.BadRequest.New(err.Error())
+	}
+
+	if err = uh.validator.ValidateStruct(user); err != nil {
+		return nil, serverErr.FromValidationError(err)
+	}
+
+	columns, err := changedColumns(original, patched, user)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(columns) == 0 {
+		return current, nil
+	}
+
+	_, dateOfBirthChanged := columns["date_of_birth"]
+	_, timezoneChanged := columns["timezone"]
+	if dateOfBirthChanged || timezoneChanged {
+		user.UserId = id
+		if err = uh.consents.Reassess(ctx, &user); err != nil {
+			return nil, err
+		}
+		if user.Status != current.Status {
+			columns["status"] = user.Status
+		}
+	}
+
+	// the patch was computed on the current version, so it's also the one expected by the update
+	return userRepo.Patch(ctx, id, columns, current.Version)
+}
+
+// applyPatch applies the patch body to the original document according to its media type
+func applyPatch(contentType string, original, body []byte) ([]byte, error) {
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return nil, errx.Localized(errx.UnsupportedMedia, "content_type.invalid", "content type %q is not valid", contentType)
+	}
+
+	switch mediaType {
+	case patch.MIMEApplicationMergePatchJSON:
+		return patch.MergePatch(original, body)
+	case patch.MIMEApplicationJSONPatchJSON:
+		return patch.JSONPatch(original, body)
+	default:
+		return nil, errx.Localized(errx.UnsupportedMedia, "content_type.unsupported", "content type must be %s or %s", patch.MIMEApplicationMergePatchJSON, patch.MIMEApplicationJSONPatchJSON)
+	}
+}
+
+// changedColumns compares the user documents before and after the patch and returns the columns to update,
+// fields outside of the patchable ones can't change
+func changedColumns(original, patched []byte, user models.User) (map[string]interface{}, error) {
+	patchable := map[string]interface{}{
+		"name":          user.Name,
+		"date_of_birth": user.DateOfBirth,
+		"timezone":      user.Timezone,
+		"email":         user.Email,
+		"address":       user.Address,
+	}
+
+	var before, after map[string]interface{}
+	if err := json.Unmarshal(original, &before); err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(patched, &after); err != nil {
+		return nil, errx.Localized(errx.BadRequest, "patch.not_object", "patched user must be an object")
+	}
+
+	fields := make(map[string]bool)
+	for field := range before {
+		fields[field] = true
+	}
+	for field := range after {
+		fields[field] = true
+	}
+
+	columns := make(map[string]interface{})
+	for field := range fields {
+		if reflect.DeepEqual(before[field], after[field]) {
+			continue
+		}
+
+		value, ok := patchable[field]
+		if !ok {
+			return nil, errx.Localized(errx.BadRequest, "patch.read_only", "field %s can't be patched", field)
+		}
+		columns[field] = value
+	}
+
+	return columns, nil
+}
+
+// includeDeletedParam reads the include_deleted query param, only admins can see deleted users
+func includeDeletedParam(ctx echo.Context) (bool, error) {
+	param := ctx.QueryParam("include_deleted")
+	if param == "" {
+		return false, nil
+	}
+
+	includeDeleted, err := strconv.ParseBool(param)
+	if err != nil {
+		return false, errx.Localized(errx.BadRequest, "users.include_deleted_boolean", "include_deleted must be a boolean")
+	}
+
+	if includeDeleted && !auth.IsAdmin(ctx) {
+		return false, errx.Localized(errx.Forbidden, "users.include_deleted_forbidden", "only admins can list deleted users")
+	}
+
+	return includeDeleted, nil
+}
+
 func (uh *UserHandler) getPagination(ctx echo.Context) (*models.Pagination, error) {
 	var (
 		pagination models.Pagination
@@ -217,11 +597,19 @@ func (uh *UserHandler) getPagination(ctx echo.Context) (*models.Pagination, erro
 	)
 
 	if err = ctx.Bind(&pagination); err != nil {
-		return nil, serverErr.HandleError(ctx, errx.BadRequest.New(err.Error()))
+		return nil, errx.BadRequest.New(err.Error())
 	}
 
 	if err = uh.validator.ValidateStruct(pagination); err != nil {
-		return nil, serverErr.HandleError(ctx, errx.BadRequest.New(err.Error()))
+		return nil, err
+	}
+
+	if pagination.After != "" && pagination.Before != "" {
+		return nil, errx.Localized(errx.BadRequest, "cursor.after_and_before", "after and before can't be used together")
+	}
+
+	if pagination.Page > 0 && (pagination.After != "" || pagination.Before != "") {
+		return nil, errx.Localized(errx.BadRequest, "cursor.with_page", "page can't be used along with a cursor")
 	}
 
 	return &pagination, nil
@@ -0,0 +1,228 @@
+package handlers
+
+import (
+	"context"
+	"io"
+	"strconv"
+	"time"
+
+	"github.com/labstack/echo/v4"
+	"github.com/rhuandantas/verifymy-test/internal/clients"
+	errx "github.com/rhuandantas/verifymy-test/internal/errors"
+	"github.com/rhuandantas/verifymy-test/internal/models"
+	"github.com/rhuandantas/verifymy-test/internal/repo"
+	serverErr "github.com/rhuandantas/verifymy-test/internal/server/error"
+	"github.com/rhuandantas/verifymy-test/internal/server/middlewares/auth"
+	"github.com/rhuandantas/verifymy-test/internal/util"
+	"github.com/rhuandantas/verifymy-test/internal/verification"
+)
+
+// expandVerification embeds the verification of the users with ?expand=verification
+const expandVerification = "verification"
+
+type VerificationHandler struct {
+	validator util.Validator
+	service   verification.Service
+	checks    verification.Checks
+	meter     clients.Meter
+	token     auth.Token
+	signature auth.Signature
+}
+
+func NewVerificationHandler(validator util.Validator, service verification.Service, checks verification.Checks, meter clients.Meter, jwt auth.Token, signature auth.Signature) *VerificationHandler {
+	return &VerificationHandler{
+		validator: validator,
+		service:   service,
+		checks:    checks,
+		meter:     meter,
+		token:     jwt,
+		signature: signature,
+	}
+}
+
+func (vh *VerificationHandler) RegisterRoutes(server *echo.Echo) {
+	authenticate := auth.Authenticate(vh.token, vh.signature)
+	server.GET("/users/:id/verification", vh.Get, authenticate)
+	server.POST("/users/:id/verification", vh.Transition, authenticate, auth.RequirePermission(auth.PermissionVerifyUsers))
+	server.POST("/users/:id/verification/start", vh.Start, authenticate, auth.RequirePermission(auth.PermissionVerifyUsers), auth.RequireQuota(vh.meter, models.MetricVerifications))
+	server.POST("/users/:id/verification/poll", vh.Poll, authenticate, auth.RequirePermission(auth.PermissionVerifyUsers))
+	// providers authenticate their callbacks with their own signature
+	server.POST("/verification/callbacks/:provider", vh.Callback)
+}
+
+// Get godoc
+// @Summary      Age verification of a user
+// @Description  Where the age verification of the user stands, with every transition it went through. A user that never started one is unverified
+// @Tags         Verification
+// @Produce      json
+// @Param        id   path      int  true  "user id"
+// @Security JWT
+// @Success      200  {object}  models.VerificationStatus
+// @Failure      400,401,404,500  {object}  error.ErrorResponse
+// @Router       /users/{id}/verification [get]
+func (vh *VerificationHandler) Get(ctx echo.Context) error {
+	userId, err := strconv.Atoi(ctx.Param("id"))
+	if err != nil {
+		return serverErr.HandleError(ctx, errx.BadRequest.New(err.Error()))
+	}
+
+	res, err := vh.service.Get(ctx.Request().Context(), userId)
+	if err != nil {
+		return serverErr.HandleError(ctx, serverErr.FromError(err))
+	}
+
+	return serverErr.ResponseJson(ctx, res)
+}
+
+// Transition godoc
+// @Summary      Move the age verification of a user to another state
+// @Description  unverified goes to pending, pending to verified, rejected or expired, verified to expired, and rejected or expired back to pending. Pending needs a method, verified an assurance level and an evidence reference, and rejected a reason. Needs the users:verify permission
+// @Tags         Verification
+// @Accept       json
+// @Produce      json
+// @Param        id   path      int  true  "user id"
+// @Param        transition body models.VerificationTransition true "transition"
+// @Security JWT
+// @Success      200  {object}  models.VerificationStatus
+// @Failure      400,401,403,404,409,500  {object}  error.ErrorResponse
+// @Router       /users/{id}/verification [post]
+func (vh *VerificationHandler) Transition(ctx echo.Context) error {
+	userId, err := strconv.Atoi(ctx.Param("id"))
+	if err != nil {
+		return serverErr.HandleError(ctx, errx.BadRequest.New(err.Error()))
+	}
+
+	var transition models.VerificationTransition
+	if err = ctx.Bind(&transition); err != nil {
+		return serverErr.HandleError(ctx, errx.BadRequest.New(err.Error()))
+	}
+
+	if err = vh.validator.ValidateStruct(transition); err != nil {
+		return serverErr.HandleError(ctx, serverErr.FromValidationError(err))
+	}
+
+	res, err := vh.service.Transition(ctx.Request().Context(), userId, transition, auth.Caller(ctx))
+	if err != nil {
+		return serverErr.HandleError(ctx, serverErr.FromError(err))
+	}
+
+	return serverErr.ResponseJson(ctx, res)
+}
+
+// Start godoc
+// @Summary      Start a provider check of the age of a user
+// @Description  Opens a check with the provider, the default one when none is named, and moves the verification to pending with the provider and its reference as evidence. The user completes the check at the redirect url of the session, if any. Needs the users:verify permission, counts as a verification of the daily quota of a client
+// @Tags         Verification
+// @Accept       json
+// @Produce      json
+// @Param        id   path      int  true  "user id"
+// @Param        check body models.CheckRequest false "provider"
+// @Security JWT
+// @Success      200  {object}  verification.Check
+// @Failure      400,401,403,404,409,429,500  {object}  error.ErrorResponse
+// @Router       /users/{id}/verification/start [post]
+func (vh *VerificationHandler) Start(ctx echo.Context) error {
+	userId, err := strconv.Atoi(ctx.Param("id"))
+	if err != nil {
+		return serverErr.HandleError(ctx, errx.BadRequest.New(err.Error()))
+	}
+
+	var check models.CheckRequest
+	if err = ctx.Bind(&check); err != nil {
+		return serverErr.HandleError(ctx, errx.BadRequest.New(err.Error()))
+	}
+
+	if err = vh.validator.ValidateStruct(check); err != nil {
+		return serverErr.HandleError(ctx, serverErr.FromValidationError(err))
+	}
+
+	res, err := vh.checks.Start(ctx.Request().Context(), userId, check.Provider, auth.Caller(ctx))
+	if err != nil {
+		return serverErr.HandleError(ctx, serverErr.FromError(err))
+	}
+
+	return serverErr.ResponseJson(ctx, res)
+}
+
+// Poll godoc
+// @Summary      Ask the provider for the outcome of the pending check of a user
+// @Description  A verified or rejected outcome moves the verification on, a pending one leaves it as it is. Needs the users:verify permission
+// @Tags         Verification
+// @Produce      json
+// @Param        id   path      int  true  "user id"
+// @Security JWT
+// @Success      200  {object}  models.VerificationStatus
+// @Failure      400,401,403,404,409,500  {object}  error.ErrorResponse
+// @Router       /users/{id}/verification/poll [post]
+func (vh *VerificationHandler) Poll(ctx echo.Context) error {
+	userId, err := strconv.Atoi(ctx.Param("id"))
+	if err != nil {
+		return serverErr.HandleError(ctx, errx.BadRequest.New(err.Error()))
+	}
+
+	res, err := vh.checks.Poll(ctx.Request().Context(), userId)
+	if err != nil {
+		return serverErr.HandleError(ctx, serverErr.FromError(err))
+	}
+
+	return serverErr.ResponseJson(ctx, res)
+}
+
+// Callback godoc
+// @Summary      Outcome of a check pushed by a provider
+// @Description  Called by the provider itself, which signs the request its own way, e.g. the fake provider with the hex HMAC-SHA256 of the body in X-Fake-Signature. Outcomes of checks already settled are ignored
+// @Tags         Verification
+// @Accept       json
+// @Produce      json
+// @Param        provider   path      string  true  "provider name"
+// @Success      200  {object}  models.VerificationStatus
+// @Failure      400,401,404,409,500  {object}  error.ErrorResponse
+// @Router       /verification/callbacks/{provider} [post]
+func (vh *VerificationHandler) Callback(ctx echo.Context) error {
+	body, err := io.ReadAll(ctx.Request().Body)
+	if err != nil {
+		return serverErr.HandleError(ctx, errx.BadRequest.New(err.Error()))
+	}
+
+	res, err := vh.checks.Callback(ctx.Request().Context(), ctx.Param("provider"), ctx.Request().Header, body)
+	if err != nil {
+		return serverErr.HandleError(ctx, serverErr.FromError(err))
+	}
+
+	return serverErr.ResponseJson(ctx, res)
+}
+
+// verificationExpander embeds the verification of each user, unverified for users that never started one. A
+// verification past its validity is shown expired, the service stores that on the next read of the user
+func verificationExpander(verificationRepo repo.VerificationRepo) expander {
+	return func(ctx context.Context, users []*models.User) (map[int]interface{}, error) {
+		userIds := make([]int, 0, len(users))
+		for _, user := range users {
+			userIds = append(userIds, user.UserId)
+		}
+
+		byUser, err := verificationRepo.ListByUsers(ctx, userIds)
+		if err != nil {
+			return nil, err
+		}
+
+		now := time.Now()
+		verifications := make(map[int]interface{}, len(users))
+		for _, userId := range userIds {
+			current, ok := byUser[userId]
+			if !ok {
+				verifications[userId] = &models.Verification{UserId: userId, State: models.VerificationUnverified}
+				continue
+			}
+
+			if current.State == models.VerificationVerified && current.ExpiresAt != nil && !now.Before(*current.ExpiresAt) {
+				expired := *current
+				expired.State = models.VerificationExpired
+				current = &expired
+			}
+			verifications[userId] = current
+		}
+
+		return verifications, nil
+	}
+}
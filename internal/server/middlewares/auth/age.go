@@ -0,0 +1,113 @@
+package auth
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/joomcode/errorx"
+	"github.com/labstack/echo/v4"
+	errx "github.com/rhuandantas/verifymy-test/internal/errors"
+	"github.com/rhuandantas/verifymy-test/internal/models"
+	"github.com/rhuandantas/verifymy-test/internal/repo"
+	"github.com/rhuandantas/verifymy-test/internal/rules"
+	error2 "github.com/rhuandantas/verifymy-test/internal/server/error"
+	"github.com/rhuandantas/verifymy-test/internal/verification"
+)
+
+//go:generate mockgen -source=$GOFILE -package=mock_auth -destination=../../../../test/mock/auth/$GOFILE
+
+// AgeGate protects routes by the verified age of the caller
+type AgeGate interface {
+	// RequireMinimumAge answers 403 to callers not verified to be at least age years old, with the reason
+	// as in the eligibility rules and a link to start a verification when one would help. It must run after
+	// Authenticate
+	RequireMinimumAge(age int) echo.MiddlewareFunc
+}
+
+type VerifiedAgeGate struct {
+	userRepo repo.UserRepo
+	service  verification.Service
+	now      func() time.Time
+}
+
+func NewVerifiedAgeGate(userRepo repo.UserRepo, service verification.Service) AgeGate {
+	return &VerifiedAgeGate{
+		userRepo: userRepo,
+		service:  service,
+		now:      time.Now,
+	}
+}
+
+// RequireMinimumAge looks up the user registered with the email of the token, restricted minors are denied
+// whatever their token says. Then it trusts the verified_age claim of the token when it has one, otherwise
+// it goes by the verification of the user. Signed partner requests have no user behind them and are denied
+func (vag *VerifiedAgeGate) RequireMinimumAge(age int) echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			if err := vag.check(c, age); err != nil {
+				return error2.HandleError(c, error2.FromError(err))
+			}
+
+			return next(c)
+		}
+	}
+}
+
+func (vag *VerifiedAgeGate) check(c echo.Context, minimumAge int) error {
+	claims, ok := c.Get(ClaimsContextKey).(*jwtCustomClaims)
+	if !ok || claims.Email == "" {
+		return ageRestricted(rules.ReasonNotVerified, "", "age.verified_only", "only verified users may access this route")
+	}
+
+	user, err := vag.userRepo.GetByEmail(c.Request().Context(), claims.Email)
+	if err != nil && !errorx.IsOfType(err, errx.NotFound) {
+		return err
+	}
+
+	// the restriction may have come after the token, so it goes before the claim
+	if user != nil && user.Status == models.UserRestricted {
+		return ageRestricted(rules.ReasonConsentRequired, fmt.Sprintf("/users/%d/guardians", user.UserId), "age.consent_missing", "user is a minor without a guardian's consent")
+	}
+
+	if claims.VerifiedAge != nil {
+		if *claims.VerifiedAge < minimumAge {
+			return ageRestricted(rules.ReasonUnderAge, "", "age.under", "%d is under the minimum age of %d", *claims.VerifiedAge, minimumAge)
+		}
+		return nil
+	}
+
+	if user == nil {
+		return ageRestricted(rules.ReasonNotVerified, "", "age.unknown_user", "no user is registered with %s", claims.Email)
+	}
+
+	status, err := vag.service.Get(c.Request().Context(), user.UserId)
+	if err != nil {
+		return err
+	}
+
+	startLink := fmt.Sprintf("/users/%d/verification/start", user.UserId)
+	if status.State != models.VerificationVerified {
+		return ageRestricted(rules.ReasonNotVerified, startLink, "age.not_verified", "age is not verified, verification is %s", status.State)
+	}
+
+	userAge := status.AgeAt(vag.now())
+	switch {
+	case userAge == nil:
+		return ageRestricted(rules.ReasonUnknownAge, startLink, "age.unknown", "date of birth is unknown")
+	case *userAge < minimumAge:
+		return ageRestricted(rules.ReasonUnderAge, "", "age.under", "%d is under the minimum age of %d", *userAge, minimumAge)
+	}
+
+	return nil
+}
+
+// ageRestricted denies access for reason, link is where the caller can do something about it, if anywhere.
+// key is the catalog detail of message
+func ageRestricted(reason, link, key, message string, args ...interface{}) error {
+	err := errx.Localized(errx.AgeRestricted, key, message, args...).WithProperty(errx.ReasonProperty, reason)
+	if link != "" {
+		err = err.WithProperty(errx.LinkProperty, link)
+	}
+
+	return err
+}
@@ -0,0 +1,109 @@
+package auth
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"encoding/pem"
+	"fmt"
+
+	"github.com/golang-jwt/jwt/v4"
+	"github.com/rhuandantas/verifymy-test/internal/config"
+	"github.com/rhuandantas/verifymy-test/internal/log"
+	"github.com/rhuandantas/verifymy-test/pkg/attestation"
+)
+
+//go:generate mockgen -source=$GOFILE -package=mock_auth -destination=../../../../test/mock/auth/$GOFILE
+
+// Attester signs the age attestations handed to relying parties. Unlike the api tokens they're signed
+// with a private key so anyone can check them with the published key set
+type Attester interface {
+	// Issuer is the iss claim of the attestations
+	Issuer() string
+	Sign(claims *attestation.Claims) (string, error)
+	KeySet() attestation.KeySet
+}
+
+type EcdsaAttester struct {
+	issuer string
+	key    *ecdsa.PrivateKey
+	jwk    attestation.JWK
+}
+
+// NewEcdsaAttester signs with the PEM encoded P-256 key in the env var named at auth.attestation.private-key,
+// failing without one. Only with dev.temporary-keys a key is generated instead, attestations then stop
+// verifying once the server restarts
+func NewEcdsaAttester(config config.ConfigProvider, logger log.SimpleLogger) (Attester, error) {
+	var key *ecdsa.PrivateKey
+	var err error
+	secretKey := config.GetString("auth.attestation.private-key")
+	switch {
+	case secretKey != "" && config.GetString(secretKey) != "":
+		if key, err = parseEcdsaKey(config.GetString(secretKey)); err != nil {
+			return nil, fmt.Errorf("attestation key in %s: %w", secretKey, err)
+		}
+	case !config.GetBool("dev.temporary-keys"):
+		return nil, fmt.Errorf("no attestation key set in %q, see auth.attestation.private-key", secretKey)
+	default:
+		logger.Warn("no attestation key configured, using a temporary one")
+		if key, err = ecdsa.GenerateKey(elliptic.P256(), rand.Reader); err != nil {
+			return nil, err
+		}
+	}
+
+	return &EcdsaAttester{
+		issuer: config.GetStringOrDefault("auth.attestation.issuer", "verifymy"),
+		key:    key,
+		jwk:    attestation.NewJWK(&key.PublicKey),
+	}, nil
+}
+
+func (ea *EcdsaAttester) Issuer() string {
+	return ea.issuer
+}
+
+func (ea *EcdsaAttester) Sign(claims *attestation.Claims) (string, error) {
+	claims.Issuer = ea.issuer
+	token := jwt.NewWithClaims(jwt.SigningMethodES256, claims)
+	token.Header["typ"] = attestation.TokenType
+	token.Header["kid"] = ea.jwk.KeyId
+
+	return token.SignedString(ea.key)
+}
+
+func (ea *EcdsaAttester) KeySet() attestation.KeySet {
+	return attestation.KeySet{Keys: []attestation.JWK{ea.jwk}}
+}
+
+// parseEcdsaKey reads a P-256 key in either SEC 1 (EC PRIVATE KEY) or PKCS #8 (PRIVATE KEY) form
+func parseEcdsaKey(value string) (*ecdsa.PrivateKey, error) {
+	block, _ := pem.Decode([]byte(value))
+	if block == nil {
+		return nil, fmt.Errorf("not a PEM encoded key")
+	}
+
+	if key, err := x509.ParseECPrivateKey(block.Bytes); err == nil {
+		return p256(key)
+	}
+
+	parsed, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		return nil, err
+	}
+
+	key, ok := parsed.(*ecdsa.PrivateKey)
+	if !ok {
+		return nil, fmt.Errorf("not an ECDSA key")
+	}
+
+	return p256(key)
+}
+
+func p256(key *ecdsa.PrivateKey) (*ecdsa.PrivateKey, error) {
+	if key.Curve != elliptic.P256() {
+		return nil, fmt.Errorf("not a P-256 key")
+	}
+
+	return key, nil
+}
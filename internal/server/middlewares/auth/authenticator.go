@@ -0,0 +1,34 @@
+package auth
+
+import "github.com/labstack/echo/v4"
+
+// Authenticate chooses the authentication middleware by the request Authorization scheme,
+// HMAC signed requests go through the signature verification and everything else through the jwt token
+func Authenticate(token Token, signature Signature) echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		verifyToken := token.VerifyToken(next)
+		verifySignature := signature.VerifySignature(next)
+
+		return func(c echo.Context) error {
+			if IsHmacSigned(c.Request()) {
+				return verifySignature(c)
+			}
+
+			return verifyToken(c)
+		}
+	}
+}
+
+// Caller names who made an authenticated request, the email of the token or partner:{credential}
+// for a signed request
+func Caller(c echo.Context) string {
+	if partner, ok := c.Get(PartnerContextKey).(string); ok && partner != "" {
+		return "partner:" + partner
+	}
+
+	if claims, ok := c.Get(ClaimsContextKey).(*jwtCustomClaims); ok {
+		return claims.Email
+	}
+
+	return ""
+}
@@ -0,0 +1,308 @@
+package auth
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/joomcode/errorx"
+	"github.com/labstack/echo/v4"
+	"github.com/rhuandantas/verifymy-test/internal/clients"
+	"github.com/rhuandantas/verifymy-test/internal/config"
+	"github.com/rhuandantas/verifymy-test/internal/errors"
+	"github.com/rhuandantas/verifymy-test/internal/models"
+	error2 "github.com/rhuandantas/verifymy-test/internal/server/error"
+)
+
+//go:generate mockgen -source=$GOFILE -package=mock_auth -destination=../../../../test/mock/auth/$GOFILE
+
+const (
+	HmacScheme        = "HMAC-SHA256"
+	PartnerContextKey = "partner"
+	// ClientContextKey is where VerifySignature keeps the *models.Client of a request signed by a registered client
+	ClientContextKey       = "client"
+	defaultTimestampWindow = 300
+)
+
+// requiredSignedHeaders must be signed, otherwise a captured signature could be replayed against another host
+// or with another content type as long as its nonce is unused
+var requiredSignedHeaders = []string{"content-type", "host"}
+
+type Signature interface {
+	VerifySignature(next echo.HandlerFunc) echo.HandlerFunc
+}
+
+// HmacSignature verifies requests signed by the partners of auth.hmac.partners and by the clients of the
+// registry, partners first. Every request of a client is metered as an api call
+type HmacSignature struct {
+	config   config.ConfigProvider
+	nonces   NonceCache
+	registry clients.Registry
+	meter    clients.Meter
+	now      func() time.Time
+}
+
+func NewHmacSignature(config config.ConfigProvider, nonces NonceCache, registry clients.Registry, meter clients.Meter) Signature {
+	return &HmacSignature{
+		config:   config,
+		nonces:   nonces,
+		registry: registry,
+		meter:    meter,
+		now:      time.Now,
+	}
+}
+
+// signatureParams holds the parameters sent through the Authorization header, e.g.
+// HMAC-SHA256 Credential=partner-a, SignedHeaders=content-type;host, Timestamp=1679964473, Nonce=abc, Signature=hex
+type signatureParams struct {
+	Credential    string
+	SignedHeaders []string
+	Timestamp     int64
+	Nonce         string
+	Signature     string
+}
+
+func (hs *HmacSignature) VerifySignature(next echo.HandlerFunc) echo.HandlerFunc {
+	return func(c echo.Context) error {
+		params, err := parseSignatureParams(c.Request().Header.Get(echo.HeaderAuthorization))
+		if err != nil {
+			return error2.HandleError(c, errors.Unauthorized.New(err.Error()))
+		}
+
+		secret, client, err := hs.getSecret(c, params.Credential)
+		if err != nil {
+			return error2.HandleError(c, error2.FromError(err))
+		}
+		if secret == "" {
+			return error2.HandleError(c, errors.Localized(errors.Unauthorized, "auth.unknown_credential", "unknown credential %s", params.Credential))
+		}
+
+		window := hs.getTimestampWindow()
+		if skew := hs.now().Sub(time.Unix(params.Timestamp, 0)); skew > window || skew < -window {
+			return error2.HandleError(c, errors.Localized(errors.Unauthorized, "auth.timestamp", "request timestamp is out of the allowed window"))
+		}
+
+		body, err := readBody(c.Request())
+		if err != nil {
+			return error2.HandleError(c, errors.BadRequest.New(err.Error()))
+		}
+
+		expected := sign(secret, canonicalRequest(c.Request(), *params, body))
+		provided, err := hex.DecodeString(params.Signature)
+		if err != nil || !hmac.Equal(expected, provided) {
+			return error2.HandleError(c, errors.Localized(errors.Unauthorized, "auth.signature", "signature is not valid"))
+		}
+
+		// the nonce is only recorded once the signature is known to be genuine, otherwise
+		// anyone could burn nonces of a partner
+		if !hs.nonces.Add(params.Credential+":"+params.Nonce, 2*window) {
+			return error2.HandleError(c, errors.Localized(errors.Unauthorized, "auth.nonce", "nonce has already been used"))
+		}
+
+		c.Set(PartnerContextKey, params.Credential)
+		if client == nil {
+			c.Set(PermissionsContextKey, hs.config.GetStringSlice(fmt.Sprintf("auth.hmac.partners.%s.permissions", params.Credential)))
+			return next(c)
+		}
+
+		if client.Status != models.ClientActive {
+			return error2.HandleError(c, errors.Localized(errors.Forbidden, "clients.status", "client %s is %s", client.ClientId, client.Status))
+		}
+		if origin := c.Request().Header.Get(echo.HeaderOrigin); origin != "" && !client.AllowsOrigin(origin) {
+			return error2.HandleError(c, errors.Localized(errors.Forbidden, "clients.origin", "origin %s is not allowed for client %s", origin, client.ClientId))
+		}
+
+		// the call counts whether the quota lets it through or not, Check counts the ones it lets through
+		if _, err = hs.meter.Check(c.Request().Context(), client, models.MetricApiCalls); err != nil {
+			hs.meter.Record(client.ClientId, models.MetricApiCalls)
+			return handleQuotaError(c, err)
+		}
+
+		c.Set(ClientContextKey, client)
+		c.Set(PermissionsContextKey, client.Permissions)
+
+		return next(c)
+	}
+}
+
+// getSecret returns the secret of the partner with the credential, or of the client along with the client.
+// The secret is empty when the credential is neither
+func (hs *HmacSignature) getSecret(c echo.Context, credential string) (string, *models.Client, error) {
+	if secret := hs.getPartnerSecret(credential); secret != "" {
+		return secret, nil, nil
+	}
+
+	client, secret, err := hs.registry.Credentials(c.Request().Context(), credential)
+	if errorx.IsOfType(err, errors.NotFound) {
+		return "", nil, nil
+	}
+
+	return secret, client, err
+}
+
+func (hs *HmacSignature) getPartnerSecret(partner string) string {
+	if partner == "" || strings.ContainsAny(partner, ".") {
+		return ""
+	}
+
+	secretKey := hs.config.GetString(fmt.Sprintf("auth.hmac.partners.%s.secret-key", partner))
+	if secretKey == "" {
+		return ""
+	}
+
+	return hs.config.GetEnv(secretKey)
+}
+
+func (hs *HmacSignature) getTimestampWindow() time.Duration {
+	window := hs.config.GetInt("auth.hmac.timestamp-window")
+	if window <= 0 {
+		window = defaultTimestampWindow
+	}
+
+	return time.Duration(window) * time.Second
+}
+
+// IsHmacSigned tells whether the request carries an HMAC-SHA256 Authorization header
+func IsHmacSigned(r *http.Request) bool {
+	return strings.HasPrefix(r.Header.Get(echo.HeaderAuthorization), HmacScheme+" ")
+}
+
+// SignRequest signs the request on behalf of a partner, it's meant to be used by clients and tests. The
+// requiredSignedHeaders are signed along with the headers given
+func SignRequest(r *http.Request, partner, secret, nonce string, timestamp time.Time, headers ...string) error {
+	body, err := readBody(r)
+	if err != nil {
+		return err
+	}
+
+	params := signatureParams{
+		Credential:    partner,
+		SignedHeaders: normalizeHeaderNames(append(headers, requiredSignedHeaders...)),
+		Timestamp:     timestamp.Unix(),
+		Nonce:         nonce,
+	}
+	params.Signature = hex.EncodeToString(sign(secret, canonicalRequest(r, params, body)))
+
+	r.Header.Set(echo.HeaderAuthorization, fmt.Sprintf("%s Credential=%s, SignedHeaders=%s, Timestamp=%d, Nonce=%s, Signature=%s",
+		HmacScheme, params.Credential, strings.Join(params.SignedHeaders, ";"), params.Timestamp, params.Nonce, params.Signature))
+
+	return nil
+}
+
+func parseSignatureParams(header string) (*signatureParams, error) {
+	if !strings.HasPrefix(header, HmacScheme+" ") {
+		return nil, fmt.Errorf("authorization scheme must be %s", HmacScheme)
+	}
+
+	params := &signatureParams{}
+	for _, part := range strings.Split(strings.TrimPrefix(header, HmacScheme+" "), ",") {
+		key, value, found := strings.Cut(strings.TrimSpace(part), "=")
+		if !found {
+			return nil, fmt.Errorf("malformed authorization parameter %q", part)
+		}
+
+		switch key {
+		case "Credential":
+			params.Credential = value
+		case "SignedHeaders":
+			if value != "" {
+				params.SignedHeaders = strings.Split(value, ";")
+			}
+		case "Timestamp":
+			timestamp, err := strconv.ParseInt(value, 10, 64)
+			if err != nil {
+				return nil, fmt.Errorf("timestamp must be unix seconds")
+			}
+			params.Timestamp = timestamp
+		case "Nonce":
+			params.Nonce = value
+		case "Signature":
+			params.Signature = value
+		}
+	}
+
+	if params.Credential == "" || params.Timestamp == 0 || params.Nonce == "" || params.Signature == "" {
+		return nil, fmt.Errorf("credential, timestamp, nonce and signature are required")
+	}
+
+	for _, name := range requiredSignedHeaders {
+		if !contains(params.SignedHeaders, name) {
+			return nil, fmt.Errorf("signed headers must include %s", strings.Join(requiredSignedHeaders, " and "))
+		}
+	}
+
+	return params, nil
+}
+
+// canonicalRequest builds the string to sign:
+// method, path and query, signed headers, timestamp, nonce and the hex sha256 of the body, one per line
+func canonicalRequest(r *http.Request, params signatureParams, body []byte) string {
+	var sb strings.Builder
+	sb.WriteString(r.Method + "\n")
+	sb.WriteString(r.URL.RequestURI() + "\n")
+	for _, name := range params.SignedHeaders {
+		value := r.Header.Get(name)
+		if name == "host" {
+			value = r.Host
+		}
+		sb.WriteString(name + ":" + strings.TrimSpace(value) + "\n")
+	}
+	sb.WriteString(strings.Join(params.SignedHeaders, ";") + "\n")
+	sb.WriteString(strconv.FormatInt(params.Timestamp, 10) + "\n")
+	sb.WriteString(params.Nonce + "\n")
+	digest := sha256.Sum256(body)
+	sb.WriteString(hex.EncodeToString(digest[:]))
+
+	return sb.String()
+}
+
+func sign(secret, payload string) []byte {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(payload))
+	return mac.Sum(nil)
+}
+
+func normalizeHeaderNames(headers []string) []string {
+	names := make([]string, 0, len(headers))
+	for _, header := range headers {
+		if name := strings.ToLower(strings.TrimSpace(header)); !contains(names, name) {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+
+	return names
+}
+
+// readBody reads the request body and puts it back so handlers can still bind it
+func readBody(r *http.Request) ([]byte, error) {
+	if r.Body == nil {
+		return []byte{}, nil
+	}
+
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		return nil, err
+	}
+	r.Body = io.NopCloser(bytes.NewReader(body))
+
+	return body, nil
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+
+	return false
+}
@@ -27,18 +27,27 @@ func NewJwtToken(config config.ConfigProvider) Token {
 	}
 }
 
+// ClaimsContextKey is where VerifyToken keeps the claims of a valid token
+const ClaimsContextKey = "claims"
+
+// jwtCustomClaims are the claims of a token. VerifiedAge is the age the user was verified to be when the
+// token was issued, only set by identity providers that know it
 type jwtCustomClaims struct {
-	Email   string `json:"email"`
-	IsAdmin bool
+	Email       string `json:"email"`
+	IsAdmin     bool
+	Permissions []string `json:"permissions,omitempty"`
+	VerifiedAge *int     `json:"verified_age,omitempty"`
 	jwt.RegisteredClaims
 }
 
+// GenerateToken issues the token of the user logged in with email, only the emails of auth.jwt.admins get
+// admin tokens
 func (jt *JwtToken) GenerateToken(email string) (string, error) {
 	secret := []byte(jt.config.GetEnv("AUTH_SECRET"))
 	claims := &jwtCustomClaims{
-		email,
-		true,
-		jwt.RegisteredClaims{
+		Email:   email,
+		IsAdmin: jt.isAdmin(email),
+		RegisteredClaims: jwt.RegisteredClaims{
 			ExpiresAt: jwt.NewNumericDate(time.Now().Add(time.Hour * 1)),
 		},
 	}
@@ -58,11 +67,12 @@ func (jt *JwtToken) VerifyToken(next echo.HandlerFunc) echo.HandlerFunc {
 	return func(c echo.Context) error {
 		tokenStr := jt.getToken(c)
 		if tokenStr == "" {
-			return error2.HandleError(c, errors.Unauthorized.New("authentication key not found"))
+			return error2.HandleError(c, errors.Localized(errors.Unauthorized, "auth.missing", "authentication key not found"))
 		}
 
 		secret := []byte(jt.config.GetEnv("AUTH_SECRET"))
-		tkn, err := jwt.ParseWithClaims(tokenStr, &jwtCustomClaims{}, func(token *jwt.Token) (interface{}, error) {
+		claims := &jwtCustomClaims{}
+		tkn, err := jwt.ParseWithClaims(tokenStr, claims, func(token *jwt.Token) (interface{}, error) {
 			return secret, nil
 		})
 		if err != nil {
@@ -70,13 +80,32 @@ func (jt *JwtToken) VerifyToken(next echo.HandlerFunc) echo.HandlerFunc {
 		}
 
 		if !tkn.Valid {
-			return error2.HandleError(c, errors.Unauthorized.New("authentication is not valid"))
+			return error2.HandleError(c, errors.Localized(errors.Unauthorized, "auth.invalid", "authentication is not valid"))
 		}
 
+		c.Set(ClaimsContextKey, claims)
+		c.Set(PermissionsContextKey, claims.Permissions)
+
 		return next(c)
 	}
 }
 
+// IsAdmin tells whether the request was authenticated with an admin token
+func IsAdmin(c echo.Context) bool {
+	claims, ok := c.Get(ClaimsContextKey).(*jwtCustomClaims)
+	return ok && claims.IsAdmin
+}
+
+func (jt *JwtToken) isAdmin(email string) bool {
+	for _, admin := range jt.config.GetStringSlice("auth.jwt.admins") {
+		if strings.EqualFold(admin, email) {
+			return true
+		}
+	}
+
+	return false
+}
+
 func (jt *JwtToken) getToken(c echo.Context) string {
 	tokenStr := ""
 	if bearer := c.Request().Header.Get("Authorization"); bearer != "" {
@@ -0,0 +1,51 @@
+package auth
+
+import (
+	"sync"
+	"time"
+)
+
+//go:generate mockgen -source=$GOFILE -package=mock_auth -destination=../../../../test/mock/auth/$GOFILE
+
+// NonceCache keeps track of nonces already seen so signed requests can't be replayed
+type NonceCache interface {
+	// Add stores the nonce for ttl and returns false when it was already there
+	Add(nonce string, ttl time.Duration) bool
+}
+
+type MemoryNonceCache struct {
+	mu     sync.Mutex
+	nonces map[string]time.Time
+	now    func() time.Time
+}
+
+func NewMemoryNonceCache() NonceCache {
+	return &MemoryNonceCache{
+		nonces: make(map[string]time.Time),
+		now:    time.Now,
+	}
+}
+
+func (nc *MemoryNonceCache) Add(nonce string, ttl time.Duration) bool {
+	nc.mu.Lock()
+	defer nc.mu.Unlock()
+
+	now := nc.now()
+	nc.evict(now)
+
+	if expiresAt, ok := nc.nonces[nonce]; ok && expiresAt.After(now) {
+		return false
+	}
+
+	nc.nonces[nonce] = now.Add(ttl)
+
+	return true
+}
+
+func (nc *MemoryNonceCache) evict(now time.Time) {
+	for nonce, expiresAt := range nc.nonces {
+		if !expiresAt.After(now) {
+			delete(nc.nonces, nonce)
+		}
+	}
+}
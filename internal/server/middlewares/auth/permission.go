@@ -0,0 +1,51 @@
+package auth
+
+import (
+	"github.com/labstack/echo/v4"
+	"github.com/rhuandantas/verifymy-test/internal/errors"
+	error2 "github.com/rhuandantas/verifymy-test/internal/server/error"
+)
+
+const (
+	// PermissionsContextKey is where the authentication middlewares keep the permissions of the caller
+	PermissionsContextKey = "permissions"
+	// PermissionExportUsers allows dumping every user through /users/export
+	PermissionExportUsers = "users:export"
+	// PermissionVerifyUsers allows moving the age verification of users through its states
+	PermissionVerifyUsers = "users:verify"
+	// PermissionAttestUsers allows issuing age attestations of verified users
+	PermissionAttestUsers = "users:attest"
+	// PermissionReviewUsers is the reviewer role, allowing to work the manual review queue of verifications
+	PermissionReviewUsers = "users:review"
+	// PermissionManageClients allows registering relying party clients and changing their plans
+	PermissionManageClients = "clients:manage"
+)
+
+// HasPermission tells whether the caller was granted the permission, admins have every permission
+func HasPermission(c echo.Context, permission string) bool {
+	if IsAdmin(c) {
+		return true
+	}
+
+	permissions, _ := c.Get(PermissionsContextKey).([]string)
+	for _, granted := range permissions {
+		if granted == permission {
+			return true
+		}
+	}
+
+	return false
+}
+
+// RequirePermission answers 403 to callers without the permission, it must run after Authenticate
+func RequirePermission(permission string) echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			if !HasPermission(c, permission) {
+				return error2.HandleError(c, errors.Localized(errors.Forbidden, "auth.permission", "%s permission is required", permission))
+			}
+
+			return next(c)
+		}
+	}
+}
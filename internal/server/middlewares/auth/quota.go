@@ -0,0 +1,52 @@
+package auth
+
+import (
+	"math"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/labstack/echo/v4"
+	"github.com/rhuandantas/verifymy-test/internal/clients"
+	"github.com/rhuandantas/verifymy-test/internal/errors"
+	"github.com/rhuandantas/verifymy-test/internal/models"
+	error2 "github.com/rhuandantas/verifymy-test/internal/server/error"
+)
+
+// RequireQuota answers 429 to clients that used up the daily quota of the metric of their plan, and counts a
+// use of the metric for every request of a client that succeeds. Callers other than registered clients
+// aren't metered, it must run after Authenticate
+func RequireQuota(meter clients.Meter, metric string) echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			client, ok := c.Get(ClientContextKey).(*models.Client)
+			if !ok {
+				return next(c)
+			}
+
+			release, err := meter.Check(c.Request().Context(), client, metric)
+			if err != nil {
+				return handleQuotaError(c, err)
+			}
+
+			// the use is counted by Check already, so that concurrent requests can't go over the quota
+			if err := next(c); err != nil || c.Response().Status >= http.StatusBadRequest {
+				release()
+				return err
+			}
+
+			return nil
+		}
+	}
+}
+
+// handleQuotaError answers with the error, telling when to retry an exceeded quota
+func handleQuotaError(c echo.Context, err error) error {
+	e := error2.FromError(err)
+	if quota := errors.QuotaOf(e); quota != nil && e.IsOfType(errors.QuotaExceeded) {
+		retryAfter := math.Ceil(time.Until(quota.ResetsAt).Seconds())
+		c.Response().Header().Set("Retry-After", strconv.Itoa(int(retryAfter)))
+	}
+
+	return error2.HandleError(c, e)
+}
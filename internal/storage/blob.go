@@ -0,0 +1,54 @@
+package storage
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/rhuandantas/verifymy-test/internal/config"
+	errx "github.com/rhuandantas/verifymy-test/internal/errors"
+	"github.com/rhuandantas/verifymy-test/internal/log"
+)
+
+//go:generate mockgen -source=$GOFILE -package=mock_storage -destination=../../test/mock/storage/$GOFILE
+
+// storage drivers, where blobs are kept
+const (
+	DriverLocal = "local"
+	DriverS3    = "s3"
+)
+
+// BlobStore keeps opaque blobs by key. Keys are slash separated paths like documents/1/ab12, without dots
+// segments. Get fails with errx.NotFound for a key never stored or deleted, Delete of such a key succeeds
+type BlobStore interface {
+	Put(ctx context.Context, key string, data []byte) error
+	Get(ctx context.Context, key string) ([]byte, error)
+	Delete(ctx context.Context, key string) error
+}
+
+// NewBlobStore returns the blob store of storage.driver, local by default
+func NewBlobStore(config config.ConfigProvider, logger log.SimpleLogger) (BlobStore, error) {
+	switch driver := config.GetStringOrDefault("storage.driver", DriverLocal); driver {
+	case DriverLocal:
+		return NewFileBlobStore(config, logger)
+	case DriverS3:
+		return NewS3BlobStore(config, logger)
+	default:
+		return nil, fmt.Errorf("unknown storage driver %q, expected %s or %s", driver, DriverLocal, DriverS3)
+	}
+}
+
+// validateKey refuses keys that could escape the directory or bucket prefix they're meant for
+func validateKey(key string) error {
+	if key == "" || strings.HasPrefix(key, "/") || strings.HasSuffix(key, "/") {
+		return errx.Localized(errx.BadRequest, "storage.key_relative", "blob key %q must be a relative path", key)
+	}
+
+	for _, segment := range strings.Split(key, "/") {
+		if segment == "" || segment == "." || segment == ".." || strings.ContainsAny(segment, "\\\x00") {
+			return errx.Localized(errx.BadRequest, "storage.key_segment", "blob key %q has an invalid segment", key)
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,147 @@
+package storage
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+
+	"github.com/rhuandantas/verifymy-test/internal/config"
+	"github.com/rhuandantas/verifymy-test/internal/log"
+)
+
+//go:generate mockgen -source=$GOFILE -package=mock_storage -destination=../../test/mock/storage/$GOFILE
+
+// Sealed is content encrypted with its own data key, WrappedKey is that key encrypted with the master key
+// KeyId names. Ciphertext goes to the blob store while WrappedKey stays in the database, so dropping either
+// makes the content unreadable
+type Sealed struct {
+	Ciphertext []byte
+	WrappedKey []byte
+	KeyId      string
+}
+
+// Envelope encrypts content with envelope encryption: a fresh data key per object, wrapped by a master key.
+// aad binds the ciphertext to where it's stored so it can't be swapped for another one
+type Envelope interface {
+	Seal(plaintext, aad []byte) (*Sealed, error)
+	Open(sealed Sealed, aad []byte) ([]byte, error)
+}
+
+type AesEnvelope struct {
+	master cipher.AEAD
+	keyId  string
+}
+
+// NewAesEnvelope wraps data keys with the base64 encoded 256 bit master key in the env var named at
+// storage.encryption.master-key, failing without one. Only with dev.temporary-keys a key is generated instead,
+// what's stored then becomes unreadable once the server restarts
+func NewAesEnvelope(config config.ConfigProvider, logger log.SimpleLogger) (Envelope, error) {
+	var key []byte
+	var err error
+	secretKey := config.GetString("storage.encryption.master-key")
+	switch {
+	case secretKey != "" && config.GetString(secretKey) != "":
+		if key, err = base64.StdEncoding.DecodeString(config.GetString(secretKey)); err != nil || len(key) != 32 {
+			return nil, fmt.Errorf("master key in %s must be 32 bytes encoded in base64", secretKey)
+		}
+	case !config.GetBool("dev.temporary-keys"):
+		return nil, fmt.Errorf("no storage master key set in %q, see storage.encryption.master-key", secretKey)
+	default:
+		logger.Warn("no storage master key configured, using a temporary one")
+		if key, err = randomBytes(32); err != nil {
+			return nil, err
+		}
+	}
+
+	master, err := newGcm(key)
+	if err != nil {
+		return nil, err
+	}
+
+	// the id tells which master key wrapped a data key without giving the key away
+	sum := sha256.Sum256(key)
+	return &AesEnvelope{master: master, keyId: hex.EncodeToString(sum[:8])}, nil
+}
+
+func (ae *AesEnvelope) Seal(plaintext, aad []byte) (*Sealed, error) {
+	dataKey, err := randomBytes(32)
+	if err != nil {
+		return nil, err
+	}
+
+	data, err := newGcm(dataKey)
+	if err != nil {
+		return nil, err
+	}
+
+	ciphertext, err := seal(data, plaintext, aad)
+	if err != nil {
+		return nil, err
+	}
+
+	wrappedKey, err := seal(ae.master, dataKey, []byte(ae.keyId))
+	if err != nil {
+		return nil, err
+	}
+
+	return &Sealed{Ciphertext: ciphertext, WrappedKey: wrappedKey, KeyId: ae.keyId}, nil
+}
+
+func (ae *AesEnvelope) Open(sealed Sealed, aad []byte) ([]byte, error) {
+	if sealed.KeyId != ae.keyId {
+		return nil, fmt.Errorf("content was sealed with master key %s, not %s", sealed.KeyId, ae.keyId)
+	}
+
+	dataKey, err := open(ae.master, sealed.WrappedKey, []byte(ae.keyId))
+	if err != nil {
+		return nil, fmt.Errorf("unwrapping data key: %w", err)
+	}
+
+	data, err := newGcm(dataKey)
+	if err != nil {
+		return nil, err
+	}
+
+	return open(data, sealed.Ciphertext, aad)
+}
+
+func newGcm(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+
+	return cipher.NewGCM(block)
+}
+
+// seal prepends the random nonce to the ciphertext
+func seal(aead cipher.AEAD, plaintext, aad []byte) ([]byte, error) {
+	nonce, err := randomBytes(aead.NonceSize())
+	if err != nil {
+		return nil, err
+	}
+
+	return aead.Seal(nonce, nonce, plaintext, aad), nil
+}
+
+func open(aead cipher.AEAD, ciphertext, aad []byte) ([]byte, error) {
+	if len(ciphertext) < aead.NonceSize() {
+		return nil, fmt.Errorf("ciphertext is too short")
+	}
+
+	nonce, ciphertext := ciphertext[:aead.NonceSize()], ciphertext[aead.NonceSize():]
+	return aead.Open(nil, nonce, ciphertext, aad)
+}
+
+func randomBytes(n int) ([]byte, error) {
+	b := make([]byte, n)
+	if _, err := rand.Read(b); err != nil {
+		return nil, err
+	}
+
+	return b, nil
+}
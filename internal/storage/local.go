@@ -0,0 +1,92 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+
+	"github.com/rhuandantas/verifymy-test/internal/config"
+	errx "github.com/rhuandantas/verifymy-test/internal/errors"
+	"github.com/rhuandantas/verifymy-test/internal/log"
+)
+
+// FileBlobStore keeps blobs as files under a directory, readable by the server user only
+type FileBlobStore struct {
+	root   string
+	logger log.SimpleLogger
+}
+
+// NewFileBlobStore stores blobs under storage.local.path, ./data/blobs by default
+func NewFileBlobStore(config config.ConfigProvider, logger log.SimpleLogger) (BlobStore, error) {
+	root := config.GetStringOrDefault("storage.local.path", "./data/blobs")
+	if err := os.MkdirAll(root, 0o700); err != nil {
+		return nil, err
+	}
+
+	return &FileBlobStore{root: root, logger: logger}, nil
+}
+
+// Put writes a temporary file renamed over the blob, so a blob is never read half written
+func (fbs *FileBlobStore) Put(_ context.Context, key string, data []byte) error {
+	path, err := fbs.path(key)
+	if err != nil {
+		return err
+	}
+
+	if err = os.MkdirAll(filepath.Dir(path), 0o700); err != nil {
+		return err
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(path), ".tmp-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err = tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err = tmp.Close(); err != nil {
+		return err
+	}
+
+	return os.Rename(tmp.Name(), path)
+}
+
+func (fbs *FileBlobStore) Get(_ context.Context, key string) ([]byte, error) {
+	path, err := fbs.path(key)
+	if err != nil {
+		return nil, err
+	}
+
+	data, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, errx.LocalizedWrap(errx.NotFound, err, "storage.not_found", "blob %s not found", key)
+	}
+
+	return data, err
+}
+
+func (fbs *FileBlobStore) Delete(_ context.Context, key string) error {
+	path, err := fbs.path(key)
+	if err != nil {
+		return err
+	}
+
+	if err = os.Remove(path); err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return err
+	}
+
+	return nil
+}
+
+func (fbs *FileBlobStore) path(key string) (string, error) {
+	if err := validateKey(key); err != nil {
+		return "", err
+	}
+
+	return filepath.Join(fbs.root, filepath.FromSlash(key)), nil
+}
@@ -0,0 +1,236 @@
+package storage
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/rhuandantas/verifymy-test/internal/config"
+	errx "github.com/rhuandantas/verifymy-test/internal/errors"
+	"github.com/rhuandantas/verifymy-test/internal/log"
+)
+
+const (
+	amzDateLayout = "20060102T150405Z"
+	amzDayLayout  = "20060102"
+)
+
+// S3BlobStore keeps blobs as objects of a bucket of any S3 compatible service, e.g. MinIO, addressed path
+// style and signed with AWS Signature Version 4
+type S3BlobStore struct {
+	endpoint  *url.URL
+	bucket    string
+	region    string
+	accessKey string
+	secretKey string
+	client    *http.Client
+	logger    log.SimpleLogger
+}
+
+// NewS3BlobStore stores blobs in storage.s3.bucket of storage.s3.endpoint, in storage.s3.region (us-east-1 by
+// default). The credentials are read from the env vars named at storage.s3.access-key and storage.s3.secret-key
+func NewS3BlobStore(config config.ConfigProvider, logger log.SimpleLogger) (BlobStore, error) {
+	endpoint, err := url.Parse(config.GetString("storage.s3.endpoint"))
+	if err != nil || endpoint.Scheme == "" || endpoint.Host == "" {
+		return nil, fmt.Errorf("storage.s3.endpoint must be an absolute url")
+	}
+
+	bucket := config.GetString("storage.s3.bucket")
+	if bucket == "" {
+		return nil, fmt.Errorf("storage.s3.bucket is required")
+	}
+
+	return &S3BlobStore{
+		endpoint:  endpoint,
+		bucket:    bucket,
+		region:    config.GetStringOrDefault("storage.s3.region", "us-east-1"),
+		accessKey: config.GetString(config.GetString("storage.s3.access-key")),
+		secretKey: config.GetString(config.GetString("storage.s3.secret-key")),
+		client:    &http.Client{Timeout: 30 * time.Second},
+		logger:    logger,
+	}, nil
+}
+
+func (sbs *S3BlobStore) Put(ctx context.Context, key string, data []byte) error {
+	res, err := sbs.do(ctx, http.MethodPut, key, data)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode/100 != 2 {
+		return s3Error(res, key)
+	}
+
+	return nil
+}
+
+func (sbs *S3BlobStore) Get(ctx context.Context, key string) ([]byte, error) {
+	res, err := sbs.do(ctx, http.MethodGet, key, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer res.Body.Close()
+
+	switch {
+	case res.StatusCode == http.StatusNotFound:
+		return nil, errx.Localized(errx.NotFound, "storage.not_found", "blob %s not found", key)
+	case res.StatusCode/100 != 2:
+		return nil, s3Error(res, key)
+	}
+
+	return io.ReadAll(res.Body)
+}
+
+func (sbs *S3BlobStore) Delete(ctx context.Context, key string) error {
+	res, err := sbs.do(ctx, http.MethodDelete, key, nil)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode/100 != 2 && res.StatusCode != http.StatusNotFound {
+		return s3Error(res, key)
+	}
+
+	return nil
+}
+
+func (sbs *S3BlobStore) do(ctx context.Context, method, key string, body []byte) (*http.Response, error) {
+	if err := validateKey(key); err != nil {
+		return nil, err
+	}
+
+	target := *sbs.endpoint
+	target.Path = strings.TrimSuffix(target.Path, "/") + "/" + sbs.bucket + "/" + key
+	req, err := http.NewRequestWithContext(ctx, method, target.String(), bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+
+	sum := sha256.Sum256(body)
+	req.Header.Set("X-Amz-Content-Sha256", hex.EncodeToString(sum[:]))
+	SignV4(req, sbs.accessKey, sbs.secretKey, sbs.region, time.Now())
+
+	return sbs.client.Do(req)
+}
+
+// s3Error reads the error code S3 answers with, e.g. AccessDenied or NoSuchBucket
+func s3Error(res *http.Response, key string) error {
+	body, _ := io.ReadAll(io.LimitReader(res.Body, 1024))
+	code := http.StatusText(res.StatusCode)
+	if start, end := bytes.Index(body, []byte("<Code>")), bytes.Index(body, []byte("</Code>")); start >= 0 && end > start {
+		code = string(body[start+len("<Code>") : end])
+	}
+
+	return fmt.Errorf("s3 answered %d %s for %s", res.StatusCode, code, key)
+}
+
+// SignV4 signs an S3 request with AWS Signature Version 4, the X-Amz-Content-Sha256 header must hold the
+// hex sha256 of the body already. The host and every Range, Content-Type, Content-Md5 and X-Amz-* header
+// are signed
+func SignV4(req *http.Request, accessKey, secretKey, region string, now time.Time) {
+	now = now.UTC()
+	req.Header.Set("X-Amz-Date", now.Format(amzDateLayout))
+
+	host := req.Host
+	if host == "" {
+		host = req.URL.Host
+	}
+	headers := map[string]string{"host": host}
+	for name, values := range req.Header {
+		lower := strings.ToLower(name)
+		if strings.HasPrefix(lower, "x-amz-") || lower == "range" || lower == "content-type" || lower == "content-md5" {
+			headers[lower] = strings.TrimSpace(strings.Join(values, ","))
+		}
+	}
+
+	names := make([]string, 0, len(headers))
+	for name := range headers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var canonicalHeaders strings.Builder
+	for _, name := range names {
+		canonicalHeaders.WriteString(name + ":" + headers[name] + "\n")
+	}
+	signedHeaders := strings.Join(names, ";")
+
+	canonicalRequest := strings.Join([]string{
+		req.Method,
+		canonicalPath(req.URL.Path),
+		canonicalQuery(req.URL.Query()),
+		canonicalHeaders.String(),
+		signedHeaders,
+		req.Header.Get("X-Amz-Content-Sha256"),
+	}, "\n")
+
+	scope := now.Format(amzDayLayout) + "/" + region + "/s3/aws4_request"
+	requestHash := sha256.Sum256([]byte(canonicalRequest))
+	stringToSign := "AWS4-HMAC-SHA256\n" + now.Format(amzDateLayout) + "\n" + scope + "\n" + hex.EncodeToString(requestHash[:])
+
+	key := hmacSha256([]byte("AWS4"+secretKey), now.Format(amzDayLayout))
+	for _, part := range []string{region, "s3", "aws4_request"} {
+		key = hmacSha256(key, part)
+	}
+
+	req.Header.Set("Authorization", fmt.Sprintf("AWS4-HMAC-SHA256 Credential=%s/%s, SignedHeaders=%s, Signature=%s",
+		accessKey, scope, signedHeaders, hex.EncodeToString(hmacSha256(key, stringToSign))))
+}
+
+// canonicalPath uri encodes every segment of the path, S3 paths aren't normalized
+func canonicalPath(path string) string {
+	if path == "" {
+		return "/"
+	}
+
+	segments := strings.Split(path, "/")
+	for i, segment := range segments {
+		segments[i] = uriEncode(segment)
+	}
+
+	return strings.Join(segments, "/")
+}
+
+func canonicalQuery(query url.Values) string {
+	pairs := make([]string, 0, len(query))
+	for name, values := range query {
+		for _, value := range values {
+			pairs = append(pairs, uriEncode(name)+"="+uriEncode(value))
+		}
+	}
+	sort.Strings(pairs)
+
+	return strings.Join(pairs, "&")
+}
+
+// uriEncode percent encodes everything but the unreserved characters of RFC 3986
+func uriEncode(value string) string {
+	var b strings.Builder
+	for i := 0; i < len(value); i++ {
+		c := value[i]
+		if 'A' <= c && c <= 'Z' || 'a' <= c && c <= 'z' || '0' <= c && c <= '9' || c == '-' || c == '_' || c == '.' || c == '~' {
+			b.WriteByte(c)
+		} else {
+			fmt.Fprintf(&b, "%%%02X", c)
+		}
+	}
+
+	return b.String()
+}
+
+func hmacSha256(key []byte, data string) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(data))
+	return mac.Sum(nil)
+}
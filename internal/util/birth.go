@@ -0,0 +1,41 @@
+package util
+
+import (
+	"reflect"
+	"time"
+
+	"github.com/go-playground/validator/v10"
+	"github.com/rhuandantas/verifymy-test/internal/models"
+)
+
+// dateOfBirthRule checks a date of birth isn't after today in the timezone of the field its param names,
+// nor more than models.MaxAge years back, e.g. validate:"date_of_birth=Timezone"
+const dateOfBirthRule = "date_of_birth"
+
+func dateOfBirth(fl validator.FieldLevel) bool {
+	birth, ok := fl.Field().Interface().(time.Time)
+	if !ok {
+		return false
+	}
+
+	loc := time.UTC
+	if timezone := reflect.Indirect(fl.Parent()).FieldByName(fl.Param()); timezone.Kind() == reflect.String && timezone.String() != "" {
+		// an unknown timezone is reported by its own rule
+		if l, err := time.LoadLocation(timezone.String()); err == nil {
+			loc = l
+		}
+	}
+
+	today := models.Today(loc)
+	date := models.DateOf(birth)
+	return !date.After(today.Time) && models.AgeOn(date, today) <= models.MaxAge
+}
+
+// dateValue lets the rules of a models.Date field check it as a time.Time
+func dateValue(field reflect.Value) interface{} {
+	if date, ok := field.Interface().(models.Date); ok {
+		return date.Time
+	}
+
+	return nil
+}
@@ -0,0 +1,53 @@
+package util
+
+import (
+	"net"
+	"net/url"
+	"regexp"
+
+	"github.com/go-playground/validator/v10"
+)
+
+const (
+	// slugRule checks an identifier is lowercase letters, digits and dashes, starting with a letter or digit
+	slugRule = "slug"
+	// originRule checks a web origin, a scheme and host with an optional port and nothing else, e.g.
+	// https://shop.example:8443
+	originRule = "origin"
+	// redirectUriRule checks an absolute https url without a fragment, http is only allowed on loopback
+	// hosts for development
+	redirectUriRule = "redirect_uri"
+)
+
+var slugFormat = regexp.MustCompile(`^[a-z0-9][a-z0-9-]*$`)
+
+func slug(fl validator.FieldLevel) bool {
+	return slugFormat.MatchString(fl.Field().String())
+}
+
+func origin(fl validator.FieldLevel) bool {
+	u, err := url.Parse(fl.Field().String())
+	if err != nil || u.Host == "" || u.User != nil || (u.Path != "" && u.Path != "/") || u.RawQuery != "" || u.Fragment != "" {
+		return false
+	}
+
+	return u.Scheme == "https" || (u.Scheme == "http" && isLoopback(u.Hostname()))
+}
+
+func redirectUri(fl validator.FieldLevel) bool {
+	u, err := url.Parse(fl.Field().String())
+	if err != nil || u.Host == "" || u.User != nil || u.Fragment != "" {
+		return false
+	}
+
+	return u.Scheme == "https" || (u.Scheme == "http" && isLoopback(u.Hostname()))
+}
+
+func isLoopback(host string) bool {
+	if host == "localhost" {
+		return true
+	}
+
+	ip := net.ParseIP(host)
+	return ip != nil && ip.IsLoopback()
+}
@@ -0,0 +1,78 @@
+package util
+
+import (
+	"strconv"
+	"unicode"
+
+	"github.com/rhuandantas/verifymy-test/internal/config"
+	errx "github.com/rhuandantas/verifymy-test/internal/errors"
+	"github.com/rhuandantas/verifymy-test/internal/i18n"
+)
+
+//go:generate mockgen -source=$GOFILE -package=mock_util -destination=../../test/mock/util/$GOFILE
+
+const (
+	passwordField            = "password"
+	defaultPasswordMinLength = 8
+)
+
+type PasswordPolicy interface {
+	// Check returns an errx.Validation error with one errx.FieldError per rule the password breaks
+	Check(password string) error
+}
+
+type ConfigPasswordPolicy struct {
+	translator    i18n.Translator
+	minLength     int
+	requireLetter bool
+	requireDigit  bool
+}
+
+// NewPasswordPolicy reads the rules from users.password, the minimum length defaults to 8
+func NewPasswordPolicy(config config.ConfigProvider, translator i18n.Translator) PasswordPolicy {
+	minLength := config.GetInt("users.password.min-length")
+	if minLength <= 0 {
+		minLength = defaultPasswordMinLength
+	}
+
+	return &ConfigPasswordPolicy{
+		translator:    translator,
+		minLength:     minLength,
+		requireLetter: config.GetBool("users.password.require-letter"),
+		requireDigit:  config.GetBool("users.password.require-digit"),
+	}
+}
+
+func (pp *ConfigPasswordPolicy) Check(password string) error {
+	var hasLetter, hasDigit bool
+	for _, r := range password {
+		hasLetter = hasLetter || unicode.IsLetter(r)
+		hasDigit = hasDigit || unicode.IsDigit(r)
+	}
+
+	fields := make([]errx.FieldError, 0)
+	if length := len([]rune(password)); length < pp.minLength {
+		fields = append(fields, pp.fieldError("password_min", strconv.Itoa(pp.minLength)))
+	}
+	if pp.requireLetter && !hasLetter {
+		fields = append(fields, pp.fieldError("password_letter", ""))
+	}
+	if pp.requireDigit && !hasDigit {
+		fields = append(fields, pp.fieldError("password_digit", ""))
+	}
+
+	if len(fields) == 0 {
+		return nil
+	}
+
+	return errx.Localized(errx.Validation, "password.policy", "password doesn't meet the password policy").WithProperty(errx.FieldErrorsProperty, fields)
+}
+
+func (pp *ConfigPasswordPolicy) fieldError(rule, param string) errx.FieldError {
+	return errx.FieldError{
+		Field:   passwordField,
+		Rule:    rule,
+		Param:   param,
+		Message: i18n.FieldMessage(pp.translator, pp.translator.Locale(), passwordField, rule, param),
+	}
+}
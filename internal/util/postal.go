@@ -0,0 +1,51 @@
+package util
+
+import (
+	"reflect"
+	"regexp"
+	"strings"
+
+	"github.com/go-playground/validator/v10"
+)
+
+// postalCodeRule checks a postal code against the format of the country in the field its param names,
+// e.g. validate:"postal_code=Country"
+const postalCodeRule = "postal_code"
+
+// postalCodes are the postal code formats of the countries we know, by ISO 3166-1 alpha-2 code.
+// Their postal codes are required
+var postalCodes = map[string]*regexp.Regexp{
+	"AR": regexp.MustCompile(`^([A-Z]\d{4}[A-Z]{3}|\d{4})$`),
+	"AU": regexp.MustCompile(`^\d{4}$`),
+	"BR": regexp.MustCompile(`^\d{5}-?\d{3}$`),
+	"CA": regexp.MustCompile(`^[A-Z]\d[A-Z] ?\d[A-Z]\d$`),
+	"DE": regexp.MustCompile(`^\d{5}$`),
+	"ES": regexp.MustCompile(`^\d{5}$`),
+	"FR": regexp.MustCompile(`^\d{5}$`),
+	"GB": regexp.MustCompile(`^[A-Z]{1,2}\d[A-Z\d]? ?\d[A-Z]{2}$`),
+	"IN": regexp.MustCompile(`^\d{6}$`),
+	"IT": regexp.MustCompile(`^\d{5}$`),
+	"JP": regexp.MustCompile(`^\d{3}-?\d{4}$`),
+	"MX": regexp.MustCompile(`^\d{5}$`),
+	"NL": regexp.MustCompile(`^\d{4} ?[A-Z]{2}$`),
+	"PT": regexp.MustCompile(`^\d{4}-\d{3}$`),
+	"US": regexp.MustCompile(`^\d{5}(-\d{4})?$`),
+}
+
+// anyPostalCode is what the optional postal code of any other country may look like
+var anyPostalCode = regexp.MustCompile(`^[A-Z\d][A-Z\d -]{0,9}$`)
+
+func postalCode(fl validator.FieldLevel) bool {
+	code := strings.ToUpper(strings.TrimSpace(fl.Field().String()))
+	country := reflect.Indirect(fl.Parent()).FieldByName(fl.Param())
+	if country.Kind() != reflect.String {
+		return false
+	}
+
+	format, known := postalCodes[strings.ToUpper(country.String())]
+	if !known {
+		return code == "" || anyPostalCode.MatchString(code)
+	}
+
+	return format.MatchString(code)
+}
@@ -1,6 +1,14 @@
 package util
 
-import "github.com/go-playground/validator/v10"
+import (
+	"reflect"
+	"strings"
+
+	"github.com/go-playground/validator/v10"
+	errx "github.com/rhuandantas/verifymy-test/internal/errors"
+	"github.com/rhuandantas/verifymy-test/internal/i18n"
+	"github.com/rhuandantas/verifymy-test/internal/models"
+)
 
 //go:generate mockgen -source=$GOFILE -package=mock_util -destination=../../test/mock/util/$GOFILE
 
@@ -9,15 +17,72 @@ type Validator interface {
 }
 
 type CustomValidator struct {
-	validator *validator.Validate
+	validator  *validator.Validate
+	translator i18n.Translator
 }
 
-func NewCustomValidator() Validator {
+func NewCustomValidator(translator i18n.Translator) Validator {
+	v := validator.New()
+	v.RegisterTagNameFunc(fieldName)
+	_ = v.RegisterValidation(postalCodeRule, postalCode)
+	_ = v.RegisterValidation(dateOfBirthRule, dateOfBirth)
+	_ = v.RegisterValidation(slugRule, slug)
+	_ = v.RegisterValidation(originRule, origin)
+	_ = v.RegisterValidation(redirectUriRule, redirectUri)
+	v.RegisterCustomTypeFunc(dateValue, models.Date{})
+
 	return &CustomValidator{
-		validator: validator.New(),
+		validator:  v,
+		translator: translator,
 	}
 }
 
+// ValidateStruct returns an errx.Validation error carrying one errx.FieldError per failed rule,
+// messages are in the default locale and get translated again when the response is written
 func (cv *CustomValidator) ValidateStruct(i interface{}) error {
-	return cv.validator.Struct(i)
+	err := cv.validator.Struct(i)
+	if err == nil {
+		return nil
+	}
+
+	validationErrors, ok := err.(validator.ValidationErrors)
+	if !ok {
+		return err
+	}
+
+	fields := make([]errx.FieldError, 0, len(validationErrors))
+	for _, fe := range validationErrors {
+		fields = append(fields, errx.FieldError{
+			Field:   fieldPath(fe),
+			Rule:    fe.Tag(),
+			Param:   fe.Param(),
+			Message: i18n.FieldMessage(cv.translator, cv.translator.Locale(), fe.Field(), fe.Tag(), fe.Param()),
+		})
+	}
+
+	return errx.Localized(errx.Validation, "request.invalid", "request validation failed").WithProperty(errx.FieldErrorsProperty, fields)
+}
+
+// fieldName reports fields by the name clients send them with, json first and then query
+func fieldName(field reflect.StructField) string {
+	for _, tag := range []string{"json", "query"} {
+		name := strings.SplitN(field.Tag.Get(tag), ",", 2)[0]
+		if name == "-" {
+			return ""
+		}
+		if name != "" {
+			return name
+		}
+	}
+
+	return field.Name
+}
+
+// fieldPath drops the root struct name from the namespace, e.g. User.address.city becomes address.city
+func fieldPath(fe validator.FieldError) string {
+	if _, path, found := strings.Cut(fe.Namespace(), "."); found {
+		return path
+	}
+
+	return fe.Field()
 }
@@ -0,0 +1,197 @@
+package verification
+
+import (
+	"context"
+	"net/http"
+	"strings"
+
+	"github.com/joomcode/errorx"
+	errx "github.com/rhuandantas/verifymy-test/internal/errors"
+	"github.com/rhuandantas/verifymy-test/internal/log"
+	"github.com/rhuandantas/verifymy-test/internal/models"
+	"github.com/rhuandantas/verifymy-test/internal/repo"
+)
+
+//go:generate mockgen -source=$GOFILE -package=mock_verification -destination=../../test/mock/verification/$GOFILE
+
+const (
+	// ProviderActor is the actor of the transitions a provider outcome makes, followed by its name
+	ProviderActor = "provider:"
+	// ResultInconclusive is the status of checks a provider couldn't decide on, they go to manual review
+	ResultInconclusive = "inconclusive"
+)
+
+// Check is a provider check started for a user along with the pending verification it opened
+type Check struct {
+	Session      *Session                   `json:"session"`
+	Verification *models.VerificationStatus `json:"verification"`
+}
+
+// Checks runs the verification of users through the providers. A started check leaves the verification
+// pending with the provider and its reference as evidence, its outcome moves it to verified or rejected,
+// or opens a review case when it's inconclusive
+type Checks interface {
+	// Start opens a check with the provider, the default one when empty, on behalf of actor
+	Start(ctx context.Context, userId int, provider, actor string) (*Check, error)
+	// Poll asks the provider of the pending check of the user for its outcome and applies it
+	Poll(ctx context.Context, userId int) (*models.VerificationStatus, error)
+	// Callback applies the outcome pushed by the provider, redelivered outcomes of settled checks are ignored
+	Callback(ctx context.Context, provider string, header http.Header, body []byte) (*models.VerificationStatus, error)
+}
+
+type ProviderChecks struct {
+	registry         *ProviderRegistry
+	service          Service
+	reviews          Reviews
+	userRepo         repo.UserRepo
+	verificationRepo repo.VerificationRepo
+	logger           log.SimpleLogger
+}
+
+func NewProviderChecks(registry *ProviderRegistry, service Service, reviews Reviews, userRepo repo.UserRepo, verificationRepo repo.VerificationRepo, logger log.SimpleLogger) Checks {
+	return &ProviderChecks{
+		registry:         registry,
+		service:          service,
+		reviews:          reviews,
+		userRepo:         userRepo,
+		verificationRepo: verificationRepo,
+		logger:           logger,
+	}
+}
+
+func (pc *ProviderChecks) Start(ctx context.Context, userId int, name, actor string) (*Check, error) {
+	provider, err := pc.registry.Get(name)
+	if err != nil {
+		return nil, err
+	}
+
+	// the state is checked up front so no check is opened with the provider for nothing
+	current, err := pc.service.Get(ctx, userId)
+	if err != nil {
+		return nil, err
+	}
+	if !allowed(current.State, models.VerificationPending) {
+		return nil, errx.Localized(errx.InvalidTransition, "verification.transition", "verification can't go from %s to %s", current.State, models.VerificationPending)
+	}
+
+	user, err := pc.userRepo.GetByID(ctx, userId)
+	if err != nil {
+		return nil, err
+	}
+
+	session, err := provider.Start(ctx, user)
+	if err != nil {
+		return nil, err
+	}
+
+	status, err := pc.service.Transition(ctx, userId, models.VerificationTransition{
+		State:       models.VerificationPending,
+		Method:      provider.Method(),
+		EvidenceRef: evidenceRef(provider.Name(), session.Reference),
+	}, actor)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Check{Session: session, Verification: status}, nil
+}
+
+func (pc *ProviderChecks) Poll(ctx context.Context, userId int) (*models.VerificationStatus, error) {
+	current, err := pc.service.Get(ctx, userId)
+	if err != nil {
+		return nil, err
+	}
+
+	name, reference, ok := strings.Cut(current.EvidenceRef, ":")
+	if current.State != models.VerificationPending || !ok {
+		return nil, errx.Localized(errx.InvalidTransition, "verification.no_pending_check", "user %d has no pending provider check", userId)
+	}
+
+	// a pending verification with evidence of something else than an enabled provider was requested by hand
+	provider, err := pc.registry.Get(name)
+	if err != nil {
+		return nil, errx.LocalizedWrap(errx.InvalidTransition, err, "verification.no_pending_check", "user %d has no pending provider check", userId)
+	}
+
+	result, err := provider.Poll(ctx, reference)
+	if err != nil {
+		return nil, err
+	}
+
+	return pc.apply(ctx, provider, current, result)
+}
+
+func (pc *ProviderChecks) Callback(ctx context.Context, name string, header http.Header, body []byte) (*models.VerificationStatus, error) {
+	provider, err := pc.registry.Get(name)
+	if err != nil {
+		return nil, errx.LocalizedWrap(errx.NotFound, err, "verification.provider_not_found", "provider %s not found", name)
+	}
+
+	result, err := provider.VerifyCallback(header, body)
+	if err != nil {
+		return nil, err
+	}
+
+	verification, err := pc.verificationRepo.GetByEvidence(ctx, evidenceRef(name, result.Reference))
+	if err != nil {
+		return nil, err
+	}
+
+	current, err := pc.service.Get(ctx, verification.UserId)
+	if err != nil {
+		return nil, err
+	}
+
+	return pc.apply(ctx, provider, current, result)
+}
+
+// apply moves the verification on with the outcome of its check. Outcomes still pending, of checks
+// already settled or replaced by a newer one leave it as it is
+func (pc *ProviderChecks) apply(ctx context.Context, provider AgeVerificationProvider, current *models.VerificationStatus, result *Result) (*models.VerificationStatus, error) {
+	ref := evidenceRef(provider.Name(), result.Reference)
+	if current.State != models.VerificationPending || current.EvidenceRef != ref {
+		pc.logger.Infof("ignoring %s outcome of check %s, verification of user %d is %s", result.Status, ref, current.UserId, current.State)
+		return current, nil
+	}
+
+	transition := models.VerificationTransition{EvidenceRef: ref, Method: provider.Method()}
+	switch result.Status {
+	case models.VerificationPending:
+		return current, nil
+	case ResultInconclusive:
+		return pc.review(ctx, provider, current, result)
+	case models.VerificationVerified:
+		transition.State = models.VerificationVerified
+		transition.AssuranceLevel = result.AssuranceLevel
+	case models.VerificationRejected:
+		transition.State = models.VerificationRejected
+		transition.Reason = result.Reason
+		if transition.Reason == "" {
+			transition.Reason = "rejected by " + provider.Name()
+		}
+	default:
+		return nil, errx.Localized(errx.BadRequest, "verification.unknown_status", "%s reported unknown status %q for check %s", provider.Name(), result.Status, result.Reference)
+	}
+
+	return pc.service.Transition(ctx, current.UserId, transition, ProviderActor+provider.Name())
+}
+
+// review hands the check over to a reviewer, the verification stays pending. A redelivered outcome finds
+// the case already open
+func (pc *ProviderChecks) review(ctx context.Context, provider AgeVerificationProvider, current *models.VerificationStatus, result *Result) (*models.VerificationStatus, error) {
+	reason := "inconclusive " + provider.Name() + " check"
+	if result.Reason != "" {
+		reason += ": " + result.Reason
+	}
+
+	_, err := pc.reviews.Open(ctx, models.ReviewRequest{UserId: current.UserId, Reason: reason}, ProviderActor+provider.Name())
+	if err != nil && !errorx.IsOfType(err, errx.Conflict) {
+		return nil, err
+	}
+
+	return current, nil
+}
+
+func evidenceRef(provider, reference string) string {
+	return provider + ":" + reference
+}
@@ -0,0 +1,264 @@
+package verification
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"mime"
+	"net/http"
+	"time"
+
+	"github.com/joomcode/errorx"
+	"github.com/rhuandantas/verifymy-test/internal/config"
+	errx "github.com/rhuandantas/verifymy-test/internal/errors"
+	"github.com/rhuandantas/verifymy-test/internal/log"
+	"github.com/rhuandantas/verifymy-test/internal/models"
+	"github.com/rhuandantas/verifymy-test/internal/repo"
+	"github.com/rhuandantas/verifymy-test/internal/storage"
+)
+
+//go:generate mockgen -source=$GOFILE -package=mock_verification -destination=../../test/mock/verification/$GOFILE
+
+const (
+	defaultDocumentMaxBytes      = 10 << 20
+	defaultDocumentRetentionDays = 30
+	documentPurgeBatch           = 100
+)
+
+// defaultDocumentTypes are the content types of the evidence accepted unless verification.documents.allowed-types says otherwise
+var defaultDocumentTypes = []string{"image/jpeg", "image/png", "application/pdf"}
+
+// Documents keeps the evidence of verifications, e.g. ID scans. Documents are encrypted before they reach
+// the blob store and deleted once their retention ends, whatever the verification became
+type Documents interface {
+	// Upload scans and stores a document for the verification of the user, which must have been started.
+	// The content type is sniffed from the content, never taken from the caller
+	Upload(ctx context.Context, userId int, content []byte, actor string) (*models.VerificationDocument, error)
+	List(ctx context.Context, userId int) ([]*models.VerificationDocument, error)
+	// Open returns the document along with its decrypted content
+	Open(ctx context.Context, userId, documentId int) (*models.VerificationDocument, []byte, error)
+	Delete(ctx context.Context, userId, documentId int, actor string) error
+	// DeleteAll deletes every document of the user on behalf of actor, e.g. before the user is purged, telling how many
+	DeleteAll(ctx context.Context, userId int, actor string) (int, error)
+	// Purge deletes the documents whose retention ended by now, telling how many
+	Purge(ctx context.Context, now time.Time) (int, error)
+	// MaxBytes is the size of the largest document accepted, callers can stop reading past it
+	MaxBytes() int64
+}
+
+type DocumentStore struct {
+	service      Service
+	documentRepo repo.DocumentRepo
+	blobs        storage.BlobStore
+	envelope     storage.Envelope
+	logger       log.SimpleLogger
+	maxBytes     int64
+	allowedTypes map[string]bool
+	retention    time.Duration
+	now          func() time.Time
+}
+
+// NewDocumentStore reads the largest document accepted from verification.documents.max-bytes, 10 MiB by
+// default, the content types accepted from verification.documents.allowed-types, jpeg, png and pdf by default,
+// and how long documents are kept from verification.documents.retention-days, 30 by default
+func NewDocumentStore(config config.ConfigProvider, service Service, documentRepo repo.DocumentRepo, blobs storage.BlobStore, envelope storage.Envelope, logger log.SimpleLogger) Documents {
+	maxBytes := int64(config.GetInt("verification.documents.max-bytes"))
+	if maxBytes <= 0 {
+		maxBytes = defaultDocumentMaxBytes
+	}
+	types := config.GetStringSlice("verification.documents.allowed-types")
+	if len(types) == 0 {
+		types = defaultDocumentTypes
+	}
+	allowedTypes := make(map[string]bool, len(types))
+	for _, contentType := range types {
+		allowedTypes[contentType] = true
+	}
+	retentionDays := config.GetInt("verification.documents.retention-days")
+	if retentionDays <= 0 {
+		retentionDays = defaultDocumentRetentionDays
+	}
+
+	return &DocumentStore{
+		service:      service,
+		documentRepo: documentRepo,
+		blobs:        blobs,
+		envelope:     envelope,
+		logger:       logger,
+		maxBytes:     maxBytes,
+		allowedTypes: allowedTypes,
+		retention:    time.Duration(retentionDays) * 24 * time.Hour,
+		now:          time.Now,
+	}
+}
+
+func (ds *DocumentStore) MaxBytes() int64 {
+	return ds.maxBytes
+}
+
+func (ds *DocumentStore) Upload(ctx context.Context, userId int, content []byte, actor string) (*models.VerificationDocument, error) {
+	status, err := ds.service.Get(ctx, userId)
+	if err != nil {
+		return nil, err
+	}
+	if status.State == models.VerificationUnverified {
+		return nil, errx.Localized(errx.InvalidTransition, "documents.no_verification", "user %d has no verification to attach evidence to, start one first", userId)
+	}
+
+	contentType, err := ds.scan(content)
+	if err != nil {
+		return nil, err
+	}
+
+	suffix := make([]byte, 16)
+	if _, err = rand.Read(suffix); err != nil {
+		return nil, err
+	}
+	key := fmt.Sprintf("documents/%d/%s", userId, hex.EncodeToString(suffix))
+
+	sealed, err := ds.envelope.Seal(content, []byte(key))
+	if err != nil {
+		return nil, err
+	}
+	if err = ds.blobs.Put(ctx, key, sealed.Ciphertext); err != nil {
+		return nil, err
+	}
+
+	sum := sha256.Sum256(content)
+	now := ds.now().UTC()
+	document, err := ds.documentRepo.Create(ctx, models.VerificationDocument{
+		UserId:      userId,
+		ContentType: contentType,
+		Size:        int64(len(content)),
+		Sha256:      hex.EncodeToString(sum[:]),
+		BlobKey:     key,
+		WrappedKey:  sealed.WrappedKey,
+		KeyId:       sealed.KeyId,
+		UploadedBy:  actor,
+		ExpiresAt:   now.Add(ds.retention),
+	})
+	if err != nil {
+		// without its record the blob can never be read nor purged
+		if deleteErr := ds.blobs.Delete(ctx, key); deleteErr != nil {
+			ds.logger.Errorf("deleting blob %s of a document that wasn't recorded: %s", key, deleteErr.Error())
+		}
+		return nil, err
+	}
+	ds.logger.Infof("document %d of %d bytes stored for user %d by %s", document.DocumentId, document.Size, userId, actor)
+
+	return document, nil
+}
+
+func (ds *DocumentStore) List(ctx context.Context, userId int) ([]*models.VerificationDocument, error) {
+	return ds.documentRepo.List(ctx, userId)
+}
+
+func (ds *DocumentStore) Open(ctx context.Context, userId, documentId int) (*models.VerificationDocument, []byte, error) {
+	document, err := ds.documentRepo.Get(ctx, userId, documentId)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	ciphertext, err := ds.blobs.Get(ctx, document.BlobKey)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	content, err := ds.envelope.Open(storage.Sealed{Ciphertext: ciphertext, WrappedKey: document.WrappedKey, KeyId: document.KeyId}, []byte(document.BlobKey))
+	if err != nil {
+		return nil, nil, fmt.Errorf("decrypting document %d: %w", documentId, err)
+	}
+
+	return document, content, nil
+}
+
+func (ds *DocumentStore) Delete(ctx context.Context, userId, documentId int, actor string) error {
+	document, err := ds.documentRepo.Get(ctx, userId, documentId)
+	if err != nil {
+		return err
+	}
+
+	if err = ds.remove(ctx, document); err != nil {
+		return err
+	}
+	ds.logger.Infof("document %d of user %d deleted by %s", documentId, userId, actor)
+
+	return nil
+}
+
+func (ds *DocumentStore) DeleteAll(ctx context.Context, userId int, actor string) (int, error) {
+	documents, err := ds.documentRepo.List(ctx, userId)
+	if err != nil {
+		return 0, err
+	}
+
+	for i, document := range documents {
+		if err = ds.remove(ctx, document); err != nil {
+			return i, err
+		}
+	}
+	if len(documents) > 0 {
+		ds.logger.Infof("%d documents of user %d deleted by %s", len(documents), userId, actor)
+	}
+
+	return len(documents), nil
+}
+
+func (ds *DocumentStore) Purge(ctx context.Context, now time.Time) (int, error) {
+	purged := 0
+	for {
+		documents, err := ds.documentRepo.Expired(ctx, now, documentPurgeBatch)
+		if err != nil {
+			return purged, err
+		}
+
+		for _, document := range documents {
+			if err = ds.remove(ctx, document); err != nil {
+				return purged, err
+			}
+			purged++
+		}
+
+		if len(documents) < documentPurgeBatch {
+			return purged, nil
+		}
+	}
+}
+
+// remove deletes the blob before the record, a record left behind by a failure is retried while a blob
+// left behind would never be
+func (ds *DocumentStore) remove(ctx context.Context, document *models.VerificationDocument) error {
+	if err := ds.blobs.Delete(ctx, document.BlobKey); err != nil && !errorx.IsOfType(err, errx.NotFound) {
+		return err
+	}
+
+	return ds.documentRepo.Delete(ctx, document.DocumentId)
+}
+
+// scan checks the size of the content and sniffs its type, returning the type
+func (ds *DocumentStore) scan(content []byte) (string, error) {
+	switch {
+	case len(content) == 0:
+		return "", errx.Localized(errx.Validation, "documents.empty", "document is empty").WithProperty(errx.FieldErrorsProperty, []errx.FieldError{{
+			Field:   "file",
+			Rule:    "required",
+			Message: "file is required",
+		}})
+	case int64(len(content)) > ds.maxBytes:
+		return "", errx.Localized(errx.Validation, "documents.too_large", "document is larger than %d bytes", ds.maxBytes).WithProperty(errx.FieldErrorsProperty, []errx.FieldError{{
+			Field:   "file",
+			Rule:    "max",
+			Param:   fmt.Sprint(ds.maxBytes),
+			Message: fmt.Sprintf("file must be at most %d bytes", ds.maxBytes),
+		}})
+	}
+
+	contentType, _, err := mime.ParseMediaType(http.DetectContentType(content))
+	if err != nil || !ds.allowedTypes[contentType] {
+		return "", errx.Localized(errx.UnsupportedMedia, "documents.type", "documents of type %s are not accepted", contentType)
+	}
+
+	return contentType, nil
+}
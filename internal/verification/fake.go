@@ -0,0 +1,127 @@
+package verification
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+
+	"github.com/rhuandantas/verifymy-test/internal/config"
+	errx "github.com/rhuandantas/verifymy-test/internal/errors"
+	"github.com/rhuandantas/verifymy-test/internal/models"
+)
+
+const (
+	FakeProviderName = "fake"
+	// FakeSignatureHeader carries the hex HMAC-SHA256 of the body of a fake callback
+	FakeSignatureHeader = "X-Fake-Signature"
+)
+
+// FakeProvider is a deterministic provider for development and tests. A check of a user whose email has
+// +reject before the @ is rejected, +review is inconclusive, +pending stays pending until Decide is called,
+// and any other is verified with substantial assurance. Callbacks are signed with the secret in the env var named at
+// verification.providers.fake.secret-key
+type FakeProvider struct {
+	secret   string
+	mu       sync.Mutex
+	checks   map[string]*Result
+	sequence int
+}
+
+func NewFakeProvider(config config.ConfigProvider) *FakeProvider {
+	secret := ""
+	if secretKey := config.GetString("verification.providers.fake.secret-key"); secretKey != "" {
+		secret = config.GetString(secretKey)
+	}
+
+	return &FakeProvider{
+		secret: secret,
+		checks: make(map[string]*Result),
+	}
+}
+
+func (fp *FakeProvider) Name() string {
+	return FakeProviderName
+}
+
+func (fp *FakeProvider) Method() string {
+	return models.MethodDocument
+}
+
+func (fp *FakeProvider) Start(_ context.Context, user *models.User) (*Session, error) {
+	fp.mu.Lock()
+	defer fp.mu.Unlock()
+
+	fp.sequence++
+	reference := fmt.Sprintf("fake-%d-%d", user.UserId, fp.sequence)
+
+	result := &Result{Reference: reference, Status: models.VerificationVerified, AssuranceLevel: models.AssuranceSubstantial}
+	local, _, _ := strings.Cut(user.Email, "@")
+	switch {
+	case strings.HasSuffix(local, "+reject"):
+		result = &Result{Reference: reference, Status: models.VerificationRejected, Reason: "document doesn't match the user"}
+	case strings.HasSuffix(local, "+review"):
+		result = &Result{Reference: reference, Status: ResultInconclusive, Reason: "document is too blurry to read"}
+	case strings.HasSuffix(local, "+pending"):
+		result = &Result{Reference: reference, Status: models.VerificationPending}
+	}
+	fp.checks[reference] = result
+
+	return &Session{Provider: FakeProviderName, Reference: reference, RedirectURL: "https://fake.verification.invalid/checks/" + reference}, nil
+}
+
+func (fp *FakeProvider) Poll(_ context.Context, reference string) (*Result, error) {
+	fp.mu.Lock()
+	defer fp.mu.Unlock()
+
+	result, ok := fp.checks[reference]
+	if !ok {
+		return nil, errx.Localized(errx.NotFound, "verification.check_not_found", "fake check %s not found", reference)
+	}
+
+	copied := *result
+	return &copied, nil
+}
+
+// Decide sets the outcome of a started check, the reference of result names it
+func (fp *FakeProvider) Decide(result Result) error {
+	fp.mu.Lock()
+	defer fp.mu.Unlock()
+
+	if _, ok := fp.checks[result.Reference]; !ok {
+		return errx.Localized(errx.NotFound, "verification.check_not_found", "fake check %s not found", result.Reference)
+	}
+	fp.checks[result.Reference] = &result
+
+	return nil
+}
+
+func (fp *FakeProvider) VerifyCallback(header http.Header, body []byte) (*Result, error) {
+	signature, err := hex.DecodeString(header.Get(FakeSignatureHeader))
+	if fp.secret == "" || err != nil || !hmac.Equal(signature, fp.sign(body)) {
+		return nil, errx.Localized(errx.Unauthorized, "verification.callback_signature", "callback signature is not valid")
+	}
+
+	var result Result
+	if err = json.Unmarshal(body, &result); err != nil {
+		return nil, errx.Localized(errx.BadRequest, "verification.callback_body", "callback body is not valid: %s", err.Error())
+	}
+
+	return &result, nil
+}
+
+// Sign returns the signature header value of a callback body
+func (fp *FakeProvider) Sign(body []byte) string {
+	return hex.EncodeToString(fp.sign(body))
+}
+
+func (fp *FakeProvider) sign(body []byte) []byte {
+	mac := hmac.New(sha256.New, []byte(fp.secret))
+	mac.Write(body)
+	return mac.Sum(nil)
+}
@@ -0,0 +1,104 @@
+package verification
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+
+	"github.com/rhuandantas/verifymy-test/internal/config"
+	errx "github.com/rhuandantas/verifymy-test/internal/errors"
+	"github.com/rhuandantas/verifymy-test/internal/models"
+)
+
+//go:generate mockgen -source=$GOFILE -package=mock_verification -destination=../../test/mock/verification/$GOFILE
+
+// Session is a check a provider opened for a user. RedirectURL is where the user completes it, empty
+// when the provider needs nothing from the user
+type Session struct {
+	Provider    string `json:"provider"`
+	Reference   string `json:"reference"`
+	RedirectURL string `json:"redirect_url,omitempty"`
+}
+
+// Result is the outcome of a check as a provider reports it, Status is pending, verified, rejected or
+// inconclusive
+type Result struct {
+	Reference      string `json:"reference"`
+	Status         string `json:"status"`
+	AssuranceLevel string `json:"assurance_level,omitempty"`
+	Reason         string `json:"reason,omitempty"`
+}
+
+// AgeVerificationProvider is a vendor checking the age of users, e.g. by document scan, credit card or
+// mobile operator lookup. A check is started, then its outcome is either polled or pushed to the callback
+type AgeVerificationProvider interface {
+	// Name is how the config and the callback url refer to the provider
+	Name() string
+	// Method is the models verification method of the checks of the provider
+	Method() string
+	Start(ctx context.Context, user *models.User) (*Session, error)
+	// Poll fails with errx.NotFound when the provider doesn't know the reference
+	Poll(ctx context.Context, reference string) (*Result, error)
+	// VerifyCallback checks a callback request really comes from the provider and reads the result it
+	// carries, failing with errx.Unauthorized when it doesn't
+	VerifyCallback(header http.Header, body []byte) (*Result, error)
+}
+
+// ProviderRegistry holds the providers enabled by verification.providers.{name}.enabled, the one named by
+// verification.default-provider is used when a check doesn't name any. None is enabled by default
+type ProviderRegistry struct {
+	providers   map[string]AgeVerificationProvider
+	defaultName string
+}
+
+// NewProviderRegistry fails when verification.default-provider names a provider that isn't enabled
+func NewProviderRegistry(config config.ConfigProvider, fake *FakeProvider) (*ProviderRegistry, error) {
+	registry := &ProviderRegistry{
+		providers:   make(map[string]AgeVerificationProvider),
+		defaultName: config.GetString("verification.default-provider"),
+	}
+
+	for _, provider := range []AgeVerificationProvider{fake} {
+		if config.GetBool(fmt.Sprintf("verification.providers.%s.enabled", provider.Name())) {
+			registry.providers[provider.Name()] = provider
+		}
+	}
+
+	if _, ok := registry.providers[registry.defaultName]; registry.defaultName != "" && !ok {
+		return nil, fmt.Errorf("default provider %q is not enabled", registry.defaultName)
+	}
+
+	return registry, nil
+}
+
+// Get returns the enabled provider of the name, the default one for an empty name
+func (pr *ProviderRegistry) Get(name string) (AgeVerificationProvider, error) {
+	if name == "" {
+		name = pr.defaultName
+	}
+
+	if provider, ok := pr.providers[name]; ok {
+		return provider, nil
+	}
+
+	names := strings.Join(pr.Names(), " ")
+	return nil, errx.Localized(errx.Validation, "verification.provider_disabled", "provider %q is not enabled", name).WithProperty(errx.FieldErrorsProperty, []errx.FieldError{{
+		Field:   "provider",
+		Rule:    "oneof",
+		Param:   names,
+		Message: fmt.Sprintf("provider must be one of [%s]", names),
+	}})
+}
+
+// Names lists the enabled providers in order
+func (pr *ProviderRegistry) Names() []string {
+	names := make([]string, 0, len(pr.providers))
+	for name := range pr.providers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	return names
+}
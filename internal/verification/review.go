@@ -0,0 +1,234 @@
+package verification
+
+import (
+	"context"
+	"strconv"
+	"time"
+
+	"github.com/joomcode/errorx"
+	"github.com/rhuandantas/verifymy-test/internal/config"
+	errx "github.com/rhuandantas/verifymy-test/internal/errors"
+	"github.com/rhuandantas/verifymy-test/internal/log"
+	"github.com/rhuandantas/verifymy-test/internal/models"
+	"github.com/rhuandantas/verifymy-test/internal/repo"
+)
+
+//go:generate mockgen -source=$GOFILE -package=mock_verification -destination=../../test/mock/verification/$GOFILE
+
+const (
+	defaultReviewSLAHours     = 24
+	defaultReviewClaimMinutes = 60
+
+	// ReviewEvidence prefixes the case id in the evidence of the verifications a reviewer decided
+	ReviewEvidence = "review:"
+)
+
+// Reviews is the queue of verifications a human has to decide on. A case holds the verification pending
+// until the reviewer who claimed it approves or rejects it, the decision moves the verification to
+// verified or rejected on behalf of the reviewer so it's recorded in its history
+type Reviews interface {
+	// Open starts a case on the verification of the user, which goes pending when it isn't already. A user
+	// has at most one case under way, another one fails with errx.Conflict
+	Open(ctx context.Context, request models.ReviewRequest, actor string) (*models.ReviewCaseDetail, error)
+	List(ctx context.Context, query *repo.Query, limit int) ([]*models.ReviewCase, error)
+	Get(ctx context.Context, caseId int) (*models.ReviewCaseDetail, error)
+	// Claim assigns the case to the reviewer, failing with errx.Conflict while another reviewer's claim lasts
+	Claim(ctx context.Context, caseId int, reviewer string) (*models.ReviewCaseDetail, error)
+	Note(ctx context.Context, caseId int, author string, request models.ReviewNoteRequest) (*models.ReviewNote, error)
+	// Decide approves or rejects the verification, only the reviewer who claimed the case may
+	Decide(ctx context.Context, caseId int, reviewer string, decision models.ReviewDecision) (*models.ReviewCaseDetail, error)
+}
+
+type ReviewQueue struct {
+	service    Service
+	reviewRepo repo.ReviewRepo
+	logger     log.SimpleLogger
+	sla        time.Duration
+	claim      time.Duration
+	now        func() time.Time
+}
+
+// NewReviewQueue reads how long a case may wait for a decision from verification.review.sla-hours, a day
+// by default, and how long a claim lasts from verification.review.claim-minutes, an hour by default
+func NewReviewQueue(config config.ConfigProvider, service Service, reviewRepo repo.ReviewRepo, logger log.SimpleLogger) Reviews {
+	slaHours := config.GetInt("verification.review.sla-hours")
+	if slaHours <= 0 {
+		slaHours = defaultReviewSLAHours
+	}
+	claimMinutes := config.GetInt("verification.review.claim-minutes")
+	if claimMinutes <= 0 {
+		claimMinutes = defaultReviewClaimMinutes
+	}
+
+	return &ReviewQueue{
+		service:    service,
+		reviewRepo: reviewRepo,
+		logger:     logger,
+		sla:        time.Duration(slaHours) * time.Hour,
+		claim:      time.Duration(claimMinutes) * time.Minute,
+		now:        time.Now,
+	}
+}
+
+func (rq *ReviewQueue) Open(ctx context.Context, request models.ReviewRequest, actor string) (*models.ReviewCaseDetail, error) {
+	undecided, err := rq.reviewRepo.GetUndecided(ctx, request.UserId)
+	if err == nil {
+		return nil, errx.Localized(errx.Conflict, "reviews.under_way", "user %d already has review case %d under way", request.UserId, undecided.CaseId)
+	}
+	if !errorx.IsOfType(err, errx.NotFound) {
+		return nil, err
+	}
+
+	current, err := rq.service.Get(ctx, request.UserId)
+	if err != nil {
+		return nil, err
+	}
+	if current.State != models.VerificationPending && !allowed(current.State, models.VerificationPending) {
+		return nil, errx.Localized(errx.InvalidTransition, "reviews.state", "%s verifications can't be reviewed", current.State)
+	}
+
+	now := rq.now().UTC()
+	reviewCase, err := rq.reviewRepo.Create(ctx, models.ReviewCase{
+		UserId:   request.UserId,
+		Status:   models.ReviewOpen,
+		Reason:   request.Reason,
+		OpenedBy: actor,
+		DueAt:    now.Add(rq.sla),
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	// a pending verification stays as it is, its evidence is what's under review
+	if current.State != models.VerificationPending {
+		_, err = rq.service.Transition(ctx, request.UserId, models.VerificationTransition{
+			State:       models.VerificationPending,
+			Method:      models.MethodManual,
+			EvidenceRef: reviewEvidence(reviewCase.CaseId),
+			Reason:      request.Reason,
+		}, actor)
+		if err != nil {
+			return nil, err
+		}
+	}
+	rq.logger.Infof("review case %d opened for user %d by %s", reviewCase.CaseId, request.UserId, actor)
+
+	return rq.detail(ctx, reviewCase)
+}
+
+func (rq *ReviewQueue) List(ctx context.Context, query *repo.Query, limit int) ([]*models.ReviewCase, error) {
+	cases, err := rq.reviewRepo.List(ctx, query, limit)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, reviewCase := range cases {
+		rq.markOverdue(reviewCase)
+	}
+
+	return cases, nil
+}
+
+func (rq *ReviewQueue) Get(ctx context.Context, caseId int) (*models.ReviewCaseDetail, error) {
+	reviewCase, err := rq.reviewRepo.Get(ctx, caseId)
+	if err != nil {
+		return nil, err
+	}
+
+	return rq.detail(ctx, reviewCase)
+}
+
+func (rq *ReviewQueue) Claim(ctx context.Context, caseId int, reviewer string) (*models.ReviewCaseDetail, error) {
+	reviewCase, err := rq.reviewRepo.Get(ctx, caseId)
+	if err != nil {
+		return nil, err
+	}
+
+	if reviewCase.Decided() {
+		return nil, errx.Localized(errx.InvalidTransition, "reviews.decided", "review case %d is %s already", caseId, reviewCase.Status)
+	}
+
+	now := rq.now().UTC()
+	if reviewCase.Status == models.ReviewClaimed && reviewCase.Reviewer != reviewer && reviewCase.ClaimExpiresAt != nil && now.Before(*reviewCase.ClaimExpiresAt) {
+		return nil, errx.Localized(errx.Conflict, "reviews.claimed", "review case %d is claimed by %s until %s", caseId, reviewCase.Reviewer, reviewCase.ClaimExpiresAt.Format(time.RFC3339))
+	}
+
+	// claiming again extends the claim
+	claimExpiresAt := now.Add(rq.claim)
+	reviewCase.Status = models.ReviewClaimed
+	reviewCase.Reviewer = reviewer
+	reviewCase.ClaimedAt = &now
+	reviewCase.ClaimExpiresAt = &claimExpiresAt
+	if reviewCase, err = rq.reviewRepo.Update(ctx, *reviewCase); err != nil {
+		return nil, err
+	}
+
+	return rq.detail(ctx, reviewCase)
+}
+
+func (rq *ReviewQueue) Note(ctx context.Context, caseId int, author string, request models.ReviewNoteRequest) (*models.ReviewNote, error) {
+	if _, err := rq.reviewRepo.Get(ctx, caseId); err != nil {
+		return nil, err
+	}
+
+	return rq.reviewRepo.AddNote(ctx, models.ReviewNote{CaseId: caseId, Author: author, Body: request.Body})
+}
+
+func (rq *ReviewQueue) Decide(ctx context.Context, caseId int, reviewer string, decision models.ReviewDecision) (*models.ReviewCaseDetail, error) {
+	reviewCase, err := rq.reviewRepo.Get(ctx, caseId)
+	if err != nil {
+		return nil, err
+	}
+
+	if reviewCase.Decided() {
+		return nil, errx.Localized(errx.InvalidTransition, "reviews.decided", "review case %d is %s already", caseId, reviewCase.Status)
+	}
+	if reviewCase.Status != models.ReviewClaimed || reviewCase.Reviewer != reviewer {
+		return nil, errx.Localized(errx.Forbidden, "reviews.claim_required", "review case %d must be claimed by %s before deciding", caseId, reviewer)
+	}
+
+	// the verification goes first, it only leaves pending once so a racing decision fails there
+	transition := models.VerificationTransition{
+		State:       models.VerificationRejected,
+		Method:      models.MethodManual,
+		EvidenceRef: reviewEvidence(caseId),
+		Reason:      decision.Reason,
+	}
+	if decision.Decision == models.ReviewApproved {
+		transition.State = models.VerificationVerified
+		transition.AssuranceLevel = decision.AssuranceLevel
+	}
+	if _, err = rq.service.Transition(ctx, reviewCase.UserId, transition, reviewer); err != nil {
+		return nil, err
+	}
+
+	now := rq.now().UTC()
+	reviewCase.Status = decision.Decision
+	reviewCase.DecidedAt = &now
+	reviewCase.DecisionReason = decision.Reason
+	if reviewCase, err = rq.reviewRepo.Update(ctx, *reviewCase); err != nil {
+		return nil, err
+	}
+	rq.logger.Infof("review case %d of user %d %s by %s", caseId, reviewCase.UserId, decision.Decision, reviewer)
+
+	return rq.detail(ctx, reviewCase)
+}
+
+func (rq *ReviewQueue) detail(ctx context.Context, reviewCase *models.ReviewCase) (*models.ReviewCaseDetail, error) {
+	notes, err := rq.reviewRepo.Notes(ctx, reviewCase.CaseId)
+	if err != nil {
+		return nil, err
+	}
+	rq.markOverdue(reviewCase)
+
+	return &models.ReviewCaseDetail{ReviewCase: reviewCase, Notes: notes}, nil
+}
+
+// markOverdue flags the undecided cases past their due date
+func (rq *ReviewQueue) markOverdue(reviewCase *models.ReviewCase) {
+	reviewCase.Overdue = !reviewCase.Decided() && rq.now().After(reviewCase.DueAt)
+}
+
+func reviewEvidence(caseId int) string {
+	return ReviewEvidence + strconv.Itoa(caseId)
+}
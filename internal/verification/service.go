@@ -0,0 +1,241 @@
+package verification
+
+import (
+	"context"
+	"time"
+
+	"github.com/joomcode/errorx"
+	"github.com/rhuandantas/verifymy-test/internal/config"
+	errx "github.com/rhuandantas/verifymy-test/internal/errors"
+	"github.com/rhuandantas/verifymy-test/internal/log"
+	"github.com/rhuandantas/verifymy-test/internal/models"
+	"github.com/rhuandantas/verifymy-test/internal/repo"
+)
+
+//go:generate mockgen -source=$GOFILE -package=mock_verification -destination=../../test/mock/verification/$GOFILE
+
+const (
+	defaultValidityDays = 365
+
+	// ActorSystem is the actor of the transitions the service makes on its own
+	ActorSystem = "system"
+)
+
+// transitions are the states each state can move to. Rejected and expired verifications start over
+// with a new pending request
+var transitions = map[string][]string{
+	models.VerificationUnverified: {models.VerificationPending},
+	models.VerificationPending:    {models.VerificationVerified, models.VerificationRejected, models.VerificationExpired},
+	models.VerificationVerified:   {models.VerificationExpired},
+	models.VerificationRejected:   {models.VerificationPending},
+	models.VerificationExpired:    {models.VerificationPending},
+}
+
+type Service interface {
+	// Get returns the verification of the user with its history, unverified when it never started one.
+	// A verified verification past its expiry is expired first
+	Get(ctx context.Context, userId int) (*models.VerificationStatus, error)
+	// Transition moves the verification of the user to the state of transition on behalf of actor, failing
+	// with errx.InvalidTransition when the current state doesn't lead there
+	Transition(ctx context.Context, userId int, transition models.VerificationTransition, actor string) (*models.VerificationStatus, error)
+}
+
+type VerificationService struct {
+	userRepo         repo.UserRepo
+	verificationRepo repo.VerificationRepo
+	logger           log.SimpleLogger
+	validity         time.Duration
+	now              func() time.Time
+}
+
+// NewVerificationService reads how long a verified verification lasts from verification.validity-days,
+// a year by default
+func NewVerificationService(config config.ConfigProvider, userRepo repo.UserRepo, verificationRepo repo.VerificationRepo, logger log.SimpleLogger) Service {
+	validityDays := config.GetInt("verification.validity-days")
+	if validityDays <= 0 {
+		validityDays = defaultValidityDays
+	}
+
+	return &VerificationService{
+		userRepo:         userRepo,
+		verificationRepo: verificationRepo,
+		logger:           logger,
+		validity:         time.Duration(validityDays) * 24 * time.Hour,
+		now:              time.Now,
+	}
+}
+
+func (vs *VerificationService) Get(ctx context.Context, userId int) (*models.VerificationStatus, error) {
+	current, err := vs.current(ctx, userId)
+	if err != nil {
+		return nil, err
+	}
+
+	return vs.status(ctx, current)
+}
+
+func (vs *VerificationService) Transition(ctx context.Context, userId int, transition models.VerificationTransition, actor string) (*models.VerificationStatus, error) {
+	current, err := vs.current(ctx, userId)
+	if err != nil {
+		return nil, err
+	}
+
+	if !allowed(current.State, transition.State) {
+		return nil, errx.Localized(errx.InvalidTransition, "verification.transition", "verification can't go from %s to %s", current.State, transition.State)
+	}
+
+	if err = required(*current, transition); err != nil {
+		return nil, err
+	}
+
+	next := vs.apply(*current, transition)
+	if next.State == models.VerificationVerified {
+		// the age stays the verified one whatever the user changes afterwards
+		user, err := vs.userRepo.GetByID(ctx, userId, "date_of_birth", "timezone")
+		if err != nil {
+			return nil, err
+		}
+		next.DateOfBirth = user.DateOfBirth
+		next.Timezone = user.Timezone
+	}
+
+	saved, err := vs.verificationRepo.Save(ctx, next, event(current.State, next, transition.Reason, actor))
+	if err != nil {
+		return nil, err
+	}
+
+	return vs.status(ctx, saved)
+}
+
+// current reads the verification of the user, expiring it when it's due
+func (vs *VerificationService) current(ctx context.Context, userId int) (*models.Verification, error) {
+	if _, err := vs.userRepo.GetByID(ctx, userId, "user_id"); err != nil {
+		return nil, err
+	}
+
+	current, err := vs.verificationRepo.Get(ctx, userId)
+	if errorx.IsOfType(err, errx.NotFound) {
+		return &models.Verification{UserId: userId, State: models.VerificationUnverified}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if current.State != models.VerificationVerified || current.ExpiresAt == nil || vs.now().Before(*current.ExpiresAt) {
+		return current, nil
+	}
+
+	expired := vs.apply(*current, models.VerificationTransition{State: models.VerificationExpired})
+	saved, err := vs.verificationRepo.Save(ctx, expired, event(current.State, expired, "validity ran out", ActorSystem))
+	if err != nil {
+		return nil, err
+	}
+	vs.logger.Infof("verification of user %d expired", userId)
+
+	return saved, nil
+}
+
+func (vs *VerificationService) status(ctx context.Context, verification *models.Verification) (*models.VerificationStatus, error) {
+	history, err := vs.verificationRepo.History(ctx, verification.UserId)
+	if err != nil {
+		return nil, err
+	}
+
+	return &models.VerificationStatus{Verification: verification, History: history}, nil
+}
+
+// apply returns the verification once it moved to the state of the transition
+func (vs *VerificationService) apply(verification models.Verification, transition models.VerificationTransition) models.Verification {
+	now := vs.now().UTC()
+	verification.State = transition.State
+	switch transition.State {
+	case models.VerificationPending:
+		verification.Method = transition.Method
+		verification.AssuranceLevel = ""
+		verification.EvidenceRef = transition.EvidenceRef
+		verification.RequestedAt = &now
+		verification.VerifiedAt = nil
+		verification.ExpiresAt = nil
+		verification.DateOfBirth = nil
+		verification.Timezone = ""
+	case models.VerificationVerified:
+		if transition.Method != "" {
+			verification.Method = transition.Method
+		}
+		verification.AssuranceLevel = transition.AssuranceLevel
+		verification.EvidenceRef = transition.EvidenceRef
+		expiresAt := now.Add(vs.validity)
+		verification.VerifiedAt = &now
+		verification.ExpiresAt = &expiresAt
+	case models.VerificationRejected:
+		if transition.EvidenceRef != "" {
+			verification.EvidenceRef = transition.EvidenceRef
+		}
+	case models.VerificationExpired:
+		if verification.ExpiresAt == nil || now.Before(*verification.ExpiresAt) {
+			verification.ExpiresAt = &now
+		}
+	}
+
+	return verification
+}
+
+func allowed(from, to string) bool {
+	for _, state := range transitions[from] {
+		if state == to {
+			return true
+		}
+	}
+
+	return false
+}
+
+// required checks the transition carries what its state needs: a pending request says how the user is
+// verified, a verified outcome how sure it is and what backs it, and a rejection why
+func required(current models.Verification, transition models.VerificationTransition) error {
+	missing := make([]string, 0)
+	switch transition.State {
+	case models.VerificationPending:
+		if transition.Method == "" {
+			missing = append(missing, "method")
+		}
+	case models.VerificationVerified:
+		if transition.Method == "" && current.Method == "" {
+			missing = append(missing, "method")
+		}
+		if transition.AssuranceLevel == "" {
+			missing = append(missing, "assurance_level")
+		}
+		if transition.EvidenceRef == "" {
+			missing = append(missing, "evidence_ref")
+		}
+	case models.VerificationRejected:
+		if transition.Reason == "" {
+			missing = append(missing, "reason")
+		}
+	}
+
+	if len(missing) == 0 {
+		return nil
+	}
+
+	fields := make([]errx.FieldError, 0, len(missing))
+	for _, field := range missing {
+		fields = append(fields, errx.FieldError{Field: field, Rule: "required", Message: field + " is required"})
+	}
+
+	return errx.Localized(errx.Validation, "verification.missing_fields", "%s verification is missing fields", transition.State).WithProperty(errx.FieldErrorsProperty, fields)
+}
+
+func event(from string, verification models.Verification, reason, actor string) models.VerificationEvent {
+	return models.VerificationEvent{
+		UserId:         verification.UserId,
+		From:           from,
+		To:             verification.State,
+		Method:         verification.Method,
+		AssuranceLevel: verification.AssuranceLevel,
+		EvidenceRef:    verification.EvidenceRef,
+		Reason:         reason,
+		Actor:          actor,
+	}
+}
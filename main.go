@@ -1,11 +1,15 @@
 package main
 
 import (
+	"context"
 	"os"
 	"os/signal"
 	"syscall"
+	"time"
 )
 
+const shutdownTimeout = 30 * time.Second
+
 //go:generate wire
 
 //	@title			VerifyMy API
@@ -19,6 +23,7 @@ import (
 //	@securityDefinitions.apikey	JWT
 //	@in							header
 //	@name						token
+//	@securityDefinitions.basic	BasicAuth
 func main() {
 	server, err := InitializeWebServer()
 	server.RegisterHandlers()
@@ -26,7 +31,7 @@ func main() {
 		server.Server.Logger.Error(err.Error())
 		panic(err)
 	}
-	server.Start()
+	go server.Start()
 
 	// listens for system signals to gracefully shutdown
 	signalChannel := make(chan os.Signal, 1)
@@ -37,4 +42,8 @@ func main() {
 	case syscall.SIGTERM:
 		server.Server.Logger.Info("Received SIGINT, stopping...")
 	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+	defer cancel()
+	server.Shutdown(ctx)
 }
@@ -0,0 +1,192 @@
+// Package attestation verifies the age attestations the service issues, offline: a relying party fetches
+// the key set published at /.well-known/jwks.json once, keeps it, and checks every token against it
+// without calling the service. It only depends on the standard library and golang-jwt so partners can
+// import it on its own.
+package attestation
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+
+	"github.com/golang-jwt/jwt/v4"
+)
+
+const (
+	// TokenType is the typ header of attestations, so they can't be mistaken for other tokens of the issuer
+	TokenType = "age-attestation+jwt"
+	// KeySetPath is where the issuer publishes its key set
+	KeySetPath = "/.well-known/jwks.json"
+)
+
+var (
+	// ErrInvalidToken is returned, wrapped with the reason, for any attestation that doesn't verify
+	ErrInvalidToken = errors.New("invalid attestation")
+	// ErrUnknownKey is returned for attestations signed by a key not in the key set, the key set is
+	// worth fetching again in case the issuer rotated it
+	ErrUnknownKey = errors.New("attestation signed by an unknown key")
+)
+
+// Claims is all an attestation tells about its subject: whether they're over each age asked for, e.g.
+// {"18": true, "21": false}, and how sure the verification behind it is. It names nobody, its ID is
+// random and only there so relying parties can refuse replays
+type Claims struct {
+	AgeOver        map[int]bool `json:"age_over"`
+	AssuranceLevel string       `json:"assurance_level"`
+	jwt.RegisteredClaims
+}
+
+// Over tells whether the subject is over age, the second value is false when the attestation doesn't
+// answer for that age
+func (c *Claims) Over(age int) (over bool, ok bool) {
+	over, ok = c.AgeOver[age]
+	return over, ok
+}
+
+// JWK is a public P-256 key in the JSON Web Key format
+type JWK struct {
+	KeyType   string `json:"kty"`
+	Curve     string `json:"crv"`
+	X         string `json:"x"`
+	Y         string `json:"y"`
+	KeyId     string `json:"kid"`
+	Use       string `json:"use"`
+	Algorithm string `json:"alg"`
+}
+
+// KeySet is the JSON Web Key Set the issuer publishes
+type KeySet struct {
+	Keys []JWK `json:"keys"`
+}
+
+// NewJWK describes the public key, its id is the RFC 7638 thumbprint of the key
+func NewJWK(key *ecdsa.PublicKey) JWK {
+	size := (key.Curve.Params().BitSize + 7) / 8
+	jwk := JWK{
+		KeyType:   "EC",
+		Curve:     key.Curve.Params().Name,
+		X:         base64.RawURLEncoding.EncodeToString(key.X.FillBytes(make([]byte, size))),
+		Y:         base64.RawURLEncoding.EncodeToString(key.Y.FillBytes(make([]byte, size))),
+		Use:       "sig",
+		Algorithm: jwt.SigningMethodES256.Alg(),
+	}
+
+	// the thumbprint hashes the required members only, in lexicographic order
+	thumbprint := sha256.Sum256([]byte(fmt.Sprintf(`{"crv":%q,"kty":%q,"x":%q,"y":%q}`, jwk.Curve, jwk.KeyType, jwk.X, jwk.Y)))
+	jwk.KeyId = base64.RawURLEncoding.EncodeToString(thumbprint[:])
+
+	return jwk
+}
+
+// PublicKey reads the key back, only P-256 keys are supported
+func (j JWK) PublicKey() (*ecdsa.PublicKey, error) {
+	if j.KeyType != "EC" || j.Curve != elliptic.P256().Params().Name {
+		return nil, fmt.Errorf("key %s is not a P-256 key", j.KeyId)
+	}
+
+	x, err := base64.RawURLEncoding.DecodeString(j.X)
+	if err != nil {
+		return nil, fmt.Errorf("key %s: %w", j.KeyId, err)
+	}
+	y, err := base64.RawURLEncoding.DecodeString(j.Y)
+	if err != nil {
+		return nil, fmt.Errorf("key %s: %w", j.KeyId, err)
+	}
+
+	key := &ecdsa.PublicKey{Curve: elliptic.P256(), X: new(big.Int).SetBytes(x), Y: new(big.Int).SetBytes(y)}
+	if !key.Curve.IsOnCurve(key.X, key.Y) {
+		return nil, fmt.Errorf("key %s is not on the curve", j.KeyId)
+	}
+
+	return key, nil
+}
+
+// Verifier checks attestations against a key set, it's safe for concurrent use
+type Verifier struct {
+	issuer string
+	keys   map[string]*ecdsa.PublicKey
+}
+
+// NewVerifier trusts the keys of the set for attestations of issuer
+func NewVerifier(keySet KeySet, issuer string) (*Verifier, error) {
+	keys := make(map[string]*ecdsa.PublicKey, len(keySet.Keys))
+	for _, jwk := range keySet.Keys {
+		key, err := jwk.PublicKey()
+		if err != nil {
+			return nil, err
+		}
+		keys[jwk.KeyId] = key
+	}
+
+	return &Verifier{issuer: issuer, keys: keys}, nil
+}
+
+// ParseVerifier builds a verifier out of the key set as the issuer publishes it
+func ParseVerifier(keySet []byte, issuer string) (*Verifier, error) {
+	var set KeySet
+	if err := json.Unmarshal(keySet, &set); err != nil {
+		return nil, fmt.Errorf("key set is not valid: %w", err)
+	}
+
+	return NewVerifier(set, issuer)
+}
+
+// Verify checks the signature, issuer, type and validity window of the attestation and returns its claims.
+// audience is the relying party checking it, attestations issued for another one don't verify; an empty
+// audience accepts attestations issued for anyone
+func (v *Verifier) Verify(token, audience string) (*Claims, error) {
+	claims := &Claims{}
+	parsed, err := jwt.ParseWithClaims(token, claims, v.key, jwt.WithValidMethods([]string{jwt.SigningMethodES256.Alg()}))
+	if err != nil {
+		if errors.Is(err, ErrUnknownKey) {
+			return nil, ErrUnknownKey
+		}
+		return nil, fmt.Errorf("%w: %s", ErrInvalidToken, err.Error())
+	}
+
+	if typ, _ := parsed.Header["typ"].(string); typ != TokenType {
+		return nil, fmt.Errorf("%w: type %q is not %s", ErrInvalidToken, typ, TokenType)
+	}
+	if !claims.VerifyIssuer(v.issuer, true) {
+		return nil, fmt.Errorf("%w: issued by %q", ErrInvalidToken, claims.Issuer)
+	}
+	if claims.ExpiresAt == nil {
+		return nil, fmt.Errorf("%w: it never expires", ErrInvalidToken)
+	}
+	if audience != "" && !claims.VerifyAudience(audience, true) {
+		return nil, fmt.Errorf("%w: issued for %v", ErrInvalidToken, claims.Audience)
+	}
+
+	return claims, nil
+}
+
+// VerifyOver verifies the attestation and tells whether its subject is over age, attestations that
+// don't answer for that age fail
+func (v *Verifier) VerifyOver(token, audience string, age int) (bool, error) {
+	claims, err := v.Verify(token, audience)
+	if err != nil {
+		return false, err
+	}
+
+	over, ok := claims.Over(age)
+	if !ok {
+		return false, fmt.Errorf("%w: it doesn't tell whether over %d", ErrInvalidToken, age)
+	}
+
+	return over, nil
+}
+
+func (v *Verifier) key(token *jwt.Token) (interface{}, error) {
+	kid, _ := token.Header["kid"].(string)
+	key, ok := v.keys[kid]
+	if !ok {
+		return nil, ErrUnknownKey
+	}
+
+	return key, nil
+}
@@ -0,0 +1,128 @@
+package attestation_test
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"github.com/golang-jwt/jwt/v4"
+	"github.com/golang/mock/gomock"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"github.com/rhuandantas/verifymy-test/internal/server/middlewares/auth"
+	"github.com/rhuandantas/verifymy-test/pkg/attestation"
+	mock_config "github.com/rhuandantas/verifymy-test/test/mock/config"
+	mock_log "github.com/rhuandantas/verifymy-test/test/mock/log"
+	"time"
+)
+
+var _ = Describe("Test attestation verifier", func() {
+	var (
+		mockCtrl *gomock.Controller
+		attester auth.Attester
+		verifier *attestation.Verifier
+	)
+
+	newAttester := func(pemKey string) auth.Attester {
+		config := mock_config.NewMockConfigProvider(mockCtrl)
+		config.EXPECT().GetString("auth.attestation.private-key").Return("ATTESTATION_PRIVATE_KEY")
+		config.EXPECT().GetString("ATTESTATION_PRIVATE_KEY").Return(pemKey).AnyTimes()
+		config.EXPECT().GetBool("dev.temporary-keys").Return(true).AnyTimes()
+		config.EXPECT().GetStringOrDefault("auth.attestation.issuer", gomock.Any()).Return("verifymy")
+		logger := mock_log.NewMockSimpleLogger(mockCtrl)
+		logger.EXPECT().Warn(gomock.Any()).AnyTimes()
+		a, err := auth.NewEcdsaAttester(config, logger)
+		Expect(err).To(BeNil())
+		return a
+	}
+
+	claims := func(audience string, expiresAt time.Time) *attestation.Claims {
+		return &attestation.Claims{
+			AgeOver:        map[int]bool{18: true, 21: false},
+			AssuranceLevel: "substantial",
+			RegisteredClaims: jwt.RegisteredClaims{
+				Audience:  jwt.ClaimStrings{audience},
+				ExpiresAt: jwt.NewNumericDate(expiresAt),
+			},
+		}
+	}
+
+	BeforeEach(func() {
+		mockCtrl = gomock.NewController(GinkgoT())
+		attester = newAttester("")
+		keySet, err := json.Marshal(attester.KeySet())
+		Expect(err).To(BeNil())
+		verifier, err = attestation.ParseVerifier(keySet, "verifymy")
+		Expect(err).To(BeNil())
+	})
+
+	It("verifies attestations of the published key set", func() {
+		token, err := attester.Sign(claims("shop.example", time.Now().Add(time.Minute)))
+		Expect(err).To(BeNil())
+		verified, err := verifier.Verify(token, "shop.example")
+		Expect(err).To(BeNil())
+		Expect(verified.AgeOver).To(Equal(map[int]bool{18: true, 21: false}))
+		Expect(verified.Subject).To(BeEmpty())
+		over, err := verifier.VerifyOver(token, "shop.example", 21)
+		Expect(err).To(BeNil())
+		Expect(over).To(BeFalse())
+	})
+
+	It("refuses attestations that don't answer for the age", func() {
+		token, _ := attester.Sign(claims("shop.example", time.Now().Add(time.Minute)))
+		_, err := verifier.VerifyOver(token, "shop.example", 16)
+		Expect(errors.Is(err, attestation.ErrInvalidToken)).To(BeTrue())
+	})
+
+	It("refuses attestations issued for another relying party", func() {
+		token, _ := attester.Sign(claims("bar.example", time.Now().Add(time.Minute)))
+		_, err := verifier.Verify(token, "shop.example")
+		Expect(errors.Is(err, attestation.ErrInvalidToken)).To(BeTrue())
+	})
+
+	It("refuses expired attestations", func() {
+		token, _ := attester.Sign(claims("shop.example", time.Now().Add(-time.Minute)))
+		_, err := verifier.Verify(token, "shop.example")
+		Expect(errors.Is(err, attestation.ErrInvalidToken)).To(BeTrue())
+	})
+
+	It("refuses tampered attestations", func() {
+		token, _ := attester.Sign(claims("shop.example", time.Now().Add(time.Minute)))
+		_, err := verifier.Verify(token[:len(token)-4]+"AAAA", "shop.example")
+		Expect(errors.Is(err, attestation.ErrInvalidToken)).To(BeTrue())
+	})
+
+	It("tells attestations signed by other keys apart", func() {
+		token, _ := newAttester("").Sign(claims("shop.example", time.Now().Add(time.Minute)))
+		_, err := verifier.Verify(token, "shop.example")
+		Expect(err).To(Equal(attestation.ErrUnknownKey))
+	})
+
+	It("refuses other tokens of the issuer", func() {
+		key, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+		der, _ := x509.MarshalECPrivateKey(key)
+		configured := newAttester(string(pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: der})))
+		Expect(configured.KeySet().Keys[0]).To(Equal(attestation.NewJWK(&key.PublicKey)))
+
+		token := jwt.NewWithClaims(jwt.SigningMethodES256, claims("shop.example", time.Now().Add(time.Minute)))
+		token.Header["kid"] = configured.KeySet().Keys[0].KeyId
+		signed, err := token.SignedString(key)
+		Expect(err).To(BeNil())
+		keySet, _ := json.Marshal(configured.KeySet())
+		verifier, _ = attestation.ParseVerifier(keySet, "verifymy")
+		_, err = verifier.Verify(signed, "shop.example")
+		Expect(errors.Is(err, attestation.ErrInvalidToken)).To(BeTrue())
+	})
+
+	It("needs a signing key outside of development", func() {
+		config := mock_config.NewMockConfigProvider(mockCtrl)
+		config.EXPECT().GetString("auth.attestation.private-key").Return("ATTESTATION_PRIVATE_KEY")
+		config.EXPECT().GetString("ATTESTATION_PRIVATE_KEY").Return("")
+		config.EXPECT().GetBool("dev.temporary-keys").Return(false)
+		_, err := auth.NewEcdsaAttester(config, mock_log.NewMockSimpleLogger(mockCtrl))
+		Expect(err).To(MatchError(ContainSubstring("ATTESTATION_PRIVATE_KEY")))
+	})
+})
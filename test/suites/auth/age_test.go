@@ -0,0 +1,156 @@
+package auth_test
+
+import (
+	"encoding/json"
+	"time"
+
+	"github.com/golang-jwt/jwt/v4"
+	"github.com/golang/mock/gomock"
+	"github.com/labstack/echo/v4"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	errx "github.com/rhuandantas/verifymy-test/internal/errors"
+	"github.com/rhuandantas/verifymy-test/internal/models"
+	"github.com/rhuandantas/verifymy-test/internal/rules"
+	serverErr "github.com/rhuandantas/verifymy-test/internal/server/error"
+	"github.com/rhuandantas/verifymy-test/internal/server/middlewares/auth"
+	mock_config "github.com/rhuandantas/verifymy-test/test/mock/config"
+	mock_repo "github.com/rhuandantas/verifymy-test/test/mock/repo"
+	mock_verification "github.com/rhuandantas/verifymy-test/test/mock/verification"
+	"net/http"
+	"net/http/httptest"
+)
+
+var _ = Describe("Test age gate", func() {
+	var (
+		mockCtrl *gomock.Controller
+		e        *echo.Echo
+		userRepo *mock_repo.MockUserRepo
+		service  *mock_verification.MockService
+		jwtToken auth.Token
+		gate     auth.AgeGate
+		next     echo.HandlerFunc
+	)
+
+	BeforeEach(func() {
+		e = echo.New()
+		mockCtrl = gomock.NewController(GinkgoT())
+		userRepo = mock_repo.NewMockUserRepo(mockCtrl)
+		service = mock_verification.NewMockService(mockCtrl)
+		config := mock_config.NewMockConfigProvider(mockCtrl)
+		config.EXPECT().GetEnv("AUTH_SECRET").Return("secret").AnyTimes()
+		jwtToken = auth.NewJwtToken(config)
+		gate = auth.NewVerifiedAgeGate(userRepo, service)
+		next = func(c echo.Context) error {
+			return c.NoContent(http.StatusOK)
+		}
+	})
+
+	// request runs the gate after the token, claims are signed into it as they are
+	request := func(claims jwt.MapClaims) *httptest.ResponseRecorder {
+		claims["exp"] = time.Now().Add(time.Hour).Unix()
+		token, err := jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString([]byte("secret"))
+		Expect(err).To(BeNil())
+		req := httptest.NewRequest(http.MethodGet, "/adult", nil)
+		req.Header.Set(echo.HeaderAuthorization, "Bearer "+token)
+		rec := httptest.NewRecorder()
+		Expect(jwtToken.VerifyToken(gate.RequireMinimumAge(18)(next))(e.NewContext(req, rec))).To(Succeed())
+		return rec
+	}
+
+	problem := func(rec *httptest.ResponseRecorder) serverErr.ErrorResponse {
+		var res serverErr.ErrorResponse
+		Expect(json.Unmarshal(rec.Body.Bytes(), &res)).To(Succeed())
+		Expect(res.Code).To(Equal(errx.AgeRestricted.FullName()))
+		return res
+	}
+
+	bornYearsAgo := func(years int) *models.Date {
+		birth := models.DateOf(time.Now().UTC()).AddDays(-1)
+		birth = models.NewDate(birth.Year()-years, birth.Month(), birth.Day())
+		return &birth
+	}
+
+	verified := func(state string, dateOfBirth *models.Date) *models.VerificationStatus {
+		return &models.VerificationStatus{Verification: &models.Verification{UserId: 1, State: state, DateOfBirth: dateOfBirth}}
+	}
+
+	Context("With a verified age claim", func() {
+		It("lets adults through without looking their verification up", func() {
+			userRepo.EXPECT().GetByEmail(gomock.Any(), "jon@email.com").Return(&models.User{UserId: 1, Status: models.UserActive}, nil)
+			Expect(request(jwt.MapClaims{"email": "jon@email.com", "verified_age": 18}).Code).To(Equal(http.StatusOK))
+		})
+
+		It("lets adults through who aren't registered", func() {
+			userRepo.EXPECT().GetByEmail(gomock.Any(), "jon@email.com").Return(nil, errx.NotFound.New("User not found with email jon@email.com"))
+			Expect(request(jwt.MapClaims{"email": "jon@email.com", "verified_age": 18}).Code).To(Equal(http.StatusOK))
+		})
+
+		It("denies restricted minors whatever the claim", func() {
+			userRepo.EXPECT().GetByEmail(gomock.Any(), "arya@email.com").Return(&models.User{UserId: 2, Status: models.UserRestricted}, nil)
+			res := problem(request(jwt.MapClaims{"email": "arya@email.com", "verified_age": 18}))
+			Expect(res.Reason).To(Equal(rules.ReasonConsentRequired))
+		})
+
+		It("denies minors", func() {
+			userRepo.EXPECT().GetByEmail(gomock.Any(), "jon@email.com").Return(&models.User{UserId: 1, Status: models.UserActive}, nil)
+			rec := request(jwt.MapClaims{"email": "jon@email.com", "verified_age": 17})
+			Expect(rec.Code).To(Equal(http.StatusForbidden))
+			Expect(problem(rec).Reason).To(Equal(rules.ReasonUnderAge))
+		})
+	})
+
+	Context("Looking up the verification", func() {
+		It("lets verified adults through", func() {
+			userRepo.EXPECT().GetByEmail(gomock.Any(), "jon@email.com").Return(&models.User{UserId: 1, DateOfBirth: bornYearsAgo(18)}, nil)
+			service.EXPECT().Get(gomock.Any(), 1).Return(verified(models.VerificationVerified, bornYearsAgo(18)), nil)
+			Expect(request(jwt.MapClaims{"email": "jon@email.com"}).Code).To(Equal(http.StatusOK))
+		})
+
+		It("links unverified users to a verification", func() {
+			userRepo.EXPECT().GetByEmail(gomock.Any(), "jon@email.com").Return(&models.User{UserId: 1, DateOfBirth: bornYearsAgo(30)}, nil)
+			service.EXPECT().Get(gomock.Any(), 1).Return(verified(models.VerificationExpired, bornYearsAgo(30)), nil)
+			rec := request(jwt.MapClaims{"email": "jon@email.com"})
+			Expect(rec.Code).To(Equal(http.StatusForbidden))
+			res := problem(rec)
+			Expect(res.Reason).To(Equal(rules.ReasonNotVerified))
+			Expect(res.Link).To(Equal("/users/1/verification/start"))
+		})
+
+		It("denies verified minors", func() {
+			userRepo.EXPECT().GetByEmail(gomock.Any(), "jon@email.com").Return(&models.User{UserId: 1, DateOfBirth: bornYearsAgo(17)}, nil)
+			service.EXPECT().Get(gomock.Any(), 1).Return(verified(models.VerificationVerified, bornYearsAgo(17)), nil)
+			res := problem(request(jwt.MapClaims{"email": "jon@email.com"}))
+			Expect(res.Reason).To(Equal(rules.ReasonUnderAge))
+			Expect(res.Link).To(BeEmpty())
+		})
+
+		It("goes by the date of birth the user was verified with", func() {
+			userRepo.EXPECT().GetByEmail(gomock.Any(), "jon@email.com").Return(&models.User{UserId: 1, DateOfBirth: bornYearsAgo(30)}, nil)
+			service.EXPECT().Get(gomock.Any(), 1).Return(verified(models.VerificationVerified, bornYearsAgo(17)), nil)
+			Expect(problem(request(jwt.MapClaims{"email": "jon@email.com"})).Reason).To(Equal(rules.ReasonUnderAge))
+		})
+
+		It("denies restricted minors", func() {
+			userRepo.EXPECT().GetByEmail(gomock.Any(), "arya@email.com").Return(&models.User{UserId: 2, Status: models.UserRestricted}, nil)
+			res := problem(request(jwt.MapClaims{"email": "arya@email.com"}))
+			Expect(res.Reason).To(Equal(rules.ReasonConsentRequired))
+			Expect(res.Link).To(Equal("/users/2/guardians"))
+		})
+
+		It("denies callers who aren't registered", func() {
+			userRepo.EXPECT().GetByEmail(gomock.Any(), "ghost@email.com").Return(nil, errx.NotFound.New("User not found with email ghost@email.com"))
+			rec := request(jwt.MapClaims{"email": "ghost@email.com"})
+			Expect(rec.Code).To(Equal(http.StatusForbidden))
+			Expect(problem(rec).Reason).To(Equal(rules.ReasonNotVerified))
+		})
+	})
+
+	It("denies signed partner requests", func() {
+		rec := httptest.NewRecorder()
+		c := e.NewContext(httptest.NewRequest(http.MethodGet, "/adult", nil), rec)
+		c.Set(auth.PartnerContextKey, "partner-a")
+		Expect(gate.RequireMinimumAge(18)(next)(c)).To(Succeed())
+		Expect(rec.Code).To(Equal(http.StatusForbidden))
+	})
+})
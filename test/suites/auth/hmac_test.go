@@ -0,0 +1,200 @@
+package auth_test
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"time"
+
+	"github.com/golang/mock/gomock"
+	"github.com/labstack/echo/v4"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	errx "github.com/rhuandantas/verifymy-test/internal/errors"
+	"github.com/rhuandantas/verifymy-test/internal/models"
+	"github.com/rhuandantas/verifymy-test/internal/server/middlewares/auth"
+	mock_clients "github.com/rhuandantas/verifymy-test/test/mock/clients"
+	mock_config "github.com/rhuandantas/verifymy-test/test/mock/config"
+)
+
+var _ = Describe("Test auth hmac signature methods", func() {
+	var (
+		mockCtrl  *gomock.Controller
+		e         *echo.Echo
+		config    *mock_config.MockConfigProvider
+		registry  *mock_clients.MockRegistry
+		meter     *mock_clients.MockMeter
+		signature auth.Signature
+		next      echo.HandlerFunc
+	)
+
+	newRequest := func() *http.Request {
+		req := httptest.NewRequest(http.MethodPost, "/users?source=partner", strings.NewReader(`{"email":"jon@email.com"}`))
+		req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+		return req
+	}
+
+	BeforeEach(func() {
+		e = echo.New()
+		mockCtrl = gomock.NewController(GinkgoT())
+		config = mock_config.NewMockConfigProvider(mockCtrl)
+		config.EXPECT().GetString("auth.hmac.partners.partner-a.secret-key").Return("PARTNER_A_SECRET").AnyTimes()
+		config.EXPECT().GetEnv("PARTNER_A_SECRET").Return("secret").AnyTimes()
+		config.EXPECT().GetString(gomock.Any()).Return("").AnyTimes()
+		config.EXPECT().GetInt("auth.hmac.timestamp-window").Return(300).AnyTimes()
+		config.EXPECT().GetStringSlice("auth.hmac.partners.partner-a.permissions").Return([]string{auth.PermissionExportUsers}).AnyTimes()
+		registry = mock_clients.NewMockRegistry(mockCtrl)
+		registry.EXPECT().Credentials(gomock.Any(), "partner-b").Return(nil, "", errx.NotFound.New("Client partner-b not found")).AnyTimes()
+		meter = mock_clients.NewMockMeter(mockCtrl)
+		signature = auth.NewHmacSignature(config, auth.NewMemoryNonceCache(), registry, meter)
+		next = func(c echo.Context) error {
+			return c.String(http.StatusOK, c.Get(auth.PartnerContextKey).(string))
+		}
+	})
+
+	AfterEach(func() {
+		e.Close()
+	})
+
+	It("verify signature successfully", func(ctx SpecContext) {
+		req := newRequest()
+		Expect(auth.SignRequest(req, "partner-a", "secret", "nonce-1", time.Now(), "Content-Type", "Host")).To(BeNil())
+		rec := httptest.NewRecorder()
+		err := signature.VerifySignature(next)(e.NewContext(req, rec))
+		Expect(err).To(BeNil())
+		Expect(rec.Code).To(Equal(200))
+		Expect(rec.Body.String()).To(Equal("partner-a"))
+	})
+
+	It("reject a signature replayed against another host", func(ctx SpecContext) {
+		req := newRequest()
+		Expect(auth.SignRequest(req, "partner-a", "secret", "nonce-1", time.Now())).To(BeNil())
+		Expect(req.Header.Get(echo.HeaderAuthorization)).To(ContainSubstring("SignedHeaders=content-type;host,"))
+		req.Host = "other.example.com"
+		rec := httptest.NewRecorder()
+		Expect(signature.VerifySignature(next)(e.NewContext(req, rec))).To(BeNil())
+		Expect(rec.Code).To(Equal(401))
+	})
+
+	It("reject signatures that leave out host or content type", func(ctx SpecContext) {
+		req := newRequest()
+		req.Header.Set(echo.HeaderAuthorization, fmt.Sprintf("%s Credential=partner-a, SignedHeaders=content-type, Timestamp=%d, Nonce=nonce-1, Signature=abc",
+			auth.HmacScheme, time.Now().Unix()))
+		rec := httptest.NewRecorder()
+		Expect(signature.VerifySignature(next)(e.NewContext(req, rec))).To(BeNil())
+		Expect(rec.Code).To(Equal(401))
+		Expect(rec.Body.String()).To(ContainSubstring("signed headers must include content-type and host"))
+	})
+
+	It("reject tampered body", func(ctx SpecContext) {
+		req := newRequest()
+		Expect(auth.SignRequest(req, "partner-a", "secret", "nonce-1", time.Now(), "Content-Type")).To(BeNil())
+		tampered := httptest.NewRequest(http.MethodPost, "/users?source=partner", strings.NewReader(`{"email":"eve@email.com"}`))
+		tampered.Header = req.Header
+		rec := httptest.NewRecorder()
+		err := signature.VerifySignature(next)(e.NewContext(tampered, rec))
+		Expect(err).To(BeNil())
+		Expect(rec.Code).To(Equal(401))
+	})
+
+	It("reject wrong secret", func(ctx SpecContext) {
+		req := newRequest()
+		Expect(auth.SignRequest(req, "partner-a", "other-secret", "nonce-1", time.Now())).To(BeNil())
+		rec := httptest.NewRecorder()
+		err := signature.VerifySignature(next)(e.NewContext(req, rec))
+		Expect(err).To(BeNil())
+		Expect(rec.Code).To(Equal(401))
+	})
+
+	It("reject unknown partner", func(ctx SpecContext) {
+		req := newRequest()
+		Expect(auth.SignRequest(req, "partner-b", "secret", "nonce-1", time.Now())).To(BeNil())
+		rec := httptest.NewRecorder()
+		err := signature.VerifySignature(next)(e.NewContext(req, rec))
+		Expect(err).To(BeNil())
+		Expect(rec.Code).To(Equal(401))
+	})
+
+	It("reject timestamp out of window", func(ctx SpecContext) {
+		req := newRequest()
+		Expect(auth.SignRequest(req, "partner-a", "secret", "nonce-1", time.Now().Add(-10*time.Minute))).To(BeNil())
+		rec := httptest.NewRecorder()
+		err := signature.VerifySignature(next)(e.NewContext(req, rec))
+		Expect(err).To(BeNil())
+		Expect(rec.Code).To(Equal(401))
+	})
+
+	It("reject replayed nonce", func(ctx SpecContext) {
+		req := newRequest()
+		Expect(auth.SignRequest(req, "partner-a", "secret", "nonce-1", time.Now())).To(BeNil())
+		first := httptest.NewRecorder()
+		Expect(signature.VerifySignature(next)(e.NewContext(req, first))).To(BeNil())
+		Expect(first.Code).To(Equal(200))
+
+		replay := newRequest()
+		replay.Header = req.Header
+		second := httptest.NewRecorder()
+		Expect(signature.VerifySignature(next)(e.NewContext(replay, second))).To(BeNil())
+		Expect(second.Code).To(Equal(401))
+	})
+
+	It("reject missing authorization", func(ctx SpecContext) {
+		rec := httptest.NewRecorder()
+		err := signature.VerifySignature(next)(e.NewContext(newRequest(), rec))
+		Expect(err).To(BeNil())
+		Expect(rec.Code).To(Equal(401))
+	})
+
+	Context("signed by a registered client", func() {
+		var client *models.Client
+
+		BeforeEach(func() {
+			client = &models.Client{ClientId: "shop", Plan: "free", Status: models.ClientActive,
+				AllowedOrigins: []string{"https://shop.example"}, Permissions: []string{auth.PermissionAttestUsers}}
+			registry.EXPECT().Credentials(gomock.Any(), "shop").Return(client, "shop-secret", nil).AnyTimes()
+		})
+
+		signed := func() *http.Request {
+			req := newRequest()
+			Expect(auth.SignRequest(req, "shop", "shop-secret", "nonce-1", time.Now())).To(BeNil())
+			return req
+		}
+
+		It("with the permissions of the client, metering the call", func(ctx SpecContext) {
+			meter.EXPECT().Check(gomock.Any(), client, models.MetricApiCalls).Return(func() {}, nil)
+			rec := httptest.NewRecorder()
+			c := e.NewContext(signed(), rec)
+			Expect(signature.VerifySignature(next)(c)).To(BeNil())
+			Expect(rec.Code).To(Equal(200))
+			Expect(auth.HasPermission(c, auth.PermissionAttestUsers)).To(BeTrue())
+			Expect(c.Get(auth.ClientContextKey)).To(Equal(client))
+		})
+
+		It("refuse a suspended client", func(ctx SpecContext) {
+			client.Status = models.ClientSuspended
+			rec := httptest.NewRecorder()
+			Expect(signature.VerifySignature(next)(e.NewContext(signed(), rec))).To(BeNil())
+			Expect(rec.Code).To(Equal(403))
+		})
+
+		It("refuse an origin the client doesn't allow", func(ctx SpecContext) {
+			req := signed()
+			req.Header.Set(echo.HeaderOrigin, "https://evil.example")
+			rec := httptest.NewRecorder()
+			Expect(signature.VerifySignature(next)(e.NewContext(req, rec))).To(BeNil())
+			Expect(rec.Code).To(Equal(403))
+		})
+
+		It("refuse a client over its quota, counting the call anyway", func(ctx SpecContext) {
+			meter.EXPECT().Check(gomock.Any(), client, models.MetricApiCalls).Return(nil, errx.QuotaExceeded.New("quota exceeded").
+				WithProperty(errx.QuotaProperty, &errx.Quota{Plan: "free", Metric: models.MetricApiCalls, Limit: 10, Used: 10, ResetsAt: time.Now().Add(time.Hour)}))
+			meter.EXPECT().Record("shop", models.MetricApiCalls)
+			rec := httptest.NewRecorder()
+			Expect(signature.VerifySignature(next)(e.NewContext(signed(), rec))).To(BeNil())
+			Expect(rec.Code).To(Equal(429))
+			Expect(rec.Header().Get("Retry-After")).To(Equal("3600"))
+			Expect(rec.Body.String()).To(ContainSubstring(`"quota":{"plan":"free","metric":"api_calls","limit":10,"used":10`))
+		})
+	})
+})
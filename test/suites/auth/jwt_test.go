@@ -1,7 +1,11 @@
 package auth_test
 
 import (
+	"net/http"
+	"net/http/httptest"
+
 	"github.com/golang/mock/gomock"
+	"github.com/labstack/echo/v4"
 	. "github.com/onsi/ginkgo/v2"
 	. "github.com/onsi/gomega"
 	"github.com/rhuandantas/verifymy-test/internal/server/middlewares/auth"
@@ -23,9 +27,30 @@ var _ = Describe("Test auth jwt methods", func() {
 
 	It("generate token successfully", func(ctx SpecContext) {
 		config.EXPECT().GetEnv("AUTH_SECRET").Return("secret")
+		config.EXPECT().GetStringSlice("auth.jwt.admins").Return(nil)
 		token, err := jwtToken.GenerateToken("email")
 		Expect(err).To(BeNil())
 		Expect(token).ToNot(BeEmpty())
 	})
 
+	DescribeTable("admin tokens only for the configured admins",
+		func(email string, admin bool) {
+			config.EXPECT().GetEnv("AUTH_SECRET").Return("secret").Times(2)
+			config.EXPECT().GetStringSlice("auth.jwt.admins").Return([]string{"admin@verifymy.com"})
+			token, err := jwtToken.GenerateToken(email)
+			Expect(err).To(BeNil())
+
+			req := httptest.NewRequest(http.MethodGet, "/users", nil)
+			req.Header.Set(echo.HeaderAuthorization, "Bearer "+token)
+			c := echo.New().NewContext(req, httptest.NewRecorder())
+			Expect(jwtToken.VerifyToken(func(c echo.Context) error {
+				Expect(auth.IsAdmin(c)).To(Equal(admin))
+				Expect(auth.Caller(c)).To(Equal(email))
+				return nil
+			})(c)).To(Succeed())
+		},
+		Entry("admin", "Admin@verifymy.com", true),
+		Entry("anyone else", "jon@email.com", false),
+	)
+
 })
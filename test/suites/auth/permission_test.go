@@ -0,0 +1,60 @@
+package auth_test
+
+import (
+	"github.com/golang/mock/gomock"
+	"github.com/labstack/echo/v4"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"github.com/rhuandantas/verifymy-test/internal/server/middlewares/auth"
+	mock_config "github.com/rhuandantas/verifymy-test/test/mock/config"
+	"net/http"
+	"net/http/httptest"
+)
+
+var _ = Describe("Test auth permissions", func() {
+	var (
+		e    *echo.Echo
+		next echo.HandlerFunc
+	)
+
+	BeforeEach(func() {
+		e = echo.New()
+		next = func(c echo.Context) error {
+			return c.NoContent(http.StatusOK)
+		}
+	})
+
+	newContext := func() (echo.Context, *httptest.ResponseRecorder) {
+		rec := httptest.NewRecorder()
+		return e.NewContext(httptest.NewRequest(http.MethodGet, "/users/export", nil), rec), rec
+	}
+
+	It("admins have every permission", func(ctx SpecContext) {
+		config := mock_config.NewMockConfigProvider(gomock.NewController(GinkgoT()))
+		config.EXPECT().GetEnv("AUTH_SECRET").Return("secret").Times(2)
+		config.EXPECT().GetStringSlice("auth.jwt.admins").Return([]string{"admin@verifymy.com"})
+		jwtToken := auth.NewJwtToken(config)
+		token, err := jwtToken.GenerateToken("admin@verifymy.com")
+		Expect(err).To(BeNil())
+
+		c, rec := newContext()
+		c.Request().Header.Set(echo.HeaderAuthorization, "Bearer "+token)
+		Expect(jwtToken.VerifyToken(auth.RequirePermission(auth.PermissionExportUsers)(next))(c)).To(Succeed())
+		Expect(rec.Code).To(Equal(http.StatusOK))
+	})
+
+	It("callers granted the permission", func(ctx SpecContext) {
+		c, rec := newContext()
+		c.Set(auth.PermissionsContextKey, []string{auth.PermissionExportUsers})
+		Expect(auth.RequirePermission(auth.PermissionExportUsers)(next)(c)).To(Succeed())
+		Expect(rec.Code).To(Equal(http.StatusOK))
+	})
+
+	It("forbidden without the permission", func(ctx SpecContext) {
+		c, rec := newContext()
+		c.Set(auth.PermissionsContextKey, []string{"users:import"})
+		Expect(auth.RequirePermission(auth.PermissionExportUsers)(next)(c)).To(Succeed())
+		Expect(rec.Code).To(Equal(http.StatusForbidden))
+		Expect(auth.HasPermission(c, auth.PermissionExportUsers)).To(BeFalse())
+	})
+})
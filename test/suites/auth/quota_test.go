@@ -0,0 +1,80 @@
+package auth_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"time"
+
+	"github.com/golang/mock/gomock"
+	"github.com/labstack/echo/v4"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	errx "github.com/rhuandantas/verifymy-test/internal/errors"
+	"github.com/rhuandantas/verifymy-test/internal/models"
+	"github.com/rhuandantas/verifymy-test/internal/server/middlewares/auth"
+	mock_clients "github.com/rhuandantas/verifymy-test/test/mock/clients"
+)
+
+var _ = Describe("Test auth quotas", func() {
+	var (
+		e      *echo.Echo
+		meter  *mock_clients.MockMeter
+		client *models.Client
+	)
+
+	BeforeEach(func() {
+		e = echo.New()
+		meter = mock_clients.NewMockMeter(gomock.NewController(GinkgoT()))
+		client = &models.Client{ClientId: "shop", Plan: "free"}
+	})
+
+	newContext := func(asClient bool) (echo.Context, *httptest.ResponseRecorder) {
+		rec := httptest.NewRecorder()
+		c := e.NewContext(httptest.NewRequest(http.MethodPost, "/users/1/attestations", nil), rec)
+		if asClient {
+			c.Set(auth.ClientContextKey, client)
+		}
+		return c, rec
+	}
+
+	respond := func(status int) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			return c.NoContent(status)
+		}
+	}
+
+	It("counts what clients got", func(ctx SpecContext) {
+		released := 0
+		meter.EXPECT().Check(gomock.Any(), client, models.MetricTokens).Return(func() { released++ }, nil)
+		c, rec := newContext(true)
+		Expect(auth.RequireQuota(meter, models.MetricTokens)(respond(http.StatusOK))(c)).To(Succeed())
+		Expect(rec.Code).To(Equal(http.StatusOK))
+		Expect(released).To(Equal(0))
+	})
+
+	It("doesn't count what failed", func(ctx SpecContext) {
+		released := 0
+		meter.EXPECT().Check(gomock.Any(), client, models.MetricTokens).Return(func() { released++ }, nil)
+		c, rec := newContext(true)
+		Expect(auth.RequireQuota(meter, models.MetricTokens)(respond(http.StatusConflict))(c)).To(Succeed())
+		Expect(rec.Code).To(Equal(http.StatusConflict))
+		Expect(released).To(Equal(1))
+	})
+
+	It("answers 429 with the plan once the quota is used up", func(ctx SpecContext) {
+		meter.EXPECT().Check(gomock.Any(), client, models.MetricTokens).Return(nil, errx.QuotaExceeded.New("quota exceeded").
+			WithProperty(errx.QuotaProperty, &errx.Quota{Plan: "free", Metric: models.MetricTokens, Limit: 5, Used: 5, ResetsAt: time.Now().Add(time.Minute)}))
+		c, rec := newContext(true)
+		Expect(auth.RequireQuota(meter, models.MetricTokens)(respond(http.StatusOK))(c)).To(Succeed())
+		Expect(rec.Code).To(Equal(http.StatusTooManyRequests))
+		Expect(rec.Header().Get("Retry-After")).To(Equal("60"))
+		Expect(rec.Body.String()).To(ContainSubstring(`"code":"common.too_many_requests.quota_exceeded"`))
+		Expect(rec.Body.String()).To(ContainSubstring(`"plan":"free"`))
+	})
+
+	It("doesn't meter callers other than clients", func(ctx SpecContext) {
+		c, rec := newContext(false)
+		Expect(auth.RequireQuota(meter, models.MetricTokens)(respond(http.StatusOK))(c)).To(Succeed())
+		Expect(rec.Code).To(Equal(http.StatusOK))
+	})
+})
@@ -0,0 +1,159 @@
+package clients_test
+
+import (
+	"errors"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/golang/mock/gomock"
+	"github.com/joomcode/errorx"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"github.com/rhuandantas/verifymy-test/internal/clients"
+	errx "github.com/rhuandantas/verifymy-test/internal/errors"
+	"github.com/rhuandantas/verifymy-test/internal/models"
+	mock_clients "github.com/rhuandantas/verifymy-test/test/mock/clients"
+	mock_log "github.com/rhuandantas/verifymy-test/test/mock/log"
+	mock_repo "github.com/rhuandantas/verifymy-test/test/mock/repo"
+)
+
+var _ = Describe("Test usage meter", func() {
+	var (
+		mockCtrl  *gomock.Controller
+		registry  *mock_clients.MockRegistry
+		usageRepo *mock_repo.MockUsageRepo
+		logger    *mock_log.MockSimpleLogger
+		meter     clients.Meter
+		client    *models.Client
+		today     models.Date
+	)
+
+	BeforeEach(func() {
+		mockCtrl = gomock.NewController(GinkgoT())
+		registry = mock_clients.NewMockRegistry(mockCtrl)
+		usageRepo = mock_repo.NewMockUsageRepo(mockCtrl)
+		logger = mock_log.NewMockSimpleLogger(mockCtrl)
+		meter = clients.NewMemoryMeter(registry, usageRepo, logger)
+		client = &models.Client{ClientId: "shop", Plan: "free"}
+		today = models.Today(time.UTC)
+		registry.EXPECT().Plan("free").Return(&models.Plan{Name: "free", Title: "Free", Quotas: map[string]int64{
+			models.MetricVerifications: 2,
+			models.MetricTokens:        0,
+		}}, nil).AnyTimes()
+	})
+
+	It("lets clients through until their quota is used up, stored usage included", func(ctx SpecContext) {
+		usageRepo.EXPECT().Count(gomock.Any(), "shop", today, models.MetricVerifications).Return(int64(1), nil).Times(1)
+		_, err := meter.Check(ctx, client, models.MetricVerifications)
+		Expect(err).To(BeNil())
+
+		_, err = meter.Check(ctx, client, models.MetricVerifications)
+		Expect(errorx.IsOfType(err, errx.QuotaExceeded)).To(BeTrue())
+		quota := errx.QuotaOf(errorx.Cast(err))
+		Expect(quota.Plan).To(Equal("free"))
+		Expect(quota.Limit).To(Equal(int64(2)))
+		Expect(quota.Used).To(Equal(int64(2)))
+		Expect(quota.ResetsAt).To(Equal(today.AddDays(1).Time))
+	})
+
+	It("doesn't limit metrics without a quota", func(ctx SpecContext) {
+		for i := 0; i < 10; i++ {
+			_, err := meter.Check(ctx, client, models.MetricTokens)
+			Expect(err).To(BeNil())
+		}
+		usageRepo.EXPECT().Add(gomock.Any(), "shop", today, models.MetricTokens, int64(10)).Return(nil)
+		Expect(meter.Flush(ctx)).To(Succeed())
+	})
+
+	It("doesn't let concurrent calls go over the quota", func(ctx SpecContext) {
+		usageRepo.EXPECT().Count(gomock.Any(), "shop", today, models.MetricVerifications).Return(int64(0), nil).AnyTimes()
+		var (
+			wg      sync.WaitGroup
+			allowed int32
+		)
+		for i := 0; i < 20; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				if _, err := meter.Check(ctx, client, models.MetricVerifications); err == nil {
+					atomic.AddInt32(&allowed, 1)
+				}
+			}()
+		}
+		wg.Wait()
+		Expect(allowed).To(Equal(int32(2)))
+	})
+
+	It("gives back what's released, once", func(ctx SpecContext) {
+		usageRepo.EXPECT().Count(gomock.Any(), "shop", today, models.MetricVerifications).Return(int64(1), nil)
+		release, err := meter.Check(ctx, client, models.MetricVerifications)
+		Expect(err).To(BeNil())
+		release()
+		release()
+
+		_, err = meter.Check(ctx, client, models.MetricVerifications)
+		Expect(err).To(BeNil())
+		usageRepo.EXPECT().Add(gomock.Any(), "shop", today, models.MetricVerifications, int64(1)).Return(nil)
+		Expect(meter.Flush(ctx)).To(Succeed())
+	})
+
+	It("gives back what's released after a flush on the next one", func(ctx SpecContext) {
+		release, err := meter.Check(ctx, client, models.MetricTokens)
+		Expect(err).To(BeNil())
+		usageRepo.EXPECT().Add(gomock.Any(), "shop", today, models.MetricTokens, int64(1)).Return(nil)
+		Expect(meter.Flush(ctx)).To(Succeed())
+		release()
+		usageRepo.EXPECT().Add(gomock.Any(), "shop", today, models.MetricTokens, int64(-1)).Return(nil)
+		Expect(meter.Flush(ctx)).To(Succeed())
+	})
+
+	It("flushes what was recorded once", func(ctx SpecContext) {
+		meter.Record("shop", models.MetricTokens)
+		meter.Record("shop", models.MetricTokens)
+		meter.Record("shop", models.MetricApiCalls)
+		usageRepo.EXPECT().Add(gomock.Any(), "shop", today, models.MetricTokens, int64(2)).Return(nil)
+		usageRepo.EXPECT().Add(gomock.Any(), "shop", today, models.MetricApiCalls, int64(1)).Return(nil)
+		Expect(meter.Flush(ctx)).To(Succeed())
+		Expect(meter.Flush(ctx)).To(Succeed())
+	})
+
+	It("keeps what failed to flush for the next time", func(ctx SpecContext) {
+		meter.Record("shop", models.MetricTokens)
+		logger.EXPECT().Errorf(gomock.Any(), gomock.Any())
+		gomock.InOrder(
+			usageRepo.EXPECT().Add(gomock.Any(), "shop", today, models.MetricTokens, int64(1)).Return(errors.New("mock error")),
+			usageRepo.EXPECT().Add(gomock.Any(), "shop", today, models.MetricTokens, int64(2)).Return(nil),
+		)
+		Expect(meter.Flush(ctx)).ToNot(Succeed())
+		meter.Record("shop", models.MetricTokens)
+		Expect(meter.Flush(ctx)).To(Succeed())
+	})
+
+	It("reports usage by day, what wasn't flushed included", func(ctx SpecContext) {
+		from := today.AddDays(-3)
+		registry.EXPECT().Get(gomock.Any(), "shop").Return(client, nil)
+		usageRepo.EXPECT().Range(gomock.Any(), "shop", from, today).Return([]*models.ClientUsage{
+			{ClientId: "shop", Day: from, Metric: models.MetricVerifications, Count: 3},
+			{ClientId: "shop", Day: today, Metric: models.MetricTokens, Count: 5},
+		}, nil)
+		meter.Record("shop", models.MetricTokens)
+		meter.Record("other", models.MetricTokens)
+
+		report, err := meter.Report(ctx, "shop", from, today)
+		Expect(err).To(BeNil())
+		Expect(report.Plan.Name).To(Equal("free"))
+		Expect(report.Days).To(HaveLen(2))
+		Expect(report.Days[0].Day).To(Equal(from))
+		Expect(report.Days[1].Counts).To(Equal(map[string]int64{
+			models.MetricVerifications: 0,
+			models.MetricTokens:        6,
+			models.MetricApiCalls:      0,
+		}))
+		Expect(report.Totals).To(Equal(map[string]int64{
+			models.MetricVerifications: 3,
+			models.MetricTokens:        6,
+			models.MetricApiCalls:      0,
+		}))
+	})
+})
@@ -0,0 +1,124 @@
+package clients_test
+
+import (
+	"context"
+
+	"github.com/golang/mock/gomock"
+	"github.com/joomcode/errorx"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"github.com/rhuandantas/verifymy-test/internal/clients"
+	errx "github.com/rhuandantas/verifymy-test/internal/errors"
+	"github.com/rhuandantas/verifymy-test/internal/models"
+	"github.com/rhuandantas/verifymy-test/internal/storage"
+	mock_config "github.com/rhuandantas/verifymy-test/test/mock/config"
+	mock_log "github.com/rhuandantas/verifymy-test/test/mock/log"
+	mock_repo "github.com/rhuandantas/verifymy-test/test/mock/repo"
+)
+
+var _ = Describe("Test client registry", func() {
+	var (
+		mockCtrl   *gomock.Controller
+		config     *mock_config.MockConfigProvider
+		clientRepo *mock_repo.MockClientRepo
+		registry   clients.Registry
+	)
+
+	BeforeEach(func() {
+		mockCtrl = gomock.NewController(GinkgoT())
+		config = mock_config.NewMockConfigProvider(mockCtrl)
+		config.EXPECT().GetStringOrDefault("clients.default-plan", gomock.Any()).Return("free")
+		config.EXPECT().GetString("clients.plans.free.title").Return("Free").AnyTimes()
+		config.EXPECT().GetInt("clients.plans.free.verifications").Return(100).AnyTimes()
+		config.EXPECT().GetInt("clients.plans.free.tokens").Return(1000).AnyTimes()
+		config.EXPECT().GetInt("clients.plans.free.api-calls").Return(0).AnyTimes()
+		config.EXPECT().GetString("auth.hmac.partners.partner-a.secret-key").Return("PARTNER_A_SECRET").AnyTimes()
+		config.EXPECT().GetString(gomock.Any()).Return("").AnyTimes()
+		config.EXPECT().GetBool("dev.temporary-keys").Return(true)
+		clientRepo = mock_repo.NewMockClientRepo(mockCtrl)
+		logger := mock_log.NewMockSimpleLogger(mockCtrl)
+		logger.EXPECT().Warn(gomock.Any()).AnyTimes()
+		logger.EXPECT().Infof(gomock.Any(), gomock.Any()).AnyTimes()
+		envelope, err := storage.NewAesEnvelope(config, logger)
+		Expect(err).To(BeNil())
+		registry = clients.NewClientRegistry(config, clientRepo, envelope, logger)
+	})
+
+	stored := func() *models.Client {
+		var client *models.Client
+		clientRepo.EXPECT().Create(gomock.Any(), gomock.Any()).DoAndReturn(func(_ interface{}, c models.Client) (*models.Client, error) {
+			client = &c
+			return &c, nil
+		})
+		credentials, err := registry.Register(context.Background(), models.ClientRegistration{ClientId: "shop", Name: "Shop"}, "admin@email.com")
+		Expect(err).To(BeNil())
+		Expect(credentials.Secret).To(HaveLen(43))
+		return client
+	}
+
+	It("registers a client on the default plan, keeping its secret encrypted", func(ctx SpecContext) {
+		client := stored()
+		Expect(client.Plan).To(Equal("free"))
+		Expect(client.Status).To(Equal(models.ClientActive))
+		Expect(client.AllowedOrigins).To(BeEmpty())
+		Expect(client.AllowedOrigins).ToNot(BeNil())
+		Expect(client.CreatedBy).To(Equal("admin@email.com"))
+		Expect(client.SecretCiphertext).ToNot(BeEmpty())
+	})
+
+	It("gives back the secret to check signatures with", func(ctx SpecContext) {
+		var client *models.Client
+		clientRepo.EXPECT().Create(gomock.Any(), gomock.Any()).DoAndReturn(func(_ interface{}, c models.Client) (*models.Client, error) {
+			client = &c
+			return &c, nil
+		})
+		credentials, err := registry.Register(ctx, models.ClientRegistration{ClientId: "shop", Name: "Shop"}, "admin@email.com")
+		Expect(err).To(BeNil())
+
+		clientRepo.EXPECT().Get(gomock.Any(), "shop").Return(client, nil)
+		_, secret, err := registry.Credentials(ctx, "shop")
+		Expect(err).To(BeNil())
+		Expect(secret).To(Equal(credentials.Secret))
+	})
+
+	It("doesn't let a client use the secret of another", func(ctx SpecContext) {
+		client := stored()
+		client.ClientId = "other"
+		clientRepo.EXPECT().Get(gomock.Any(), "other").Return(client, nil)
+		_, _, err := registry.Credentials(ctx, "other")
+		Expect(err).ToNot(BeNil())
+	})
+
+	It("refuses unknown plans", func(ctx SpecContext) {
+		_, err := registry.Register(ctx, models.ClientRegistration{ClientId: "shop", Name: "Shop", Plan: "gold"}, "admin@email.com")
+		Expect(errorx.IsOfType(err, errx.BadRequest)).To(BeTrue())
+	})
+
+	It("refuses the id of a configured partner", func(ctx SpecContext) {
+		_, err := registry.Register(ctx, models.ClientRegistration{ClientId: "partner-a", Name: "Partner"}, "admin@email.com")
+		Expect(errorx.IsOfType(err, errx.Conflict)).To(BeTrue())
+	})
+
+	It("rotates the secret", func(ctx SpecContext) {
+		client := stored()
+		previous := client.SecretCiphertext
+		clientRepo.EXPECT().Get(gomock.Any(), "shop").Return(client, nil)
+		clientRepo.EXPECT().Update(gomock.Any(), gomock.Any()).DoAndReturn(func(_ interface{}, c models.Client) (*models.Client, error) {
+			Expect(c.SecretCiphertext).ToNot(Equal(previous))
+			return &c, nil
+		})
+		credentials, err := registry.RotateSecret(ctx, "shop", "admin@email.com")
+		Expect(err).To(BeNil())
+		Expect(credentials.Secret).ToNot(BeEmpty())
+	})
+
+	It("reads plans from the config", func(ctx SpecContext) {
+		plan, err := registry.Plan("free")
+		Expect(err).To(BeNil())
+		Expect(plan).To(Equal(&models.Plan{Name: "free", Title: "Free", Quotas: map[string]int64{
+			models.MetricVerifications: 100,
+			models.MetricTokens:        1000,
+			models.MetricApiCalls:      0,
+		}}))
+	})
+})
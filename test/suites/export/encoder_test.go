@@ -0,0 +1,83 @@
+package export_test
+
+import (
+	"bytes"
+	"github.com/joomcode/errorx"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	errx "github.com/rhuandantas/verifymy-test/internal/errors"
+	"github.com/rhuandantas/verifymy-test/internal/export"
+	"github.com/rhuandantas/verifymy-test/internal/models"
+	"gorm.io/gorm"
+	"strconv"
+	"time"
+)
+
+var _ = Describe("Test export encoders", func() {
+	var (
+		buf   *bytes.Buffer
+		users []*models.User
+		age   int
+	)
+
+	BeforeEach(func() {
+		buf = &bytes.Buffer{}
+		created := time.Date(2023, 3, 1, 10, 0, 0, 0, time.UTC)
+		birth := models.NewDate(1993, 5, 17)
+		age = models.AgeOn(birth, models.Today(time.UTC))
+		users = []*models.User{
+			{UserId: 1, Name: "Jon Snow", DateOfBirth: &birth, Timezone: "UTC", Email: "jon@email.com", Password: "hash", Address: "Winterfell, North", Status: models.UserActive, CreatedAt: created, UpdatedAt: created, Version: 1},
+			{UserId: 2, Name: "Ned", Email: "ned@email.com", Status: models.UserRestricted, CreatedAt: created, UpdatedAt: created, Version: 2, DeletedAt: gorm.DeletedAt{Time: created, Valid: true}},
+		}
+	})
+
+	encode := func(format string, users []*models.User) string {
+		encoder, err := export.NewEncoder(format, buf)
+		Expect(err).To(BeNil())
+		for _, user := range users {
+			Expect(encoder.Encode(user)).To(Succeed())
+		}
+		Expect(encoder.Close()).To(Succeed())
+		return buf.String()
+	}
+
+	It("csv with a header line", func() {
+		Expect(encode(export.FormatCSV, users)).To(Equal(
+			"user_id,name,date_of_birth,age,timezone,email,address,status,created_at,updated_at,version,deleted_at\n" +
+				"1,Jon Snow,1993-05-17," + strconv.Itoa(age) + ",UTC,jon@email.com,\"Winterfell, North\",active,2023-03-01T10:00:00Z,2023-03-01T10:00:00Z,1,\n" +
+				"2,Ned,,,,ned@email.com,,restricted,2023-03-01T10:00:00Z,2023-03-01T10:00:00Z,2,2023-03-01T10:00:00Z\n"))
+	})
+
+	It("csv with the values a spreadsheet would run escaped", func() {
+		formulas := []*models.User{{UserId: 3, Name: "=HYPERLINK(\"https://evil.example\")", Email: "@arya@email.com", Address: "-1+2", Status: "active",
+			CreatedAt: users[0].CreatedAt, UpdatedAt: users[0].UpdatedAt, Version: 1}}
+		Expect(encode(export.FormatCSV, formulas)).To(HaveSuffix(
+			"3,\"'=HYPERLINK(\"\"https://evil.example\"\")\",,,,'@arya@email.com,'-1+2,active,2023-03-01T10:00:00Z,2023-03-01T10:00:00Z,1,\n"))
+	})
+
+	It("csv without users still has the header", func() {
+		Expect(encode(export.FormatCSV, nil)).To(Equal("user_id,name,date_of_birth,age,timezone,email,address,status,created_at,updated_at,version,deleted_at\n"))
+	})
+
+	It("ndjson without passwords", func() {
+		out := encode(export.FormatNDJSON, users)
+		Expect(bytes.Count([]byte(out), []byte("\n"))).To(Equal(2))
+		Expect(out).ToNot(ContainSubstring("password"))
+	})
+
+	It("json array", func() {
+		Expect(encode(export.FormatJSON, users)).To(MatchJSON(`[
+			{"user_id":1,"name":"Jon Snow","date_of_birth":"1993-05-17","age":` + strconv.Itoa(age) + `,"timezone":"UTC","email":"jon@email.com","address":"Winterfell, North","status":"active","created_at":"2023-03-01T10:00:00Z","updated_at":"2023-03-01T10:00:00Z","version":1,"deleted_at":null},
+			{"user_id":2,"name":"Ned","date_of_birth":null,"age":null,"timezone":"","email":"ned@email.com","address":"","status":"restricted","created_at":"2023-03-01T10:00:00Z","updated_at":"2023-03-01T10:00:00Z","version":2,"deleted_at":"2023-03-01T10:00:00Z"}
+		]`))
+	})
+
+	It("empty json array", func() {
+		Expect(encode(export.FormatJSON, nil)).To(MatchJSON(`[]`))
+	})
+
+	It("unknown format", func() {
+		_, err := export.NewEncoder("xml", buf)
+		Expect(errorx.IsOfType(err, errx.BadRequest)).To(BeTrue())
+	})
+})
@@ -0,0 +1,234 @@
+package guardian_test
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"time"
+
+	"github.com/golang/mock/gomock"
+	"github.com/joomcode/errorx"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	errx "github.com/rhuandantas/verifymy-test/internal/errors"
+	"github.com/rhuandantas/verifymy-test/internal/guardian"
+	"github.com/rhuandantas/verifymy-test/internal/models"
+	mock_config "github.com/rhuandantas/verifymy-test/test/mock/config"
+	mock_log "github.com/rhuandantas/verifymy-test/test/mock/log"
+	mock_repo "github.com/rhuandantas/verifymy-test/test/mock/repo"
+	mock_verification "github.com/rhuandantas/verifymy-test/test/mock/verification"
+)
+
+var _ = Describe("Test guardian consents", func() {
+	var (
+		mockCtrl     *gomock.Controller
+		userRepo     *mock_repo.MockUserRepo
+		guardianRepo *mock_repo.MockGuardianRepo
+		service      *mock_verification.MockService
+		consents     guardian.Consents
+	)
+
+	BeforeEach(func() {
+		mockCtrl = gomock.NewController(GinkgoT())
+		userRepo = mock_repo.NewMockUserRepo(mockCtrl)
+		guardianRepo = mock_repo.NewMockGuardianRepo(mockCtrl)
+		service = mock_verification.NewMockService(mockCtrl)
+		logger := mock_log.NewMockSimpleLogger(mockCtrl)
+		logger.EXPECT().Infof(gomock.Any(), gomock.Any()).AnyTimes()
+		config := mock_config.NewMockConfigProvider(mockCtrl)
+		config.EXPECT().GetInt("users.consent.minimum-age").Return(0)
+		config.EXPECT().GetInt("users.consent.guardian-minimum-age").Return(0)
+		config.EXPECT().GetInt("users.consent.invitation-hours").Return(24)
+		consents = guardian.NewConsentService(config, userRepo, guardianRepo, service, logger)
+	})
+
+	bornYearsAgo := func(years int) *models.Date {
+		birth := models.DateOf(time.Now().UTC()).AddDays(-1)
+		birth = models.NewDate(birth.Year()-years, birth.Month(), birth.Day())
+		return &birth
+	}
+
+	hash := func(token string) string {
+		sum := sha256.Sum256([]byte(token))
+		return hex.EncodeToString(sum[:])
+	}
+
+	invited := func(expiresAt time.Time) *models.GuardianLink {
+		return &models.GuardianLink{LinkId: 3, MinorId: 2, GuardianEmail: "ned@email.com", Status: models.GuardianInvited, ExpiresAt: expiresAt, Version: 1}
+	}
+
+	updated := func() {
+		guardianRepo.EXPECT().Update(gomock.Any(), gomock.Any()).DoAndReturn(func(_ interface{}, link models.GuardianLink) (*models.GuardianLink, error) {
+			link.Version++
+			return &link, nil
+		})
+	}
+
+	Context("Restrict a new user", func() {
+		It("under 16 by default", func() {
+			user := &models.User{DateOfBirth: bornYearsAgo(15)}
+			Expect(consents.Restrict(user)).To(BeTrue())
+			Expect(user.Status).To(Equal(models.UserRestricted))
+		})
+
+		It("not when 16 or without a date of birth", func() {
+			user := &models.User{DateOfBirth: bornYearsAgo(16)}
+			Expect(consents.Restrict(user)).To(BeFalse())
+			Expect(user.Status).To(Equal(models.UserActive))
+			Expect(consents.Restrict(&models.User{})).To(BeFalse())
+		})
+	})
+
+	Context("Reassess a registered user", func() {
+		It("restricting a minor without a consenting guardian", func(ctx SpecContext) {
+			guardianRepo.EXPECT().CountConsented(gomock.Any(), 2).Return(int64(0), nil)
+			user := &models.User{UserId: 2, DateOfBirth: bornYearsAgo(15), Status: models.UserActive}
+			Expect(consents.Reassess(ctx, user)).To(Succeed())
+			Expect(user.Status).To(Equal(models.UserRestricted))
+		})
+
+		It("leaving a minor a guardian consents for active", func(ctx SpecContext) {
+			guardianRepo.EXPECT().CountConsented(gomock.Any(), 2).Return(int64(1), nil)
+			user := &models.User{UserId: 2, DateOfBirth: bornYearsAgo(15), Status: models.UserActive}
+			Expect(consents.Reassess(ctx, user)).To(Succeed())
+			Expect(user.Status).To(Equal(models.UserActive))
+		})
+
+		It("lifting the restriction of an adult", func(ctx SpecContext) {
+			user := &models.User{UserId: 2, DateOfBirth: bornYearsAgo(20), Status: models.UserRestricted}
+			Expect(consents.Reassess(ctx, user)).To(Succeed())
+			Expect(user.Status).To(Equal(models.UserActive))
+		})
+	})
+
+	Context("Invite a guardian", func() {
+		It("storing only the hash of the token", func(ctx SpecContext) {
+			userRepo.EXPECT().GetByID(gomock.Any(), 2, "email", "status").Return(&models.User{UserId: 2, Email: "arya@email.com", Status: models.UserRestricted}, nil)
+			guardianRepo.EXPECT().Create(gomock.Any(), gomock.Any()).DoAndReturn(func(_ interface{}, link models.GuardianLink) (*models.GuardianLink, error) {
+				Expect(link.Status).To(Equal(models.GuardianInvited))
+				Expect(link.ExpiresAt).To(BeTemporally("~", time.Now().Add(24*time.Hour), time.Minute))
+				link.LinkId = 3
+				return &link, nil
+			})
+			res, err := consents.Invite(ctx, 2, models.GuardianInvite{GuardianEmail: "ned@email.com"}, "admin@email.com")
+			Expect(err).To(BeNil())
+			Expect(res.Token).ToNot(BeEmpty())
+			Expect(res.TokenHash).To(Equal(hash(res.Token)))
+			Expect(res.InvitedBy).To(Equal("admin@email.com"))
+		})
+
+		It("only for restricted minors", func(ctx SpecContext) {
+			userRepo.EXPECT().GetByID(gomock.Any(), 2, "email", "status").Return(&models.User{UserId: 2, Status: models.UserActive}, nil)
+			_, err := consents.Invite(ctx, 2, models.GuardianInvite{GuardianEmail: "ned@email.com"}, "admin@email.com")
+			Expect(errorx.IsOfType(err, errx.InvalidTransition)).To(BeTrue())
+		})
+
+		It("who isn't the minor", func(ctx SpecContext) {
+			userRepo.EXPECT().GetByID(gomock.Any(), 2, "email", "status").Return(&models.User{UserId: 2, Email: "arya@email.com", Status: models.UserRestricted}, nil)
+			_, err := consents.Invite(ctx, 2, models.GuardianInvite{GuardianEmail: "Arya@email.com"}, "admin@email.com")
+			Expect(errorx.IsOfType(err, errx.Validation)).To(BeTrue())
+			Expect(errx.FieldErrors(errorx.Cast(err))[0].Field).To(Equal("guardian_email"))
+		})
+	})
+
+	Context("Accept an invitation", func() {
+		acceptance := models.GuardianAcceptance{Token: "token"}
+
+		It("lifting the restriction of the minor", func(ctx SpecContext) {
+			guardianRepo.EXPECT().GetByToken(gomock.Any(), hash("token")).Return(invited(time.Now().Add(time.Hour)), nil)
+			userRepo.EXPECT().GetByEmail(gomock.Any(), "Ned@email.com").Return(&models.User{UserId: 1, DateOfBirth: bornYearsAgo(40), Status: models.UserActive}, nil)
+			service.EXPECT().Get(gomock.Any(), 1).Return(&models.VerificationStatus{Verification: &models.Verification{UserId: 1, State: models.VerificationVerified}}, nil)
+			updated()
+			userRepo.EXPECT().Patch(gomock.Any(), 2, map[string]interface{}{"status": models.UserActive}, 0).Return(&models.User{UserId: 2}, nil)
+			link, err := consents.Accept(ctx, acceptance, "Ned@email.com")
+			Expect(err).To(BeNil())
+			Expect(link.Status).To(Equal(models.GuardianConsented))
+			Expect(*link.GuardianId).To(Equal(1))
+			Expect(link.ConsentedAt).ToNot(BeNil())
+		})
+
+		It("not once expired", func(ctx SpecContext) {
+			guardianRepo.EXPECT().GetByToken(gomock.Any(), hash("token")).Return(invited(time.Now().Add(-time.Minute)), nil)
+			_, err := consents.Accept(ctx, acceptance, "ned@email.com")
+			Expect(errorx.IsOfType(err, errx.InvalidTransition)).To(BeTrue())
+		})
+
+		It("only by the invited guardian", func(ctx SpecContext) {
+			guardianRepo.EXPECT().GetByToken(gomock.Any(), hash("token")).Return(invited(time.Now().Add(time.Hour)), nil)
+			_, err := consents.Accept(ctx, acceptance, "partner:partner-a")
+			Expect(errorx.IsOfType(err, errx.Forbidden)).To(BeTrue())
+		})
+
+		It("only by an adult guardian", func(ctx SpecContext) {
+			guardianRepo.EXPECT().GetByToken(gomock.Any(), hash("token")).Return(invited(time.Now().Add(time.Hour)), nil)
+			userRepo.EXPECT().GetByEmail(gomock.Any(), "ned@email.com").Return(&models.User{UserId: 1, DateOfBirth: bornYearsAgo(17)}, nil)
+			_, err := consents.Accept(ctx, acceptance, "ned@email.com")
+			Expect(errorx.IsOfType(err, errx.Forbidden)).To(BeTrue())
+		})
+
+		It("only by an age verified guardian", func(ctx SpecContext) {
+			guardianRepo.EXPECT().GetByToken(gomock.Any(), hash("token")).Return(invited(time.Now().Add(time.Hour)), nil)
+			userRepo.EXPECT().GetByEmail(gomock.Any(), "ned@email.com").Return(&models.User{UserId: 1, DateOfBirth: bornYearsAgo(40)}, nil)
+			service.EXPECT().Get(gomock.Any(), 1).Return(&models.VerificationStatus{Verification: &models.Verification{UserId: 1, State: models.VerificationPending}}, nil)
+			_, err := consents.Accept(ctx, acceptance, "ned@email.com")
+			Expect(errorx.IsOfType(err, errx.NotVerified)).To(BeTrue())
+		})
+	})
+
+	Context("Revoke a consent", func() {
+		consented := func() *models.GuardianLink {
+			guardianId := 1
+			return &models.GuardianLink{LinkId: 3, MinorId: 2, GuardianEmail: "ned@email.com", GuardianId: &guardianId, Status: models.GuardianConsented, Version: 2}
+		}
+
+		It("restricting the minor again when it was the last one", func(ctx SpecContext) {
+			guardianRepo.EXPECT().Get(gomock.Any(), 2, 3).Return(consented(), nil)
+			updated()
+			guardianRepo.EXPECT().CountConsented(gomock.Any(), 2).Return(int64(0), nil)
+			userRepo.EXPECT().Patch(gomock.Any(), 2, map[string]interface{}{"status": models.UserRestricted}, 0).Return(&models.User{UserId: 2}, nil)
+			link, err := consents.Revoke(ctx, 2, 3, "ned@email.com", false)
+			Expect(err).To(BeNil())
+			Expect(link.Status).To(Equal(models.GuardianRevoked))
+			Expect(link.RevokedAt).ToNot(BeNil())
+		})
+
+		It("keeping the minor active while another guardian consents", func(ctx SpecContext) {
+			guardianRepo.EXPECT().Get(gomock.Any(), 2, 3).Return(consented(), nil)
+			updated()
+			guardianRepo.EXPECT().CountConsented(gomock.Any(), 2).Return(int64(1), nil)
+			_, err := consents.Revoke(ctx, 2, 3, "admin@email.com", true)
+			Expect(err).To(BeNil())
+		})
+
+		It("only by the guardian or an admin", func(ctx SpecContext) {
+			guardianRepo.EXPECT().Get(gomock.Any(), 2, 3).Return(consented(), nil)
+			_, err := consents.Revoke(ctx, 2, 3, "arya@email.com", false)
+			Expect(errorx.IsOfType(err, errx.Forbidden)).To(BeTrue())
+		})
+
+		It("not twice", func(ctx SpecContext) {
+			link := consented()
+			link.Status = models.GuardianRevoked
+			guardianRepo.EXPECT().Get(gomock.Any(), 2, 3).Return(link, nil)
+			_, err := consents.Revoke(ctx, 2, 3, "ned@email.com", false)
+			Expect(errorx.IsOfType(err, errx.InvalidTransition)).To(BeTrue())
+		})
+	})
+
+	Context("Withdraw the consents of a guardian", func() {
+		It("restricting the minors no other guardian consents for", func(ctx SpecContext) {
+			guardianId := 5
+			guardianRepo.EXPECT().ListConsentedBy(gomock.Any(), 5).Return([]*models.GuardianLink{
+				{LinkId: 3, MinorId: 2, GuardianId: &guardianId, Status: models.GuardianConsented, Version: 2},
+				{LinkId: 4, MinorId: 6, GuardianId: &guardianId, Status: models.GuardianConsented, Version: 2},
+			}, nil)
+			guardianRepo.EXPECT().Update(gomock.Any(), gomock.Any()).DoAndReturn(func(_ interface{}, link models.GuardianLink) (*models.GuardianLink, error) {
+				Expect(link.Status).To(Equal(models.GuardianRevoked))
+				return &link, nil
+			}).Times(2)
+			guardianRepo.EXPECT().CountConsented(gomock.Any(), 2).Return(int64(0), nil)
+			guardianRepo.EXPECT().CountConsented(gomock.Any(), 6).Return(int64(1), nil)
+			userRepo.EXPECT().Patch(gomock.Any(), 2, map[string]interface{}{"status": models.UserRestricted}, 0).Return(&models.User{UserId: 2}, nil)
+			Expect(consents.Withdraw(ctx, 5, "system")).To(Succeed())
+		})
+	})
+})
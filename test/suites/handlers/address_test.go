@@ -0,0 +1,125 @@
+package handlers_test
+
+import (
+	"github.com/golang/mock/gomock"
+	"github.com/labstack/echo/v4"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	errx "github.com/rhuandantas/verifymy-test/internal/errors"
+	"github.com/rhuandantas/verifymy-test/internal/models"
+	"github.com/rhuandantas/verifymy-test/internal/server/handlers"
+	mock_auth "github.com/rhuandantas/verifymy-test/test/mock/auth"
+	mock_repo "github.com/rhuandantas/verifymy-test/test/mock/repo"
+	mock_util "github.com/rhuandantas/verifymy-test/test/mock/util"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+)
+
+var _ = Describe("Test address handler", func() {
+	var (
+		mockCtrl       *gomock.Controller
+		e              *echo.Echo
+		validator      *mock_util.MockValidator
+		userRepo       *mock_repo.MockUserRepo
+		addressRepo    *mock_repo.MockAddressRepo
+		addressHandler *handlers.AddressHandler
+	)
+
+	BeforeEach(func() {
+		e = echo.New()
+		mockCtrl = gomock.NewController(GinkgoT())
+		validator = mock_util.NewMockValidator(mockCtrl)
+		userRepo = mock_repo.NewMockUserRepo(mockCtrl)
+		addressRepo = mock_repo.NewMockAddressRepo(mockCtrl)
+		addressHandler = handlers.NewAddressHandler(validator, userRepo, addressRepo, mock_auth.NewMockToken(mockCtrl), mock_auth.NewMockSignature(mockCtrl))
+	})
+
+	AfterEach(func() {
+		e.Close()
+	})
+
+	newContext := func(method, body string, params ...string) (echo.Context, *httptest.ResponseRecorder) {
+		req := httptest.NewRequest(method, "/", strings.NewReader(body))
+		req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+		rec := httptest.NewRecorder()
+		c := e.NewContext(req, rec)
+		c.SetParamNames([]string{"id", "address_id"}[:len(params)]...)
+		c.SetParamValues(params...)
+		return c, rec
+	}
+
+	It("lists the addresses of a user", func() {
+		userRepo.EXPECT().GetByID(gomock.Any(), 1, "user_id").Return(&models.User{UserId: 1}, nil)
+		addressRepo.EXPECT().List(gomock.Any(), 1).Return([]*models.Address{{AddressId: 2, UserId: 1}}, nil)
+		c, rec := newContext(http.MethodGet, "", "1")
+		Expect(addressHandler.List(c)).To(Succeed())
+		Expect(rec.Code).To(Equal(http.StatusOK))
+	})
+
+	It("doesn't list the addresses of a user that doesn't exist", func() {
+		userRepo.EXPECT().GetByID(gomock.Any(), 1, "user_id").Return(nil, errx.NotFound.New("User not found with id 1"))
+		c, rec := newContext(http.MethodGet, "", "1")
+		Expect(addressHandler.List(c)).To(Succeed())
+		Expect(rec.Code).To(Equal(http.StatusNotFound))
+	})
+
+	It("creates an address with normalized country and postal code", func() {
+		userRepo.EXPECT().GetByID(gomock.Any(), 1, "user_id").Return(&models.User{UserId: 1}, nil)
+		validator.EXPECT().ValidateStruct(gomock.Any()).Return(nil)
+		addressRepo.EXPECT().Create(gomock.Any(), gomock.Any()).DoAndReturn(func(_ interface{}, address models.Address) (*models.Address, error) {
+			Expect(address.UserId).To(Equal(1))
+			Expect(address.Country).To(Equal("GB"))
+			Expect(address.PostalCode).To(Equal("SW1A 1AA"))
+			return &address, nil
+		})
+		c, rec := newContext(http.MethodPost, `{"type":"home","line1":"10 Downing St","city":"London","postal_code":" sw1a 1aa","country":"gb"}`, "1")
+		Expect(addressHandler.Create(c)).To(Succeed())
+		Expect(rec.Code).To(Equal(http.StatusOK))
+	})
+
+	It("rejects an invalid address", func() {
+		userRepo.EXPECT().GetByID(gomock.Any(), 1, "user_id").Return(&models.User{UserId: 1}, nil)
+		validator.EXPECT().ValidateStruct(gomock.Any()).Return(errx.Validation.New("validation failed"))
+		c, rec := newContext(http.MethodPost, `{"type":"work"}`, "1")
+		Expect(addressHandler.Create(c)).To(Succeed())
+		Expect(rec.Code).To(Equal(http.StatusBadRequest))
+	})
+
+	It("reports a second address of the same type as a conflict", func() {
+		userRepo.EXPECT().GetByID(gomock.Any(), 1, "user_id").Return(&models.User{UserId: 1}, nil)
+		validator.EXPECT().ValidateStruct(gomock.Any()).Return(nil)
+		addressRepo.EXPECT().Create(gomock.Any(), gomock.Any()).Return(nil, errx.Conflict.New("user already has an address of this type"))
+		c, rec := newContext(http.MethodPost, `{"type":"home"}`, "1")
+		Expect(addressHandler.Create(c)).To(Succeed())
+		Expect(rec.Code).To(Equal(http.StatusConflict))
+	})
+
+	It("replaces an address of the user", func() {
+		userRepo.EXPECT().GetByID(gomock.Any(), 1, "user_id").Return(&models.User{UserId: 1}, nil)
+		validator.EXPECT().ValidateStruct(gomock.Any()).Return(nil)
+		addressRepo.EXPECT().Update(gomock.Any(), gomock.Any()).DoAndReturn(func(_ interface{}, address models.Address) (*models.Address, error) {
+			Expect(address.AddressId).To(Equal(2))
+			Expect(address.UserId).To(Equal(1))
+			return &address, nil
+		})
+		c, rec := newContext(http.MethodPut, `{"type":"billing"}`, "1", "2")
+		Expect(addressHandler.Update(c)).To(Succeed())
+		Expect(rec.Code).To(Equal(http.StatusOK))
+	})
+
+	It("rejects an address id that isn't a number", func() {
+		userRepo.EXPECT().GetByID(gomock.Any(), 1, "user_id").Return(&models.User{UserId: 1}, nil)
+		c, rec := newContext(http.MethodGet, "", "1", "home")
+		Expect(addressHandler.Get(c)).To(Succeed())
+		Expect(rec.Code).To(Equal(http.StatusBadRequest))
+	})
+
+	It("deletes an address of the user", func() {
+		userRepo.EXPECT().GetByID(gomock.Any(), 1, "user_id").Return(&models.User{UserId: 1}, nil)
+		addressRepo.EXPECT().Delete(gomock.Any(), 1, 2).Return(nil)
+		c, rec := newContext(http.MethodDelete, "", "1", "2")
+		Expect(addressHandler.Delete(c)).To(Succeed())
+		Expect(rec.Code).To(Equal(http.StatusOK))
+	})
+})
@@ -0,0 +1,142 @@
+package handlers_test
+
+import (
+	"encoding/json"
+	"github.com/golang/mock/gomock"
+	"github.com/labstack/echo/v4"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"github.com/rhuandantas/verifymy-test/internal/i18n"
+	"github.com/rhuandantas/verifymy-test/internal/models"
+	"github.com/rhuandantas/verifymy-test/internal/server/handlers"
+	"github.com/rhuandantas/verifymy-test/internal/server/middlewares/auth"
+	"github.com/rhuandantas/verifymy-test/internal/util"
+	"github.com/rhuandantas/verifymy-test/pkg/attestation"
+	mock_auth "github.com/rhuandantas/verifymy-test/test/mock/auth"
+	mock_clients "github.com/rhuandantas/verifymy-test/test/mock/clients"
+	mock_config "github.com/rhuandantas/verifymy-test/test/mock/config"
+	mock_log "github.com/rhuandantas/verifymy-test/test/mock/log"
+	mock_repo "github.com/rhuandantas/verifymy-test/test/mock/repo"
+	mock_verification "github.com/rhuandantas/verifymy-test/test/mock/verification"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"time"
+)
+
+var _ = Describe("Test attestation handler", func() {
+	var (
+		mockCtrl           *gomock.Controller
+		e                  *echo.Echo
+		userRepo           *mock_repo.MockUserRepo
+		service            *mock_verification.MockService
+		attester           auth.Attester
+		attestationHandler *handlers.AttestationHandler
+	)
+
+	BeforeEach(func() {
+		e = echo.New()
+		mockCtrl = gomock.NewController(GinkgoT())
+		userRepo = mock_repo.NewMockUserRepo(mockCtrl)
+		service = mock_verification.NewMockService(mockCtrl)
+		config := mock_config.NewMockConfigProvider(mockCtrl)
+		config.EXPECT().GetStringOrDefault("i18n.default-locale", gomock.Any()).Return("en")
+		config.EXPECT().GetStringOrDefault("i18n.path", gomock.Any()).Return("../../../resources/i18n")
+		config.EXPECT().GetString("auth.attestation.private-key").Return("")
+		config.EXPECT().GetBool("dev.temporary-keys").Return(true)
+		config.EXPECT().GetStringOrDefault("auth.attestation.issuer", gomock.Any()).Return("verifymy")
+		config.EXPECT().GetInt("auth.attestation.ttl-seconds").Return(60)
+		logger := mock_log.NewMockSimpleLogger(mockCtrl)
+		logger.EXPECT().Warn(gomock.Any())
+		translator, err := i18n.NewCatalogTranslator(config)
+		Expect(err).To(BeNil())
+		attester, err = auth.NewEcdsaAttester(config, logger)
+		Expect(err).To(BeNil())
+		attestationHandler = handlers.NewAttestationHandler(config, util.NewCustomValidator(translator), userRepo, service, attester,
+			mock_clients.NewMockMeter(mockCtrl), mock_auth.NewMockToken(mockCtrl), mock_auth.NewMockSignature(mockCtrl))
+	})
+
+	AfterEach(func() {
+		e.Close()
+	})
+
+	newContext := func(body string) (echo.Context, *httptest.ResponseRecorder) {
+		req := httptest.NewRequest(http.MethodPost, "/users/1/attestations", strings.NewReader(body))
+		req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+		rec := httptest.NewRecorder()
+		c := e.NewContext(req, rec)
+		c.SetParamNames("id")
+		c.SetParamValues("1")
+		return c, rec
+	}
+
+	verified := func(expiresAt time.Time, dateOfBirth *models.Date) {
+		service.EXPECT().Get(gomock.Any(), 1).Return(&models.VerificationStatus{Verification: &models.Verification{
+			UserId: 1, State: models.VerificationVerified, AssuranceLevel: models.AssuranceHigh, ExpiresAt: &expiresAt, DateOfBirth: dateOfBirth,
+		}}, nil)
+	}
+
+	It("attests only the ages asked for, verifiable with the published key set", func() {
+		dateOfBirth := models.DateOf(time.Now().AddDate(-19, 0, -1))
+		verified(time.Now().Add(24*time.Hour), &dateOfBirth)
+		userRepo.EXPECT().GetByID(gomock.Any(), 1, "status").Return(&models.User{UserId: 1, Email: "john@mail.com"}, nil)
+		c, rec := newContext(`{"age_over":[18,21],"audience":"shop.example"}`)
+		Expect(attestationHandler.Issue(c)).To(Succeed())
+		Expect(rec.Code).To(Equal(http.StatusOK))
+
+		var res models.Attestation
+		Expect(json.Unmarshal(rec.Body.Bytes(), &res)).To(Succeed())
+		Expect(res.ExpiresAt).To(BeTemporally("~", time.Now().Add(time.Minute), 2*time.Second))
+		verifier, err := attestation.NewVerifier(attester.KeySet(), "verifymy")
+		Expect(err).To(BeNil())
+		claims, err := verifier.Verify(res.Token, "shop.example")
+		Expect(err).To(BeNil())
+		Expect(claims.AgeOver).To(Equal(map[int]bool{18: true, 21: false}))
+		Expect(claims.AssuranceLevel).To(Equal(models.AssuranceHigh))
+		Expect(claims.Subject).To(BeEmpty())
+		Expect(res.Token).ToNot(ContainSubstring("john"))
+	})
+
+	It("doesn't outlive the verification", func() {
+		expiresAt := time.Now().Add(10 * time.Second).Truncate(time.Second)
+		dateOfBirth := models.NewDate(1990, time.January, 1)
+		verified(expiresAt, &dateOfBirth)
+		userRepo.EXPECT().GetByID(gomock.Any(), 1, "status").Return(&models.User{UserId: 1}, nil)
+		c, rec := newContext(`{"age_over":[18]}`)
+		Expect(attestationHandler.Issue(c)).To(Succeed())
+		var res models.Attestation
+		Expect(json.Unmarshal(rec.Body.Bytes(), &res)).To(Succeed())
+		Expect(res.ExpiresAt).To(BeTemporally("==", expiresAt))
+	})
+
+	It("refuses verifications without a date of birth", func() {
+		verified(time.Now().Add(24*time.Hour), nil)
+		userRepo.EXPECT().GetByID(gomock.Any(), 1, "status").Return(&models.User{UserId: 1}, nil)
+		c, rec := newContext(`{"age_over":[18]}`)
+		Expect(attestationHandler.Issue(c)).To(Succeed())
+		Expect(rec.Code).To(Equal(http.StatusConflict))
+		Expect(rec.Body.String()).To(ContainSubstring("holds no date of birth"))
+	})
+
+	It("refuses users that aren't verified", func() {
+		service.EXPECT().Get(gomock.Any(), 1).Return(&models.VerificationStatus{Verification: &models.Verification{UserId: 1, State: models.VerificationPending}}, nil)
+		c, rec := newContext(`{"age_over":[18]}`)
+		Expect(attestationHandler.Issue(c)).To(Succeed())
+		Expect(rec.Code).To(Equal(http.StatusConflict))
+		Expect(rec.Body.String()).To(ContainSubstring("common.conflict.not_verified"))
+	})
+
+	It("refuses requests without ages", func() {
+		c, rec := newContext(`{"age_over":[]}`)
+		Expect(attestationHandler.Issue(c)).To(Succeed())
+		Expect(rec.Code).To(Equal(http.StatusBadRequest))
+	})
+
+	It("publishes the key set", func() {
+		req := httptest.NewRequest(http.MethodGet, attestation.KeySetPath, nil)
+		rec := httptest.NewRecorder()
+		Expect(attestationHandler.KeySet(e.NewContext(req, rec))).To(Succeed())
+		Expect(rec.Code).To(Equal(http.StatusOK))
+		Expect(rec.Body.String()).To(ContainSubstring(`"kid":"` + attester.KeySet().Keys[0].KeyId + `"`))
+	})
+})
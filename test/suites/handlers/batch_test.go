@@ -0,0 +1,210 @@
+package handlers_test
+
+import (
+	"context"
+	"encoding/json"
+	"github.com/golang/mock/gomock"
+	"github.com/labstack/echo/v4"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	errx "github.com/rhuandantas/verifymy-test/internal/errors"
+	"github.com/rhuandantas/verifymy-test/internal/models"
+	"github.com/rhuandantas/verifymy-test/internal/repo"
+	"github.com/rhuandantas/verifymy-test/internal/server/handlers"
+	mock_auth "github.com/rhuandantas/verifymy-test/test/mock/auth"
+	mock_config "github.com/rhuandantas/verifymy-test/test/mock/config"
+	mock_guardian "github.com/rhuandantas/verifymy-test/test/mock/guardian"
+	mock_log "github.com/rhuandantas/verifymy-test/test/mock/log"
+	mock_repo "github.com/rhuandantas/verifymy-test/test/mock/repo"
+	mock_util "github.com/rhuandantas/verifymy-test/test/mock/util"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+)
+
+var _ = Describe("Test batch handlers", func() {
+	var (
+		mockCtrl    *gomock.Controller
+		e           *echo.Echo
+		validator   *mock_util.MockValidator
+		userRepo    *mock_repo.MockUserRepo
+		consents    *mock_guardian.MockConsents
+		userHandler *handlers.UserHandler
+	)
+
+	BeforeEach(func() {
+		e = echo.New()
+		mockCtrl = gomock.NewController(GinkgoT())
+		validator = mock_util.NewMockValidator(mockCtrl)
+		userRepo = mock_repo.NewMockUserRepo(mockCtrl)
+		config := mock_config.NewMockConfigProvider(mockCtrl)
+		config.EXPECT().GetInt("users.batch.max-size").Return(3)
+		config.EXPECT().GetInt("users.batch.insert-size").Return(2)
+		config.EXPECT().GetInt("users.import.max-rows").Return(0)
+		config.EXPECT().GetInt("users.export.batch-size").Return(0)
+		consents = mock_guardian.NewMockConsents(mockCtrl)
+		// users with a date of birth stand for minors
+		consents.EXPECT().Restrict(gomock.Any()).DoAndReturn(func(user *models.User) bool {
+			return user.DateOfBirth != nil
+		}).AnyTimes()
+		userHandler = handlers.NewUserHandler(config, validator, mock_util.NewMockPasswordPolicy(mockCtrl), userRepo, mock_repo.NewMockAddressRepo(mockCtrl), mock_repo.NewMockVerificationRepo(mockCtrl), consents, mock_auth.NewMockToken(mockCtrl),
+			mock_auth.NewMockSignature(mockCtrl), mock_log.NewMockSimpleLogger(mockCtrl))
+	})
+
+	AfterEach(func() {
+		e.Close()
+	})
+
+	newContext := func(method, body string) (echo.Context, *httptest.ResponseRecorder) {
+		req := httptest.NewRequest(method, "/users", strings.NewReader(body))
+		req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+		rec := httptest.NewRecorder()
+		return e.NewContext(req, rec), rec
+	}
+
+	readResponse := func(rec *httptest.ResponseRecorder) handlers.BatchResponse {
+		var res handlers.BatchResponse
+		Expect(json.Unmarshal(rec.Body.Bytes(), &res)).To(Succeed())
+		return res
+	}
+
+	inTransaction := func() {
+		userRepo.EXPECT().Transaction(gomock.Any(), gomock.Any()).DoAndReturn(func(_ context.Context, fn func(repo.UserRepo) error) error {
+			return fn(userRepo)
+		})
+	}
+
+	created := func(_ context.Context, users []models.User, _ int) ([]*models.User, error) {
+		res := make([]*models.User, 0, len(users))
+		for i := range users {
+			users[i].UserId = i + 1
+			res = append(res, &users[i])
+		}
+		return res, nil
+	}
+
+	Context("Call batch create handler", func() {
+		It("successfully in transactional mode", func(ctx SpecContext) {
+			validator.EXPECT().ValidateStruct(gomock.Any()).Return(nil).Times(3)
+			userRepo.EXPECT().CreateBatch(gomock.Any(), gomock.Len(3), 2).DoAndReturn(created)
+			c, rec := newContext(http.MethodPost, `{"items":[{"name":"a","email":"a@email.com","password":"1"},{"name":"b","email":"b@email.com"},{"name":"c","email":"c@email.com"}]}`)
+			Expect(userHandler.BatchCreate(c)).To(Succeed())
+			Expect(rec.Code).To(Equal(200))
+			res := readResponse(rec)
+			Expect(res.Mode).To(Equal(handlers.BatchTransactional))
+			Expect(res.Succeeded).To(Equal(3))
+			Expect(res.Results[0].User.Password).To(BeEmpty())
+		})
+
+		It("nothing is created when an item is invalid in transactional mode", func(ctx SpecContext) {
+			validator.EXPECT().ValidateStruct(gomock.Any()).Return(nil)
+			validator.EXPECT().ValidateStruct(gomock.Any()).Return(errx.Validation.New("request validation failed"))
+			c, rec := newContext(http.MethodPost, `{"mode":"transactional","items":[{"name":"a","email":"a@email.com"},{"name":"b"}]}`)
+			Expect(userHandler.BatchCreate(c)).To(Succeed())
+			Expect(rec.Code).To(Equal(400))
+			res := readResponse(rec)
+			Expect(res.Failed).To(Equal(2))
+			Expect(res.Results[0].Status).To(Equal(424))
+			Expect(res.Results[1].Status).To(Equal(400))
+			Expect(res.Results[1].Error).ToNot(BeNil())
+		})
+
+		It("falls back to one by one inserts in best effort mode", func(ctx SpecContext) {
+			validator.EXPECT().ValidateStruct(gomock.Any()).Return(nil).Times(3)
+			userRepo.EXPECT().CreateBatch(gomock.Any(), gomock.Len(2), 2).Return(nil, errx.Conflict.New("email is already registered"))
+			userRepo.EXPECT().Create(gomock.Any(), gomock.Any()).Return(&models.User{UserId: 1}, nil)
+			userRepo.EXPECT().Create(gomock.Any(), gomock.Any()).Return(nil, errx.Conflict.New("email is already registered"))
+			userRepo.EXPECT().CreateBatch(gomock.Any(), gomock.Len(1), 1).DoAndReturn(created)
+			c, rec := newContext(http.MethodPost, `{"mode":"best_effort","items":[{"name":"a","email":"a@email.com"},{"name":"b","email":"b@email.com"},{"name":"c","email":"c@email.com"}]}`)
+			Expect(userHandler.BatchCreate(c)).To(Succeed())
+			Expect(rec.Code).To(Equal(207))
+			res := readResponse(rec)
+			Expect(res.Succeeded).To(Equal(2))
+			Expect(res.Failed).To(Equal(1))
+			Expect(res.Results[1].Status).To(Equal(409))
+		})
+
+		It("fails minors without a guardian and invites the guardian of the others", func(ctx SpecContext) {
+			validator.EXPECT().ValidateStruct(gomock.Any()).Return(nil).Times(3)
+			userRepo.EXPECT().CreateBatch(gomock.Any(), gomock.Len(2), 2).DoAndReturn(created)
+			consents.EXPECT().Invite(gomock.Any(), 1, models.GuardianInvite{GuardianEmail: "ned@email.com"}, gomock.Any()).
+				Return(&models.GuardianInvitation{GuardianLink: &models.GuardianLink{MinorId: 1}, Token: "token"}, nil)
+			c, rec := newContext(http.MethodPost, `{"mode":"best_effort","items":[{"name":"a","email":"a@email.com","date_of_birth":"2015-01-01"},`+
+				`{"name":"b","email":"b@email.com","date_of_birth":"2015-01-01","guardian_email":"ned@email.com"},{"name":"c","email":"c@email.com"}]}`)
+			Expect(userHandler.BatchCreate(c)).To(Succeed())
+			Expect(rec.Code).To(Equal(207))
+			res := readResponse(rec)
+			Expect(res.Succeeded).To(Equal(2))
+			Expect(res.Results[0].Status).To(Equal(400))
+			Expect(res.Results[0].Error.Detail).To(Equal("minors need a guardian to consent for them"))
+			Expect(res.Results[1].User.GuardianInvitation.Token).To(Equal("token"))
+			Expect(res.Results[2].User.GuardianInvitation).To(BeNil())
+		})
+
+		It("too many items", func(ctx SpecContext) {
+			c, rec := newContext(http.MethodPost, `{"items":[{},{},{},{}]}`)
+			Expect(userHandler.BatchCreate(c)).To(Succeed())
+			Expect(rec.Code).To(Equal(400))
+		})
+
+		It("unknown mode", func(ctx SpecContext) {
+			c, rec := newContext(http.MethodPost, `{"mode":"eventually","items":[{}]}`)
+			Expect(userHandler.BatchCreate(c)).To(Succeed())
+			Expect(rec.Code).To(Equal(400))
+		})
+	})
+
+	Context("Call batch update handler", func() {
+		It("successfully in transactional mode", func(ctx SpecContext) {
+			inTransaction()
+			userRepo.EXPECT().GetByID(gomock.Any(), 1).Return(&models.User{UserId: 1, Name: "a", Version: 1}, nil)
+			validator.EXPECT().ValidateStruct(gomock.Any()).Return(nil)
+			userRepo.EXPECT().Patch(gomock.Any(), 1, map[string]interface{}{"name": "z"}, 1).Return(&models.User{UserId: 1, Name: "z", Version: 2}, nil)
+			c, rec := newContext(http.MethodPatch, `{"items":[{"user_id":1,"patch":{"name":"z"}}]}`)
+			Expect(userHandler.BatchUpdate(c)).To(Succeed())
+			Expect(rec.Code).To(Equal(200))
+			res := readResponse(rec)
+			Expect(res.Results[0].User.Version).To(Equal(2))
+		})
+
+		It("rolls back on the first failure in transactional mode", func(ctx SpecContext) {
+			inTransaction()
+			userRepo.EXPECT().GetByID(gomock.Any(), 1).Return(&models.User{UserId: 1, Name: "a", Version: 1}, nil)
+			validator.EXPECT().ValidateStruct(gomock.Any()).Return(nil)
+			userRepo.EXPECT().Patch(gomock.Any(), 1, gomock.Any(), 1).Return(&models.User{UserId: 1, Name: "z", Version: 2}, nil)
+			userRepo.EXPECT().GetByID(gomock.Any(), 2).Return(&models.User{UserId: 2, Name: "b", Version: 3}, nil)
+			c, rec := newContext(http.MethodPatch, `{"items":[{"user_id":1,"patch":{"name":"z"}},{"user_id":2,"version":2,"patch":{"name":"y"}},{"user_id":3,"patch":{"name":"x"}}]}`)
+			Expect(userHandler.BatchUpdate(c)).To(Succeed())
+			Expect(rec.Code).To(Equal(412))
+			res := readResponse(rec)
+			Expect(res.Succeeded).To(Equal(0))
+			Expect(res.Results[0].Status).To(Equal(424))
+			Expect(res.Results[0].User).To(BeNil())
+			Expect(res.Results[1].Status).To(Equal(412))
+			Expect(res.Results[2].Status).To(Equal(424))
+		})
+	})
+
+	Context("Call batch delete handler", func() {
+		It("successfully in transactional mode", func(ctx SpecContext) {
+			inTransaction()
+			userRepo.EXPECT().Delete(gomock.Any(), 1).Return(true, nil)
+			userRepo.EXPECT().Delete(gomock.Any(), 2).Return(true, nil)
+			c, rec := newContext(http.MethodPost, `{"user_ids":[1,2]}`)
+			Expect(userHandler.BatchDelete(c)).To(Succeed())
+			Expect(rec.Code).To(Equal(200))
+			Expect(readResponse(rec).Results[1].UserId).To(Equal(2))
+		})
+
+		It("keeps going in best effort mode", func(ctx SpecContext) {
+			userRepo.EXPECT().Delete(gomock.Any(), 1).Return(false, errx.NotFound.New("User not found with id 1"))
+			userRepo.EXPECT().Delete(gomock.Any(), 2).Return(true, nil)
+			c, rec := newContext(http.MethodPost, `{"mode":"best_effort","user_ids":[1,2]}`)
+			Expect(userHandler.BatchDelete(c)).To(Succeed())
+			Expect(rec.Code).To(Equal(207))
+			res := readResponse(rec)
+			Expect(res.Results[0].Status).To(Equal(404))
+			Expect(res.Results[1].Status).To(Equal(200))
+		})
+	})
+})
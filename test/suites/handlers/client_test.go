@@ -0,0 +1,142 @@
+package handlers_test
+
+import (
+	"github.com/golang/mock/gomock"
+	"github.com/labstack/echo/v4"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	errx "github.com/rhuandantas/verifymy-test/internal/errors"
+	"github.com/rhuandantas/verifymy-test/internal/i18n"
+	"github.com/rhuandantas/verifymy-test/internal/models"
+	"github.com/rhuandantas/verifymy-test/internal/server/handlers"
+	"github.com/rhuandantas/verifymy-test/internal/server/middlewares/auth"
+	"github.com/rhuandantas/verifymy-test/internal/util"
+	mock_auth "github.com/rhuandantas/verifymy-test/test/mock/auth"
+	mock_clients "github.com/rhuandantas/verifymy-test/test/mock/clients"
+	mock_config "github.com/rhuandantas/verifymy-test/test/mock/config"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+)
+
+var _ = Describe("Test client handler", func() {
+	var (
+		mockCtrl      *gomock.Controller
+		e             *echo.Echo
+		registry      *mock_clients.MockRegistry
+		meter         *mock_clients.MockMeter
+		clientHandler *handlers.ClientHandler
+	)
+
+	BeforeEach(func() {
+		e = echo.New()
+		mockCtrl = gomock.NewController(GinkgoT())
+		registry = mock_clients.NewMockRegistry(mockCtrl)
+		meter = mock_clients.NewMockMeter(mockCtrl)
+		config := mock_config.NewMockConfigProvider(mockCtrl)
+		config.EXPECT().GetStringOrDefault("i18n.default-locale", gomock.Any()).Return("en")
+		config.EXPECT().GetStringOrDefault("i18n.path", gomock.Any()).Return("../../../resources/i18n")
+		translator, err := i18n.NewCatalogTranslator(config)
+		Expect(err).To(BeNil())
+		clientHandler = handlers.NewClientHandler(util.NewCustomValidator(translator), registry, meter,
+			mock_auth.NewMockToken(mockCtrl), mock_auth.NewMockSignature(mockCtrl))
+	})
+
+	AfterEach(func() {
+		e.Close()
+	})
+
+	newContext := func(method, target, body string) (echo.Context, *httptest.ResponseRecorder) {
+		req := httptest.NewRequest(method, target, strings.NewReader(body))
+		req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+		rec := httptest.NewRecorder()
+		c := e.NewContext(req, rec)
+		c.SetParamNames("client_id")
+		c.SetParamValues("shop")
+		return c, rec
+	}
+
+	Context("Register", func() {
+		It("returns the secret of the client", func() {
+			registry.EXPECT().Register(gomock.Any(), models.ClientRegistration{
+				ClientId:       "shop",
+				Name:           "Shop",
+				AllowedOrigins: []string{"https://shop.example"},
+			}, "partner:admin").Return(&models.ClientCredentials{
+				Client: &models.Client{ClientId: "shop", Name: "Shop", Plan: "free", SecretCiphertext: []byte("sealed")},
+				Secret: "s3cr3t",
+			}, nil)
+			c, rec := newContext(http.MethodPost, "/clients", `{"client_id":"shop","name":"Shop","allowed_origins":["https://shop.example"]}`)
+			c.Set(auth.PartnerContextKey, "admin")
+			Expect(clientHandler.Register(c)).To(Succeed())
+			Expect(rec.Code).To(Equal(http.StatusOK))
+			Expect(rec.Body.String()).To(ContainSubstring(`"secret":"s3cr3t"`))
+			Expect(rec.Body.String()).ToNot(ContainSubstring("sealed"))
+		})
+
+		It("with an invalid id and origin", func() {
+			c, rec := newContext(http.MethodPost, "/clients", `{"client_id":"Shop!","name":"Shop","allowed_origins":["http://shop.example"]}`)
+			Expect(clientHandler.Register(c)).To(Succeed())
+			Expect(rec.Code).To(Equal(http.StatusBadRequest))
+			Expect(rec.Body.String()).To(ContainSubstring(`"field":"client_id"`))
+			Expect(rec.Body.String()).To(ContainSubstring(`"rule":"origin"`))
+		})
+
+		It("with an id already taken", func() {
+			registry.EXPECT().Register(gomock.Any(), gomock.Any(), gomock.Any()).Return(nil, errx.Conflict.New("client shop already exists"))
+			c, rec := newContext(http.MethodPost, "/clients", `{"client_id":"shop","name":"Shop"}`)
+			Expect(clientHandler.Register(c)).To(Succeed())
+			Expect(rec.Code).To(Equal(http.StatusConflict))
+		})
+	})
+
+	It("Update needs every field", func() {
+		c, rec := newContext(http.MethodPut, "/clients/shop", `{"name":"Shop","plan":"free","status":"paused"}`)
+		Expect(clientHandler.Update(c)).To(Succeed())
+		Expect(rec.Code).To(Equal(http.StatusBadRequest))
+		Expect(rec.Body.String()).To(ContainSubstring(`"field":"status"`))
+	})
+
+	Context("Usage", func() {
+		It("of the calling client", func() {
+			meter.EXPECT().Report(gomock.Any(), "shop", models.NewDate(2026, 9, 1), models.NewDate(2026, 9, 30)).
+				Return(&models.UsageReport{ClientId: "shop", Plan: &models.Plan{Name: "free"}}, nil)
+			c, rec := newContext(http.MethodGet, "/clients/shop/usage?from=2026-09-01&to=2026-09-30", "")
+			c.Set(auth.ClientContextKey, &models.Client{ClientId: "shop"})
+			Expect(clientHandler.Usage(c)).To(Succeed())
+			Expect(rec.Code).To(Equal(http.StatusOK))
+			Expect(rec.Body.String()).To(ContainSubstring(`"client_id":"shop"`))
+		})
+
+		It("of another client", func() {
+			c, rec := newContext(http.MethodGet, "/clients/shop/usage", "")
+			c.Set(auth.ClientContextKey, &models.Client{ClientId: "other"})
+			Expect(clientHandler.Usage(c)).To(Succeed())
+			Expect(rec.Code).To(Equal(http.StatusForbidden))
+		})
+
+		It("of another client managing clients", func() {
+			meter.EXPECT().Report(gomock.Any(), "shop", gomock.Any(), gomock.Any()).Return(&models.UsageReport{ClientId: "shop"}, nil)
+			c, rec := newContext(http.MethodGet, "/clients/shop/usage", "")
+			c.Set(auth.PermissionsContextKey, []string{auth.PermissionManageClients})
+			Expect(clientHandler.Usage(c)).To(Succeed())
+			Expect(rec.Code).To(Equal(http.StatusOK))
+		})
+
+		It("with an invalid date", func() {
+			c, rec := newContext(http.MethodGet, "/clients/shop/usage?from=09/01/2026", "")
+			c.Set(auth.ClientContextKey, &models.Client{ClientId: "shop"})
+			Expect(clientHandler.Usage(c)).To(Succeed())
+			Expect(rec.Code).To(Equal(http.StatusBadRequest))
+			Expect(rec.Body.String()).To(ContainSubstring(`"field":"from"`))
+		})
+
+		It("over more than a year", func() {
+			c, rec := newContext(http.MethodGet, "/clients/shop/usage?from=2025-01-01&to=2026-01-02", "")
+			c.Set(auth.ClientContextKey, &models.Client{ClientId: "shop"})
+			Expect(clientHandler.Usage(c)).To(Succeed())
+			Expect(rec.Code).To(Equal(http.StatusBadRequest))
+			Expect(rec.Body.String()).To(ContainSubstring(`"field":"to"`))
+		})
+	})
+})
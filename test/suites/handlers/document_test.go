@@ -0,0 +1,114 @@
+package handlers_test
+
+import (
+	"bytes"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+
+	"github.com/golang/mock/gomock"
+	"github.com/labstack/echo/v4"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	errx "github.com/rhuandantas/verifymy-test/internal/errors"
+	"github.com/rhuandantas/verifymy-test/internal/models"
+	"github.com/rhuandantas/verifymy-test/internal/server/handlers"
+	"github.com/rhuandantas/verifymy-test/internal/server/middlewares/auth"
+	mock_auth "github.com/rhuandantas/verifymy-test/test/mock/auth"
+	mock_verification "github.com/rhuandantas/verifymy-test/test/mock/verification"
+)
+
+var _ = Describe("Test document handler", func() {
+	var (
+		mockCtrl        *gomock.Controller
+		e               *echo.Echo
+		documents       *mock_verification.MockDocuments
+		documentHandler *handlers.DocumentHandler
+	)
+
+	png := append([]byte("\x89PNG\r\n\x1a\n"), make([]byte, 24)...)
+
+	BeforeEach(func() {
+		e = echo.New()
+		mockCtrl = gomock.NewController(GinkgoT())
+		documents = mock_verification.NewMockDocuments(mockCtrl)
+		documents.EXPECT().MaxBytes().Return(int64(64)).AnyTimes()
+		documentHandler = handlers.NewDocumentHandler(documents, mock_auth.NewMockToken(mockCtrl), mock_auth.NewMockSignature(mockCtrl))
+	})
+
+	AfterEach(func() {
+		e.Close()
+	})
+
+	newContext := func(req *http.Request) (echo.Context, *httptest.ResponseRecorder) {
+		rec := httptest.NewRecorder()
+		c := e.NewContext(req, rec)
+		c.SetParamNames("id", "document_id")
+		c.SetParamValues("1", "5")
+		c.Set(auth.PartnerContextKey, "partner-a")
+		return c, rec
+	}
+
+	upload := func(field string, content []byte) *http.Request {
+		body := &bytes.Buffer{}
+		form := multipart.NewWriter(body)
+		part, _ := form.CreateFormFile(field, "scan.png")
+		_, _ = part.Write(content)
+		_ = form.Close()
+		req := httptest.NewRequest(http.MethodPost, "/users/1/verification/documents", body)
+		req.Header.Set(echo.HeaderContentType, form.FormDataContentType())
+		return req
+	}
+
+	It("uploads the file of the form on behalf of the caller", func() {
+		documents.EXPECT().Upload(gomock.Any(), 1, png, "partner:partner-a").
+			Return(&models.VerificationDocument{DocumentId: 5, UserId: 1, ContentType: "image/png", BlobKey: "documents/1/a", WrappedKey: []byte("wrapped")}, nil)
+		c, rec := newContext(upload("file", png))
+		Expect(documentHandler.Upload(c)).To(Succeed())
+		Expect(rec.Code).To(Equal(http.StatusOK))
+		Expect(rec.Body.String()).To(ContainSubstring(`"document_id":5`))
+		Expect(rec.Body.String()).ToNot(ContainSubstring("documents/1/a"))
+		Expect(rec.Body.String()).ToNot(ContainSubstring("wrapped"))
+	})
+
+	It("needs a file", func() {
+		c, rec := newContext(upload("other", png))
+		Expect(documentHandler.Upload(c)).To(Succeed())
+		Expect(rec.Code).To(Equal(http.StatusBadRequest))
+		Expect(rec.Body.String()).To(ContainSubstring(`"field":"file"`))
+	})
+
+	It("stops reading a file past the largest document accepted", func() {
+		documents.EXPECT().Upload(gomock.Any(), 1, gomock.Len(65), gomock.Any()).Return(nil, errx.Validation.New("document is larger than 64 bytes"))
+		c, rec := newContext(upload("file", make([]byte, 1000)))
+		Expect(documentHandler.Upload(c)).To(Succeed())
+		Expect(rec.Code).To(Equal(http.StatusBadRequest))
+	})
+
+	It("refuses a body far larger than the largest document accepted", func() {
+		c, rec := newContext(upload("file", make([]byte, 128<<10)))
+		Expect(documentHandler.Upload(c)).To(Succeed())
+		Expect(rec.Code).To(Equal(http.StatusBadRequest))
+		Expect(rec.Body.String()).To(ContainSubstring(`"rule":"max"`))
+	})
+
+	It("downloads a document as an attachment never cached", func() {
+		documents.EXPECT().Open(gomock.Any(), 1, 5).Return(&models.VerificationDocument{DocumentId: 5, ContentType: "image/png"}, png, nil)
+		c, rec := newContext(httptest.NewRequest(http.MethodGet, "/users/1/verification/documents/5", nil))
+		Expect(documentHandler.Download(c)).To(Succeed())
+		Expect(rec.Code).To(Equal(http.StatusOK))
+		Expect(rec.Header().Get(echo.HeaderContentType)).To(Equal("image/png"))
+		Expect(rec.Header().Get(echo.HeaderContentDisposition)).To(HavePrefix("attachment"))
+		Expect(rec.Header().Get(echo.HeaderCacheControl)).To(Equal("no-store"))
+		content, _ := io.ReadAll(rec.Body)
+		Expect(content).To(Equal(png))
+	})
+
+	It("deletes a document of another user as not found", func() {
+		documents.EXPECT().Delete(gomock.Any(), 1, 5, "partner:partner-a").Return(errx.NotFound.New("Document 5 not found for user 1"))
+		c, rec := newContext(httptest.NewRequest(http.MethodDelete, "/users/1/verification/documents/5", nil))
+		Expect(documentHandler.Delete(c)).To(Succeed())
+		Expect(rec.Code).To(Equal(http.StatusNotFound))
+	})
+})
@@ -0,0 +1,85 @@
+package handlers_test
+
+import (
+	"github.com/golang/mock/gomock"
+	"github.com/labstack/echo/v4"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"github.com/rhuandantas/verifymy-test/internal/i18n"
+	"github.com/rhuandantas/verifymy-test/internal/models"
+	"github.com/rhuandantas/verifymy-test/internal/rules"
+	"github.com/rhuandantas/verifymy-test/internal/server/handlers"
+	"github.com/rhuandantas/verifymy-test/internal/util"
+	mock_auth "github.com/rhuandantas/verifymy-test/test/mock/auth"
+	mock_config "github.com/rhuandantas/verifymy-test/test/mock/config"
+	mock_repo "github.com/rhuandantas/verifymy-test/test/mock/repo"
+	mock_rules "github.com/rhuandantas/verifymy-test/test/mock/rules"
+	mock_verification "github.com/rhuandantas/verifymy-test/test/mock/verification"
+	"net/http"
+	"net/http/httptest"
+	"time"
+)
+
+var _ = Describe("Test eligibility handler", func() {
+	var (
+		mockCtrl           *gomock.Controller
+		e                  *echo.Echo
+		userRepo           *mock_repo.MockUserRepo
+		service            *mock_verification.MockService
+		engine             *mock_rules.MockEngine
+		eligibilityHandler *handlers.EligibilityHandler
+	)
+
+	BeforeEach(func() {
+		e = echo.New()
+		mockCtrl = gomock.NewController(GinkgoT())
+		userRepo = mock_repo.NewMockUserRepo(mockCtrl)
+		service = mock_verification.NewMockService(mockCtrl)
+		engine = mock_rules.NewMockEngine(mockCtrl)
+		config := mock_config.NewMockConfigProvider(mockCtrl)
+		config.EXPECT().GetStringOrDefault("i18n.default-locale", gomock.Any()).Return("en")
+		config.EXPECT().GetStringOrDefault("i18n.path", gomock.Any()).Return("../../../resources/i18n")
+		translator, err := i18n.NewCatalogTranslator(config)
+		Expect(err).To(BeNil())
+		eligibilityHandler = handlers.NewEligibilityHandler(util.NewCustomValidator(translator), userRepo, service, engine,
+			mock_auth.NewMockToken(mockCtrl), mock_auth.NewMockSignature(mockCtrl))
+	})
+
+	AfterEach(func() {
+		e.Close()
+	})
+
+	newContext := func(query string) (echo.Context, *httptest.ResponseRecorder) {
+		req := httptest.NewRequest(http.MethodGet, "/users/1/eligibility?"+query, nil)
+		rec := httptest.NewRecorder()
+		c := e.NewContext(req, rec)
+		c.SetParamNames("id")
+		c.SetParamValues("1")
+		return c, rec
+	}
+
+	It("evaluates the rules with the verified age and verification of the user", func() {
+		dateOfBirth := models.DateOf(time.Now().AddDate(-20, 0, -1))
+		service.EXPECT().Get(gomock.Any(), 1).Return(&models.VerificationStatus{Verification: &models.Verification{
+			UserId: 1, State: models.VerificationVerified, Method: models.MethodDocument, DateOfBirth: &dateOfBirth,
+		}}, nil)
+		userRepo.EXPECT().GetByID(gomock.Any(), 1, "status").Return(&models.User{UserId: 1}, nil)
+		engine.EXPECT().Evaluate(gomock.Any(), rules.Context{Jurisdiction: "US", Category: "alcohol"}).
+			DoAndReturn(func(subject rules.Subject, context rules.Context) rules.Decision {
+				Expect(*subject.Age).To(Equal(20))
+				Expect(subject.Method).To(Equal(models.MethodDocument))
+				return rules.Decision{Reason: rules.ReasonUnderAge, Message: "20 is under the minimum age of 21", Context: context}
+			})
+		c, rec := newContext("jurisdiction=US&category=alcohol")
+		Expect(eligibilityHandler.Evaluate(c)).To(Succeed())
+		Expect(rec.Code).To(Equal(http.StatusOK))
+		Expect(rec.Body.String()).To(ContainSubstring(`"allowed":false`))
+		Expect(rec.Body.String()).To(ContainSubstring(`"reason":"under_age"`))
+	})
+
+	It("needs a jurisdiction and a category", func() {
+		c, rec := newContext("jurisdiction=US")
+		Expect(eligibilityHandler.Evaluate(c)).To(Succeed())
+		Expect(rec.Code).To(Equal(http.StatusBadRequest))
+	})
+})
@@ -0,0 +1,131 @@
+package handlers_test
+
+import (
+	"context"
+	"github.com/golang/mock/gomock"
+	"github.com/labstack/echo/v4"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	errx "github.com/rhuandantas/verifymy-test/internal/errors"
+	"github.com/rhuandantas/verifymy-test/internal/models"
+	"github.com/rhuandantas/verifymy-test/internal/repo"
+	"github.com/rhuandantas/verifymy-test/internal/server/handlers"
+	mock_auth "github.com/rhuandantas/verifymy-test/test/mock/auth"
+	mock_config "github.com/rhuandantas/verifymy-test/test/mock/config"
+	mock_guardian "github.com/rhuandantas/verifymy-test/test/mock/guardian"
+	mock_log "github.com/rhuandantas/verifymy-test/test/mock/log"
+	mock_repo "github.com/rhuandantas/verifymy-test/test/mock/repo"
+	mock_util "github.com/rhuandantas/verifymy-test/test/mock/util"
+	"net/http"
+	"net/http/httptest"
+)
+
+var _ = Describe("Test export handler", func() {
+	var (
+		mockCtrl    *gomock.Controller
+		e           *echo.Echo
+		userRepo    *mock_repo.MockUserRepo
+		logger      *mock_log.MockSimpleLogger
+		userHandler *handlers.UserHandler
+	)
+
+	BeforeEach(func() {
+		e = echo.New()
+		mockCtrl = gomock.NewController(GinkgoT())
+		userRepo = mock_repo.NewMockUserRepo(mockCtrl)
+		logger = mock_log.NewMockSimpleLogger(mockCtrl)
+		config := mock_config.NewMockConfigProvider(mockCtrl)
+		config.EXPECT().GetInt("users.export.batch-size").Return(2)
+		config.EXPECT().GetInt(gomock.Any()).Return(0).AnyTimes()
+		userHandler = handlers.NewUserHandler(config, mock_util.NewMockValidator(mockCtrl), mock_util.NewMockPasswordPolicy(mockCtrl),
+			userRepo, mock_repo.NewMockAddressRepo(mockCtrl), mock_repo.NewMockVerificationRepo(mockCtrl), mock_guardian.NewMockConsents(mockCtrl), mock_auth.NewMockToken(mockCtrl), mock_auth.NewMockSignature(mockCtrl), logger)
+	})
+
+	AfterEach(func() {
+		e.Close()
+	})
+
+	newContext := func(target string) (echo.Context, *httptest.ResponseRecorder) {
+		rec := httptest.NewRecorder()
+		return e.NewContext(httptest.NewRequest(http.MethodGet, target, nil), rec), rec
+	}
+
+	exportUsers := func(batches ...[]*models.User) func(context.Context, *repo.Query, bool, int, func([]*models.User) error) error {
+		return func(_ context.Context, _ *repo.Query, _ bool, _ int, fn func([]*models.User) error) error {
+			for _, batch := range batches {
+				if err := fn(batch); err != nil {
+					return err
+				}
+			}
+			return nil
+		}
+	}
+
+	It("streams csv", func(ctx SpecContext) {
+		userRepo.EXPECT().Export(gomock.Any(), gomock.Any(), false, 2, gomock.Any()).
+			DoAndReturn(exportUsers([]*models.User{{UserId: 1, Email: "jon@email.com"}, {UserId: 2, Email: "ned@email.com"}}, []*models.User{{UserId: 3, Email: "arya@email.com"}}))
+		c, rec := newContext("/users/export?format=csv")
+		Expect(userHandler.Export(c)).To(Succeed())
+		Expect(rec.Code).To(Equal(200))
+		Expect(rec.Header().Get(echo.HeaderContentType)).To(Equal("text/csv"))
+		Expect(rec.Header().Get(echo.HeaderContentDisposition)).To(Equal("attachment; filename=users.csv"))
+		Expect(rec.Body.String()).To(ContainSubstring("3,,,,,arya@email.com"))
+	})
+
+	It("streams a json array by default", func(ctx SpecContext) {
+		userRepo.EXPECT().Export(gomock.Any(), gomock.Any(), false, 2, gomock.Any()).
+			DoAndReturn(exportUsers([]*models.User{{UserId: 1, Password: "hash"}}))
+		c, rec := newContext("/users/export")
+		Expect(userHandler.Export(c)).To(Succeed())
+		Expect(rec.Header().Get(echo.HeaderContentType)).To(Equal(echo.MIMEApplicationJSON))
+		Expect(rec.Body.String()).ToNot(ContainSubstring("hash"))
+	})
+
+	It("with filters", func(ctx SpecContext) {
+		userRepo.EXPECT().Export(gomock.Any(), gomock.Any(), false, 2, gomock.Any()).
+			DoAndReturn(func(_ context.Context, query *repo.Query, _ bool, _ int, _ func([]*models.User) error) error {
+				Expect(query).ToNot(BeNil())
+				return nil
+			})
+		c, rec := newContext("/users/export?format=ndjson&age_gte=18")
+		Expect(userHandler.Export(c)).To(Succeed())
+		Expect(rec.Code).To(Equal(200))
+	})
+
+	It("can't be sorted", func(ctx SpecContext) {
+		c, rec := newContext("/users/export?sort=name")
+		Expect(userHandler.Export(c)).To(Succeed())
+		Expect(rec.Code).To(Equal(400))
+	})
+
+	It("unknown format", func(ctx SpecContext) {
+		c, rec := newContext("/users/export?format=xml")
+		Expect(userHandler.Export(c)).To(Succeed())
+		Expect(rec.Code).To(Equal(400))
+	})
+
+	It("deleted users without being an admin", func(ctx SpecContext) {
+		c, rec := newContext("/users/export?include_deleted=true")
+		Expect(userHandler.Export(c)).To(Succeed())
+		Expect(rec.Code).To(Equal(403))
+	})
+
+	It("fails before anything was sent", func(ctx SpecContext) {
+		userRepo.EXPECT().Export(gomock.Any(), gomock.Any(), false, 2, gomock.Any()).Return(errx.ConstraintViolation.New("mock error"))
+		c, rec := newContext("/users/export?format=ndjson")
+		Expect(userHandler.Export(c)).To(Succeed())
+		Expect(rec.Code).To(Equal(422))
+		Expect(rec.Header().Get(echo.HeaderContentDisposition)).To(BeEmpty())
+	})
+
+	It("aborts when it fails halfway", func(ctx SpecContext) {
+		userRepo.EXPECT().Export(gomock.Any(), gomock.Any(), false, 2, gomock.Any()).
+			DoAndReturn(func(_ context.Context, _ *repo.Query, _ bool, _ int, fn func([]*models.User) error) error {
+				Expect(fn([]*models.User{{UserId: 1}})).To(Succeed())
+				return errx.ConstraintViolation.New("mock error")
+			})
+		logger.EXPECT().Error(gomock.Any(), gomock.Any())
+		c, _ := newContext("/users/export?format=ndjson")
+		Expect(func() { _ = userHandler.Export(c) }).To(PanicWith(http.ErrAbortHandler))
+	})
+})
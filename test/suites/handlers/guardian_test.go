@@ -0,0 +1,91 @@
+package handlers_test
+
+import (
+	"github.com/golang/mock/gomock"
+	"github.com/labstack/echo/v4"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	errx "github.com/rhuandantas/verifymy-test/internal/errors"
+	"github.com/rhuandantas/verifymy-test/internal/i18n"
+	"github.com/rhuandantas/verifymy-test/internal/models"
+	"github.com/rhuandantas/verifymy-test/internal/server/handlers"
+	"github.com/rhuandantas/verifymy-test/internal/server/middlewares/auth"
+	"github.com/rhuandantas/verifymy-test/internal/util"
+	mock_auth "github.com/rhuandantas/verifymy-test/test/mock/auth"
+	mock_config "github.com/rhuandantas/verifymy-test/test/mock/config"
+	mock_guardian "github.com/rhuandantas/verifymy-test/test/mock/guardian"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+)
+
+var _ = Describe("Test guardian handler", func() {
+	var (
+		mockCtrl        *gomock.Controller
+		e               *echo.Echo
+		consents        *mock_guardian.MockConsents
+		guardianHandler *handlers.GuardianHandler
+	)
+
+	BeforeEach(func() {
+		e = echo.New()
+		mockCtrl = gomock.NewController(GinkgoT())
+		consents = mock_guardian.NewMockConsents(mockCtrl)
+		config := mock_config.NewMockConfigProvider(mockCtrl)
+		config.EXPECT().GetStringOrDefault("i18n.default-locale", gomock.Any()).Return("en")
+		config.EXPECT().GetStringOrDefault("i18n.path", gomock.Any()).Return("../../../resources/i18n")
+		translator, err := i18n.NewCatalogTranslator(config)
+		Expect(err).To(BeNil())
+		guardianHandler = handlers.NewGuardianHandler(util.NewCustomValidator(translator), consents,
+			mock_auth.NewMockToken(mockCtrl), mock_auth.NewMockSignature(mockCtrl))
+	})
+
+	AfterEach(func() {
+		e.Close()
+	})
+
+	newContext := func(method, target, body string) (echo.Context, *httptest.ResponseRecorder) {
+		req := httptest.NewRequest(method, target, strings.NewReader(body))
+		req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+		rec := httptest.NewRecorder()
+		c := e.NewContext(req, rec)
+		c.SetParamNames("id", "link_id")
+		c.SetParamValues("2", "3")
+		c.Set(auth.PartnerContextKey, "partner-a")
+		return c, rec
+	}
+
+	It("invites a guardian on behalf of the caller", func() {
+		consents.EXPECT().Invite(gomock.Any(), 2, models.GuardianInvite{GuardianEmail: "ned@email.com"}, "partner:partner-a").
+			Return(&models.GuardianInvitation{GuardianLink: &models.GuardianLink{LinkId: 3, MinorId: 2, Status: models.GuardianInvited}, Token: "token"}, nil)
+		c, rec := newContext(http.MethodPost, "/users/2/guardians", `{"guardian_email":"ned@email.com"}`)
+		Expect(guardianHandler.Invite(c)).To(Succeed())
+		Expect(rec.Code).To(Equal(http.StatusOK))
+		Expect(rec.Body.String()).To(ContainSubstring(`"token":"token"`))
+		Expect(rec.Body.String()).ToNot(ContainSubstring("token_hash"))
+	})
+
+	It("needs a valid guardian email", func() {
+		c, rec := newContext(http.MethodPost, "/users/2/guardians", `{"guardian_email":"ned"}`)
+		Expect(guardianHandler.Invite(c)).To(Succeed())
+		Expect(rec.Code).To(Equal(http.StatusBadRequest))
+		Expect(rec.Body.String()).To(ContainSubstring("guardian_email"))
+	})
+
+	It("accepts an invitation as the caller", func() {
+		consents.EXPECT().Accept(gomock.Any(), models.GuardianAcceptance{Token: "token"}, "partner:partner-a").
+			Return(nil, errx.Forbidden.New("invitation is for another guardian"))
+		c, rec := newContext(http.MethodPost, "/guardian-invitations/accept", `{"token":"token"}`)
+		Expect(guardianHandler.Accept(c)).To(Succeed())
+		Expect(rec.Code).To(Equal(http.StatusForbidden))
+	})
+
+	It("revokes a consent", func() {
+		consents.EXPECT().Revoke(gomock.Any(), 2, 3, "partner:partner-a", false).
+			Return(&models.GuardianLink{LinkId: 3, MinorId: 2, Status: models.GuardianRevoked}, nil)
+		c, rec := newContext(http.MethodDelete, "/users/2/guardians/3", "")
+		Expect(guardianHandler.Revoke(c)).To(Succeed())
+		Expect(rec.Code).To(Equal(http.StatusOK))
+		Expect(rec.Body.String()).To(ContainSubstring(`"status":"revoked"`))
+	})
+})
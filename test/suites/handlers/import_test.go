@@ -0,0 +1,175 @@
+package handlers_test
+
+import (
+	"encoding/json"
+	"github.com/golang/mock/gomock"
+	"github.com/labstack/echo/v4"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	errx "github.com/rhuandantas/verifymy-test/internal/errors"
+	"github.com/rhuandantas/verifymy-test/internal/models"
+	"github.com/rhuandantas/verifymy-test/internal/server/handlers"
+	mock_auth "github.com/rhuandantas/verifymy-test/test/mock/auth"
+	mock_config "github.com/rhuandantas/verifymy-test/test/mock/config"
+	mock_guardian "github.com/rhuandantas/verifymy-test/test/mock/guardian"
+	mock_log "github.com/rhuandantas/verifymy-test/test/mock/log"
+	mock_repo "github.com/rhuandantas/verifymy-test/test/mock/repo"
+	mock_util "github.com/rhuandantas/verifymy-test/test/mock/util"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+)
+
+var _ = Describe("Test import handler", func() {
+	var (
+		mockCtrl    *gomock.Controller
+		e           *echo.Echo
+		validator   *mock_util.MockValidator
+		policy      *mock_util.MockPasswordPolicy
+		userRepo    *mock_repo.MockUserRepo
+		consents    *mock_guardian.MockConsents
+		logger      *mock_log.MockSimpleLogger
+		userHandler *handlers.UserHandler
+	)
+
+	BeforeEach(func() {
+		e = echo.New()
+		mockCtrl = gomock.NewController(GinkgoT())
+		validator = mock_util.NewMockValidator(mockCtrl)
+		policy = mock_util.NewMockPasswordPolicy(mockCtrl)
+		userRepo = mock_repo.NewMockUserRepo(mockCtrl)
+		config := mock_config.NewMockConfigProvider(mockCtrl)
+		config.EXPECT().GetInt("users.batch.max-size").Return(0)
+		config.EXPECT().GetInt("users.batch.insert-size").Return(2)
+		config.EXPECT().GetInt("users.import.max-rows").Return(10)
+		config.EXPECT().GetInt("users.export.batch-size").Return(0)
+		logger = mock_log.NewMockSimpleLogger(mockCtrl)
+		consents = mock_guardian.NewMockConsents(mockCtrl)
+		// users with a date of birth stand for minors
+		consents.EXPECT().Restrict(gomock.Any()).DoAndReturn(func(user *models.User) bool {
+			return user.DateOfBirth != nil
+		}).AnyTimes()
+		userHandler = handlers.NewUserHandler(config, validator, policy, userRepo, mock_repo.NewMockAddressRepo(mockCtrl), mock_repo.NewMockVerificationRepo(mockCtrl), consents, mock_auth.NewMockToken(mockCtrl),
+			mock_auth.NewMockSignature(mockCtrl), logger)
+	})
+
+	AfterEach(func() {
+		e.Close()
+	})
+
+	newContext := func(target, contentType, body string) (echo.Context, *httptest.ResponseRecorder) {
+		req := httptest.NewRequest(http.MethodPost, target, strings.NewReader(body))
+		req.Header.Set(echo.HeaderContentType, contentType)
+		rec := httptest.NewRecorder()
+		return e.NewContext(req, rec), rec
+	}
+
+	readReport := func(rec *httptest.ResponseRecorder) handlers.ImportReport {
+		var report handlers.ImportReport
+		Expect(json.Unmarshal(rec.Body.Bytes(), &report)).To(Succeed())
+		return report
+	}
+
+	csv := "Full Name,email,password\n" +
+		"Jon Snow,jon@email.com,winter1sComing\n" +
+		"Arya Stark,arya@email.com,needle1sSharp\n" +
+		"Sansa Stark,JON@email.com,lemon1Cakes\n" +
+		"Ned Stark,ned@email.com,ice1sCold\n" +
+		"Bran Stark,,\n"
+
+	It("reports created, skipped and failed rows", func(ctx SpecContext) {
+		validator.EXPECT().ValidateStruct(gomock.Any()).Return(nil).Times(4)
+		validator.EXPECT().ValidateStruct(models.User{Name: "Bran Stark"}).Return(errx.Validation.New("request validation failed"))
+		policy.EXPECT().Check(gomock.Any()).Return(nil).Times(4)
+		userRepo.EXPECT().ExistingEmails(gomock.Any(), []string{"jon@email.com", "arya@email.com", "ned@email.com"}).
+			Return(map[string]bool{"ned@email.com": true}, nil)
+		userRepo.EXPECT().CreateBatch(gomock.Any(), gomock.Len(2), 2).Return([]*models.User{{UserId: 1}, {UserId: 2}}, nil)
+		c, rec := newContext("/users/import?map=Full%20Name:name", "text/csv; charset=utf-8", csv)
+		Expect(userHandler.Import(c)).To(Succeed())
+		Expect(rec.Code).To(Equal(200))
+		report := readReport(rec)
+		Expect(report.Total).To(Equal(5))
+		Expect(report.Created).To(Equal([]handlers.ImportRow{
+			{Line: 2, UserId: 1, Email: "jon@email.com"},
+			{Line: 3, UserId: 2, Email: "arya@email.com"},
+		}))
+		Expect(report.Skipped).To(HaveLen(2))
+		Expect(report.Skipped[0].Line).To(Equal(4))
+		Expect(report.Skipped[0].Error.Detail).To(Equal("email already appears on line 2"))
+		Expect(report.Skipped[1].Line).To(Equal(5))
+		Expect(report.Failed).To(HaveLen(1))
+		Expect(report.Failed[0].Line).To(Equal(6))
+	})
+
+	It("fails minors without a guardian and invites the guardian of the others", func(ctx SpecContext) {
+		validator.EXPECT().ValidateStruct(gomock.Any()).Return(nil).Times(2)
+		policy.EXPECT().Check(gomock.Any()).Return(nil).Times(2)
+		userRepo.EXPECT().ExistingEmails(gomock.Any(), []string{"arya@email.com"}).Return(map[string]bool{}, nil)
+		userRepo.EXPECT().CreateBatch(gomock.Any(), gomock.Len(1), 1).Return([]*models.User{{UserId: 1}}, nil)
+		consents.EXPECT().Invite(gomock.Any(), 1, models.GuardianInvite{GuardianEmail: "ned@email.com"}, gomock.Any()).Return(nil, errx.Conflict.New("mock error"))
+		logger.EXPECT().Errorf(gomock.Any(), gomock.Any())
+		c, rec := newContext("/users/import", "application/x-ndjson",
+			`{"name":"Bran Stark","email":"bran@email.com","password":"raven1Eyed","date_of_birth":"2015-01-01"}`+"\n"+
+				`{"name":"Arya Stark","email":"arya@email.com","password":"needle1sSharp","date_of_birth":"2015-01-01","guardian_email":"ned@email.com"}`+"\n")
+		Expect(userHandler.Import(c)).To(Succeed())
+		Expect(rec.Code).To(Equal(200))
+		report := readReport(rec)
+		Expect(report.Created).To(Equal([]handlers.ImportRow{{Line: 2, UserId: 1, Email: "arya@email.com"}}))
+		Expect(report.Failed).To(HaveLen(1))
+		Expect(report.Failed[0].Line).To(Equal(1))
+		Expect(report.Failed[0].Error.Detail).To(Equal("minors need a guardian to consent for them"))
+	})
+
+	It("writes nothing on a dry run", func(ctx SpecContext) {
+		validator.EXPECT().ValidateStruct(gomock.Any()).Return(nil).Times(2)
+		policy.EXPECT().Check("short").Return(errx.Validation.New("password doesn't meet the password policy"))
+		policy.EXPECT().Check("needle1sSharp").Return(nil)
+		userRepo.EXPECT().ExistingEmails(gomock.Any(), []string{"arya@email.com"}).Return(map[string]bool{}, nil)
+		ndjson := `{"email":"jon@email.com","password":"short"}` + "\n" + `{"email":"arya@email.com","password":"needle1sSharp"}`
+		c, rec := newContext("/users/import?dry_run=true", "application/x-ndjson", ndjson)
+		Expect(userHandler.Import(c)).To(Succeed())
+		report := readReport(rec)
+		Expect(report.DryRun).To(BeTrue())
+		Expect(report.Created).To(Equal([]handlers.ImportRow{{Line: 2, Email: "arya@email.com"}}))
+		Expect(report.Failed[0].Line).To(Equal(1))
+		Expect(report.Failed[0].Error.Status).To(Equal(400))
+	})
+
+	It("fails rows without a password", func(ctx SpecContext) {
+		validator.EXPECT().ValidateStruct(gomock.Any()).Return(nil)
+		policy.EXPECT().Check("").Return(errx.Validation.New("password doesn't meet the password policy"))
+		userRepo.EXPECT().ExistingEmails(gomock.Any(), gomock.Len(0)).Return(map[string]bool{}, nil)
+		c, rec := newContext("/users/import", "application/x-ndjson", `{"email":"jon@email.com"}`)
+		Expect(userHandler.Import(c)).To(Succeed())
+		report := readReport(rec)
+		Expect(report.Created).To(BeEmpty())
+		Expect(report.Failed).To(HaveLen(1))
+		Expect(report.Failed[0].Error.Status).To(Equal(400))
+	})
+
+	It("creates one by one when the batch insert fails", func(ctx SpecContext) {
+		validator.EXPECT().ValidateStruct(gomock.Any()).Return(nil).Times(2)
+		policy.EXPECT().Check(gomock.Any()).Return(nil).Times(2)
+		userRepo.EXPECT().ExistingEmails(gomock.Any(), gomock.Any()).Return(map[string]bool{}, nil)
+		userRepo.EXPECT().CreateBatch(gomock.Any(), gomock.Len(2), 2).Return(nil, errx.Conflict.New("email is already registered"))
+		userRepo.EXPECT().Create(gomock.Any(), gomock.Any()).Return(nil, errx.Conflict.New("email is already registered"))
+		userRepo.EXPECT().Create(gomock.Any(), gomock.Any()).Return(&models.User{UserId: 9}, nil)
+		c, rec := newContext("/users/import", "text/csv", "email,password\njon@email.com,winter1sComing\narya@email.com,needle1sSharp\n")
+		Expect(userHandler.Import(c)).To(Succeed())
+		report := readReport(rec)
+		Expect(report.Skipped[0].Line).To(Equal(2))
+		Expect(report.Created).To(Equal([]handlers.ImportRow{{Line: 3, UserId: 9, Email: "arya@email.com"}}))
+	})
+
+	It("unsupported content type", func(ctx SpecContext) {
+		c, rec := newContext("/users/import", echo.MIMEApplicationJSON, "[]")
+		Expect(userHandler.Import(c)).To(Succeed())
+		Expect(rec.Code).To(Equal(415))
+	})
+
+	It("csv header that doesn't map to a field", func(ctx SpecContext) {
+		c, rec := newContext("/users/import", "text/csv", "email,phone\n")
+		Expect(userHandler.Import(c)).To(Succeed())
+		Expect(rec.Code).To(Equal(400))
+	})
+})
@@ -0,0 +1,94 @@
+package handlers_test
+
+import (
+	"github.com/golang/mock/gomock"
+	"github.com/labstack/echo/v4"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	errx "github.com/rhuandantas/verifymy-test/internal/errors"
+	"github.com/rhuandantas/verifymy-test/internal/i18n"
+	"github.com/rhuandantas/verifymy-test/internal/models"
+	"github.com/rhuandantas/verifymy-test/internal/server/handlers"
+	"github.com/rhuandantas/verifymy-test/internal/server/middlewares/auth"
+	"github.com/rhuandantas/verifymy-test/internal/util"
+	mock_auth "github.com/rhuandantas/verifymy-test/test/mock/auth"
+	mock_config "github.com/rhuandantas/verifymy-test/test/mock/config"
+	mock_verification "github.com/rhuandantas/verifymy-test/test/mock/verification"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+)
+
+var _ = Describe("Test review handler", func() {
+	var (
+		mockCtrl      *gomock.Controller
+		e             *echo.Echo
+		reviews       *mock_verification.MockReviews
+		reviewHandler *handlers.ReviewHandler
+	)
+
+	BeforeEach(func() {
+		e = echo.New()
+		mockCtrl = gomock.NewController(GinkgoT())
+		reviews = mock_verification.NewMockReviews(mockCtrl)
+		config := mock_config.NewMockConfigProvider(mockCtrl)
+		config.EXPECT().GetStringOrDefault("i18n.default-locale", gomock.Any()).Return("en")
+		config.EXPECT().GetStringOrDefault("i18n.path", gomock.Any()).Return("../../../resources/i18n")
+		translator, err := i18n.NewCatalogTranslator(config)
+		Expect(err).To(BeNil())
+		reviewHandler = handlers.NewReviewHandler(util.NewCustomValidator(translator), reviews,
+			mock_auth.NewMockToken(mockCtrl), mock_auth.NewMockSignature(mockCtrl))
+	})
+
+	AfterEach(func() {
+		e.Close()
+	})
+
+	newContext := func(method, target, body string) (echo.Context, *httptest.ResponseRecorder) {
+		req := httptest.NewRequest(method, target, strings.NewReader(body))
+		req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+		rec := httptest.NewRecorder()
+		c := e.NewContext(req, rec)
+		c.SetParamNames("case_id")
+		c.SetParamValues("7")
+		c.Set(auth.PartnerContextKey, "reviewer-a")
+		return c, rec
+	}
+
+	It("lists the queue with its filters", func() {
+		reviews.EXPECT().List(gomock.Any(), gomock.Any(), 10).Return([]*models.ReviewCase{{CaseId: 7, Status: models.ReviewOpen, Overdue: true}}, nil)
+		c, rec := newContext(http.MethodGet, "/reviews?status=open&limit=10", "")
+		Expect(reviewHandler.List(c)).To(Succeed())
+		Expect(rec.Code).To(Equal(http.StatusOK))
+		Expect(rec.Body.String()).To(ContainSubstring(`"overdue":true`))
+	})
+
+	It("refuses unknown sort fields", func() {
+		c, rec := newContext(http.MethodGet, "/reviews?sort=reason", "")
+		Expect(reviewHandler.List(c)).To(Succeed())
+		Expect(rec.Code).To(Equal(http.StatusBadRequest))
+	})
+
+	It("claims the case for the caller", func() {
+		reviews.EXPECT().Claim(gomock.Any(), 7, "partner:reviewer-a").
+			Return(&models.ReviewCaseDetail{ReviewCase: &models.ReviewCase{CaseId: 7, Status: models.ReviewClaimed, Reviewer: "partner:reviewer-a"}}, nil)
+		c, rec := newContext(http.MethodPost, "/reviews/7/claim", "")
+		Expect(reviewHandler.Claim(c)).To(Succeed())
+		Expect(rec.Code).To(Equal(http.StatusOK))
+	})
+
+	It("decides on behalf of the caller", func() {
+		reviews.EXPECT().Decide(gomock.Any(), 7, "partner:reviewer-a", models.ReviewDecision{Decision: models.ReviewRejected, Reason: "photo doesn't match"}).
+			Return(nil, errx.Forbidden.New("review case 7 must be claimed by partner:reviewer-a before deciding"))
+		c, rec := newContext(http.MethodPost, "/reviews/7/decision", `{"decision":"rejected","reason":"photo doesn't match"}`)
+		Expect(reviewHandler.Decide(c)).To(Succeed())
+		Expect(rec.Code).To(Equal(http.StatusForbidden))
+	})
+
+	It("needs an assurance level to approve", func() {
+		c, rec := newContext(http.MethodPost, "/reviews/7/decision", `{"decision":"approved","reason":"looks fine"}`)
+		Expect(reviewHandler.Decide(c)).To(Succeed())
+		Expect(rec.Code).To(Equal(http.StatusBadRequest))
+		Expect(rec.Body.String()).To(ContainSubstring("assurance_level"))
+	})
+})
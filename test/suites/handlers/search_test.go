@@ -0,0 +1,96 @@
+package handlers_test
+
+import (
+	"encoding/json"
+	"errors"
+	"github.com/golang/mock/gomock"
+	"github.com/labstack/echo/v4"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"github.com/rhuandantas/verifymy-test/internal/models"
+	"github.com/rhuandantas/verifymy-test/internal/search"
+	serverErr "github.com/rhuandantas/verifymy-test/internal/server/error"
+	"github.com/rhuandantas/verifymy-test/internal/server/handlers"
+	mock_auth "github.com/rhuandantas/verifymy-test/test/mock/auth"
+	mock_config "github.com/rhuandantas/verifymy-test/test/mock/config"
+	mock_search "github.com/rhuandantas/verifymy-test/test/mock/search"
+	"net/http"
+	"net/http/httptest"
+)
+
+var _ = Describe("Test search handler", func() {
+	var (
+		mockCtrl      *gomock.Controller
+		e             *echo.Echo
+		searcher      *mock_search.MockUserSearcher
+		searchHandler *handlers.SearchHandler
+	)
+
+	BeforeEach(func() {
+		e = echo.New()
+		mockCtrl = gomock.NewController(GinkgoT())
+		searcher = mock_search.NewMockUserSearcher(mockCtrl)
+		config := mock_config.NewMockConfigProvider(mockCtrl)
+		config.EXPECT().GetInt("users.search.max-limit").Return(50)
+		config.EXPECT().GetInt("users.search.default-limit").Return(0)
+		searchHandler = handlers.NewSearchHandler(config, searcher, mock_auth.NewMockToken(mockCtrl), mock_auth.NewMockSignature(mockCtrl))
+	})
+
+	AfterEach(func() {
+		e.Close()
+	})
+
+	newContext := func(target string) (echo.Context, *httptest.ResponseRecorder) {
+		rec := httptest.NewRecorder()
+		return e.NewContext(httptest.NewRequest(http.MethodGet, target, nil), rec), rec
+	}
+
+	It("returns the hits of the searcher", func() {
+		hits := []search.Hit{{
+			User:       &models.User{UserId: 1, Name: "Jon Snow"},
+			Score:      0.9,
+			Fuzzy:      true,
+			Highlights: map[string]string{"name": "<em>Jon</em> Snow"},
+		}}
+		searcher.EXPECT().Search(gomock.Any(), "jon snwo", 20).Return(hits, nil)
+		c, rec := newContext("/users/search?q=+jon+snwo+")
+		Expect(searchHandler.Search(c)).To(Succeed())
+		Expect(rec.Code).To(Equal(http.StatusOK))
+
+		var res []search.Hit
+		Expect(json.Unmarshal(rec.Body.Bytes(), &res)).To(Succeed())
+		Expect(res).To(Equal(hits))
+	})
+
+	It("takes the limit", func() {
+		searcher.EXPECT().Search(gomock.Any(), "jon", 50).Return([]search.Hit{}, nil)
+		c, rec := newContext("/users/search?q=jon&limit=50")
+		Expect(searchHandler.Search(c)).To(Succeed())
+		Expect(rec.Code).To(Equal(http.StatusOK))
+		Expect(rec.Body.String()).To(Equal("[]\n"))
+	})
+
+	It("fails without q or with a limit out of range", func() {
+		c, rec := newContext("/users/search?limit=51")
+		Expect(searchHandler.Search(c)).To(Succeed())
+		Expect(rec.Code).To(Equal(http.StatusBadRequest))
+
+		var res serverErr.ErrorResponse
+		Expect(json.Unmarshal(rec.Body.Bytes(), &res)).To(Succeed())
+		Expect(res.Errors).To(HaveLen(2))
+		Expect(res.Errors[0].Field).To(Equal("q"))
+		Expect(res.Errors[1].Field).To(Equal("limit"))
+		Expect(res.Errors[1].Rule).To(Equal("max"))
+
+		c, rec = newContext("/users/search?q=jon&limit=ten")
+		Expect(searchHandler.Search(c)).To(Succeed())
+		Expect(rec.Code).To(Equal(http.StatusBadRequest))
+	})
+
+	It("fails when the searcher does", func() {
+		searcher.EXPECT().Search(gomock.Any(), "jon", 20).Return(nil, errors.New("connection refused"))
+		c, rec := newContext("/users/search?q=jon")
+		Expect(searchHandler.Search(c)).To(Succeed())
+		Expect(rec.Code).To(Equal(http.StatusInternalServerError))
+	})
+})
@@ -1,14 +1,23 @@
 package handlers_test
 
 import (
+	"encoding/json"
 	"errors"
 	"github.com/golang/mock/gomock"
 	"github.com/labstack/echo/v4"
 	. "github.com/onsi/ginkgo/v2"
 	. "github.com/onsi/gomega"
+	errx "github.com/rhuandantas/verifymy-test/internal/errors"
+	"github.com/rhuandantas/verifymy-test/internal/i18n"
 	"github.com/rhuandantas/verifymy-test/internal/models"
+	"github.com/rhuandantas/verifymy-test/internal/repo"
+	serverErr "github.com/rhuandantas/verifymy-test/internal/server/error"
 	"github.com/rhuandantas/verifymy-test/internal/server/handlers"
+	"github.com/rhuandantas/verifymy-test/internal/server/middlewares/auth"
+	"github.com/rhuandantas/verifymy-test/internal/util"
 	mock_auth "github.com/rhuandantas/verifymy-test/test/mock/auth"
+	mock_config "github.com/rhuandantas/verifymy-test/test/mock/config"
+	mock_guardian "github.com/rhuandantas/verifymy-test/test/mock/guardian"
 	mock_log "github.com/rhuandantas/verifymy-test/test/mock/log"
 	mock_repo "github.com/rhuandantas/verifymy-test/test/mock/repo"
 	mock_util "github.com/rhuandantas/verifymy-test/test/mock/util"
@@ -16,18 +25,24 @@ import (
 	"net/http/httptest"
 	"net/url"
 	"strings"
+	"time"
 )
 
 var _ = Describe("Test all handlers methods", func() {
 	var (
-		mockCtrl    *gomock.Controller
-		e           *echo.Echo
-		validator   *mock_util.MockValidator
-		userRepo    *mock_repo.MockUserRepo
-		tokenJwt    *mock_auth.MockToken
-		logger      *mock_log.MockSimpleLogger
-		userHandler *handlers.UserHandler
-		mockUser    models.User
+		mockCtrl      *gomock.Controller
+		e             *echo.Echo
+		validator     *mock_util.MockValidator
+		userRepo      *mock_repo.MockUserRepo
+		addressRepo   *mock_repo.MockAddressRepo
+		verifications *mock_repo.MockVerificationRepo
+		consents      *mock_guardian.MockConsents
+		tokenJwt      *mock_auth.MockToken
+		signature     *mock_auth.MockSignature
+		logger        *mock_log.MockSimpleLogger
+		userHandler   *handlers.UserHandler
+		mockUser      models.User
+		translator    i18n.Translator
 	)
 
 	BeforeEach(func() {
@@ -35,16 +50,26 @@ var _ = Describe("Test all handlers methods", func() {
 		mockCtrl = gomock.NewController(GinkgoT())
 		validator = mock_util.NewMockValidator(mockCtrl)
 		userRepo = mock_repo.NewMockUserRepo(mockCtrl)
+		addressRepo = mock_repo.NewMockAddressRepo(mockCtrl)
+		verifications = mock_repo.NewMockVerificationRepo(mockCtrl)
+		consents = mock_guardian.NewMockConsents(mockCtrl)
 		tokenJwt = mock_auth.NewMockToken(mockCtrl)
+		signature = mock_auth.NewMockSignature(mockCtrl)
 		logger = mock_log.NewMockSimpleLogger(mockCtrl)
-		userHandler = handlers.NewUserHandler(validator, userRepo, tokenJwt, logger)
+		config := mock_config.NewMockConfigProvider(mockCtrl)
+		config.EXPECT().GetInt(gomock.Any()).Return(0).AnyTimes()
+		userHandler = handlers.NewUserHandler(config, validator, mock_util.NewMockPasswordPolicy(mockCtrl), userRepo, addressRepo, verifications, consents, tokenJwt, signature, logger)
+		config.EXPECT().GetStringOrDefault("i18n.default-locale", gomock.Any()).Return("en")
+		config.EXPECT().GetStringOrDefault("i18n.path", gomock.Any()).Return("../../../resources/i18n")
+		translator, _ = i18n.NewCatalogTranslator(config)
+		birth := models.NewDate(1993, 5, 17)
 		mockUser = models.User{
-			UserId:   1,
-			Name:     "Jon Snow",
-			Age:      30,
-			Email:    "jon@email.com",
-			Password: "123456",
-			Address:  "rua network",
+			UserId:      1,
+			Name:        "Jon Snow",
+			DateOfBirth: &birth,
+			Email:       "jon@email.com",
+			Password:    "123456",
+			Address:     "rua network",
 		}
 	})
 
@@ -52,10 +77,18 @@ var _ = Describe("Test all handlers methods", func() {
 		e.Close()
 	})
 
+	reassessed := func(status string) {
+		consents.EXPECT().Reassess(gomock.Any(), gomock.Any()).DoAndReturn(func(_ interface{}, user *models.User) error {
+			user.Status = status
+			return nil
+		})
+	}
+
 	Context("Call user create handler", func() {
 		It("successfully", func(ctx SpecContext) {
 			userJSON := `{"name":"Jon Snow","email":"jon@labstack.com","password":"12345"}`
 			validator.EXPECT().ValidateStruct(gomock.Any()).Return(nil)
+			consents.EXPECT().Restrict(gomock.Any()).Return(false)
 			userRepo.EXPECT().Create(gomock.Any(), gomock.Any()).Return(&mockUser, nil)
 			req := httptest.NewRequest(http.MethodPost, "/users", strings.NewReader(userJSON))
 			req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
@@ -67,6 +100,48 @@ var _ = Describe("Test all handlers methods", func() {
 			Expect(c.Response().Status).To(Equal(200))
 		})
 
+		It("of a minor inviting their guardian", func(ctx SpecContext) {
+			userJSON := `{"name":"Arya Stark","email":"arya@email.com","date_of_birth":"2015-03-01","guardian_email":"ned@email.com"}`
+			validator.EXPECT().ValidateStruct(gomock.Any()).Return(nil)
+			consents.EXPECT().Restrict(gomock.Any()).DoAndReturn(func(user *models.User) bool {
+				user.Status = models.UserRestricted
+				return true
+			})
+			userRepo.EXPECT().Create(gomock.Any(), gomock.Any()).DoAndReturn(func(_ interface{}, user models.User) (*models.User, error) {
+				Expect(user.Status).To(Equal(models.UserRestricted))
+				user.UserId = 2
+				return &user, nil
+			})
+			consents.EXPECT().Invite(gomock.Any(), 2, models.GuardianInvite{GuardianEmail: "ned@email.com"}, gomock.Any()).
+				Return(&models.GuardianInvitation{GuardianLink: &models.GuardianLink{LinkId: 1, MinorId: 2}, Token: "token"}, nil)
+			req := httptest.NewRequest(http.MethodPost, "/users", strings.NewReader(userJSON))
+			req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+			rec := httptest.NewRecorder()
+			c := e.NewContext(req, rec)
+			Expect(userHandler.Create(c)).To(Succeed())
+			Expect(rec.Code).To(Equal(200))
+			var res map[string]interface{}
+			Expect(json.Unmarshal(rec.Body.Bytes(), &res)).To(Succeed())
+			Expect(res["status"]).To(Equal(models.UserRestricted))
+			Expect(res["guardian_invitation"]).To(HaveKeyWithValue("token", "token"))
+		})
+
+		It("of a minor without a guardian", func(ctx SpecContext) {
+			userJSON := `{"name":"Arya Stark","email":"arya@email.com","date_of_birth":"2015-03-01"}`
+			validator.EXPECT().ValidateStruct(gomock.Any()).Return(nil)
+			consents.EXPECT().Restrict(gomock.Any()).Return(true)
+			req := httptest.NewRequest(http.MethodPost, "/users", strings.NewReader(userJSON))
+			req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+			rec := httptest.NewRecorder()
+			c := e.NewContext(req, rec)
+			Expect(userHandler.Create(c)).To(Succeed())
+			Expect(rec.Code).To(Equal(400))
+			var problem serverErr.ErrorResponse
+			Expect(json.Unmarshal(rec.Body.Bytes(), &problem)).To(Succeed())
+			Expect(problem.Errors).To(HaveLen(1))
+			Expect(problem.Errors[0].Field).To(Equal("guardian_email"))
+		})
+
 		It("json body invalid", func(ctx SpecContext) {
 			userJSON := `{"name":"Jon Snow","email":"jon@labstack.com","password":12345}`
 			req := httptest.NewRequest(http.MethodPost, "/users", strings.NewReader(userJSON))
@@ -92,9 +167,49 @@ var _ = Describe("Test all handlers methods", func() {
 			Expect(c.Response().Status).To(Equal(400))
 		})
 
+		It("validation errors as problem details", func(ctx SpecContext) {
+			userJSON := `{"name":"Jon Snow","password":"12345"}`
+			validator.EXPECT().ValidateStruct(gomock.Any()).Return(util.NewCustomValidator(translator).ValidateStruct(models.User{}))
+			req := httptest.NewRequest(http.MethodPost, "/users", strings.NewReader(userJSON))
+			req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+			rec := httptest.NewRecorder()
+			c := e.NewContext(req, rec)
+			err := userHandler.Create(c)
+			Expect(err).To(BeNil())
+			Expect(c.Response().Status).To(Equal(400))
+			Expect(rec.Header().Get(echo.HeaderContentType)).To(Equal(serverErr.MIMEApplicationProblemJSON))
+			var problem serverErr.ErrorResponse
+			Expect(json.Unmarshal(rec.Body.Bytes(), &problem)).To(Succeed())
+			Expect(problem.Status).To(Equal(400))
+			Expect(problem.Code).To(Equal("common.bad_request.validation"))
+			Expect(problem.Instance).To(Equal("/users"))
+			Expect(problem.Errors).To(HaveLen(1))
+			Expect(problem.Errors[0].Field).To(Equal("email"))
+			Expect(problem.Errors[0].Rule).To(Equal("required"))
+		})
+
+		It("problem details localized by accept language", func(ctx SpecContext) {
+			userJSON := `{"name":"Jon Snow","password":"12345"}`
+			validator.EXPECT().ValidateStruct(gomock.Any()).Return(util.NewCustomValidator(translator).ValidateStruct(models.User{}))
+			req := httptest.NewRequest(http.MethodPost, "/users", strings.NewReader(userJSON))
+			req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+			req.Header.Set(i18n.HeaderAcceptLanguage, "pt-BR,pt;q=0.9")
+			rec := httptest.NewRecorder()
+			c := e.NewContext(req, rec)
+			c.Set(serverErr.TranslatorContextKey, translator)
+			err := userHandler.Create(c)
+			Expect(err).To(BeNil())
+			var problem serverErr.ErrorResponse
+			Expect(json.Unmarshal(rec.Body.Bytes(), &problem)).To(Succeed())
+			Expect(problem.Title).To(Equal("Falha de validação"))
+			Expect(problem.Detail).To(Equal("falha na validação da requisição"))
+			Expect(problem.Errors[0].Message).To(Equal("email é obrigatório"))
+		})
+
 		It("create user repo fails", func(ctx SpecContext) {
 			userJSON := `{"name":"Jon Snow","email":"jon@labstack.com","password":"12345"}`
 			validator.EXPECT().ValidateStruct(gomock.Any()).Return(nil)
+			consents.EXPECT().Restrict(gomock.Any()).Return(false)
 			userRepo.EXPECT().Create(gomock.Any(), gomock.Any()).Return(nil, errors.New("mock error"))
 			req := httptest.NewRequest(http.MethodPost, "/users", strings.NewReader(userJSON))
 			req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
@@ -105,13 +220,42 @@ var _ = Describe("Test all handlers methods", func() {
 			Expect(c.Response()).ToNot(BeNil())
 			Expect(c.Response().Status).To(Equal(500))
 		})
+
+		It("email already registered", func(ctx SpecContext) {
+			userJSON := `{"name":"Jon Snow","email":"jon@labstack.com","password":"12345"}`
+			validator.EXPECT().ValidateStruct(gomock.Any()).Return(nil)
+			consents.EXPECT().Restrict(gomock.Any()).Return(false)
+			userRepo.EXPECT().Create(gomock.Any(), gomock.Any()).Return(nil, errx.Conflict.New("email is already registered"))
+			req := httptest.NewRequest(http.MethodPost, "/users", strings.NewReader(userJSON))
+			req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+			rec := httptest.NewRecorder()
+			c := e.NewContext(req, rec)
+			err := userHandler.Create(c)
+			Expect(err).To(BeNil())
+			Expect(c.Response().Status).To(Equal(409))
+		})
+
+		It("create user violates a constraint", func(ctx SpecContext) {
+			userJSON := `{"name":"Jon Snow","email":"jon@labstack.com","password":"12345"}`
+			validator.EXPECT().ValidateStruct(gomock.Any()).Return(nil)
+			consents.EXPECT().Restrict(gomock.Any()).Return(false)
+			userRepo.EXPECT().Create(gomock.Any(), gomock.Any()).Return(nil, errx.ConstraintViolation.New("Data too long for column 'name' at row 1"))
+			req := httptest.NewRequest(http.MethodPost, "/users", strings.NewReader(userJSON))
+			req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+			rec := httptest.NewRecorder()
+			c := e.NewContext(req, rec)
+			err := userHandler.Create(c)
+			Expect(err).To(BeNil())
+			Expect(c.Response().Status).To(Equal(422))
+		})
 	})
 
 	Context("Call user update handler", func() {
 		It("successfully", func(ctx SpecContext) {
 			userJSON := `{"name":"Jon Snow","email":"jon@labstack.com","password":"12345","address":"teste"}`
 			validator.EXPECT().ValidateStruct(gomock.Any()).Return(nil)
-			userRepo.EXPECT().Update(gomock.Any(), gomock.Any(), gomock.Any()).Return(&mockUser, nil)
+			reassessed(models.UserActive)
+			userRepo.EXPECT().Update(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any()).Return(&mockUser, nil)
 			req := httptest.NewRequest(http.MethodPut, "/users", strings.NewReader(userJSON))
 			req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
 			rec := httptest.NewRecorder()
@@ -169,10 +313,31 @@ var _ = Describe("Test all handlers methods", func() {
 			Expect(c.Response().Status).To(Equal(400))
 		})
 
+		It("with the status the date of birth calls for", func(ctx SpecContext) {
+			userJSON := `{"name":"Arya Stark","email":"arya@email.com","date_of_birth":"2015-01-01","status":"active"}`
+			validator.EXPECT().ValidateStruct(gomock.Any()).Return(nil)
+			reassessed(models.UserRestricted)
+			userRepo.EXPECT().Update(gomock.Any(), 1, gomock.Any(), 0).DoAndReturn(func(_ interface{}, _ int, user models.User, _ int) (*models.User, error) {
+				Expect(user.UserId).To(Equal(1))
+				Expect(user.Status).To(Equal(models.UserRestricted))
+				return &user, nil
+			})
+			req := httptest.NewRequest(http.MethodPut, "/users", strings.NewReader(userJSON))
+			req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+			rec := httptest.NewRecorder()
+			c := e.NewContext(req, rec)
+			c.SetPath("/:id")
+			c.SetParamNames("id")
+			c.SetParamValues("1")
+			Expect(userHandler.Update(c)).To(Succeed())
+			Expect(c.Response().Status).To(Equal(200))
+		})
+
 		It("update repo fails", func(ctx SpecContext) {
 			userJSON := `{"name":"Jon Snow","email":"jon@labstack.com","password":"12345","address":"teste"}`
 			validator.EXPECT().ValidateStruct(gomock.Any()).Return(nil)
-			userRepo.EXPECT().Update(gomock.Any(), gomock.Any(), gomock.Any()).Return(nil, errors.New("mock error"))
+			reassessed(models.UserActive)
+			userRepo.EXPECT().Update(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any()).Return(nil, errors.New("mock error"))
 			req := httptest.NewRequest(http.MethodPut, "/users", strings.NewReader(userJSON))
 			req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
 			rec := httptest.NewRecorder()
@@ -187,6 +352,189 @@ var _ = Describe("Test all handlers methods", func() {
 		})
 	})
 
+	Context("Call user update handler with If-Match", func() {
+		userJSON := `{"name":"Jon Snow","email":"jon@labstack.com","address":"teste"}`
+
+		newContext := func(ifMatch string) (echo.Context, *httptest.ResponseRecorder) {
+			req := httptest.NewRequest(http.MethodPut, "/users", strings.NewReader(userJSON))
+			req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+			req.Header.Set("If-Match", ifMatch)
+			rec := httptest.NewRecorder()
+			c := e.NewContext(req, rec)
+			c.SetPath("/:id")
+			c.SetParamNames("id")
+			c.SetParamValues("1")
+			return c, rec
+		}
+
+		It("successfully", func(ctx SpecContext) {
+			updated := mockUser
+			updated.Version = 4
+			validator.EXPECT().ValidateStruct(gomock.Any()).Return(nil)
+			reassessed(models.UserActive)
+			userRepo.EXPECT().Update(gomock.Any(), 1, gomock.Any(), 3).Return(&updated, nil)
+			c, rec := newContext(`"3"`)
+			err := userHandler.Update(c)
+			Expect(err).To(BeNil())
+			Expect(c.Response().Status).To(Equal(200))
+			Expect(rec.Header().Get("ETag")).To(Equal(`"4"`))
+		})
+
+		It("stale version", func(ctx SpecContext) {
+			validator.EXPECT().ValidateStruct(gomock.Any()).Return(nil)
+			reassessed(models.UserActive)
+			userRepo.EXPECT().Update(gomock.Any(), 1, gomock.Any(), 3).Return(nil, errx.PreconditionFailed.New("User 1 is at version 4, not 3"))
+			c, _ := newContext(`"3"`)
+			err := userHandler.Update(c)
+			Expect(err).To(BeNil())
+			Expect(c.Response().Status).To(Equal(412))
+		})
+
+		It("weak etag", func(ctx SpecContext) {
+			validator.EXPECT().ValidateStruct(gomock.Any()).Return(nil)
+			c, _ := newContext(`W/"3"`)
+			err := userHandler.Update(c)
+			Expect(err).To(BeNil())
+			Expect(c.Response().Status).To(Equal(412))
+		})
+	})
+
+	Context("Call user patch handler", func() {
+		newContext := func(contentType, body string) (echo.Context, *httptest.ResponseRecorder) {
+			req := httptest.NewRequest(http.MethodPatch, "/users", strings.NewReader(body))
+			req.Header.Set(echo.HeaderContentType, contentType)
+			rec := httptest.NewRecorder()
+			c := e.NewContext(req, rec)
+			c.SetPath("/:id")
+			c.SetParamNames("id")
+			c.SetParamValues("1")
+			return c, rec
+		}
+
+		BeforeEach(func() {
+			mockUser.Version = 3
+		})
+
+		It("successfully with a merge patch", func(ctx SpecContext) {
+			patched := mockUser
+			patched.Name = "Arya Stark"
+			patched.Version = 4
+			userRepo.EXPECT().GetByID(gomock.Any(), 1).Return(&mockUser, nil)
+			validator.EXPECT().ValidateStruct(gomock.Any()).Return(nil)
+			userRepo.EXPECT().Patch(gomock.Any(), 1, map[string]interface{}{"name": "Arya Stark"}, 3).Return(&patched, nil)
+			c, rec := newContext("application/merge-patch+json", `{"name":"Arya Stark"}`)
+			err := userHandler.Patch(c)
+			Expect(err).To(BeNil())
+			Expect(c.Response().Status).To(Equal(200))
+			Expect(rec.Header().Get("ETag")).To(Equal(`"4"`))
+			Expect(rec.Body.String()).ToNot(ContainSubstring("123456"))
+		})
+
+		It("successfully with a json patch", func(ctx SpecContext) {
+			birth := models.NewDate(1994, 5, 17)
+			patched := mockUser
+			patched.DateOfBirth = &birth
+			userRepo.EXPECT().GetByID(gomock.Any(), 1).Return(&mockUser, nil)
+			validator.EXPECT().ValidateStruct(gomock.Any()).Return(nil)
+			reassessed(mockUser.Status)
+			userRepo.EXPECT().Patch(gomock.Any(), 1, map[string]interface{}{"date_of_birth": &birth}, 3).Return(&patched, nil)
+			c, _ := newContext("application/json-patch+json", `[{"op":"test","path":"/date_of_birth","value":"1993-05-17"},{"op":"replace","path":"/date_of_birth","value":"1994-05-17"}]`)
+			err := userHandler.Patch(c)
+			Expect(err).To(BeNil())
+			Expect(c.Response().Status).To(Equal(200))
+		})
+
+		It("restricting a user the new date of birth makes a minor", func(ctx SpecContext) {
+			current := mockUser
+			current.Status = models.UserActive
+			birth := models.DateOf(time.Now()).AddDays(-365 * 10)
+			patched := current
+			patched.DateOfBirth = &birth
+			patched.Status = models.UserRestricted
+			userRepo.EXPECT().GetByID(gomock.Any(), 1).Return(&current, nil)
+			validator.EXPECT().ValidateStruct(gomock.Any()).Return(nil)
+			reassessed(models.UserRestricted)
+			userRepo.EXPECT().Patch(gomock.Any(), 1, map[string]interface{}{"date_of_birth": &birth, "status": models.UserRestricted}, 3).Return(&patched, nil)
+			c, rec := newContext("application/merge-patch+json", `{"date_of_birth":"`+birth.String()+`"}`)
+			err := userHandler.Patch(c)
+			Expect(err).To(BeNil())
+			Expect(c.Response().Status).To(Equal(200))
+			Expect(rec.Body.String()).To(ContainSubstring(`"status":"restricted"`))
+		})
+
+		It("age can't be patched", func(ctx SpecContext) {
+			userRepo.EXPECT().GetByID(gomock.Any(), 1).Return(&mockUser, nil)
+			validator.EXPECT().ValidateStruct(gomock.Any()).Return(nil)
+			c, _ := newContext("application/merge-patch+json", `{"age":18}`)
+			err := userHandler.Patch(c)
+			Expect(err).To(BeNil())
+			Expect(c.Response().Status).To(Equal(400))
+		})
+
+		It("without changes", func(ctx SpecContext) {
+			userRepo.EXPECT().GetByID(gomock.Any(), 1).Return(&mockUser, nil)
+			validator.EXPECT().ValidateStruct(gomock.Any()).Return(nil)
+			c, _ := newContext("application/merge-patch+json", `{"name":"Jon Snow"}`)
+			err := userHandler.Patch(c)
+			Expect(err).To(BeNil())
+			Expect(c.Response().Status).To(Equal(200))
+		})
+
+		It("unsupported content type", func(ctx SpecContext) {
+			userRepo.EXPECT().GetByID(gomock.Any(), 1).Return(&mockUser, nil)
+			c, _ := newContext(echo.MIMEApplicationJSON, `{"name":"Arya Stark"}`)
+			err := userHandler.Patch(c)
+			Expect(err).To(BeNil())
+			Expect(c.Response().Status).To(Equal(415))
+		})
+
+		It("read-only field", func(ctx SpecContext) {
+			userRepo.EXPECT().GetByID(gomock.Any(), 1).Return(&mockUser, nil)
+			validator.EXPECT().ValidateStruct(gomock.Any()).Return(nil)
+			c, _ := newContext("application/merge-patch+json", `{"version":10}`)
+			err := userHandler.Patch(c)
+			Expect(err).To(BeNil())
+			Expect(c.Response().Status).To(Equal(400))
+		})
+
+		It("failed test operation", func(ctx SpecContext) {
+			userRepo.EXPECT().GetByID(gomock.Any(), 1).Return(&mockUser, nil)
+			c, _ := newContext("application/json-patch+json", `[{"op":"test","path":"/age","value":40}]`)
+			err := userHandler.Patch(c)
+			Expect(err).To(BeNil())
+			Expect(c.Response().Status).To(Equal(409))
+		})
+
+		It("stale If-Match", func(ctx SpecContext) {
+			userRepo.EXPECT().GetByID(gomock.Any(), 1).Return(&mockUser, nil)
+			c, _ := newContext("application/merge-patch+json", `{"name":"Arya Stark"}`)
+			c.Request().Header.Set("If-Match", `"2"`)
+			err := userHandler.Patch(c)
+			Expect(err).To(BeNil())
+			Expect(c.Response().Status).To(Equal(412))
+		})
+
+		It("user not found", func(ctx SpecContext) {
+			userRepo.EXPECT().GetByID(gomock.Any(), 1).Return(nil, errx.NotFound.New("User 1 not found"))
+			c, _ := newContext("application/merge-patch+json", `{"name":"Arya Stark"}`)
+			err := userHandler.Patch(c)
+			Expect(err).To(BeNil())
+			Expect(c.Response().Status).To(Equal(404))
+		})
+
+		It("user not found localized by accept language", func(ctx SpecContext) {
+			userRepo.EXPECT().GetByID(gomock.Any(), 1).Return(nil, errx.Localized(errx.NotFound, "users.not_found", "User not found with id %d", 1))
+			c, rec := newContext("application/merge-patch+json", `{"name":"Arya Stark"}`)
+			c.Request().Header.Set(i18n.HeaderAcceptLanguage, "es")
+			c.Set(serverErr.TranslatorContextKey, translator)
+			Expect(userHandler.Patch(c)).To(Succeed())
+			var problem serverErr.ErrorResponse
+			Expect(json.Unmarshal(rec.Body.Bytes(), &problem)).To(Succeed())
+			Expect(problem.Status).To(Equal(404))
+			Expect(problem.Detail).To(Equal("Usuario no encontrado con id 1"))
+		})
+	})
+
 	Context("Call user delete handler", func() {
 		It("successfully", func(ctx SpecContext) {
 			userRepo.EXPECT().Delete(gomock.Any(), gomock.Any()).Return(true, nil)
@@ -227,6 +575,34 @@ var _ = Describe("Test all handlers methods", func() {
 		})
 	})
 
+	Context("Call user restore handler", func() {
+		It("successfully", func(ctx SpecContext) {
+			userRepo.EXPECT().Restore(gomock.Any(), 1).Return(&mockUser, nil)
+			req := httptest.NewRequest(http.MethodPost, "/users/1/restore", nil)
+			rec := httptest.NewRecorder()
+			c := e.NewContext(req, rec)
+			c.SetPath("/:id/restore")
+			c.SetParamNames("id")
+			c.SetParamValues("1")
+			err := userHandler.Restore(c)
+			Expect(err).To(BeNil())
+			Expect(c.Response().Status).To(Equal(200))
+		})
+
+		It("user is not deleted", func(ctx SpecContext) {
+			userRepo.EXPECT().Restore(gomock.Any(), 1).Return(nil, errx.NotFound.New("Deleted user not found with id 1"))
+			req := httptest.NewRequest(http.MethodPost, "/users/1/restore", nil)
+			rec := httptest.NewRecorder()
+			c := e.NewContext(req, rec)
+			c.SetPath("/:id/restore")
+			c.SetParamNames("id")
+			c.SetParamValues("1")
+			err := userHandler.Restore(c)
+			Expect(err).To(BeNil())
+			Expect(c.Response().Status).To(Equal(404))
+		})
+	})
+
 	Context("Call get user by id handler", func() {
 		It("successfully", func(ctx SpecContext) {
 			userRepo.EXPECT().GetByID(gomock.Any(), gomock.Any()).Return(&models.User{}, nil)
@@ -240,6 +616,88 @@ var _ = Describe("Test all handlers methods", func() {
 			Expect(err).To(BeNil())
 			Expect(c.Response()).ToNot(BeNil())
 			Expect(c.Response().Status).To(Equal(200))
+			Expect(rec.Header().Get("ETag")).To(Equal(`"0"`))
+		})
+
+		It("with only some fields", func(ctx SpecContext) {
+			userRepo.EXPECT().GetByID(gomock.Any(), 1, "name", "email").Return(&models.User{UserId: 1, Name: "Jon Snow", Email: "jon@email.com", Version: 3}, nil)
+			req := httptest.NewRequest(http.MethodGet, "/users/1?fields=name,email", nil)
+			rec := httptest.NewRecorder()
+			c := e.NewContext(req, rec)
+			c.SetPath("/:id")
+			c.SetParamNames("id")
+			c.SetParamValues("1")
+			err := userHandler.GetById(c)
+			Expect(err).To(BeNil())
+			Expect(c.Response().Status).To(Equal(200))
+			Expect(rec.Header().Get("ETag")).To(Equal(`"3"`))
+			Expect(rec.Body.String()).To(MatchJSON(`{"name":"Jon Snow","email":"jon@email.com"}`))
+		})
+
+		It("with its addresses expanded", func(ctx SpecContext) {
+			userRepo.EXPECT().GetByID(gomock.Any(), 1, "name").Return(&models.User{UserId: 1, Name: "Jon Snow", Version: 3}, nil)
+			addressRepo.EXPECT().ListByUsers(gomock.Any(), []int{1}).Return(map[int][]*models.Address{
+				1: {{AddressId: 7, UserId: 1, Type: models.AddressHome, Line1: "1 Castle Black", City: "The Wall", Country: "GB"}},
+			}, nil)
+			req := httptest.NewRequest(http.MethodGet, "/users/1?fields=name&expand=addresses", nil)
+			rec := httptest.NewRecorder()
+			c := e.NewContext(req, rec)
+			c.SetPath("/:id")
+			c.SetParamNames("id")
+			c.SetParamValues("1")
+			err := userHandler.GetById(c)
+			Expect(err).To(BeNil())
+			Expect(c.Response().Status).To(Equal(200))
+			var body struct {
+				Name      string           `json:"name"`
+				Addresses []models.Address `json:"addresses"`
+			}
+			Expect(json.Unmarshal(rec.Body.Bytes(), &body)).To(Succeed())
+			Expect(body.Name).To(Equal("Jon Snow"))
+			Expect(body.Addresses).To(HaveLen(1))
+			Expect(body.Addresses[0].City).To(Equal("The Wall"))
+		})
+
+		It("with its verification expanded", func(ctx SpecContext) {
+			expiredAt := time.Now().Add(-time.Hour)
+			userRepo.EXPECT().GetUsers(gomock.Any(), gomock.Any(), models.Pagination{Size: 10}, false).
+				Return(&models.UserPage{Items: []*models.User{{UserId: 1, Name: "Jon Snow"}, {UserId: 2, Name: "Arya Stark"}, {UserId: 3, Name: "Sansa Stark"}}}, nil)
+			validator.EXPECT().ValidateStruct(gomock.Any()).Return(nil)
+			verifications.EXPECT().ListByUsers(gomock.Any(), []int{1, 2, 3}).Return(map[int]*models.Verification{
+				1: {UserId: 1, State: models.VerificationPending, Method: models.MethodDocument},
+				3: {UserId: 3, State: models.VerificationVerified, ExpiresAt: &expiredAt},
+			}, nil)
+			req := httptest.NewRequest(http.MethodGet, "/users?size=10&fields=name&expand=verification", nil)
+			rec := httptest.NewRecorder()
+			c := e.NewContext(req, rec)
+			err := userHandler.GetUsers(c)
+			Expect(err).To(BeNil())
+			Expect(c.Response().Status).To(Equal(200))
+			var body struct {
+				Items []struct {
+					Name         string              `json:"name"`
+					Verification models.Verification `json:"verification"`
+				} `json:"items"`
+			}
+			Expect(json.Unmarshal(rec.Body.Bytes(), &body)).To(Succeed())
+			Expect(body.Items).To(HaveLen(3))
+			Expect(body.Items[0].Verification.State).To(Equal(models.VerificationPending))
+			Expect(body.Items[1].Verification.State).To(Equal(models.VerificationUnverified))
+			Expect(body.Items[2].Verification.State).To(Equal(models.VerificationExpired))
+		})
+
+		It("with a field or an expansion that don't exist", func(ctx SpecContext) {
+			for _, query := range []string{"fields=password", "expand=friends"} {
+				req := httptest.NewRequest(http.MethodGet, "/users/1?"+query, nil)
+				rec := httptest.NewRecorder()
+				c := e.NewContext(req, rec)
+				c.SetPath("/:id")
+				c.SetParamNames("id")
+				c.SetParamValues("1")
+				err := userHandler.GetById(c)
+				Expect(err).To(BeNil())
+				Expect(c.Response().Status).To(Equal(400))
+			}
 		})
 
 		It("path param id is missing", func(ctx SpecContext) {
@@ -265,11 +723,24 @@ var _ = Describe("Test all handlers methods", func() {
 			Expect(c.Response()).ToNot(BeNil())
 			Expect(c.Response().Status).To(Equal(500))
 		})
+
+		It("user not found", func(ctx SpecContext) {
+			userRepo.EXPECT().GetByID(gomock.Any(), gomock.Any()).Return(nil, errx.NotFound.New("User not found with id 1"))
+			req := httptest.NewRequest(http.MethodGet, "/users", nil)
+			rec := httptest.NewRecorder()
+			c := e.NewContext(req, rec)
+			c.SetPath("/:id")
+			c.SetParamNames("id")
+			c.SetParamValues("1")
+			err := userHandler.GetById(c)
+			Expect(err).To(BeNil())
+			Expect(c.Response().Status).To(Equal(404))
+		})
 	})
 
 	Context("Call get all users handler", func() {
 		It("successfully", func(ctx SpecContext) {
-			userRepo.EXPECT().GetUsers(gomock.Any(), gomock.Any(), gomock.Any()).Return([]*models.User{}, nil)
+			userRepo.EXPECT().GetUsers(gomock.Any(), gomock.Any(), models.Pagination{Size: 10}, false).Return(&models.UserPage{Items: []*models.User{}}, nil)
 			validator.EXPECT().ValidateStruct(gomock.Any()).Return(nil)
 			q := make(url.Values)
 			q.Set("page", "0")
@@ -282,30 +753,186 @@ var _ = Describe("Test all handlers methods", func() {
 			Expect(c.Response()).ToNot(BeNil())
 			Expect(c.Response().Status).To(Equal(200))
 		})
+
+		It("with filters and sorting", func(ctx SpecContext) {
+			userRepo.EXPECT().GetUsers(gomock.Any(), gomock.Any(), models.Pagination{Size: 10}, false).
+				DoAndReturn(func(_ interface{}, query *repo.Query, _ models.Pagination, _ bool) (*models.UserPage, error) {
+					Expect(query.Ordered("created_at")).To(BeTrue())
+					return &models.UserPage{Items: []*models.User{}}, nil
+				})
+			validator.EXPECT().ValidateStruct(gomock.Any()).Return(nil)
+			req := httptest.NewRequest(http.MethodGet, "/users?page=0&size=10&email=jon@email.com&age_gte=18&sort=-created_at", nil)
+			rec := httptest.NewRecorder()
+			c := e.NewContext(req, rec)
+			err := userHandler.GetUsers(c)
+			Expect(err).To(BeNil())
+			Expect(c.Response().Status).To(Equal(200))
+		})
+
+		It("with the page links", func(ctx SpecContext) {
+			userRepo.EXPECT().GetUsers(gomock.Any(), gomock.Any(), models.Pagination{Size: 10, After: "b"}, false).
+				Return(&models.UserPage{Items: []*models.User{&mockUser}, Next: "c", Prev: "b1"}, nil)
+			validator.EXPECT().ValidateStruct(gomock.Any()).Return(nil)
+			req := httptest.NewRequest(http.MethodGet, "/users?size=10&name=Jon&after=b", nil)
+			rec := httptest.NewRecorder()
+			c := e.NewContext(req, rec)
+			err := userHandler.GetUsers(c)
+			Expect(err).To(BeNil())
+			Expect(c.Response().Status).To(Equal(200))
+			Expect(rec.Header().Get("Link")).To(Equal(`</users?after=c&name=Jon&size=10>; rel="next", ` +
+				`</users?before=b1&name=Jon&size=10>; rel="prev", </users?name=Jon&size=10>; rel="first"`))
+
+			var page models.UserPage
+			Expect(json.Unmarshal(rec.Body.Bytes(), &page)).To(Succeed())
+			Expect(page.Items).To(HaveLen(1))
+			Expect(page.Next).To(Equal("c"))
+		})
+
+		It("with only some fields", func(ctx SpecContext) {
+			userRepo.EXPECT().GetUsers(gomock.Any(), gomock.Any(), models.Pagination{Size: 10}, false).
+				DoAndReturn(func(_ interface{}, query *repo.Query, _ models.Pagination, _ bool) (*models.UserPage, error) {
+					Expect(query.Selected()).To(Equal([]string{"user_id", "name"}))
+					return &models.UserPage{Items: []*models.User{&mockUser}, Next: "c"}, nil
+				})
+			validator.EXPECT().ValidateStruct(gomock.Any()).Return(nil)
+			req := httptest.NewRequest(http.MethodGet, "/users?size=10&fields=user_id,name", nil)
+			rec := httptest.NewRecorder()
+			c := e.NewContext(req, rec)
+			err := userHandler.GetUsers(c)
+			Expect(err).To(BeNil())
+			Expect(c.Response().Status).To(Equal(200))
+			Expect(rec.Body.String()).To(MatchJSON(`{"items":[{"user_id":1,"name":"Jon Snow"}],"next":"c"}`))
+		})
+
+		It("with a page that isn't valid", func(ctx SpecContext) {
+			validator.EXPECT().ValidateStruct(gomock.Any()).Return(errx.Validation.New("size must be at least 10"))
+			req := httptest.NewRequest(http.MethodGet, "/users?page=0&size=5", nil)
+			rec := httptest.NewRecorder()
+			c := e.NewContext(req, rec)
+			err := userHandler.GetUsers(c)
+			Expect(err).To(BeNil())
+			Expect(c.Response().Status).To(Equal(400))
+		})
+
+		It("with a page along with a cursor", func(ctx SpecContext) {
+			validator.EXPECT().ValidateStruct(gomock.Any()).Return(nil)
+			req := httptest.NewRequest(http.MethodGet, "/users?page=2&size=10&after=b", nil)
+			rec := httptest.NewRecorder()
+			c := e.NewContext(req, rec)
+			err := userHandler.GetUsers(c)
+			Expect(err).To(BeNil())
+			Expect(c.Response().Status).To(Equal(400))
+		})
+
+		It("sorting by a field that isn't allowed", func(ctx SpecContext) {
+			validator.EXPECT().ValidateStruct(gomock.Any()).Return(nil)
+			req := httptest.NewRequest(http.MethodGet, "/users?page=0&size=10&sort=password", nil)
+			rec := httptest.NewRecorder()
+			c := e.NewContext(req, rec)
+			err := userHandler.GetUsers(c)
+			Expect(err).To(BeNil())
+			Expect(c.Response().Status).To(Equal(400))
+			Expect(rec.Body.String()).To(ContainSubstring(`"field":"sort"`))
+		})
 	})
 
-	Context("Call token handler", func() {
-		It("successfully", func(ctx SpecContext) {
-			tokenJwt.EXPECT().GenerateToken(gomock.Any()).Return("", nil)
-			req := httptest.NewRequest(http.MethodGet, "/token", nil)
+	Context("Call get all users including deleted handler", func() {
+		var jwtToken auth.Token
+
+		BeforeEach(func() {
+			config := mock_config.NewMockConfigProvider(mockCtrl)
+			config.EXPECT().GetEnv("AUTH_SECRET").Return("secret").AnyTimes()
+			config.EXPECT().GetStringSlice("auth.jwt.admins").Return([]string{"admin@verifymy.com"}).AnyTimes()
+			jwtToken = auth.NewJwtToken(config)
+		})
+
+		It("successfully as admin", func(ctx SpecContext) {
+			userRepo.EXPECT().GetUsers(gomock.Any(), gomock.Any(), models.Pagination{Size: 10}, true).Return(&models.UserPage{Items: []*models.User{}}, nil)
+			validator.EXPECT().ValidateStruct(gomock.Any()).Return(nil)
+			token, err := jwtToken.GenerateToken("admin@verifymy.com")
+			Expect(err).To(BeNil())
+			req := httptest.NewRequest(http.MethodGet, "/users?page=0&size=10&include_deleted=true", nil)
+			req.Header.Set(echo.HeaderAuthorization, "Bearer "+token)
 			rec := httptest.NewRecorder()
 			c := e.NewContext(req, rec)
-			err := userHandler.Token(c)
+			err = jwtToken.VerifyToken(userHandler.GetUsers)(c)
 			Expect(err).To(BeNil())
-			Expect(c.Response()).ToNot(BeNil())
 			Expect(c.Response().Status).To(Equal(200))
 		})
 
-		It("generate token fails", func(ctx SpecContext) {
-			tokenJwt.EXPECT().GenerateToken(gomock.Any()).Return("", errors.New("mock error"))
-			req := httptest.NewRequest(http.MethodGet, "/token", nil)
-			req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+		It("forbidden without an admin token", func(ctx SpecContext) {
+			validator.EXPECT().ValidateStruct(gomock.Any()).Return(nil)
+			req := httptest.NewRequest(http.MethodGet, "/users?page=0&size=10&include_deleted=true", nil)
 			rec := httptest.NewRecorder()
 			c := e.NewContext(req, rec)
-			err := userHandler.Token(c)
+			err := userHandler.GetUsers(c)
 			Expect(err).To(BeNil())
-			Expect(c.Response()).ToNot(BeNil())
-			Expect(c.Response().Status).To(Equal(500))
+			Expect(c.Response().Status).To(Equal(403))
+		})
+	})
+
+	Context("Call token handler", func() {
+		var hashed []byte
+
+		BeforeEach(func() {
+			var err error
+			hashed, err = models.Hash("secret1")
+			Expect(err).To(BeNil())
+		})
+
+		newContext := func(body string) (echo.Context, *httptest.ResponseRecorder) {
+			req := httptest.NewRequest(http.MethodPost, "/token", strings.NewReader(body))
+			req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+			rec := httptest.NewRecorder()
+			return e.NewContext(req, rec), rec
+		}
+
+		It("successfully", func(ctx SpecContext) {
+			validator.EXPECT().ValidateStruct(gomock.Any()).Return(nil)
+			userRepo.EXPECT().GetByEmail(gomock.Any(), "jon@email.com").Return(&models.User{UserId: 1, Email: "jon@email.com", Password: string(hashed)}, nil)
+			tokenJwt.EXPECT().GenerateToken("jon@email.com").Return("token", nil)
+			c, rec := newContext(`{"email":"jon@email.com","password":"secret1"}`)
+			Expect(userHandler.Token(c)).To(Succeed())
+			Expect(rec.Code).To(Equal(200))
+			Expect(rec.Body.String()).To(ContainSubstring(`"token":"token"`))
+		})
+
+		It("successfully with basic auth", func(ctx SpecContext) {
+			validator.EXPECT().ValidateStruct(models.Credentials{Email: "jon@email.com", Password: "secret1"}).Return(nil)
+			userRepo.EXPECT().GetByEmail(gomock.Any(), "jon@email.com").Return(&models.User{UserId: 1, Email: "jon@email.com", Password: string(hashed)}, nil)
+			tokenJwt.EXPECT().GenerateToken("jon@email.com").Return("token", nil)
+			req := httptest.NewRequest(http.MethodGet, "/token", nil)
+			req.SetBasicAuth("jon@email.com", "secret1")
+			rec := httptest.NewRecorder()
+			Expect(userHandler.Token(e.NewContext(req, rec))).To(Succeed())
+			Expect(rec.Code).To(Equal(200))
+			Expect(rec.Body.String()).To(ContainSubstring(`"token":"token"`))
+		})
+
+		It("wrong password", func(ctx SpecContext) {
+			validator.EXPECT().ValidateStruct(gomock.Any()).Return(nil)
+			userRepo.EXPECT().GetByEmail(gomock.Any(), "jon@email.com").Return(&models.User{UserId: 1, Email: "jon@email.com", Password: string(hashed)}, nil)
+			c, rec := newContext(`{"email":"jon@email.com","password":"secret2"}`)
+			Expect(userHandler.Token(c)).To(Succeed())
+			Expect(rec.Code).To(Equal(401))
+		})
+
+		It("unknown email looks like a wrong password", func(ctx SpecContext) {
+			validator.EXPECT().ValidateStruct(gomock.Any()).Return(nil)
+			userRepo.EXPECT().GetByEmail(gomock.Any(), "arya@email.com").Return(nil, errx.NotFound.New("User not found with email arya@email.com"))
+			c, rec := newContext(`{"email":"arya@email.com","password":"secret1"}`)
+			Expect(userHandler.Token(c)).To(Succeed())
+			Expect(rec.Code).To(Equal(401))
+			Expect(rec.Body.String()).To(ContainSubstring("email or password is not valid"))
+		})
+
+		It("generate token fails", func(ctx SpecContext) {
+			validator.EXPECT().ValidateStruct(gomock.Any()).Return(nil)
+			userRepo.EXPECT().GetByEmail(gomock.Any(), "jon@email.com").Return(&models.User{UserId: 1, Email: "jon@email.com", Password: string(hashed)}, nil)
+			tokenJwt.EXPECT().GenerateToken(gomock.Any()).Return("", errors.New("mock error"))
+			c, rec := newContext(`{"email":"jon@email.com","password":"secret1"}`)
+			Expect(userHandler.Token(c)).To(Succeed())
+			Expect(rec.Code).To(Equal(500))
 		})
 	})
 
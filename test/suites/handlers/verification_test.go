@@ -0,0 +1,115 @@
+package handlers_test
+
+import (
+	"github.com/golang/mock/gomock"
+	"github.com/labstack/echo/v4"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	errx "github.com/rhuandantas/verifymy-test/internal/errors"
+	"github.com/rhuandantas/verifymy-test/internal/i18n"
+	"github.com/rhuandantas/verifymy-test/internal/models"
+	"github.com/rhuandantas/verifymy-test/internal/server/handlers"
+	"github.com/rhuandantas/verifymy-test/internal/util"
+	"github.com/rhuandantas/verifymy-test/internal/verification"
+	mock_auth "github.com/rhuandantas/verifymy-test/test/mock/auth"
+	mock_clients "github.com/rhuandantas/verifymy-test/test/mock/clients"
+	mock_config "github.com/rhuandantas/verifymy-test/test/mock/config"
+	mock_verification "github.com/rhuandantas/verifymy-test/test/mock/verification"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+)
+
+var _ = Describe("Test verification handler", func() {
+	var (
+		mockCtrl            *gomock.Controller
+		e                   *echo.Echo
+		service             *mock_verification.MockService
+		checks              *mock_verification.MockChecks
+		verificationHandler *handlers.VerificationHandler
+	)
+
+	BeforeEach(func() {
+		e = echo.New()
+		mockCtrl = gomock.NewController(GinkgoT())
+		service = mock_verification.NewMockService(mockCtrl)
+		checks = mock_verification.NewMockChecks(mockCtrl)
+		config := mock_config.NewMockConfigProvider(mockCtrl)
+		config.EXPECT().GetStringOrDefault("i18n.default-locale", gomock.Any()).Return("en")
+		config.EXPECT().GetStringOrDefault("i18n.path", gomock.Any()).Return("../../../resources/i18n")
+		translator, err := i18n.NewCatalogTranslator(config)
+		Expect(err).To(BeNil())
+		verificationHandler = handlers.NewVerificationHandler(util.NewCustomValidator(translator), service, checks,
+			mock_clients.NewMockMeter(mockCtrl), mock_auth.NewMockToken(mockCtrl), mock_auth.NewMockSignature(mockCtrl))
+	})
+
+	AfterEach(func() {
+		e.Close()
+	})
+
+	newContext := func(method, body string) (echo.Context, *httptest.ResponseRecorder) {
+		req := httptest.NewRequest(method, "/users/1/verification", strings.NewReader(body))
+		req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+		rec := httptest.NewRecorder()
+		c := e.NewContext(req, rec)
+		c.SetParamNames("id")
+		c.SetParamValues("1")
+		return c, rec
+	}
+
+	It("returns the verification with its history", func() {
+		service.EXPECT().Get(gomock.Any(), 1).Return(&models.VerificationStatus{
+			Verification: &models.Verification{UserId: 1, State: models.VerificationPending},
+			History:      []*models.VerificationEvent{{UserId: 1, From: models.VerificationUnverified, To: models.VerificationPending}},
+		}, nil)
+		c, rec := newContext(http.MethodGet, "")
+		Expect(verificationHandler.Get(c)).To(Succeed())
+		Expect(rec.Code).To(Equal(http.StatusOK))
+		Expect(rec.Body.String()).To(ContainSubstring(`"state":"pending"`))
+		Expect(rec.Body.String()).To(ContainSubstring(`"from":"unverified"`))
+	})
+
+	It("moves the verification on", func() {
+		service.EXPECT().Transition(gomock.Any(), 1, models.VerificationTransition{State: models.VerificationPending, Method: models.MethodDocument}, gomock.Any()).
+			Return(&models.VerificationStatus{Verification: &models.Verification{UserId: 1, State: models.VerificationPending}}, nil)
+		c, rec := newContext(http.MethodPost, `{"state":"pending","method":"document"}`)
+		Expect(verificationHandler.Transition(c)).To(Succeed())
+		Expect(rec.Code).To(Equal(http.StatusOK))
+	})
+
+	It("rejects unknown states and methods", func() {
+		c, rec := newContext(http.MethodPost, `{"state":"approved","method":"selfie"}`)
+		Expect(verificationHandler.Transition(c)).To(Succeed())
+		Expect(rec.Code).To(Equal(http.StatusBadRequest))
+	})
+
+	It("reports transitions the state doesn't allow as conflicts", func() {
+		service.EXPECT().Transition(gomock.Any(), 1, gomock.Any(), gomock.Any()).
+			Return(nil, errx.InvalidTransition.New("verification can't go from unverified to verified"))
+		c, rec := newContext(http.MethodPost, `{"state":"verified"}`)
+		Expect(verificationHandler.Transition(c)).To(Succeed())
+		Expect(rec.Code).To(Equal(http.StatusConflict))
+		Expect(rec.Body.String()).To(ContainSubstring("common.conflict.invalid_transition"))
+	})
+
+	It("starts a check with the named provider", func() {
+		checks.EXPECT().Start(gomock.Any(), 1, "fake", gomock.Any()).Return(&verification.Check{
+			Session:      &verification.Session{Provider: "fake", Reference: "fake-1-1"},
+			Verification: &models.VerificationStatus{Verification: &models.Verification{UserId: 1, State: models.VerificationPending}},
+		}, nil)
+		c, rec := newContext(http.MethodPost, `{"provider":"fake"}`)
+		Expect(verificationHandler.Start(c)).To(Succeed())
+		Expect(rec.Code).To(Equal(http.StatusOK))
+		Expect(rec.Body.String()).To(ContainSubstring(`"reference":"fake-1-1"`))
+	})
+
+	It("passes callbacks to the provider as they came", func() {
+		checks.EXPECT().Callback(gomock.Any(), "fake", gomock.Any(), []byte(`{"reference":"fake-1-1","status":"verified"}`)).
+			Return(nil, errx.Unauthorized.New("callback signature is not valid"))
+		c, rec := newContext(http.MethodPost, `{"reference":"fake-1-1","status":"verified"}`)
+		c.SetParamNames("provider")
+		c.SetParamValues("fake")
+		Expect(verificationHandler.Callback(c)).To(Succeed())
+		Expect(rec.Code).To(Equal(http.StatusUnauthorized))
+	})
+})
@@ -0,0 +1,52 @@
+package i18n_test
+
+import (
+	"github.com/golang/mock/gomock"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"github.com/rhuandantas/verifymy-test/internal/i18n"
+	mock_config "github.com/rhuandantas/verifymy-test/test/mock/config"
+)
+
+var _ = Describe("Test translator methods", func() {
+	var (
+		mockCtrl   *gomock.Controller
+		config     *mock_config.MockConfigProvider
+		translator i18n.Translator
+	)
+
+	BeforeEach(func() {
+		var err error
+		mockCtrl = gomock.NewController(GinkgoT())
+		config = mock_config.NewMockConfigProvider(mockCtrl)
+		config.EXPECT().GetStringOrDefault("i18n.default-locale", gomock.Any()).Return("en")
+		config.EXPECT().GetStringOrDefault("i18n.path", gomock.Any()).Return("../../../resources/i18n")
+		translator, err = i18n.NewCatalogTranslator(config)
+		Expect(err).To(BeNil())
+	})
+
+	It("pick locale from accept language", func(ctx SpecContext) {
+		Expect(translator.Locale(i18n.AcceptLanguage("pt-BR,pt;q=0.9,en;q=0.8")...)).To(Equal("pt"))
+		Expect(translator.Locale(i18n.AcceptLanguage("es-AR")...)).To(Equal("es"))
+	})
+
+	It("fall back to the default locale", func(ctx SpecContext) {
+		Expect(translator.Locale(i18n.AcceptLanguage("de-DE")...)).To(Equal("en"))
+		Expect(translator.Locale(i18n.AcceptLanguage("not a header;;")...)).To(Equal("en"))
+	})
+
+	It("translate messages with params", func(ctx SpecContext) {
+		message, ok := translator.Translate("pt", "validation.min", "size", "10")
+		Expect(ok).To(BeTrue())
+		Expect(message).To(Equal("size deve ser no mínimo 10"))
+	})
+
+	It("translate unknown rules with the default message", func(ctx SpecContext) {
+		Expect(i18n.FieldMessage(translator, "es", "email", "unknown_rule", "")).To(Equal("email falló en la regla unknown_rule"))
+	})
+
+	It("report missing keys", func(ctx SpecContext) {
+		_, ok := translator.Translate("pt", "missing.key")
+		Expect(ok).To(BeFalse())
+	})
+})
@@ -0,0 +1,84 @@
+package importer_test
+
+import (
+	"github.com/joomcode/errorx"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	errx "github.com/rhuandantas/verifymy-test/internal/errors"
+	"github.com/rhuandantas/verifymy-test/internal/importer"
+	"github.com/rhuandantas/verifymy-test/internal/models"
+	"strings"
+)
+
+var _ = Describe("Test import readers", func() {
+	Context("Read csv", func() {
+		It("successfully with mapped and matched headers", func() {
+			csv := "Full Name,Email,Born,Notes\nJon Snow, jon@email.com ,1993-05-17,x\nArya,arya@email.com,,y\n"
+			rows, err := importer.ReadCSV(strings.NewReader(csv), map[string]string{"Full Name": "name", "Born": "date_of_birth", "Notes": importer.SkipColumn}, 10)
+			Expect(err).To(BeNil())
+			Expect(rows).To(HaveLen(2))
+			Expect(rows[0].Line).To(Equal(2))
+			Expect(rows[0].User.Name).To(Equal("Jon Snow"))
+			Expect(rows[0].User.Email).To(Equal("jon@email.com"))
+			Expect(*rows[0].User.DateOfBirth).To(Equal(models.NewDate(1993, 5, 17)))
+			Expect(rows[1].Line).To(Equal(3))
+			Expect(rows[1].User.DateOfBirth).To(BeNil())
+		})
+
+		It("reports bad lines and keeps going", func() {
+			csv := "email,date_of_birth\na@email.com,17/05/1993\nb@email.com\nc@email.com,1993-05-17\n"
+			rows, err := importer.ReadCSV(strings.NewReader(csv), nil, 10)
+			Expect(err).To(BeNil())
+			Expect(rows).To(HaveLen(3))
+			Expect(rows[0].Err).ToNot(BeNil())
+			Expect(rows[1].Err).ToNot(BeNil())
+			Expect(rows[1].Line).To(Equal(3))
+			Expect(rows[2].Err).To(BeNil())
+		})
+
+		It("with a header that doesn't map to a field", func() {
+			_, err := importer.ReadCSV(strings.NewReader("email,phone\n"), nil, 10)
+			Expect(errorx.IsOfType(err, errx.BadRequest)).To(BeTrue())
+		})
+
+		It("with the computed age", func() {
+			_, err := importer.ReadCSV(strings.NewReader("email,age\n"), nil, 10)
+			Expect(errorx.IsOfType(err, errx.BadRequest)).To(BeTrue())
+		})
+
+		It("without an email column", func() {
+			_, err := importer.ReadCSV(strings.NewReader("name\nJon\n"), nil, 10)
+			Expect(errorx.IsOfType(err, errx.BadRequest)).To(BeTrue())
+		})
+
+		It("with too many rows", func() {
+			_, err := importer.ReadCSV(strings.NewReader("email\na@email.com\nb@email.com\n"), nil, 1)
+			Expect(errorx.IsOfType(err, errx.BadRequest)).To(BeTrue())
+		})
+	})
+
+	Context("Read ndjson", func() {
+		It("successfully skipping blank lines", func() {
+			ndjson := `{"name":"Jon","email":"jon@email.com","date_of_birth":"1993-05-17","timezone":"Europe/London"}` + "\n\n" + `{"email":"arya@email.com","password":"needle123","guardian_email":"ned@email.com"}` + "\n"
+			rows, err := importer.ReadNDJSON(strings.NewReader(ndjson), 10)
+			Expect(err).To(BeNil())
+			Expect(rows).To(HaveLen(2))
+			Expect(*rows[0].User.DateOfBirth).To(Equal(models.NewDate(1993, 5, 17)))
+			Expect(rows[0].User.Timezone).To(Equal("Europe/London"))
+			Expect(rows[1].Line).To(Equal(3))
+			Expect(rows[1].User.Password).To(Equal("needle123"))
+			Expect(rows[1].User.GuardianEmail).To(Equal("ned@email.com"))
+		})
+
+		It("rejects fields that can't be imported", func() {
+			rows, err := importer.ReadNDJSON(strings.NewReader(`{"email":"jon@email.com","user_id":7}`), 10)
+			Expect(err).To(BeNil())
+			Expect(errorx.IsOfType(rows[0].Err, errx.BadRequest)).To(BeTrue())
+		})
+
+		It("with too many rows", func() {
+			_, err := importer.ReadNDJSON(strings.NewReader("{}\n{}\n"), 1)
+			Expect(errorx.IsOfType(err, errx.BadRequest)).To(BeTrue())
+		})
+	})
+})
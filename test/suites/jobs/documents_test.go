@@ -0,0 +1,50 @@
+package jobs_test
+
+import (
+	"errors"
+	"time"
+
+	"github.com/golang/mock/gomock"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"github.com/rhuandantas/verifymy-test/internal/jobs"
+	mock_config "github.com/rhuandantas/verifymy-test/test/mock/config"
+	mock_log "github.com/rhuandantas/verifymy-test/test/mock/log"
+	mock_verification "github.com/rhuandantas/verifymy-test/test/mock/verification"
+)
+
+var _ = Describe("Test document purge job", func() {
+	var (
+		mockCtrl      *gomock.Controller
+		logger        *mock_log.MockSimpleLogger
+		documents     *mock_verification.MockDocuments
+		documentPurge *jobs.DocumentPurge
+	)
+
+	BeforeEach(func() {
+		mockCtrl = gomock.NewController(GinkgoT())
+		config := mock_config.NewMockConfigProvider(mockCtrl)
+		logger = mock_log.NewMockSimpleLogger(mockCtrl)
+		documents = mock_verification.NewMockDocuments(mockCtrl)
+		config.EXPECT().GetInt("verification.documents.purge-interval-minutes").Return(60)
+		documentPurge = jobs.NewDocumentPurge(config, documents, logger)
+	})
+
+	It("read interval from config", func() {
+		Expect(documentPurge.Interval()).To(Equal(time.Hour))
+	})
+
+	It("purge documents past their retention", func(ctx SpecContext) {
+		documents.EXPECT().Purge(gomock.Any(), gomock.Any()).DoAndReturn(func(_ interface{}, now time.Time) (int, error) {
+			Expect(now).To(BeTemporally("~", time.Now(), time.Minute))
+			return 3, nil
+		})
+		logger.EXPECT().Infof(gomock.Any(), 3)
+		Expect(documentPurge.Run(ctx)).To(Succeed())
+	})
+
+	It("with fail", func(ctx SpecContext) {
+		documents.EXPECT().Purge(gomock.Any(), gomock.Any()).Return(0, errors.New("mock error"))
+		Expect(documentPurge.Run(ctx)).ToNot(Succeed())
+	})
+})
@@ -0,0 +1,77 @@
+package jobs_test
+
+import (
+	"errors"
+	"time"
+
+	"github.com/golang/mock/gomock"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"github.com/rhuandantas/verifymy-test/internal/jobs"
+	mock_config "github.com/rhuandantas/verifymy-test/test/mock/config"
+	mock_guardian "github.com/rhuandantas/verifymy-test/test/mock/guardian"
+	mock_log "github.com/rhuandantas/verifymy-test/test/mock/log"
+	mock_repo "github.com/rhuandantas/verifymy-test/test/mock/repo"
+	mock_verification "github.com/rhuandantas/verifymy-test/test/mock/verification"
+)
+
+var _ = Describe("Test user purge job", func() {
+	var (
+		mockCtrl  *gomock.Controller
+		config    *mock_config.MockConfigProvider
+		logger    *mock_log.MockSimpleLogger
+		userRepo  *mock_repo.MockUserRepo
+		consents  *mock_guardian.MockConsents
+		documents *mock_verification.MockDocuments
+		userPurge *jobs.UserPurge
+	)
+
+	BeforeEach(func() {
+		mockCtrl = gomock.NewController(GinkgoT())
+		config = mock_config.NewMockConfigProvider(mockCtrl)
+		logger = mock_log.NewMockSimpleLogger(mockCtrl)
+		userRepo = mock_repo.NewMockUserRepo(mockCtrl)
+		consents = mock_guardian.NewMockConsents(mockCtrl)
+		documents = mock_verification.NewMockDocuments(mockCtrl)
+		config.EXPECT().GetInt("users.purge.retention-hours").Return(24)
+		config.EXPECT().GetInt("users.purge.interval-minutes").Return(60)
+		userPurge = jobs.NewUserPurge(config, userRepo, consents, documents, logger)
+	})
+
+	It("read interval from config", func(ctx SpecContext) {
+		Expect(userPurge.Interval()).To(Equal(time.Hour))
+	})
+
+	It("purge users deleted before the retention period", func(ctx SpecContext) {
+		userRepo.EXPECT().Purgeable(gomock.Any(), gomock.Any()).Return([]int{1, 2}, nil)
+		consents.EXPECT().Withdraw(gomock.Any(), 1, "system").Return(nil)
+		consents.EXPECT().Withdraw(gomock.Any(), 2, "system").Return(nil)
+		documents.EXPECT().DeleteAll(gomock.Any(), 1, "system").Return(2, nil)
+		documents.EXPECT().DeleteAll(gomock.Any(), 2, "system").Return(0, nil)
+		userRepo.EXPECT().Purge(gomock.Any(), gomock.Any()).DoAndReturn(func(_ interface{}, deletedBefore time.Time) (int64, error) {
+			Expect(deletedBefore).To(BeTemporally("~", time.Now().Add(-24*time.Hour), time.Minute))
+			return 2, nil
+		})
+		logger.EXPECT().Infof(gomock.Any(), gomock.Any())
+		Expect(userPurge.Run(ctx)).To(Succeed())
+	})
+
+	It("keeps the guardians whose consents can't be withdrawn", func(ctx SpecContext) {
+		userRepo.EXPECT().Purgeable(gomock.Any(), gomock.Any()).Return([]int{1}, nil)
+		consents.EXPECT().Withdraw(gomock.Any(), 1, "system").Return(errors.New("mock error"))
+		Expect(userPurge.Run(ctx)).ToNot(Succeed())
+	})
+
+	It("keeps the users whose documents can't be deleted", func(ctx SpecContext) {
+		userRepo.EXPECT().Purgeable(gomock.Any(), gomock.Any()).Return([]int{1}, nil)
+		consents.EXPECT().Withdraw(gomock.Any(), 1, "system").Return(nil)
+		documents.EXPECT().DeleteAll(gomock.Any(), 1, "system").Return(0, errors.New("mock error"))
+		Expect(userPurge.Run(ctx)).ToNot(Succeed())
+	})
+
+	It("with fail", func(ctx SpecContext) {
+		userRepo.EXPECT().Purgeable(gomock.Any(), gomock.Any()).Return([]int{}, nil)
+		userRepo.EXPECT().Purge(gomock.Any(), gomock.Any()).Return(int64(0), errors.New("mock error"))
+		Expect(userPurge.Run(ctx)).ToNot(Succeed())
+	})
+})
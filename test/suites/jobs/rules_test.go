@@ -0,0 +1,25 @@
+package jobs_test
+
+import (
+	"time"
+
+	"github.com/golang/mock/gomock"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"github.com/rhuandantas/verifymy-test/internal/jobs"
+	mock_config "github.com/rhuandantas/verifymy-test/test/mock/config"
+	mock_rules "github.com/rhuandantas/verifymy-test/test/mock/rules"
+)
+
+var _ = Describe("Test rules reload job", func() {
+	It("reloads the rules every interval", func(ctx SpecContext) {
+		mockCtrl := gomock.NewController(GinkgoT())
+		config := mock_config.NewMockConfigProvider(mockCtrl)
+		config.EXPECT().GetInt("rules.reload-seconds").Return(30)
+		engine := mock_rules.NewMockEngine(mockCtrl)
+		engine.EXPECT().Reload(gomock.Any()).Return(nil)
+		rulesReload := jobs.NewRulesReload(config, engine)
+		Expect(rulesReload.Interval()).To(Equal(30 * time.Second))
+		Expect(rulesReload.Run(ctx)).To(Succeed())
+	})
+})
@@ -0,0 +1,52 @@
+package jobs_test
+
+import (
+	"errors"
+	"time"
+
+	"github.com/golang/mock/gomock"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"github.com/rhuandantas/verifymy-test/internal/jobs"
+	mock_clients "github.com/rhuandantas/verifymy-test/test/mock/clients"
+	mock_config "github.com/rhuandantas/verifymy-test/test/mock/config"
+)
+
+var _ = Describe("Test usage flush job", func() {
+	var (
+		mockCtrl *gomock.Controller
+		config   *mock_config.MockConfigProvider
+		meter    *mock_clients.MockMeter
+	)
+
+	BeforeEach(func() {
+		mockCtrl = gomock.NewController(GinkgoT())
+		config = mock_config.NewMockConfigProvider(mockCtrl)
+		meter = mock_clients.NewMockMeter(mockCtrl)
+	})
+
+	It("flush every minute by default", func() {
+		config.EXPECT().GetInt("clients.usage.flush-seconds").Return(0)
+		Expect(jobs.NewUsageFlush(config, meter).Interval()).To(Equal(time.Minute))
+	})
+
+	It("flush the usage of the meter", func(ctx SpecContext) {
+		config.EXPECT().GetInt("clients.usage.flush-seconds").Return(30)
+		usageFlush := jobs.NewUsageFlush(config, meter)
+		Expect(usageFlush.Interval()).To(Equal(30 * time.Second))
+		meter.EXPECT().Flush(gomock.Any()).Return(nil)
+		Expect(usageFlush.Run(ctx)).To(Succeed())
+	})
+
+	It("flush the usage of the meter once more when finalized", func(ctx SpecContext) {
+		config.EXPECT().GetInt("clients.usage.flush-seconds").Return(60)
+		meter.EXPECT().Flush(gomock.Any()).Return(nil)
+		Expect(jobs.NewUsageFlush(config, meter).Finalize(ctx)).To(Succeed())
+	})
+
+	It("with fail", func(ctx SpecContext) {
+		config.EXPECT().GetInt("clients.usage.flush-seconds").Return(60)
+		meter.EXPECT().Flush(gomock.Any()).Return(errors.New("mock error"))
+		Expect(jobs.NewUsageFlush(config, meter).Run(ctx)).ToNot(Succeed())
+	})
+})
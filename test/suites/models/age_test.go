@@ -0,0 +1,68 @@
+package models_test
+
+import (
+	"encoding/json"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"github.com/rhuandantas/verifymy-test/internal/models"
+	"time"
+)
+
+var _ = Describe("Test ages", func() {
+	It("turns a year older on the birthday", func() {
+		birth := models.NewDate(2005, 6, 15)
+		Expect(models.AgeOn(birth, models.NewDate(2023, 6, 14))).To(Equal(17))
+		Expect(models.AgeOn(birth, models.NewDate(2023, 6, 15))).To(Equal(18))
+		Expect(models.AgeOn(birth, models.NewDate(2023, 12, 31))).To(Equal(18))
+	})
+
+	It("turns a year older on march 1 when born on a leap day", func() {
+		birth := models.NewDate(2004, 2, 29)
+		Expect(models.AgeOn(birth, models.NewDate(2022, 2, 28))).To(Equal(17))
+		Expect(models.AgeOn(birth, models.NewDate(2022, 3, 1))).To(Equal(18))
+		Expect(models.AgeOn(birth, models.NewDate(2024, 2, 29))).To(Equal(20))
+	})
+
+	It("finds the latest date of birth of an age", func() {
+		Expect(models.LatestBirthDate(18, models.NewDate(2023, 6, 15))).To(Equal(models.NewDate(2005, 6, 15)))
+		// 2006 has no february 29, someone born on the 28th is already 18 and someone born on march 1 isn't
+		Expect(models.LatestBirthDate(18, models.NewDate(2024, 2, 29))).To(Equal(models.NewDate(2006, 2, 28)))
+	})
+
+	It("computes the age in the timezone of the user", func() {
+		birth := models.NewDate(2005, 6, 15)
+		// still june 14 in new york
+		now := time.Date(2023, 6, 15, 2, 0, 0, 0, time.UTC)
+		Expect(*(&models.User{DateOfBirth: &birth}).AgeAt(now)).To(Equal(18))
+		Expect(*(&models.User{DateOfBirth: &birth, Timezone: "America/New_York"}).AgeAt(now)).To(Equal(17))
+		Expect((&models.User{}).AgeAt(now)).To(BeNil())
+	})
+
+	It("writes the current age whatever the client sent", func() {
+		var user models.User
+		Expect(json.Unmarshal([]byte(`{"email":"jon@email.com","date_of_birth":"1993-05-17","age":7}`), &user)).To(Succeed())
+		Expect(*user.DateOfBirth).To(Equal(models.NewDate(1993, 5, 17)))
+
+		content, err := json.Marshal(user)
+		Expect(err).To(BeNil())
+		var out map[string]interface{}
+		Expect(json.Unmarshal(content, &out)).To(Succeed())
+		Expect(out["date_of_birth"]).To(Equal("1993-05-17"))
+		Expect(out["age"]).To(BeEquivalentTo(*user.AgeAt(time.Now())))
+	})
+
+	It("reads dates from the database", func() {
+		var date models.Date
+		Expect(date.Scan([]byte("1993-05-17"))).To(Succeed())
+		Expect(date).To(Equal(models.NewDate(1993, 5, 17)))
+		Expect(date.Scan(time.Date(1993, 5, 17, 0, 0, 0, 0, time.Local))).To(Succeed())
+		Expect(date).To(Equal(models.NewDate(1993, 5, 17)))
+		Expect(date.Scan(17)).ToNot(Succeed())
+	})
+
+	It("rejects dates that aren't like 2006-01-02", func() {
+		var user models.User
+		Expect(json.Unmarshal([]byte(`{"date_of_birth":"17/05/1993"}`), &user)).ToNot(Succeed())
+		Expect(json.Unmarshal([]byte(`{"date_of_birth":19930517}`), &user)).ToNot(Succeed())
+	})
+})
@@ -0,0 +1,98 @@
+package patch_test
+
+import (
+	"github.com/joomcode/errorx"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	errx "github.com/rhuandantas/verifymy-test/internal/errors"
+	"github.com/rhuandantas/verifymy-test/internal/patch"
+)
+
+var _ = Describe("Test json patches", func() {
+	Context("Merge patch", func() {
+		It("replaces, adds and removes members", func() {
+			doc := `{"a":"b","c":{"d":"e","f":"g"}}`
+			res, err := patch.MergePatch([]byte(doc), []byte(`{"a":"z","c":{"f":null},"h":1}`))
+			Expect(err).To(BeNil())
+			Expect(res).To(MatchJSON(`{"a":"z","c":{"d":"e"},"h":1}`))
+		})
+
+		It("replaces arrays as a whole", func() {
+			res, err := patch.MergePatch([]byte(`{"a":["b","c"]}`), []byte(`{"a":["d"]}`))
+			Expect(err).To(BeNil())
+			Expect(res).To(MatchJSON(`{"a":["d"]}`))
+		})
+
+		It("replaces the document with a non object patch", func() {
+			res, err := patch.MergePatch([]byte(`{"a":"b"}`), []byte(`"c"`))
+			Expect(err).To(BeNil())
+			Expect(res).To(MatchJSON(`"c"`))
+		})
+
+		It("keeps large numbers as they are", func() {
+			res, err := patch.MergePatch([]byte(`{"id":9007199254740993}`), []byte(`{"a":1}`))
+			Expect(err).To(BeNil())
+			Expect(string(res)).To(ContainSubstring("9007199254740993"))
+		})
+
+		It("with invalid json", func() {
+			_, err := patch.MergePatch([]byte(`{}`), []byte(`{`))
+			Expect(errorx.IsOfType(err, errx.BadRequest)).To(BeTrue())
+		})
+	})
+
+	Context("Json patch", func() {
+		doc := []byte(`{"name":"Jon","tags":["a","b"],"address":{"city":"Winterfell"}}`)
+
+		It("applies every operation", func() {
+			res, err := patch.JSONPatch(doc, []byte(`[
+				{"op":"replace","path":"/name","value":"Arya"},
+				{"op":"add","path":"/tags/-","value":"c"},
+				{"op":"add","path":"/tags/0","value":"z"},
+				{"op":"remove","path":"/tags/1"},
+				{"op":"copy","from":"/address/city","path":"/city"},
+				{"op":"move","from":"/address","path":"/home"},
+				{"op":"test","path":"/home/city","value":"Winterfell"}
+			]`))
+			Expect(err).To(BeNil())
+			Expect(res).To(MatchJSON(`{"name":"Arya","tags":["z","b","c"],"city":"Winterfell","home":{"city":"Winterfell"}}`))
+		})
+
+		It("unescapes json pointer tokens", func() {
+			res, err := patch.JSONPatch([]byte(`{"a/b":1,"m~n":2}`), []byte(`[{"op":"replace","path":"/a~1b","value":3},{"op":"remove","path":"/m~0n"}]`))
+			Expect(err).To(BeNil())
+			Expect(res).To(MatchJSON(`{"a/b":3}`))
+		})
+
+		It("adds a null value", func() {
+			res, err := patch.JSONPatch(doc, []byte(`[{"op":"add","path":"/address","value":null}]`))
+			Expect(err).To(BeNil())
+			Expect(res).To(MatchJSON(`{"name":"Jon","tags":["a","b"],"address":null}`))
+		})
+
+		It("with a failed test operation", func() {
+			_, err := patch.JSONPatch(doc, []byte(`[{"op":"test","path":"/name","value":"Arya"}]`))
+			Expect(errorx.IsOfType(err, errx.Conflict)).To(BeTrue())
+		})
+
+		It("with a missing path", func() {
+			_, err := patch.JSONPatch(doc, []byte(`[{"op":"replace","path":"/missing","value":1}]`))
+			Expect(errorx.IsOfType(err, errx.ConstraintViolation)).To(BeTrue())
+		})
+
+		It("with an index out of bounds", func() {
+			_, err := patch.JSONPatch(doc, []byte(`[{"op":"remove","path":"/tags/5"}]`))
+			Expect(errorx.IsOfType(err, errx.ConstraintViolation)).To(BeTrue())
+		})
+
+		It("with an unknown operation", func() {
+			_, err := patch.JSONPatch(doc, []byte(`[{"op":"merge","path":"/name","value":1}]`))
+			Expect(errorx.IsOfType(err, errx.BadRequest)).To(BeTrue())
+		})
+
+		It("with a patch that isn't an array", func() {
+			_, err := patch.JSONPatch(doc, []byte(`{"op":"remove","path":"/name"}`))
+			Expect(errorx.IsOfType(err, errx.BadRequest)).To(BeTrue())
+		})
+	})
+})
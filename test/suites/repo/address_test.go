@@ -0,0 +1,132 @@
+package repo_test
+
+import (
+	"errors"
+	"github.com/go-sql-driver/mysql"
+	"github.com/golang/mock/gomock"
+	"github.com/joomcode/errorx"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	errx "github.com/rhuandantas/verifymy-test/internal/errors"
+	"github.com/rhuandantas/verifymy-test/internal/models"
+	"github.com/rhuandantas/verifymy-test/internal/repo"
+	mock_log "github.com/rhuandantas/verifymy-test/test/mock/log"
+	mock_repo "github.com/rhuandantas/verifymy-test/test/mock/repo"
+	"gorm.io/gorm"
+	"time"
+)
+
+var _ = Describe("Test all address repo methods", func() {
+	var (
+		mockCtrl    *gomock.Controller
+		db          *mock_repo.MockDBConnection
+		addressRepo repo.AddressRepo
+	)
+
+	BeforeEach(func() {
+		mockCtrl = gomock.NewController(GinkgoT())
+		db = mock_repo.NewMockDBConnection(mockCtrl)
+		addressRepo = repo.NewAddressRepo(db, mock_log.NewMockSimpleLogger(mockCtrl))
+	})
+
+	Context("List addresses", func() {
+		It("of a user", func(ctx SpecContext) {
+			db.EXPECT().Find(gomock.Any(), gomock.Any(), gomock.Any(), 0, 0).
+				DoAndReturn(func(_, dest interface{}, _ *repo.Query, _, _ int, _ ...string) *gorm.DB {
+					*dest.(*[]*models.Address) = []*models.Address{{AddressId: 1, UserId: 1, Type: models.AddressBilling}}
+					return &gorm.DB{Error: nil}
+				})
+			addresses, err := addressRepo.List(ctx, 1)
+			Expect(err).To(BeNil())
+			Expect(addresses).To(HaveLen(1))
+		})
+		It("of many users keyed by user", func(ctx SpecContext) {
+			db.EXPECT().Find(gomock.Any(), gomock.Any(), gomock.Any(), 0, 0).
+				DoAndReturn(func(_, dest interface{}, _ *repo.Query, _, _ int, _ ...string) *gorm.DB {
+					*dest.(*[]*models.Address) = []*models.Address{
+						{AddressId: 1, UserId: 1, Type: models.AddressBilling},
+						{AddressId: 2, UserId: 1, Type: models.AddressHome},
+						{AddressId: 3, UserId: 3, Type: models.AddressHome},
+					}
+					return &gorm.DB{Error: nil}
+				})
+			byUser, err := addressRepo.ListByUsers(ctx, []int{1, 2, 3})
+			Expect(err).To(BeNil())
+			Expect(byUser).To(HaveLen(2))
+			Expect(byUser[1]).To(HaveLen(2))
+			Expect(byUser[3]).To(HaveLen(1))
+		})
+		It("of no users without reading", func(ctx SpecContext) {
+			byUser, err := addressRepo.ListByUsers(ctx, nil)
+			Expect(err).To(BeNil())
+			Expect(byUser).To(BeEmpty())
+		})
+		It("with fail", func(ctx SpecContext) {
+			db.EXPECT().Find(gomock.Any(), gomock.Any(), gomock.Any(), 0, 0).Return(&gorm.DB{Error: errors.New("mock error")})
+			_, err := addressRepo.List(ctx, 1)
+			Expect(err).ToNot(BeNil())
+		})
+	})
+
+	Context("Get an address", func() {
+		It("successfully", func(ctx SpecContext) {
+			db.EXPECT().First(gomock.Any(), gomock.Any(), gomock.Any(), 2, 1).Return(&gorm.DB{Error: nil})
+			address, err := addressRepo.Get(ctx, 1, 2)
+			Expect(err).To(BeNil())
+			Expect(address).ToNot(BeNil())
+		})
+		It("of another user", func(ctx SpecContext) {
+			db.EXPECT().First(gomock.Any(), gomock.Any(), gomock.Any(), 2, 1).Return(&gorm.DB{Error: gorm.ErrRecordNotFound})
+			_, err := addressRepo.Get(ctx, 1, 2)
+			Expect(errorx.IsOfType(err, errx.NotFound)).To(BeTrue())
+		})
+	})
+
+	Context("Create an address", func() {
+		It("successfully", func(ctx SpecContext) {
+			db.EXPECT().Insert(gomock.Any(), gomock.Any()).Return(&gorm.DB{Error: nil})
+			address, err := addressRepo.Create(ctx, models.Address{AddressId: 9, UserId: 1, Type: models.AddressHome})
+			Expect(err).To(BeNil())
+			Expect(address.AddressId).To(Equal(0))
+		})
+		It("of a type the user already has", func(ctx SpecContext) {
+			db.EXPECT().Insert(gomock.Any(), gomock.Any()).Return(&gorm.DB{Error: &mysql.MySQLError{Number: 1062, Message: "Duplicate entry '1-home' for key 'addresses.idx_address_user_type'"}})
+			_, err := addressRepo.Create(ctx, models.Address{UserId: 1, Type: models.AddressHome})
+			Expect(errorx.IsOfType(err, errx.Conflict)).To(BeTrue())
+			Expect(errorx.Cast(err).Message()).To(Equal("user already has an address of this type"))
+		})
+	})
+
+	Context("Update an address", func() {
+		It("keeping when it was created", func(ctx SpecContext) {
+			createdAt := time.Date(2023, 1, 1, 0, 0, 0, 0, time.UTC)
+			db.EXPECT().First(gomock.Any(), gomock.Any(), gomock.Any(), 2, 1).
+				DoAndReturn(func(_, dest interface{}, _ ...interface{}) *gorm.DB {
+					*dest.(*models.Address) = models.Address{AddressId: 2, UserId: 1, CreatedAt: createdAt}
+					return &gorm.DB{Error: nil}
+				})
+			db.EXPECT().Update(gomock.Any(), gomock.Any()).Return(&gorm.DB{Error: nil})
+			address, err := addressRepo.Update(ctx, models.Address{AddressId: 2, UserId: 1, City: "Winterfell"})
+			Expect(err).To(BeNil())
+			Expect(address.CreatedAt).To(Equal(createdAt))
+			Expect(address.City).To(Equal("Winterfell"))
+		})
+		It("that doesn't exist", func(ctx SpecContext) {
+			db.EXPECT().First(gomock.Any(), gomock.Any(), gomock.Any(), 2, 1).Return(&gorm.DB{Error: gorm.ErrRecordNotFound})
+			_, err := addressRepo.Update(ctx, models.Address{AddressId: 2, UserId: 1})
+			Expect(errorx.IsOfType(err, errx.NotFound)).To(BeTrue())
+		})
+	})
+
+	Context("Delete an address", func() {
+		It("successfully", func(ctx SpecContext) {
+			db.EXPECT().Delete(gomock.Any(), gomock.Any(), gomock.Any(), 2, 1).Return(&gorm.DB{RowsAffected: 1})
+			Expect(addressRepo.Delete(ctx, 1, 2)).To(Succeed())
+		})
+		It("that doesn't exist", func(ctx SpecContext) {
+			db.EXPECT().Delete(gomock.Any(), gomock.Any(), gomock.Any(), 2, 1).Return(&gorm.DB{RowsAffected: 0})
+			err := addressRepo.Delete(ctx, 1, 2)
+			Expect(errorx.IsOfType(err, errx.NotFound)).To(BeTrue())
+		})
+	})
+})
@@ -0,0 +1,40 @@
+package repo_test
+
+import (
+	"github.com/golang/mock/gomock"
+	"github.com/joomcode/errorx"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	errx "github.com/rhuandantas/verifymy-test/internal/errors"
+	"github.com/rhuandantas/verifymy-test/internal/repo"
+	mock_log "github.com/rhuandantas/verifymy-test/test/mock/log"
+	mock_repo "github.com/rhuandantas/verifymy-test/test/mock/repo"
+	"gorm.io/gorm"
+)
+
+var _ = Describe("Test all client repo methods", func() {
+	var (
+		mockCtrl   *gomock.Controller
+		db         *mock_repo.MockDBConnection
+		clientRepo repo.ClientRepo
+	)
+
+	BeforeEach(func() {
+		mockCtrl = gomock.NewController(GinkgoT())
+		db = mock_repo.NewMockDBConnection(mockCtrl)
+		clientRepo = repo.NewClientRepo(db, mock_log.NewMockSimpleLogger(mockCtrl))
+	})
+
+	It("gets a client by id", func(ctx SpecContext) {
+		db.EXPECT().First(gomock.Any(), gomock.Any(), "client_id = ?", "shop").Return(&gorm.DB{Error: gorm.ErrRecordNotFound})
+		_, err := clientRepo.Get(ctx, "shop")
+		Expect(errorx.IsOfType(err, errx.NotFound)).To(BeTrue())
+	})
+
+	It("lists the clients by id", func(ctx SpecContext) {
+		db.EXPECT().Find(gomock.Any(), gomock.Any(), repo.NewQuery().OrderBy("client_id", repo.StringValue, false), 0, 0).Return(&gorm.DB{Error: nil})
+		clients, err := clientRepo.List(ctx)
+		Expect(err).To(BeNil())
+		Expect(clients).To(BeEmpty())
+	})
+})
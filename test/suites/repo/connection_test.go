@@ -0,0 +1,78 @@
+package repo_test
+
+import (
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	driver "github.com/go-sql-driver/mysql"
+	"github.com/golang/mock/gomock"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"github.com/rhuandantas/verifymy-test/internal/models"
+	"github.com/rhuandantas/verifymy-test/internal/repo"
+	mock_config "github.com/rhuandantas/verifymy-test/test/mock/config"
+	"gorm.io/driver/mysql"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+var _ = Describe("Test mysql connection", func() {
+	It("reads times as time.Time in UTC", func() {
+		mockCtrl := gomock.NewController(GinkgoT())
+		config := mock_config.NewMockConfigProvider(mockCtrl)
+		config.EXPECT().GetString("db.mysql.host").Return("localhost")
+		config.EXPECT().GetString("db.mysql.port").Return("3306")
+		config.EXPECT().GetString("db.mysql.database").Return("verifymy")
+		config.EXPECT().GetString("db.mysql.user-key").Return("DB_USER")
+		config.EXPECT().GetString("db.mysql.password-key").Return("DB_PASSWORD")
+		config.EXPECT().GetEnv("DB_USER").Return("root")
+		config.EXPECT().GetEnv("DB_PASSWORD").Return("secret")
+
+		dsn, err := driver.ParseDSN(repo.DataSourceName(config))
+		Expect(err).To(BeNil())
+		Expect(dsn.ParseTime).To(BeTrue())
+		Expect(dsn.Loc).To(Equal(time.UTC))
+		Expect(dsn.Addr).To(Equal("localhost:3306"))
+		Expect(dsn.DBName).To(Equal("verifymy"))
+	})
+
+	Context("scans the rows the driver returns", func() {
+		var (
+			mock sqlmock.Sqlmock
+			db   *gorm.DB
+		)
+
+		BeforeEach(func() {
+			conn, sqlMock, err := sqlmock.New()
+			Expect(err).To(BeNil())
+			mock = sqlMock
+			DeferCleanup(func() {
+				Expect(mock.ExpectationsWereMet()).To(Succeed())
+				mock.ExpectClose()
+				Expect(conn.Close()).To(Succeed())
+			})
+			db, err = gorm.Open(mysql.New(mysql.Config{Conn: conn, SkipInitializeWithVersion: true}), &gorm.Config{Logger: logger.Discard})
+			Expect(err).To(BeNil())
+		})
+
+		columns := []string{"user_id", "email", "date_of_birth", "created_at", "updated_at", "deleted_at"}
+
+		It("with parseTime", func() {
+			created := time.Date(2026, 10, 19, 10, 30, 0, 0, time.UTC)
+			mock.ExpectQuery("SELECT").WillReturnRows(sqlmock.NewRows(columns).
+				AddRow(1, "john@doe.com", time.Date(2000, 2, 29, 0, 0, 0, 0, time.UTC), created, created, nil))
+			var user models.User
+			Expect(db.First(&user).Error).To(BeNil())
+			Expect(user.CreatedAt).To(Equal(created))
+			Expect(*user.DateOfBirth).To(Equal(models.NewDate(2000, 2, 29)))
+			Expect(user.DeletedAt.Valid).To(BeFalse())
+		})
+
+		It("without parseTime", func() {
+			mock.ExpectQuery("SELECT").WillReturnRows(sqlmock.NewRows(columns).
+				AddRow(1, "john@doe.com", []byte("2000-02-29"), []byte("2026-10-19 10:30:00"), []byte("2026-10-19 10:30:00"), nil))
+			var user models.User
+			Expect(db.First(&user).Error).To(MatchError(ContainSubstring("unsupported Scan")))
+		})
+	})
+})
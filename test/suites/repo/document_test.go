@@ -0,0 +1,49 @@
+package repo_test
+
+import (
+	"time"
+
+	"github.com/golang/mock/gomock"
+	"github.com/joomcode/errorx"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	errx "github.com/rhuandantas/verifymy-test/internal/errors"
+	"github.com/rhuandantas/verifymy-test/internal/repo"
+	mock_log "github.com/rhuandantas/verifymy-test/test/mock/log"
+	mock_repo "github.com/rhuandantas/verifymy-test/test/mock/repo"
+	"gorm.io/gorm"
+)
+
+var _ = Describe("Test all document repo methods", func() {
+	var (
+		mockCtrl     *gomock.Controller
+		db           *mock_repo.MockDBConnection
+		documentRepo repo.DocumentRepo
+	)
+
+	BeforeEach(func() {
+		mockCtrl = gomock.NewController(GinkgoT())
+		db = mock_repo.NewMockDBConnection(mockCtrl)
+		documentRepo = repo.NewDocumentRepo(db, mock_log.NewMockSimpleLogger(mockCtrl))
+	})
+
+	It("gets a document of the user only", func(ctx SpecContext) {
+		db.EXPECT().First(gomock.Any(), gomock.Any(), "document_id = ? AND user_id = ?", 5, 2).Return(&gorm.DB{Error: gorm.ErrRecordNotFound})
+		_, err := documentRepo.Get(ctx, 2, 5)
+		Expect(errorx.IsOfType(err, errx.NotFound)).To(BeTrue())
+	})
+
+	It("finds the documents expired longest first", func(ctx SpecContext) {
+		now := time.Now()
+		db.EXPECT().Find(gomock.Any(), gomock.Any(), repo.NewQuery().Where("expires_at", repo.LessOrEqual, now).OrderBy("expires_at", repo.TimeValue, false), 100, 0).
+			Return(&gorm.DB{Error: nil})
+		documents, err := documentRepo.Expired(ctx, now, 100)
+		Expect(err).To(BeNil())
+		Expect(documents).To(BeEmpty())
+	})
+
+	It("deletes a document", func(ctx SpecContext) {
+		db.EXPECT().Delete(gomock.Any(), gomock.Any(), "document_id = ?", 5).Return(&gorm.DB{Error: nil})
+		Expect(documentRepo.Delete(ctx, 5)).To(Succeed())
+	})
+})
@@ -0,0 +1,71 @@
+package repo_test
+
+import (
+	"github.com/golang/mock/gomock"
+	"github.com/joomcode/errorx"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	errx "github.com/rhuandantas/verifymy-test/internal/errors"
+	"github.com/rhuandantas/verifymy-test/internal/models"
+	"github.com/rhuandantas/verifymy-test/internal/repo"
+	mock_log "github.com/rhuandantas/verifymy-test/test/mock/log"
+	mock_repo "github.com/rhuandantas/verifymy-test/test/mock/repo"
+	"gorm.io/gorm"
+)
+
+var _ = Describe("Test all guardian repo methods", func() {
+	var (
+		mockCtrl     *gomock.Controller
+		db           *mock_repo.MockDBConnection
+		guardianRepo repo.GuardianRepo
+	)
+
+	BeforeEach(func() {
+		mockCtrl = gomock.NewController(GinkgoT())
+		db = mock_repo.NewMockDBConnection(mockCtrl)
+		guardianRepo = repo.NewGuardianRepo(db, mock_log.NewMockSimpleLogger(mockCtrl))
+	})
+
+	It("finds an invitation by the hash of its token", func(ctx SpecContext) {
+		db.EXPECT().First(gomock.Any(), gomock.Any(), "token_hash = ?", "hash").Return(&gorm.DB{Error: gorm.ErrRecordNotFound})
+		_, err := guardianRepo.GetByToken(ctx, "hash")
+		Expect(errorx.IsOfType(err, errx.NotFound)).To(BeTrue())
+	})
+
+	It("counts the consenting guardians of a minor", func(ctx SpecContext) {
+		query := repo.NewQuery().Where("minor_id", repo.Equal, 2).Where("status", repo.Equal, models.GuardianConsented)
+		db.EXPECT().Count(gomock.Any(), gomock.Any(), query, gomock.Any()).DoAndReturn(func(_, _ interface{}, _ *repo.Query, count *int64) *gorm.DB {
+			*count = 2
+			return &gorm.DB{Error: nil}
+		})
+		count, err := guardianRepo.CountConsented(ctx, 2)
+		Expect(err).To(BeNil())
+		Expect(count).To(Equal(int64(2)))
+	})
+
+	It("lists the consents of a guardian", func(ctx SpecContext) {
+		query := repo.NewQuery().Where("guardian_id", repo.Equal, 5).Where("status", repo.Equal, models.GuardianConsented).OrderBy("link_id", repo.IntValue, false)
+		db.EXPECT().Find(gomock.Any(), gomock.Any(), query, 0, 0).DoAndReturn(func(_, dest interface{}, _ *repo.Query, _, _ int, _ ...string) *gorm.DB {
+			*dest.(*[]*models.GuardianLink) = []*models.GuardianLink{{LinkId: 3, MinorId: 2, Status: models.GuardianConsented}}
+			return &gorm.DB{Error: nil}
+		})
+		links, err := guardianRepo.ListConsentedBy(ctx, 5)
+		Expect(err).To(BeNil())
+		Expect(links).To(HaveLen(1))
+	})
+
+	Context("Update a link", func() {
+		It("at the version it was read at", func(ctx SpecContext) {
+			db.EXPECT().Updates(gomock.Any(), gomock.Any(), gomock.Any(), "link_id = ? AND version = ?", 3, 1).Return(&gorm.DB{Error: nil, RowsAffected: 1})
+			updated, err := guardianRepo.Update(ctx, models.GuardianLink{LinkId: 3, Status: models.GuardianConsented, Version: 1})
+			Expect(err).To(BeNil())
+			Expect(updated.Version).To(Equal(2))
+		})
+
+		It("after it changed meanwhile", func(ctx SpecContext) {
+			db.EXPECT().Updates(gomock.Any(), gomock.Any(), gomock.Any(), "link_id = ? AND version = ?", 3, 1).Return(&gorm.DB{Error: nil, RowsAffected: 0})
+			_, err := guardianRepo.Update(ctx, models.GuardianLink{LinkId: 3, Status: models.GuardianRevoked, Version: 1})
+			Expect(errorx.IsOfType(err, errx.Conflict)).To(BeTrue())
+		})
+	})
+})
@@ -0,0 +1,199 @@
+package repo_test
+
+import (
+	"encoding/base64"
+
+	"github.com/joomcode/errorx"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	errx "github.com/rhuandantas/verifymy-test/internal/errors"
+	"github.com/rhuandantas/verifymy-test/internal/models"
+	"github.com/rhuandantas/verifymy-test/internal/repo"
+	"gorm.io/driver/mysql"
+	"gorm.io/gorm"
+	"net/url"
+	"time"
+)
+
+var _ = Describe("Test query builder", func() {
+	var db *gorm.DB
+
+	BeforeEach(func() {
+		var err error
+		// a dry run only renders the sql, nothing connects to the database
+		db, err = gorm.Open(mysql.New(mysql.Config{DSN: "user:pass@tcp(127.0.0.1:3306)/verifymy", SkipInitializeWithVersion: true}),
+			&gorm.Config{DryRun: true, DisableAutomaticPing: true})
+		Expect(err).To(BeNil())
+	})
+
+	render := func(query *repo.Query) *gorm.Statement {
+		return query.Apply(db.Model(&models.User{})).Find(&[]models.User{}).Statement
+	}
+
+	It("builds conditions and ordering from params", func() {
+		params, _ := url.ParseQuery("email_prefix=jon_&age_gte=18&age_lte=30&created_at_gte=2023-01-01T00:00:00Z&sort=-created_at,name,age&page=1")
+		query, err := repo.UserQuery.Build(params)
+		Expect(err).To(BeNil())
+		stmt := render(query)
+		Expect(stmt.SQL.String()).To(Equal("SELECT * FROM `users` WHERE (`date_of_birth` <= ? AND `date_of_birth` >= ? AND `created_at` >= ? AND `email` LIKE ?) " +
+			"AND `users`.`deleted_at` IS NULL ORDER BY `created_at` DESC,`name`,`date_of_birth` DESC"))
+		// 18 or older is born 18 years ago or earlier, 30 or younger is born after the day someone turned 31
+		today := models.Today(time.UTC)
+		Expect(stmt.Vars).To(Equal([]interface{}{models.LatestBirthDate(18, today), models.LatestBirthDate(31, today).AddDays(1),
+			time.Date(2023, 1, 1, 0, 0, 0, 0, time.UTC), `jon\_%`}))
+	})
+
+	It("without params", func() {
+		query, err := repo.UserQuery.Build(url.Values{})
+		Expect(err).To(BeNil())
+		Expect(render(query).SQL.String()).To(Equal("SELECT * FROM `users` WHERE `users`.`deleted_at` IS NULL"))
+	})
+
+	It("filter leaves the ordering out", func() {
+		query := repo.NewQuery().Where("name", repo.Equal, "Jon").OrderBy("name", repo.StringValue, true)
+		stmt := query.Filter(db.Model(&models.User{})).Find(&[]models.User{}).Statement
+		Expect(stmt.SQL.String()).To(Equal("SELECT * FROM `users` WHERE `name` = ? AND `users`.`deleted_at` IS NULL"))
+	})
+
+	It("selects the fields", func() {
+		params, _ := url.ParseQuery("fields=name, email,name&sort=name")
+		query, err := repo.UserQuery.Build(params)
+		Expect(err).To(BeNil())
+		Expect(query.Selected()).To(Equal([]string{"name", "email"}))
+
+		query, err = repo.UserQuery.Projection(params)
+		Expect(err).To(BeNil())
+		Expect(query.Selected()).To(Equal([]string{"name", "email"}))
+		Expect(query.Ordered("name")).To(BeFalse())
+
+		params, _ = url.ParseQuery("fields=name,password")
+		_, err = repo.UserQuery.Projection(params)
+		Expect(errorx.IsOfType(err, errx.Validation)).To(BeTrue())
+		Expect(errx.FieldErrors(errorx.Cast(err))[0].Param).To(Equal("address age created_at date_of_birth deleted_at email name status timezone updated_at user_id version"))
+	})
+
+	It("matches any of a list of values", func() {
+		query := repo.NewQuery().Where("user_id", repo.In, []int{1, 2})
+		stmt := render(query)
+		Expect(stmt.SQL.String()).To(Equal("SELECT * FROM `users` WHERE `user_id` IN (?,?) AND `users`.`deleted_at` IS NULL"))
+		Expect(stmt.Vars).To(Equal([]interface{}{1, 2}))
+	})
+
+	It("reports every invalid param", func() {
+		params, _ := url.ParseQuery("age_gte=old&created_at_lte=yesterday&sort=password")
+		_, err := repo.UserQuery.Build(params)
+		Expect(errorx.IsOfType(err, errx.Validation)).To(BeTrue())
+		fields := errx.FieldErrors(errorx.Cast(err))
+		Expect(fields).To(HaveLen(3))
+		Expect(fields[0].Field).To(Equal("age_gte"))
+		Expect(fields[1].Field).To(Equal("created_at_lte"))
+		Expect(fields[2]).To(Equal(errx.FieldError{
+			Field:   "sort",
+			Rule:    "oneof",
+			Param:   "age created_at date_of_birth email name updated_at user_id",
+			Message: "sort must be one of [age created_at date_of_birth email name updated_at user_id]",
+		}))
+	})
+	Context("cursors", func() {
+		var query *repo.Query
+
+		BeforeEach(func() {
+			query = repo.NewQuery().Where("age", repo.GreaterOrEqual, 18).
+				OrderBy("created_at", repo.TimeValue, true).
+				OrderBy("user_id", repo.IntValue, false)
+		})
+
+		cursorOf := func(createdAt time.Time, userId string) string {
+			return query.Cursor(func(column string) string {
+				if column == "created_at" {
+					return createdAt.Format(time.RFC3339Nano)
+				}
+				return userId
+			})
+		}
+
+		It("seek the rows after the cursor", func() {
+			createdAt := time.Date(2023, 1, 1, 10, 0, 0, 500, time.UTC)
+			seek, err := query.Seek(cursorOf(createdAt, "7"), false)
+			Expect(err).To(BeNil())
+			stmt := render(seek)
+			Expect(stmt.SQL.String()).To(Equal("SELECT * FROM `users` WHERE (`age` >= ? AND (`created_at` < ? OR (`created_at` = ? AND `user_id` > ?))) " +
+				"AND `users`.`deleted_at` IS NULL ORDER BY `created_at` DESC,`user_id`"))
+			Expect(stmt.Vars).To(Equal([]interface{}{18, createdAt, createdAt, 7}))
+		})
+
+		It("seek the rows before the cursor the other way round", func() {
+			createdAt := time.Date(2023, 1, 1, 10, 0, 0, 0, time.UTC)
+			seek, err := query.Seek(cursorOf(createdAt, "7"), true)
+			Expect(err).To(BeNil())
+			Expect(render(seek).SQL.String()).To(Equal("SELECT * FROM `users` WHERE (`age` >= ? AND (`created_at` > ? OR (`created_at` = ? AND `user_id` < ?))) " +
+				"AND `users`.`deleted_at` IS NULL ORDER BY `created_at`,`user_id` DESC"))
+			Expect(render(query).SQL.String()).To(Equal("SELECT * FROM `users` WHERE `age` >= ? " +
+				"AND `users`.`deleted_at` IS NULL ORDER BY `created_at` DESC,`user_id`"))
+		})
+
+		It("seek past rows without a value of a nullable column, which come first", func() {
+			nullable := repo.NewQuery().OrderByNullable("date_of_birth", repo.DateValue, false).OrderBy("user_id", repo.IntValue, false)
+			cursor := nullable.Cursor(func(column string) string {
+				if column == "user_id" {
+					return "7"
+				}
+				return ""
+			})
+
+			seek, err := nullable.Seek(cursor, false)
+			Expect(err).To(BeNil())
+			stmt := render(seek)
+			Expect(stmt.SQL.String()).To(Equal("SELECT * FROM `users` WHERE (`date_of_birth` IS NOT NULL OR (`date_of_birth` IS NULL AND `user_id` > ?)) " +
+				"AND `users`.`deleted_at` IS NULL ORDER BY `date_of_birth`,`user_id`"))
+			Expect(stmt.Vars).To(Equal([]interface{}{7}))
+
+			seek, err = nullable.Seek(cursor, true)
+			Expect(err).To(BeNil())
+			Expect(render(seek).SQL.String()).To(Equal("SELECT * FROM `users` WHERE (`date_of_birth` IS NULL AND `user_id` < ?) " +
+				"AND `users`.`deleted_at` IS NULL ORDER BY `date_of_birth` DESC,`user_id` DESC"))
+
+			_, err = query.Seek(cursor, false)
+			Expect(err).To(MatchError(ContainSubstring("another sort")))
+		})
+
+		It("seek back to rows without a value of a nullable column", func() {
+			nullable := repo.NewQuery().OrderByNullable("date_of_birth", repo.DateValue, true).OrderBy("user_id", repo.IntValue, false)
+			dateOfBirth := models.NewDate(1990, time.May, 4)
+			seek, err := nullable.Seek(nullable.Cursor(func(column string) string {
+				if column == "user_id" {
+					return "7"
+				}
+				return dateOfBirth.String()
+			}), false)
+			Expect(err).To(BeNil())
+			stmt := render(seek)
+			Expect(stmt.SQL.String()).To(Equal("SELECT * FROM `users` WHERE ((`date_of_birth` < ? OR `date_of_birth` IS NULL) OR (`date_of_birth` = ? AND `user_id` > ?)) " +
+				"AND `users`.`deleted_at` IS NULL ORDER BY `date_of_birth` DESC,`user_id`"))
+			Expect(stmt.Vars).To(Equal([]interface{}{dateOfBirth, dateOfBirth, 7}))
+		})
+
+		It("find nothing past the last row without a value", func() {
+			nullable := repo.NewQuery().OrderByNullable("date_of_birth", repo.DateValue, true)
+			seek, err := nullable.Seek(nullable.Cursor(func(string) string { return "" }), false)
+			Expect(err).To(BeNil())
+			Expect(render(seek).SQL.String()).To(Equal("SELECT * FROM `users` WHERE 1 = 0 AND `users`.`deleted_at` IS NULL ORDER BY `date_of_birth` DESC"))
+		})
+
+		It("reject cursors that aren't valid or belong to another sort", func() {
+			_, err := query.Seek("not a cursor", false)
+			Expect(errorx.IsOfType(err, errx.BadRequest)).To(BeTrue())
+
+			_, err = query.Seek(cursorOf(time.Now(), "seven"), false)
+			Expect(errorx.IsOfType(err, errx.BadRequest)).To(BeTrue())
+
+			// only nullable columns have NULL keys
+			_, err = query.Seek(base64.RawURLEncoding.EncodeToString([]byte(`{"o":"-created_at,user_id","k":[null,"7"]}`)), false)
+			Expect(errorx.IsOfType(err, errx.BadRequest)).To(BeTrue())
+
+			other := repo.NewQuery().OrderBy("user_id", repo.IntValue, false)
+			_, err = other.Seek(cursorOf(time.Now(), "7"), false)
+			Expect(err).To(MatchError(ContainSubstring("another sort")))
+		})
+	})
+})
@@ -0,0 +1,60 @@
+package repo_test
+
+import (
+	"github.com/golang/mock/gomock"
+	"github.com/joomcode/errorx"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	errx "github.com/rhuandantas/verifymy-test/internal/errors"
+	"github.com/rhuandantas/verifymy-test/internal/models"
+	"github.com/rhuandantas/verifymy-test/internal/repo"
+	mock_log "github.com/rhuandantas/verifymy-test/test/mock/log"
+	mock_repo "github.com/rhuandantas/verifymy-test/test/mock/repo"
+	"gorm.io/gorm"
+	"net/url"
+)
+
+var _ = Describe("Test all review repo methods", func() {
+	var (
+		mockCtrl   *gomock.Controller
+		db         *mock_repo.MockDBConnection
+		reviewRepo repo.ReviewRepo
+	)
+
+	BeforeEach(func() {
+		mockCtrl = gomock.NewController(GinkgoT())
+		db = mock_repo.NewMockDBConnection(mockCtrl)
+		reviewRepo = repo.NewReviewRepo(db, mock_log.NewMockSimpleLogger(mockCtrl))
+	})
+
+	It("finds the case of a user still under way", func(ctx SpecContext) {
+		db.EXPECT().First(gomock.Any(), gomock.Any(), "user_id = ? AND status IN ?", 1, []string{models.ReviewOpen, models.ReviewClaimed}).
+			Return(&gorm.DB{Error: gorm.ErrRecordNotFound})
+		_, err := reviewRepo.GetUndecided(ctx, 1)
+		Expect(errorx.IsOfType(err, errx.NotFound)).To(BeTrue())
+	})
+
+	It("lists the queue by due date", func(ctx SpecContext) {
+		query, err := repo.ReviewQuery.Build(url.Values{"status": {"open"}})
+		Expect(err).To(BeNil())
+		db.EXPECT().Find(gomock.Any(), gomock.Any(), query, 50, 0).Return(&gorm.DB{Error: nil})
+		_, err = reviewRepo.List(ctx, query, 50)
+		Expect(err).To(BeNil())
+		Expect(query.Ordered("due_at")).To(BeTrue())
+	})
+
+	Context("Update a case", func() {
+		It("at the version it was read at", func(ctx SpecContext) {
+			db.EXPECT().Updates(gomock.Any(), gomock.Any(), gomock.Any(), "case_id = ? AND version = ?", 7, 2).Return(&gorm.DB{Error: nil, RowsAffected: 1})
+			updated, err := reviewRepo.Update(ctx, models.ReviewCase{CaseId: 7, Status: models.ReviewClaimed, Version: 2})
+			Expect(err).To(BeNil())
+			Expect(updated.Version).To(Equal(3))
+		})
+
+		It("after another reviewer changed it", func(ctx SpecContext) {
+			db.EXPECT().Updates(gomock.Any(), gomock.Any(), gomock.Any(), "case_id = ? AND version = ?", 7, 2).Return(&gorm.DB{Error: nil, RowsAffected: 0})
+			_, err := reviewRepo.Update(ctx, models.ReviewCase{CaseId: 7, Status: models.ReviewClaimed, Version: 2})
+			Expect(errorx.IsOfType(err, errx.Conflict)).To(BeTrue())
+		})
+	})
+})
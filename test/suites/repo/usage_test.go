@@ -0,0 +1,71 @@
+package repo_test
+
+import (
+	"github.com/go-sql-driver/mysql"
+	"github.com/golang/mock/gomock"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"github.com/rhuandantas/verifymy-test/internal/models"
+	"github.com/rhuandantas/verifymy-test/internal/repo"
+	mock_log "github.com/rhuandantas/verifymy-test/test/mock/log"
+	mock_repo "github.com/rhuandantas/verifymy-test/test/mock/repo"
+	"gorm.io/gorm"
+)
+
+var _ = Describe("Test all usage repo methods", func() {
+	var (
+		mockCtrl  *gomock.Controller
+		db        *mock_repo.MockDBConnection
+		usageRepo repo.UsageRepo
+		day       models.Date
+	)
+
+	BeforeEach(func() {
+		mockCtrl = gomock.NewController(GinkgoT())
+		db = mock_repo.NewMockDBConnection(mockCtrl)
+		usageRepo = repo.NewUsageRepo(db, mock_log.NewMockSimpleLogger(mockCtrl))
+		day = models.NewDate(2026, 10, 19)
+	})
+
+	It("counts nothing for a day without usage", func(ctx SpecContext) {
+		db.EXPECT().First(gomock.Any(), gomock.Any(), "client_id = ? AND day = ? AND metric = ?", "shop", day, models.MetricTokens).
+			Return(&gorm.DB{Error: gorm.ErrRecordNotFound})
+		count, err := usageRepo.Count(ctx, "shop", day, models.MetricTokens)
+		Expect(err).To(BeNil())
+		Expect(count).To(BeZero())
+	})
+
+	Context("Add usage", func() {
+		It("to the row of the day", func(ctx SpecContext) {
+			db.EXPECT().Updates(gomock.Any(), gomock.Any(), gomock.Any(), "client_id = ? AND day = ? AND metric = ?", "shop", day, models.MetricTokens).
+				Return(&gorm.DB{RowsAffected: 1})
+			Expect(usageRepo.Add(ctx, "shop", day, models.MetricTokens, 3)).To(Succeed())
+		})
+
+		It("inserting the row of the day", func(ctx SpecContext) {
+			db.EXPECT().Updates(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any(), "shop", day, models.MetricTokens).Return(&gorm.DB{RowsAffected: 0})
+			db.EXPECT().Insert(gomock.Any(), &models.ClientUsage{ClientId: "shop", Day: day, Metric: models.MetricTokens, Count: 3}).Return(&gorm.DB{Error: nil})
+			Expect(usageRepo.Add(ctx, "shop", day, models.MetricTokens, 3)).To(Succeed())
+		})
+
+		It("when another server inserted the row meanwhile", func(ctx SpecContext) {
+			gomock.InOrder(
+				db.EXPECT().Updates(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any(), "shop", day, models.MetricTokens).Return(&gorm.DB{RowsAffected: 0}),
+				db.EXPECT().Insert(gomock.Any(), gomock.Any()).Return(&gorm.DB{Error: &mysql.MySQLError{Number: 1062, Message: "Duplicate entry for key 'PRIMARY'"}}),
+				db.EXPECT().Updates(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any(), "shop", day, models.MetricTokens).Return(&gorm.DB{RowsAffected: 1}),
+			)
+			Expect(usageRepo.Add(ctx, "shop", day, models.MetricTokens, 3)).To(Succeed())
+		})
+	})
+
+	It("reads a range of days", func(ctx SpecContext) {
+		query := repo.NewQuery().
+			Where("client_id", repo.Equal, "shop").
+			Where("day", repo.GreaterOrEqual, day.AddDays(-6)).
+			Where("day", repo.LessOrEqual, day).
+			OrderBy("day", repo.DateValue, false)
+		db.EXPECT().Find(gomock.Any(), gomock.Any(), query, 0, 0).Return(&gorm.DB{Error: nil})
+		_, err := usageRepo.Range(ctx, "shop", day.AddDays(-6), day)
+		Expect(err).To(BeNil())
+	})
+})
@@ -2,14 +2,19 @@ package repo_test
 
 import (
 	"errors"
+	"github.com/go-sql-driver/mysql"
 	"github.com/golang/mock/gomock"
+	"github.com/joomcode/errorx"
 	. "github.com/onsi/ginkgo/v2"
 	. "github.com/onsi/gomega"
+	errx "github.com/rhuandantas/verifymy-test/internal/errors"
 	"github.com/rhuandantas/verifymy-test/internal/models"
 	"github.com/rhuandantas/verifymy-test/internal/repo"
 	mock_log "github.com/rhuandantas/verifymy-test/test/mock/log"
 	mock_repo "github.com/rhuandantas/verifymy-test/test/mock/repo"
 	"gorm.io/gorm"
+	"strconv"
+	"time"
 )
 
 var _ = Describe("Test all user repo methods", func() {
@@ -39,29 +44,105 @@ var _ = Describe("Test all user repo methods", func() {
 			_, err := userRepo.Create(ctx, models.User{})
 			Expect(err).ToNot(BeNil())
 		})
+		It("with duplicate email", func(ctx SpecContext) {
+			db.EXPECT().Insert(gomock.Any(), gomock.Any()).Return(&gorm.DB{Error: &mysql.MySQLError{Number: 1062, Message: "Duplicate entry 'jon@email.com' for key 'users.idx_email_deleted'"}})
+			_, err := userRepo.Create(ctx, models.User{})
+			Expect(errorx.IsOfType(err, errx.Conflict)).To(BeTrue())
+			Expect(errorx.Cast(err).Message()).To(Equal("email is already registered"))
+		})
+		It("with constraint violation", func(ctx SpecContext) {
+			db.EXPECT().Insert(gomock.Any(), gomock.Any()).Return(&gorm.DB{Error: &mysql.MySQLError{Number: 1406, Message: "Data too long for column 'name' at row 1"}})
+			_, err := userRepo.Create(ctx, models.User{})
+			Expect(errorx.IsOfType(err, errx.ConstraintViolation)).To(BeTrue())
+		})
+	})
+
+	Context("Create users in batch", func() {
+		It("successfully", func(ctx SpecContext) {
+			db.EXPECT().InsertInBatches(gomock.Any(), gomock.Any(), 2).Return(&gorm.DB{Error: nil})
+			users, err := userRepo.CreateBatch(ctx, []models.User{{Name: "a"}, {Name: "b"}, {Name: "c"}}, 2)
+			Expect(err).To(BeNil())
+			Expect(users).To(HaveLen(3))
+			Expect(users[2].Version).To(Equal(1))
+		})
+		It("with duplicate email", func(ctx SpecContext) {
+			db.EXPECT().InsertInBatches(gomock.Any(), gomock.Any(), 2).Return(&gorm.DB{Error: &mysql.MySQLError{Number: 1062, Message: "Duplicate entry 'jon@email.com' for key 'users.idx_email_deleted'"}})
+			_, err := userRepo.CreateBatch(ctx, []models.User{{Name: "a"}}, 2)
+			Expect(errorx.IsOfType(err, errx.Conflict)).To(BeTrue())
+		})
+	})
+
+	Context("Run in a transaction", func() {
+		It("with a repo bound to the transaction", func(ctx SpecContext) {
+			tx := mock_repo.NewMockDBConnection(mockCtrl)
+			db.EXPECT().Transaction(gomock.Any(), gomock.Any()).DoAndReturn(func(_ interface{}, fn func(repo.DBConnection) error) error {
+				return fn(tx)
+			})
+			tx.EXPECT().First(gomock.Any(), gomock.Any()).Return(&gorm.DB{Error: nil})
+			err := userRepo.Transaction(ctx, func(txRepo repo.UserRepo) error {
+				_, err := txRepo.GetByID(ctx, 1)
+				return err
+			})
+			Expect(err).To(BeNil())
+		})
 	})
 
 	Context("Update a user", func() {
 		It("successfully", func(ctx SpecContext) {
-			db.EXPECT().Update(gomock.Any(), gomock.Any()).Return(&gorm.DB{Error: nil})
+			db.EXPECT().Updates(gomock.Any(), gomock.Any(), gomock.Any(), "user_id = ?", 1).Return(&gorm.DB{Error: nil, RowsAffected: 1})
+			db.EXPECT().First(gomock.Any(), gomock.Any(), gomock.Any()).Return(&gorm.DB{Error: nil})
+			user, err := userRepo.Update(ctx, 1, models.User{}, 0)
+			Expect(err).To(BeNil())
+			Expect(user).ToNot(BeNil())
+		})
+		It("with expected version", func(ctx SpecContext) {
+			db.EXPECT().Updates(gomock.Any(), gomock.Any(), gomock.Any(), "user_id = ? AND version = ?", 1, 2).Return(&gorm.DB{Error: nil, RowsAffected: 1})
 			db.EXPECT().First(gomock.Any(), gomock.Any(), gomock.Any()).Return(&gorm.DB{Error: nil})
-			user, err := userRepo.Update(ctx, 1, models.User{})
+			user, err := userRepo.Update(ctx, 1, models.User{}, 2)
 			Expect(err).To(BeNil())
 			Expect(user).ToNot(BeNil())
 		})
+		It("with stale version", func(ctx SpecContext) {
+			db.EXPECT().Updates(gomock.Any(), gomock.Any(), gomock.Any(), "user_id = ? AND version = ?", 1, 2).Return(&gorm.DB{Error: nil, RowsAffected: 0})
+			db.EXPECT().First(gomock.Any(), gomock.Any(), gomock.Any()).Return(&gorm.DB{Error: nil})
+			_, err := userRepo.Update(ctx, 1, models.User{}, 2)
+			Expect(errorx.IsOfType(err, errx.PreconditionFailed)).To(BeTrue())
+		})
 		It("with get by id fail", func(ctx SpecContext) {
-			db.EXPECT().First(gomock.Any(), gomock.Any(), gomock.Any()).Return(&gorm.DB{Error: errors.New("mock error")})
-			_, err := userRepo.Update(ctx, 1, models.User{})
-			Expect(err).ToNot(BeNil())
+			db.EXPECT().Updates(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any()).Return(&gorm.DB{Error: nil, RowsAffected: 0})
+			db.EXPECT().First(gomock.Any(), gomock.Any(), gomock.Any()).Return(&gorm.DB{Error: gorm.ErrRecordNotFound})
+			_, err := userRepo.Update(ctx, 1, models.User{}, 0)
+			Expect(errorx.IsOfType(err, errx.NotFound)).To(BeTrue())
 		})
 		It("with fail", func(ctx SpecContext) {
-			db.EXPECT().Update(gomock.Any(), gomock.Any()).Return(&gorm.DB{Error: errors.New("mock error")})
-			db.EXPECT().First(gomock.Any(), gomock.Any(), gomock.Any()).Return(&gorm.DB{Error: nil})
-			_, err := userRepo.Update(ctx, 1, models.User{})
+			db.EXPECT().Updates(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any()).Return(&gorm.DB{Error: errors.New("mock error")})
+			_, err := userRepo.Update(ctx, 1, models.User{}, 0)
 			Expect(err).ToNot(BeNil())
 		})
 	})
 
+	Context("Patch a user", func() {
+		It("successfully", func(ctx SpecContext) {
+			db.EXPECT().Updates(gomock.Any(), gomock.Any(), gomock.Any(), "user_id = ? AND version = ?", 1, 2).
+				DoAndReturn(func(_ interface{}, _ interface{}, values map[string]interface{}, _ ...interface{}) *gorm.DB {
+					Expect(values).To(HaveKeyWithValue("name", "Arya"))
+					Expect(values).To(HaveKey("version"))
+					Expect(values).ToNot(HaveKey("email"))
+					return &gorm.DB{Error: nil, RowsAffected: 1}
+				})
+			db.EXPECT().First(gomock.Any(), gomock.Any(), gomock.Any()).Return(&gorm.DB{Error: nil})
+			user, err := userRepo.Patch(ctx, 1, map[string]interface{}{"name": "Arya"}, 2)
+			Expect(err).To(BeNil())
+			Expect(user).ToNot(BeNil())
+		})
+		It("with stale version", func(ctx SpecContext) {
+			db.EXPECT().Updates(gomock.Any(), gomock.Any(), gomock.Any(), "user_id = ? AND version = ?", 1, 2).Return(&gorm.DB{Error: nil, RowsAffected: 0})
+			db.EXPECT().First(gomock.Any(), gomock.Any(), gomock.Any()).Return(&gorm.DB{Error: nil})
+			_, err := userRepo.Patch(ctx, 1, map[string]interface{}{"name": "Arya"}, 2)
+			Expect(errorx.IsOfType(err, errx.PreconditionFailed)).To(BeTrue())
+		})
+	})
+
 	Context("Find a user", func() {
 		It("successfully", func(ctx SpecContext) {
 			db.EXPECT().First(gomock.Any(), gomock.Any(), gomock.Any()).Return(&gorm.DB{Error: nil})
@@ -75,15 +156,31 @@ var _ = Describe("Test all user repo methods", func() {
 			Expect(err).ToNot(BeNil())
 		})
 		It("with record not found", func(ctx SpecContext) {
-			db.EXPECT().First(gomock.Any(), gomock.Any(), gomock.Any()).Return(&gorm.DB{Error: errors.New("record not found")})
+			db.EXPECT().First(gomock.Any(), gomock.Any(), gomock.Any()).Return(&gorm.DB{Error: gorm.ErrRecordNotFound})
 			_, err := userRepo.GetByID(ctx, 1)
-			Expect(err.Error()).To(Equal("User not found with id 1"))
+			Expect(errorx.IsOfType(err, errx.NotFound)).To(BeTrue())
+			Expect(errorx.Cast(err).Message()).To(Equal("User not found with id 1"))
+		})
+		It("reading only some columns", func(ctx SpecContext) {
+			db.EXPECT().Find(gomock.Any(), gomock.Any(), gomock.Any(), 1, 0, "name", "user_id", "version").
+				DoAndReturn(func(_, dest interface{}, _ *repo.Query, _, _ int, _ ...string) *gorm.DB {
+					*dest.(*[]*models.User) = []*models.User{{UserId: 1, Name: "Jon", Version: 2}}
+					return &gorm.DB{Error: nil}
+				})
+			user, err := userRepo.GetByID(ctx, 1, "name")
+			Expect(err).To(BeNil())
+			Expect(user.Name).To(Equal("Jon"))
+		})
+		It("reading only some columns of a user that doesn't exist", func(ctx SpecContext) {
+			db.EXPECT().Find(gomock.Any(), gomock.Any(), gomock.Any(), 1, 0, gomock.Any()).Return(&gorm.DB{Error: nil})
+			_, err := userRepo.GetByID(ctx, 1, "name")
+			Expect(errorx.IsOfType(err, errx.NotFound)).To(BeTrue())
 		})
 	})
 
 	Context("Delete a user", func() {
 		It("successfully", func(ctx SpecContext) {
-			db.EXPECT().Delete(gomock.Any(), gomock.Any(), gomock.Any()).Return(&gorm.DB{Error: nil})
+			db.EXPECT().Updates(gomock.Any(), gomock.Any(), gomock.Any()).Return(&gorm.DB{Error: nil})
 			db.EXPECT().First(gomock.Any(), gomock.Any(), gomock.Any()).Return(&gorm.DB{Error: nil})
 			user, err := userRepo.Delete(ctx, 1)
 			Expect(err).To(BeNil())
@@ -95,24 +192,218 @@ var _ = Describe("Test all user repo methods", func() {
 			Expect(err).ToNot(BeNil())
 		})
 		It("with fail", func(ctx SpecContext) {
-			db.EXPECT().Delete(gomock.Any(), gomock.Any(), gomock.Any()).Return(&gorm.DB{Error: errors.New("mock error")})
+			db.EXPECT().Updates(gomock.Any(), gomock.Any(), gomock.Any()).Return(&gorm.DB{Error: errors.New("mock error")})
 			db.EXPECT().First(gomock.Any(), gomock.Any(), gomock.Any()).Return(&gorm.DB{Error: nil})
 			_, err := userRepo.Delete(ctx, 1)
 			Expect(err).ToNot(BeNil())
 		})
 	})
 
+	Context("Restore a user", func() {
+		var unscoped *mock_repo.MockDBConnection
+
+		BeforeEach(func() {
+			unscoped = mock_repo.NewMockDBConnection(mockCtrl)
+			db.EXPECT().Unscoped().Return(unscoped).AnyTimes()
+		})
+
+		It("successfully", func(ctx SpecContext) {
+			unscoped.EXPECT().First(gomock.Any(), gomock.Any(), "deleted_at IS NOT NULL").Return(&gorm.DB{Error: nil})
+			unscoped.EXPECT().Updates(gomock.Any(), gomock.Any(), map[string]interface{}{"deleted_at": nil, "deleted_key": 0}).Return(&gorm.DB{Error: nil})
+			user, err := userRepo.Restore(ctx, 1)
+			Expect(err).To(BeNil())
+			Expect(user.DeletedAt.Valid).To(BeFalse())
+		})
+		It("when it isn't deleted", func(ctx SpecContext) {
+			unscoped.EXPECT().First(gomock.Any(), gomock.Any(), gomock.Any()).Return(&gorm.DB{Error: gorm.ErrRecordNotFound})
+			_, err := userRepo.Restore(ctx, 1)
+			Expect(errorx.IsOfType(err, errx.NotFound)).To(BeTrue())
+		})
+		It("when the email was taken meanwhile", func(ctx SpecContext) {
+			unscoped.EXPECT().First(gomock.Any(), gomock.Any(), gomock.Any()).Return(&gorm.DB{Error: nil})
+			unscoped.EXPECT().Updates(gomock.Any(), gomock.Any(), gomock.Any()).Return(&gorm.DB{Error: &mysql.MySQLError{Number: 1062, Message: "Duplicate entry 'jon@email.com-0' for key 'users.idx_email_deleted'"}})
+			_, err := userRepo.Restore(ctx, 1)
+			Expect(errorx.IsOfType(err, errx.Conflict)).To(BeTrue())
+		})
+	})
+
+	Context("Purge deleted users", func() {
+		It("listing the ones to purge", func(ctx SpecContext) {
+			unscoped := mock_repo.NewMockDBConnection(mockCtrl)
+			db.EXPECT().Unscoped().Return(unscoped)
+			unscoped.EXPECT().Pluck(gomock.Any(), gomock.Any(), "user_id", gomock.Any(), gomock.Any(), gomock.Any()).
+				DoAndReturn(func(_, _ interface{}, _ string, dest interface{}, _ ...interface{}) *gorm.DB {
+					*dest.(*[]int) = []int{4, 7}
+					return &gorm.DB{Error: nil}
+				})
+			userIds, err := userRepo.Purgeable(ctx, time.Now())
+			Expect(err).To(BeNil())
+			Expect(userIds).To(Equal([]int{4, 7}))
+		})
+		It("successfully", func(ctx SpecContext) {
+			unscoped := mock_repo.NewMockDBConnection(mockCtrl)
+			db.EXPECT().Unscoped().Return(unscoped)
+			unscoped.EXPECT().Delete(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any()).
+				DoAndReturn(func(_, _ interface{}, conds ...interface{}) *gorm.DB {
+					Expect(conds[0]).To(ContainSubstring("NOT EXISTS (SELECT 1 FROM verification_events"))
+					Expect(conds[0]).To(ContainSubstring("NOT EXISTS (SELECT 1 FROM review_cases"))
+					return &gorm.DB{Error: nil, RowsAffected: 3}
+				})
+			purged, err := userRepo.Purge(ctx, time.Now())
+			Expect(err).To(BeNil())
+			Expect(purged).To(Equal(int64(3)))
+		})
+	})
+
+	Context("Find existing emails", func() {
+		It("successfully", func(ctx SpecContext) {
+			db.EXPECT().Pluck(gomock.Any(), gomock.Any(), "email", gomock.Any(), "email IN ?", []string{"Jon@email.com", "arya@email.com"}).
+				DoAndReturn(func(_, _ interface{}, _ string, dest interface{}, _ ...interface{}) *gorm.DB {
+					*dest.(*[]string) = []string{"jon@email.com"}
+					return &gorm.DB{Error: nil}
+				})
+			existing, err := userRepo.ExistingEmails(ctx, []string{"Jon@email.com", "arya@email.com"})
+			Expect(err).To(BeNil())
+			Expect(existing).To(Equal(map[string]bool{"jon@email.com": true}))
+		})
+		It("without emails", func(ctx SpecContext) {
+			existing, err := userRepo.ExistingEmails(ctx, nil)
+			Expect(err).To(BeNil())
+			Expect(existing).To(BeEmpty())
+		})
+	})
+
+	Context("Export users", func() {
+		It("successfully in batches", func(ctx SpecContext) {
+			db.EXPECT().FindInBatches(gomock.Any(), gomock.Any(), gomock.Any(), 2, gomock.Any(), gomock.Any()).
+				DoAndReturn(func(_, dest interface{}, _ *repo.Query, _ int, fn func() error, columns ...string) *gorm.DB {
+					Expect(columns).ToNot(ContainElement("password"))
+					users := dest.(*[]*models.User)
+					*users = []*models.User{{UserId: 1}, {UserId: 2}}
+					Expect(fn()).To(Succeed())
+					*users = []*models.User{{UserId: 3}}
+					Expect(fn()).To(Succeed())
+					return &gorm.DB{Error: nil}
+				})
+			exported := 0
+			err := userRepo.Export(ctx, repo.NewQuery(), false, 2, func(users []*models.User) error {
+				exported += len(users)
+				return nil
+			})
+			Expect(err).To(BeNil())
+			Expect(exported).To(Equal(3))
+		})
+		It("including deleted ones", func(ctx SpecContext) {
+			db.EXPECT().Unscoped().Return(db)
+			db.EXPECT().FindInBatches(gomock.Any(), gomock.Any(), gomock.Any(), 2, gomock.Any(), gomock.Any()).Return(&gorm.DB{Error: errors.New("mock error")})
+			err := userRepo.Export(ctx, nil, true, 2, func(users []*models.User) error { return nil })
+			Expect(err).ToNot(BeNil())
+		})
+	})
+
 	Context("Get all users", func() {
+		users := func(ids ...int) []*models.User {
+			found := make([]*models.User, 0, len(ids))
+			for _, id := range ids {
+				found = append(found, &models.User{UserId: id})
+			}
+			return found
+		}
+
+		findUsers := func(ids ...int) func(_, dest interface{}, _ *repo.Query, _, _ int, _ ...string) *gorm.DB {
+			return func(_, dest interface{}, _ *repo.Query, _, _ int, _ ...string) *gorm.DB {
+				*dest.(*[]*models.User) = users(ids...)
+				return &gorm.DB{Error: nil}
+			}
+		}
+
+		cursorOf := func(userId int) string {
+			return repo.NewQuery().OrderBy("user_id", repo.IntValue, false).Cursor(func(string) string {
+				return strconv.Itoa(userId)
+			})
+		}
+
 		It("successfully", func(ctx SpecContext) {
-			db.EXPECT().FindAll(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any()).Return(&gorm.DB{Error: nil})
-			user, err := userRepo.GetUsers(ctx, 1, 1)
+			db.EXPECT().Find(gomock.Any(), gomock.Any(), gomock.Any(), 11, 20, gomock.Any()).
+				DoAndReturn(func(_, _ interface{}, query *repo.Query, _, _ int, columns ...string) *gorm.DB {
+					Expect(query.Ordered("user_id")).To(BeTrue())
+					Expect(columns).ToNot(ContainElement("password"))
+					return &gorm.DB{Error: nil}
+				})
+			page, err := userRepo.GetUsers(ctx, nil, models.Pagination{Page: 2, Size: 10}, false)
+			Expect(err).To(BeNil())
+			Expect(page.Items).To(BeEmpty())
+			Expect(page.Next).To(BeEmpty())
+			Expect(page.Prev).To(BeEmpty())
+			Expect(page.Total).To(BeNil())
+		})
+		It("with the cursors around the page", func(ctx SpecContext) {
+			db.EXPECT().Find(gomock.Any(), gomock.Any(), gomock.Any(), 3, 2, gomock.Any()).DoAndReturn(findUsers(3, 4, 5))
+			page, err := userRepo.GetUsers(ctx, repo.NewQuery(), models.Pagination{Page: 1, Size: 2}, false)
+			Expect(err).To(BeNil())
+			Expect(page.Items).To(Equal(users(3, 4)))
+			Expect(page.Next).To(Equal(cursorOf(4)))
+			Expect(page.Prev).To(Equal(cursorOf(3)))
+		})
+		It("after a cursor", func(ctx SpecContext) {
+			db.EXPECT().Find(gomock.Any(), gomock.Any(), gomock.Any(), 3, 0, gomock.Any()).DoAndReturn(findUsers(5, 6))
+			page, err := userRepo.GetUsers(ctx, repo.NewQuery(), models.Pagination{Size: 2, After: cursorOf(4)}, false)
+			Expect(err).To(BeNil())
+			Expect(page.Items).To(Equal(users(5, 6)))
+			Expect(page.Next).To(BeEmpty())
+			Expect(page.Prev).To(Equal(cursorOf(5)))
+		})
+		It("before a cursor", func(ctx SpecContext) {
+			db.EXPECT().Find(gomock.Any(), gomock.Any(), gomock.Any(), 3, 0, gomock.Any()).DoAndReturn(findUsers(4, 3))
+			page, err := userRepo.GetUsers(ctx, repo.NewQuery(), models.Pagination{Size: 2, Before: cursorOf(5)}, false)
 			Expect(err).To(BeNil())
-			Expect(user).To(BeEmpty())
+			Expect(page.Items).To(Equal(users(3, 4)))
+			Expect(page.Next).To(Equal(cursorOf(4)))
+			Expect(page.Prev).To(BeEmpty())
+		})
+		It("leaving the query of the caller as it is", func(ctx SpecContext) {
+			db.EXPECT().Find(gomock.Any(), gomock.Any(), gomock.Any(), 3, 0, gomock.Any()).DoAndReturn(findUsers(3, 4)).Times(2)
+			query := repo.NewQuery()
+			_, err := userRepo.GetUsers(ctx, query, models.Pagination{Size: 2}, false)
+			Expect(err).To(BeNil())
+			Expect(query).To(Equal(repo.NewQuery()))
+			page, err := userRepo.GetUsers(ctx, query, models.Pagination{Size: 2, After: cursorOf(2)}, false)
+			Expect(err).To(BeNil())
+			Expect(page.Items).To(Equal(users(3, 4)))
+		})
+		It("with a cursor of another sort", func(ctx SpecContext) {
+			query := repo.NewQuery().OrderBy("name", repo.StringValue, false)
+			_, err := userRepo.GetUsers(ctx, query, models.Pagination{Size: 2, After: cursorOf(5)}, false)
+			Expect(errorx.IsOfType(err, errx.BadRequest)).To(BeTrue())
+		})
+		It("reading the selected and sort columns", func(ctx SpecContext) {
+			db.EXPECT().Find(gomock.Any(), gomock.Any(), gomock.Any(), 11, 0, "name", "user_id", "version", "created_at").Return(&gorm.DB{Error: nil})
+			query := repo.NewQuery().Select("name").OrderBy("created_at", repo.TimeValue, true)
+			_, err := userRepo.GetUsers(ctx, query, models.Pagination{Size: 10}, false)
+			Expect(err).To(BeNil())
+		})
+		It("with the total", func(ctx SpecContext) {
+			db.EXPECT().Count(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any()).
+				DoAndReturn(func(_, _ interface{}, _ *repo.Query, count *int64) *gorm.DB {
+					*count = 42
+					return &gorm.DB{Error: nil}
+				})
+			db.EXPECT().Find(gomock.Any(), gomock.Any(), gomock.Any(), 11, 0, gomock.Any()).Return(&gorm.DB{Error: nil})
+			page, err := userRepo.GetUsers(ctx, repo.NewQuery(), models.Pagination{Size: 10, Total: true}, false)
+			Expect(err).To(BeNil())
+			Expect(*page.Total).To(Equal(int64(42)))
 		})
 		It("with fail", func(ctx SpecContext) {
-			db.EXPECT().FindAll(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any()).Return(&gorm.DB{Error: errors.New("mock error")})
-			_, err := userRepo.GetUsers(ctx, 1, 1)
+			db.EXPECT().Find(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any()).Return(&gorm.DB{Error: errors.New("mock error")})
+			_, err := userRepo.GetUsers(ctx, repo.NewQuery(), models.Pagination{Size: 10}, false)
 			Expect(err).ToNot(BeNil())
 		})
+		It("including deleted ones", func(ctx SpecContext) {
+			unscoped := mock_repo.NewMockDBConnection(mockCtrl)
+			db.EXPECT().Unscoped().Return(unscoped)
+			unscoped.EXPECT().Find(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any()).Return(&gorm.DB{Error: nil})
+			_, err := userRepo.GetUsers(ctx, repo.NewQuery(), models.Pagination{Size: 10}, true)
+			Expect(err).To(BeNil())
+		})
 	})
 })
@@ -0,0 +1,122 @@
+package repo_test
+
+import (
+	"time"
+
+	"github.com/golang/mock/gomock"
+	"github.com/joomcode/errorx"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	errx "github.com/rhuandantas/verifymy-test/internal/errors"
+	"github.com/rhuandantas/verifymy-test/internal/models"
+	"github.com/rhuandantas/verifymy-test/internal/repo"
+	mock_log "github.com/rhuandantas/verifymy-test/test/mock/log"
+	mock_repo "github.com/rhuandantas/verifymy-test/test/mock/repo"
+	"gorm.io/gorm"
+)
+
+var _ = Describe("Test all verification repo methods", func() {
+	var (
+		mockCtrl         *gomock.Controller
+		db               *mock_repo.MockDBConnection
+		tx               *mock_repo.MockDBConnection
+		verificationRepo repo.VerificationRepo
+	)
+
+	BeforeEach(func() {
+		mockCtrl = gomock.NewController(GinkgoT())
+		db = mock_repo.NewMockDBConnection(mockCtrl)
+		tx = mock_repo.NewMockDBConnection(mockCtrl)
+		verificationRepo = repo.NewVerificationRepo(db, mock_log.NewMockSimpleLogger(mockCtrl))
+		db.EXPECT().Transaction(gomock.Any(), gomock.Any()).DoAndReturn(func(_ interface{}, fn func(repo.DBConnection) error) error {
+			return fn(tx)
+		}).AnyTimes()
+	})
+
+	Context("Get a verification", func() {
+		It("that was never started", func(ctx SpecContext) {
+			db.EXPECT().First(gomock.Any(), gomock.Any(), "user_id = ?", 1).Return(&gorm.DB{Error: gorm.ErrRecordNotFound})
+			_, err := verificationRepo.Get(ctx, 1)
+			Expect(errorx.IsOfType(err, errx.NotFound)).To(BeTrue())
+		})
+
+		It("by the evidence backing it", func(ctx SpecContext) {
+			db.EXPECT().First(gomock.Any(), gomock.Any(), "evidence_ref = ?", "fake:fake-1-1").
+				DoAndReturn(func(_ interface{}, dest interface{}, _ ...interface{}) *gorm.DB {
+					*dest.(*models.Verification) = models.Verification{UserId: 1, State: models.VerificationPending}
+					return &gorm.DB{Error: nil}
+				})
+			verification, err := verificationRepo.GetByEvidence(ctx, "fake:fake-1-1")
+			Expect(err).To(BeNil())
+			Expect(verification.UserId).To(Equal(1))
+		})
+	})
+
+	Context("List verifications", func() {
+		It("of many users keyed by user", func(ctx SpecContext) {
+			db.EXPECT().Find(gomock.Any(), gomock.Any(), gomock.Any(), 0, 0).
+				DoAndReturn(func(_, dest interface{}, _ *repo.Query, _, _ int, _ ...string) *gorm.DB {
+					*dest.(*[]*models.Verification) = []*models.Verification{
+						{UserId: 1, State: models.VerificationVerified},
+						{UserId: 3, State: models.VerificationPending},
+					}
+					return &gorm.DB{Error: nil}
+				})
+			byUser, err := verificationRepo.ListByUsers(ctx, []int{1, 2, 3})
+			Expect(err).To(BeNil())
+			Expect(byUser).To(HaveLen(2))
+			Expect(byUser[1].State).To(Equal(models.VerificationVerified))
+			Expect(byUser).ToNot(HaveKey(2))
+		})
+
+		It("of no users without reading", func(ctx SpecContext) {
+			byUser, err := verificationRepo.ListByUsers(ctx, nil)
+			Expect(err).To(BeNil())
+			Expect(byUser).To(BeEmpty())
+		})
+	})
+
+	Context("Save a verification", func() {
+		It("creating it with its first event", func(ctx SpecContext) {
+			tx.EXPECT().Insert(gomock.Any(), gomock.Any()).Return(&gorm.DB{Error: nil}).Times(2)
+			saved, err := verificationRepo.Save(ctx, models.Verification{UserId: 1, State: models.VerificationPending},
+				models.VerificationEvent{From: models.VerificationUnverified, To: models.VerificationPending})
+			Expect(err).To(BeNil())
+			Expect(saved.Version).To(Equal(1))
+		})
+
+		It("updating the version it was read at", func(ctx SpecContext) {
+			now := time.Now()
+			expiresAt := now.AddDate(1, 0, 0)
+			dateOfBirth := models.NewDate(1990, time.May, 4)
+			tx.EXPECT().Updates(gomock.Any(), gomock.Any(), map[string]interface{}{
+				"state":           models.VerificationVerified,
+				"method":          models.MethodDocument,
+				"assurance_level": models.AssuranceHigh,
+				"evidence_ref":    "evidence-1",
+				"requested_at":    &now,
+				"verified_at":     &now,
+				"expires_at":      &expiresAt,
+				"date_of_birth":   &dateOfBirth,
+				"timezone":        "Europe/Lisbon",
+				"version":         gorm.Expr("version + 1"),
+			}, "user_id = ? AND version = ?", 1, 2).Return(&gorm.DB{RowsAffected: 1})
+			tx.EXPECT().Insert(gomock.Any(), gomock.Any()).DoAndReturn(func(_, value interface{}) *gorm.DB {
+				Expect(value.(*models.VerificationEvent).UserId).To(Equal(1))
+				return &gorm.DB{Error: nil}
+			})
+			saved, err := verificationRepo.Save(ctx, models.Verification{UserId: 1, State: models.VerificationVerified, Method: models.MethodDocument,
+				AssuranceLevel: models.AssuranceHigh, EvidenceRef: "evidence-1", RequestedAt: &now, VerifiedAt: &now, ExpiresAt: &expiresAt,
+				DateOfBirth: &dateOfBirth, Timezone: "Europe/Lisbon", Version: 2},
+				models.VerificationEvent{From: models.VerificationPending, To: models.VerificationVerified})
+			Expect(err).To(BeNil())
+			Expect(saved.Version).To(Equal(3))
+		})
+
+		It("after another transition won", func(ctx SpecContext) {
+			tx.EXPECT().Updates(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any(), 1, 2).Return(&gorm.DB{RowsAffected: 0})
+			_, err := verificationRepo.Save(ctx, models.Verification{UserId: 1, State: models.VerificationVerified, Version: 2}, models.VerificationEvent{})
+			Expect(errorx.IsOfType(err, errx.Conflict)).To(BeTrue())
+		})
+	})
+})
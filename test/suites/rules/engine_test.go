@@ -0,0 +1,129 @@
+package rules_test
+
+import (
+	"github.com/golang/mock/gomock"
+	"github.com/joomcode/errorx"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"github.com/rhuandantas/verifymy-test/internal/models"
+	"github.com/rhuandantas/verifymy-test/internal/rules"
+	mock_config "github.com/rhuandantas/verifymy-test/test/mock/config"
+	mock_log "github.com/rhuandantas/verifymy-test/test/mock/log"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+func age(years int) *int {
+	return &years
+}
+
+func verified(years int, method string) rules.Subject {
+	return rules.Subject{Age: age(years), VerificationState: models.VerificationVerified, Method: method}
+}
+
+var _ = Describe("Test rules engine", func() {
+	var mockCtrl *gomock.Controller
+
+	newEngine := func(path string) (rules.Engine, error) {
+		config := mock_config.NewMockConfigProvider(mockCtrl)
+		config.EXPECT().GetStringOrDefault("rules.path", gomock.Any()).Return(path)
+		logger := mock_log.NewMockSimpleLogger(mockCtrl)
+		logger.EXPECT().Infof(gomock.Any(), gomock.Any()).AnyTimes()
+		return rules.NewFileEngine(config, logger)
+	}
+
+	writeRules := func(path, content string) {
+		Expect(os.WriteFile(path, []byte(content), 0o644)).To(Succeed())
+	}
+
+	BeforeEach(func() {
+		mockCtrl = gomock.NewController(GinkgoT())
+	})
+
+	Context("With the shipped rules", func() {
+		var engine rules.Engine
+
+		BeforeEach(func() {
+			var err error
+			engine, err = newEngine("../../../resources/rules.yml")
+			Expect(err).To(BeNil())
+		})
+
+		DescribeTable("evaluates",
+			func(subject rules.Subject, context rules.Context, allowed bool, reason string, minimumAge int) {
+				decision := engine.Evaluate(subject, context)
+				Expect(decision.Allowed).To(Equal(allowed))
+				Expect(decision.Reason).To(Equal(reason))
+				Expect(decision.Message).ToNot(BeEmpty())
+				Expect(decision.Rule.MinimumAge).To(Equal(minimumAge))
+			},
+			Entry("adults anywhere by default", verified(18, models.MethodEstimation), rules.Context{Jurisdiction: "FR", Category: "adult"}, true, rules.ReasonAllowed, 18),
+			Entry("minors anywhere by default", verified(17, models.MethodDocument), rules.Context{Jurisdiction: "FR", Category: "adult"}, false, rules.ReasonUnderAge, 18),
+			Entry("the category over the default", verified(20, models.MethodDocument), rules.Context{Jurisdiction: "US", Category: "alcohol"}, false, rules.ReasonUnderAge, 21),
+			Entry("the country of a subdivision", verified(21, models.MethodDocument), rules.Context{Jurisdiction: "US-CA", Category: "alcohol"}, true, rules.ReasonAllowed, 21),
+			Entry("the subdivision over its country", verified(30, models.MethodCreditCard), rules.Context{Jurisdiction: "US-UT", Category: "adult"}, false, rules.ReasonMethodNotAccepted, 18),
+			Entry("the category in any jurisdiction over the country", verified(19, models.MethodEstimation), rules.Context{Jurisdiction: "GB", Category: "gambling"}, false, rules.ReasonMethodNotAccepted, 18),
+			Entry("contexts in any case", verified(20, models.MethodDocument), rules.Context{Jurisdiction: " jp ", Category: "Alcohol"}, true, rules.ReasonAllowed, 20),
+			Entry("users not verified", rules.Subject{Age: age(40), VerificationState: models.VerificationPending}, rules.Context{Jurisdiction: "GB", Category: "adult"}, false, rules.ReasonNotVerified, 18),
+			Entry("verifications that expired", rules.Subject{Age: age(40), VerificationState: models.VerificationExpired, Method: models.MethodDocument}, rules.Context{Jurisdiction: "KR", Category: "alcohol"}, false, rules.ReasonNotVerified, 19),
+			Entry("minors without a guardian's consent", rules.Subject{Age: age(17), VerificationState: models.VerificationVerified, Method: models.MethodDocument, Restricted: true}, rules.Context{Jurisdiction: "FR", Category: "alcohol"}, false, rules.ReasonConsentRequired, 18),
+			Entry("users without a date of birth", rules.Subject{VerificationState: models.VerificationVerified, Method: models.MethodManual}, rules.Context{Jurisdiction: "DE", Category: "adult"}, false, rules.ReasonUnknownAge, 18),
+		)
+	})
+
+	Context("With rules files", func() {
+		var path string
+
+		BeforeEach(func() {
+			path = filepath.Join(GinkgoT().TempDir(), "rules.yml")
+		})
+
+		It("denies contexts no rule covers", func() {
+			writeRules(path, "rules:\n  - {jurisdiction: GB, category: adult, minimum-age: 18}\n")
+			engine, err := newEngine(path)
+			Expect(err).To(BeNil())
+			decision := engine.Evaluate(verified(30, models.MethodDocument), rules.Context{Jurisdiction: "FR", Category: "adult"})
+			Expect(decision.Allowed).To(BeFalse())
+			Expect(decision.Reason).To(Equal(rules.ReasonNoRule))
+			Expect(decision.Rule).To(BeNil())
+		})
+
+		DescribeTable("refuses invalid rules",
+			func(content string) {
+				writeRules(path, content)
+				_, err := newEngine(path)
+				Expect(errorx.IsOfType(err, errorx.IllegalArgument)).To(BeTrue())
+			},
+			Entry("unknown jurisdictions", "rules:\n  - {jurisdiction: Britain, category: adult, minimum-age: 18}\n"),
+			Entry("missing categories", "rules:\n  - {jurisdiction: GB, minimum-age: 18}\n"),
+			Entry("negative ages", "rules:\n  - {jurisdiction: GB, category: adult, minimum-age: -1}\n"),
+			Entry("unknown methods", "rules:\n  - {jurisdiction: GB, category: adult, minimum-age: 18, methods: [selfie]}\n"),
+			Entry("two rules for the same context", "rules:\n  - {jurisdiction: gb, category: adult, minimum-age: 18}\n  - {jurisdiction: GB, category: Adult, minimum-age: 21}\n"),
+		)
+
+		It("reloads the rules once the file changes", func(ctx SpecContext) {
+			writeRules(path, "rules:\n  - {jurisdiction: GB, category: adult, minimum-age: 18}\n")
+			engine, err := newEngine(path)
+			Expect(err).To(BeNil())
+			context := rules.Context{Jurisdiction: "GB", Category: "adult"}
+			Expect(engine.Evaluate(verified(19, models.MethodDocument), context).Allowed).To(BeTrue())
+
+			writeRules(path, "rules:\n  - {jurisdiction: GB, category: adult, minimum-age: 21}\n")
+			later := time.Now().Add(time.Second)
+			Expect(os.Chtimes(path, later, later)).To(Succeed())
+			Expect(engine.Reload(ctx)).To(Succeed())
+			Expect(engine.Evaluate(verified(19, models.MethodDocument), context).Reason).To(Equal(rules.ReasonUnderAge))
+		})
+
+		It("keeps the rules when the new file is broken", func(ctx SpecContext) {
+			writeRules(path, "rules:\n  - {jurisdiction: GB, category: adult, minimum-age: 18}\n")
+			engine, err := newEngine(path)
+			Expect(err).To(BeNil())
+
+			writeRules(path, "rules: [")
+			Expect(engine.Reload(ctx)).ToNot(Succeed())
+			Expect(engine.Evaluate(verified(19, models.MethodDocument), rules.Context{Jurisdiction: "GB", Category: "adult"}).Allowed).To(BeTrue())
+		})
+	})
+})
@@ -0,0 +1,90 @@
+package search_test
+
+import (
+	"context"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"github.com/rhuandantas/verifymy-test/internal/models"
+	"github.com/rhuandantas/verifymy-test/internal/search"
+	"gorm.io/gorm"
+	"time"
+)
+
+var _ = Describe("Test memory user searcher", func() {
+	var searcher *search.MemoryUserSearcher
+
+	BeforeEach(func() {
+		searcher = search.NewMemoryUserSearcher(
+			&models.User{UserId: 1, Name: "Jon Snow", Email: "jon@email.com", Address: "Castle Black"},
+			&models.User{UserId: 2, Name: "Arya Stark", Email: "arya@email.com", Address: "Winterfell"},
+			&models.User{UserId: 3, Name: "Sansa Stark", Email: "sansa@email.com", Address: "Winterfell <north>"},
+			&models.User{UserId: 4, Name: "Jon Arryn", Email: "arryn@email.com", Address: "Eyrie",
+				DeletedAt: gorm.DeletedAt{Time: time.Now(), Valid: true}},
+		)
+	})
+
+	ids := func(hits []search.Hit) []int {
+		userIds := make([]int, 0, len(hits))
+		for _, hit := range hits {
+			userIds = append(userIds, hit.User.UserId)
+		}
+		return userIds
+	}
+
+	It("ranks users having more of the words first", func() {
+		hits, err := searcher.Search(context.Background(), "stark winterfell", 10)
+		Expect(err).To(BeNil())
+		Expect(ids(hits)).To(Equal([]int{2, 3}))
+		Expect(hits[0].Score).To(Equal(1.0))
+		Expect(hits[0].Fuzzy).To(BeFalse())
+		Expect(hits[0].Highlights).To(Equal(map[string]string{
+			"name":    "Arya <em>Stark</em>",
+			"address": "<em>Winterfell</em>",
+		}))
+		Expect(hits[1].Highlights["address"]).To(Equal("<em>Winterfell</em> &lt;north&gt;"))
+
+		hits, err = searcher.Search(context.Background(), "jon stark", 10)
+		Expect(err).To(BeNil())
+		Expect(ids(hits)).To(Equal([]int{1, 2, 3}))
+		Expect(hits[0].Highlights).To(Equal(map[string]string{
+			"name":  "<em>Jon</em> Snow",
+			"email": "<em>jon</em>@email.com",
+		}))
+		Expect(hits[0].Score).To(Equal(0.5))
+	})
+
+	It("falls back to words within a few typos", func() {
+		hits, err := searcher.Search(context.Background(), "Wintrfell", 10)
+		Expect(err).To(BeNil())
+		Expect(ids(hits)).To(Equal([]int{2, 3}))
+		Expect(hits[0].Fuzzy).To(BeTrue())
+		Expect(hits[0].Score).To(BeNumerically("~", 0.9, 0.001))
+		Expect(hits[0].Highlights).To(Equal(map[string]string{"address": "<em>Winterfell</em>"}))
+
+		hits, err = searcher.Search(context.Background(), "Jn", 10)
+		Expect(err).To(BeNil())
+		Expect(hits).To(BeEmpty())
+	})
+
+	It("leaves deleted users out and keeps to the limit", func() {
+		hits, err := searcher.Search(context.Background(), "arryn", 10)
+		Expect(err).To(BeNil())
+		Expect(ids(hits)).To(BeEmpty())
+
+		hits, err = searcher.Search(context.Background(), "email", 2)
+		Expect(err).To(BeNil())
+		Expect(ids(hits)).To(Equal([]int{1, 2}))
+	})
+
+	It("searches the indexed users", func() {
+		searcher.Remove(1)
+		searcher.Index(&models.User{UserId: 5, Name: "Jon Connington", Email: "jc@email.com"})
+		hits, err := searcher.Search(context.Background(), "jon", 10)
+		Expect(err).To(BeNil())
+		Expect(ids(hits)).To(Equal([]int{5}))
+
+		hits, err = searcher.Search(context.Background(), " ,. ", 10)
+		Expect(err).To(BeNil())
+		Expect(hits).To(BeEmpty())
+	})
+})
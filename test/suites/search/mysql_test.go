@@ -0,0 +1,66 @@
+package search_test
+
+import (
+	"context"
+	"github.com/golang/mock/gomock"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"github.com/rhuandantas/verifymy-test/internal/search"
+	mock_config "github.com/rhuandantas/verifymy-test/test/mock/config"
+	mock_repo "github.com/rhuandantas/verifymy-test/test/mock/repo"
+	"gorm.io/driver/mysql"
+	"gorm.io/gorm"
+)
+
+var _ = Describe("Test mysql user searcher", func() {
+	var (
+		mockCtrl   *gomock.Controller
+		searcher   search.UserSearcher
+		statements []*gorm.Statement
+	)
+
+	BeforeEach(func() {
+		mockCtrl = gomock.NewController(GinkgoT())
+		// a dry run only renders the sql, nothing connects to the database and no rows are found
+		db, err := gorm.Open(mysql.New(mysql.Config{DSN: "user:pass@tcp(127.0.0.1:3306)/verifymy", SkipInitializeWithVersion: true}),
+			&gorm.Config{DryRun: true, DisableAutomaticPing: true})
+		Expect(err).To(BeNil())
+		statements = nil
+		Expect(db.Callback().Query().After("gorm:query").Register("test:capture", func(tx *gorm.DB) {
+			statements = append(statements, tx.Statement)
+		})).To(Succeed())
+
+		conn := mock_repo.NewMockDBConnection(mockCtrl)
+		conn.EXPECT().GetDB().Return(db).AnyTimes()
+		config := mock_config.NewMockConfigProvider(mockCtrl)
+		config.EXPECT().GetInt("users.search.fuzzy-candidates").Return(50)
+		searcher = search.NewMysqlUserSearcher(config, conn)
+	})
+
+	It("ranks with the fulltext index and looks for fuzzy candidates when nothing matches", func() {
+		hits, err := searcher.Search(context.Background(), "Jon  Sn%w", 10)
+		Expect(err).To(BeNil())
+		Expect(hits).To(BeEmpty())
+		Expect(statements).To(HaveLen(2))
+
+		Expect(statements[0].SQL.String()).To(Equal("SELECT user_id, name, date_of_birth, timezone, email, address, status, created_at, updated_at, version, deleted_at, " +
+			"MATCH (name, email, address) AGAINST (? IN NATURAL LANGUAGE MODE) AS score FROM `users` " +
+			"WHERE MATCH (name, email, address) AGAINST (? IN NATURAL LANGUAGE MODE) AND `users`.`deleted_at` IS NULL " +
+			"ORDER BY score DESC,user_id LIMIT 10"))
+		Expect(statements[0].Vars).To(Equal([]interface{}{"jon sn w", "jon sn w"}))
+
+		Expect(statements[1].SQL.String()).To(Equal("SELECT `user_id`,`name`,`date_of_birth`,`timezone`,`email`,`address`,`status`,`created_at`,`updated_at`,`version`,`deleted_at` " +
+			"FROM `users` WHERE (`name` LIKE ? OR `name` LIKE ? OR `email` LIKE ? OR `email` LIKE ? OR `address` LIKE ? OR `address` LIKE ? OR " +
+			"`name` LIKE ? OR `name` LIKE ? OR `email` LIKE ? OR `email` LIKE ? OR `address` LIKE ? OR `address` LIKE ?) " +
+			"AND `users`.`deleted_at` IS NULL ORDER BY user_id LIMIT 50"))
+		Expect(statements[1].Vars).To(Equal([]interface{}{"jo%", "% jo%", "jo%", "% jo%", "jo%", "% jo%",
+			"sn%", "% sn%", "sn%", "% sn%", "sn%", "% sn%"}))
+	})
+
+	It("doesn't query for text without words", func() {
+		hits, err := searcher.Search(context.Background(), "- _ -", 10)
+		Expect(err).To(BeNil())
+		Expect(hits).To(BeEmpty())
+		Expect(statements).To(BeEmpty())
+	})
+})
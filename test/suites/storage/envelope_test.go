@@ -0,0 +1,94 @@
+package storage_test
+
+import (
+	"encoding/base64"
+
+	"github.com/golang/mock/gomock"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"github.com/rhuandantas/verifymy-test/internal/storage"
+	mock_config "github.com/rhuandantas/verifymy-test/test/mock/config"
+	mock_log "github.com/rhuandantas/verifymy-test/test/mock/log"
+)
+
+var _ = Describe("Test envelope encryption", func() {
+	var (
+		mockCtrl *gomock.Controller
+		config   *mock_config.MockConfigProvider
+		envelope storage.Envelope
+		err      error
+	)
+
+	masterKey := base64.StdEncoding.EncodeToString([]byte("0123456789abcdef0123456789abcdef"))
+
+	BeforeEach(func() {
+		mockCtrl = gomock.NewController(GinkgoT())
+		config = mock_config.NewMockConfigProvider(mockCtrl)
+		config.EXPECT().GetString("storage.encryption.master-key").Return("STORAGE_MASTER_KEY").AnyTimes()
+		config.EXPECT().GetString("STORAGE_MASTER_KEY").Return(masterKey).AnyTimes()
+		envelope, err = storage.NewAesEnvelope(config, mock_log.NewMockSimpleLogger(mockCtrl))
+		Expect(err).To(BeNil())
+	})
+
+	It("opens what it sealed", func() {
+		sealed, err := envelope.Seal([]byte("id scan"), []byte("documents/1/a"))
+		Expect(err).To(BeNil())
+		Expect(sealed.Ciphertext).ToNot(ContainSubstring("id scan"))
+		Expect(sealed.KeyId).To(HaveLen(16))
+
+		content, err := envelope.Open(*sealed, []byte("documents/1/a"))
+		Expect(err).To(BeNil())
+		Expect(string(content)).To(Equal("id scan"))
+	})
+
+	It("seals each object with its own key", func() {
+		first, _ := envelope.Seal([]byte("id scan"), nil)
+		second, _ := envelope.Seal([]byte("id scan"), nil)
+		Expect(first.WrappedKey).ToNot(Equal(second.WrappedKey))
+		Expect(first.Ciphertext).ToNot(Equal(second.Ciphertext))
+	})
+
+	It("refuses content stored somewhere else", func() {
+		sealed, _ := envelope.Seal([]byte("id scan"), []byte("documents/1/a"))
+		_, err := envelope.Open(*sealed, []byte("documents/2/a"))
+		Expect(err).ToNot(BeNil())
+	})
+
+	It("refuses tampered content", func() {
+		sealed, _ := envelope.Seal([]byte("id scan"), nil)
+		sealed.Ciphertext[len(sealed.Ciphertext)-1] ^= 1
+		_, err := envelope.Open(*sealed, nil)
+		Expect(err).ToNot(BeNil())
+	})
+
+	It("refuses content sealed with another master key", func() {
+		other := mock_config.NewMockConfigProvider(mockCtrl)
+		other.EXPECT().GetString("storage.encryption.master-key").Return("")
+		other.EXPECT().GetBool("dev.temporary-keys").Return(true)
+		logger := mock_log.NewMockSimpleLogger(mockCtrl)
+		logger.EXPECT().Warn(gomock.Any())
+		temporary, err := storage.NewAesEnvelope(other, logger)
+		Expect(err).To(BeNil())
+
+		sealed, _ := temporary.Seal([]byte("id scan"), nil)
+		_, err = envelope.Open(*sealed, nil)
+		Expect(err).To(MatchError(ContainSubstring("master key")))
+	})
+
+	It("without a master key outside of development", func() {
+		config := mock_config.NewMockConfigProvider(mockCtrl)
+		config.EXPECT().GetString("storage.encryption.master-key").Return("STORAGE_MASTER_KEY")
+		config.EXPECT().GetString("STORAGE_MASTER_KEY").Return("")
+		config.EXPECT().GetBool("dev.temporary-keys").Return(false)
+		_, err := storage.NewAesEnvelope(config, mock_log.NewMockSimpleLogger(mockCtrl))
+		Expect(err).To(MatchError(ContainSubstring("STORAGE_MASTER_KEY")))
+	})
+
+	It("with a master key of the wrong size", func() {
+		config := mock_config.NewMockConfigProvider(mockCtrl)
+		config.EXPECT().GetString("storage.encryption.master-key").Return("STORAGE_MASTER_KEY").AnyTimes()
+		config.EXPECT().GetString("STORAGE_MASTER_KEY").Return(base64.StdEncoding.EncodeToString([]byte("short"))).AnyTimes()
+		_, err := storage.NewAesEnvelope(config, mock_log.NewMockSimpleLogger(mockCtrl))
+		Expect(err).ToNot(BeNil())
+	})
+})
@@ -0,0 +1,46 @@
+package util_test
+
+import (
+	"github.com/golang/mock/gomock"
+	"github.com/joomcode/errorx"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	errx "github.com/rhuandantas/verifymy-test/internal/errors"
+	"github.com/rhuandantas/verifymy-test/internal/i18n"
+	"github.com/rhuandantas/verifymy-test/internal/util"
+	mock_config "github.com/rhuandantas/verifymy-test/test/mock/config"
+)
+
+var _ = Describe("Test password policy", func() {
+	var policy util.PasswordPolicy
+
+	BeforeEach(func() {
+		config := mock_config.NewMockConfigProvider(gomock.NewController(GinkgoT()))
+		config.EXPECT().GetStringOrDefault("i18n.default-locale", gomock.Any()).Return("en")
+		config.EXPECT().GetStringOrDefault("i18n.path", gomock.Any()).Return("../../../resources/i18n")
+		config.EXPECT().GetInt("users.password.min-length").Return(0)
+		config.EXPECT().GetBool("users.password.require-letter").Return(true)
+		config.EXPECT().GetBool("users.password.require-digit").Return(true)
+		translator, err := i18n.NewCatalogTranslator(config)
+		Expect(err).To(BeNil())
+		policy = util.NewPasswordPolicy(config, translator)
+	})
+
+	It("accepts a password following every rule", func() {
+		Expect(policy.Check("winter1sComing")).To(Succeed())
+	})
+
+	It("reports every rule the password breaks", func() {
+		err := policy.Check("1234")
+		Expect(errorx.IsOfType(err, errx.Validation)).To(BeTrue())
+		Expect(errx.FieldErrors(errorx.Cast(err))).To(ConsistOf(
+			errx.FieldError{Field: "password", Rule: "password_min", Param: "8", Message: "password must have at least 8 characters"},
+			errx.FieldError{Field: "password", Rule: "password_letter", Message: "password must contain a letter"},
+		))
+	})
+
+	It("counts characters rather than bytes", func() {
+		err := policy.Check("ção1")
+		Expect(errx.FieldErrors(errorx.Cast(err))).To(HaveLen(1))
+	})
+})
@@ -0,0 +1,130 @@
+package util_test
+
+import (
+	"github.com/golang/mock/gomock"
+	"github.com/joomcode/errorx"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	errx "github.com/rhuandantas/verifymy-test/internal/errors"
+	"github.com/rhuandantas/verifymy-test/internal/i18n"
+	"github.com/rhuandantas/verifymy-test/internal/models"
+	"github.com/rhuandantas/verifymy-test/internal/util"
+	mock_config "github.com/rhuandantas/verifymy-test/test/mock/config"
+	"time"
+)
+
+var _ = Describe("Test validator methods", func() {
+	var validator util.Validator
+
+	BeforeEach(func() {
+		config := mock_config.NewMockConfigProvider(gomock.NewController(GinkgoT()))
+		config.EXPECT().GetStringOrDefault("i18n.default-locale", gomock.Any()).Return("en")
+		config.EXPECT().GetStringOrDefault("i18n.path", gomock.Any()).Return("../../../resources/i18n")
+		translator, err := i18n.NewCatalogTranslator(config)
+		Expect(err).To(BeNil())
+		validator = util.NewCustomValidator(translator)
+	})
+
+	It("validate struct successfully", func(ctx SpecContext) {
+		err := validator.ValidateStruct(models.User{Email: "jon@email.com"})
+		Expect(err).To(BeNil())
+	})
+
+	It("report field errors by json name", func(ctx SpecContext) {
+		err := validator.ValidateStruct(models.User{Name: "Jon Snow"})
+		Expect(errorx.IsOfType(err, errx.Validation)).To(BeTrue())
+		Expect(errx.FieldErrors(errorx.Cast(err))).To(ConsistOf(errx.FieldError{
+			Field:   "email",
+			Rule:    "required",
+			Message: "email is required",
+		}))
+	})
+
+	It("validate postal codes by country", func(ctx SpecContext) {
+		address := models.Address{Type: models.AddressHome, Line1: "1 Main St", City: "Springfield", PostalCode: "62704", Country: "US"}
+		Expect(validator.ValidateStruct(address)).To(BeNil())
+
+		address.PostalCode = "62704-1234"
+		Expect(validator.ValidateStruct(address)).To(BeNil())
+
+		address.Country, address.PostalCode = "GB", "SW1A 1AA"
+		Expect(validator.ValidateStruct(address)).To(BeNil())
+
+		address.Country, address.PostalCode = "BR", "12345"
+		err := validator.ValidateStruct(address)
+		Expect(errx.FieldErrors(errorx.Cast(err))).To(ConsistOf(errx.FieldError{
+			Field:   "postal_code",
+			Rule:    "postal_code",
+			Param:   "Country",
+			Message: "postal_code is not a valid postal code for the country",
+		}))
+	})
+
+	It("report unknown countries", func(ctx SpecContext) {
+		err := validator.ValidateStruct(models.Address{Type: models.AddressBilling, Line1: "1 Main St", City: "Nowhere", Country: "XX"})
+		Expect(errx.FieldErrors(errorx.Cast(err))).To(ConsistOf(errx.FieldError{
+			Field:   "country",
+			Rule:    "iso3166_1_alpha2",
+			Message: "country must be an ISO 3166-1 alpha-2 country code",
+		}))
+	})
+
+	It("validate dates of birth", func(ctx SpecContext) {
+		birth := models.NewDate(1993, 5, 17)
+		Expect(validator.ValidateStruct(models.User{Email: "jon@email.com", DateOfBirth: &birth, Timezone: "Europe/London"})).To(BeNil())
+
+		tomorrow := models.Today(time.UTC).AddDays(2)
+		err := validator.ValidateStruct(models.User{Email: "jon@email.com", DateOfBirth: &tomorrow})
+		Expect(errx.FieldErrors(errorx.Cast(err))).To(ConsistOf(errx.FieldError{
+			Field:   "date_of_birth",
+			Rule:    "date_of_birth",
+			Param:   "Timezone",
+			Message: "date_of_birth must not be in the future nor more than 150 years back",
+		}))
+
+		ancient := models.NewDate(1850, 1, 1)
+		err = validator.ValidateStruct(models.User{Email: "jon@email.com", DateOfBirth: &ancient})
+		Expect(errx.FieldErrors(errorx.Cast(err))[0].Rule).To(Equal("date_of_birth"))
+	})
+
+	It("report unknown timezones", func(ctx SpecContext) {
+		err := validator.ValidateStruct(models.User{Email: "jon@email.com", Timezone: "Mars/Olympus"})
+		Expect(errx.FieldErrors(errorx.Cast(err))).To(ConsistOf(errx.FieldError{
+			Field:   "timezone",
+			Rule:    "timezone",
+			Message: "timezone must be an IANA timezone like Europe/London",
+		}))
+	})
+
+	It("report rule params", func(ctx SpecContext) {
+		err := validator.ValidateStruct(models.Pagination{Page: 0, Size: 1})
+		Expect(errx.FieldErrors(errorx.Cast(err))).To(ConsistOf(errx.FieldError{
+			Field:   "size",
+			Rule:    "min",
+			Param:   "10",
+			Message: "size must be at least 10",
+		}))
+	})
+
+	It("validate client ids, origins and redirect uris", func(ctx SpecContext) {
+		registration := models.ClientRegistration{
+			ClientId:       "shop-example",
+			Name:           "Shop",
+			AllowedOrigins: []string{"https://shop.example", "http://localhost:3000"},
+			RedirectUris:   []string{"https://shop.example/verified?step=2", "http://127.0.0.1:8080/callback"},
+		}
+		Expect(validator.ValidateStruct(registration)).To(BeNil())
+
+		registration.ClientId = "Shop.Example"
+		registration.AllowedOrigins = []string{"http://shop.example", "https://shop.example/path"}
+		registration.RedirectUris = []string{"https://shop.example/#fragment", "javascript:alert(1)"}
+		err := validator.ValidateStruct(registration)
+		Expect(errx.FieldErrors(errorx.Cast(err))).To(ConsistOf(
+			errx.FieldError{Field: "client_id", Rule: "slug", Message: "client_id must be lowercase letters, digits and dashes"},
+			HaveField("Field", "allowed_origins[0]"),
+			HaveField("Field", "allowed_origins[1]"),
+			HaveField("Field", "redirect_uris[0]"),
+			HaveField("Field", "redirect_uris[1]"),
+		))
+	})
+})
@@ -0,0 +1,188 @@
+package verification_test
+
+import (
+	"github.com/golang/mock/gomock"
+	"github.com/joomcode/errorx"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	errx "github.com/rhuandantas/verifymy-test/internal/errors"
+	"github.com/rhuandantas/verifymy-test/internal/models"
+	"github.com/rhuandantas/verifymy-test/internal/verification"
+	mock_config "github.com/rhuandantas/verifymy-test/test/mock/config"
+	mock_log "github.com/rhuandantas/verifymy-test/test/mock/log"
+	mock_repo "github.com/rhuandantas/verifymy-test/test/mock/repo"
+	mock_verification "github.com/rhuandantas/verifymy-test/test/mock/verification"
+	"net/http"
+)
+
+var _ = Describe("Test provider checks", func() {
+	var (
+		mockCtrl         *gomock.Controller
+		service          *mock_verification.MockService
+		reviews          *mock_verification.MockReviews
+		userRepo         *mock_repo.MockUserRepo
+		verificationRepo *mock_repo.MockVerificationRepo
+		fake             *verification.FakeProvider
+		checks           verification.Checks
+	)
+
+	BeforeEach(func() {
+		mockCtrl = gomock.NewController(GinkgoT())
+		service = mock_verification.NewMockService(mockCtrl)
+		reviews = mock_verification.NewMockReviews(mockCtrl)
+		userRepo = mock_repo.NewMockUserRepo(mockCtrl)
+		verificationRepo = mock_repo.NewMockVerificationRepo(mockCtrl)
+		logger := mock_log.NewMockSimpleLogger(mockCtrl)
+		logger.EXPECT().Infof(gomock.Any(), gomock.Any()).AnyTimes()
+		config := mock_config.NewMockConfigProvider(mockCtrl)
+		config.EXPECT().GetString("verification.providers.fake.secret-key").Return("FAKE_PROVIDER_SECRET")
+		config.EXPECT().GetString("FAKE_PROVIDER_SECRET").Return("secret")
+		config.EXPECT().GetString("verification.default-provider").Return("fake")
+		config.EXPECT().GetBool("verification.providers.fake.enabled").Return(true)
+		fake = verification.NewFakeProvider(config)
+		registry, err := verification.NewProviderRegistry(config, fake)
+		Expect(err).To(BeNil())
+		checks = verification.NewProviderChecks(registry, service, reviews, userRepo, verificationRepo, logger)
+	})
+
+	status := func(state, evidenceRef string) *models.VerificationStatus {
+		return &models.VerificationStatus{Verification: &models.Verification{UserId: 1, State: state, EvidenceRef: evidenceRef}}
+	}
+
+	start := func(ctx SpecContext, email string) *verification.Check {
+		service.EXPECT().Get(gomock.Any(), 1).Return(status(models.VerificationUnverified, ""), nil)
+		userRepo.EXPECT().GetByID(gomock.Any(), 1).Return(&models.User{UserId: 1, Email: email}, nil)
+		service.EXPECT().Transition(gomock.Any(), 1, gomock.Any(), "admin").
+			DoAndReturn(func(_ interface{}, _ int, transition models.VerificationTransition, _ string) (*models.VerificationStatus, error) {
+				Expect(transition.State).To(Equal(models.VerificationPending))
+				Expect(transition.Method).To(Equal(models.MethodDocument))
+				return status(transition.State, transition.EvidenceRef), nil
+			})
+		check, err := checks.Start(ctx, 1, "", "admin")
+		Expect(err).To(BeNil())
+		return check
+	}
+
+	It("starts a check with the default provider leaving the verification pending", func(ctx SpecContext) {
+		check := start(ctx, "john@mail.com")
+		Expect(check.Session.Reference).To(Equal("fake-1-1"))
+		Expect(check.Verification.EvidenceRef).To(Equal("fake:fake-1-1"))
+	})
+
+	It("refuses providers that aren't enabled", func(ctx SpecContext) {
+		_, err := checks.Start(ctx, 1, "acme", "admin")
+		Expect(errorx.IsOfType(err, errx.Validation)).To(BeTrue())
+	})
+
+	It("doesn't open a check when the verification can't go pending", func(ctx SpecContext) {
+		service.EXPECT().Get(gomock.Any(), 1).Return(status(models.VerificationVerified, "fake:fake-1-1"), nil)
+		_, err := checks.Start(ctx, 1, "fake", "admin")
+		Expect(errorx.IsOfType(err, errx.InvalidTransition)).To(BeTrue())
+	})
+
+	It("applies the polled outcome on behalf of the provider", func(ctx SpecContext) {
+		start(ctx, "jane+reject@mail.com")
+		service.EXPECT().Get(gomock.Any(), 1).Return(status(models.VerificationPending, "fake:fake-1-1"), nil)
+		service.EXPECT().Transition(gomock.Any(), 1, models.VerificationTransition{
+			State:       models.VerificationRejected,
+			Method:      models.MethodDocument,
+			EvidenceRef: "fake:fake-1-1",
+			Reason:      "document doesn't match the user",
+		}, "provider:fake").Return(status(models.VerificationRejected, "fake:fake-1-1"), nil)
+		res, err := checks.Poll(ctx, 1)
+		Expect(err).To(BeNil())
+		Expect(res.State).To(Equal(models.VerificationRejected))
+	})
+
+	It("leaves checks still pending as they are", func(ctx SpecContext) {
+		start(ctx, "jane+pending@mail.com")
+		service.EXPECT().Get(gomock.Any(), 1).Return(status(models.VerificationPending, "fake:fake-1-1"), nil)
+		res, err := checks.Poll(ctx, 1)
+		Expect(err).To(BeNil())
+		Expect(res.State).To(Equal(models.VerificationPending))
+	})
+
+	It("hands inconclusive checks over to a reviewer", func(ctx SpecContext) {
+		start(ctx, "jane+review@mail.com")
+		service.EXPECT().Get(gomock.Any(), 1).Return(status(models.VerificationPending, "fake:fake-1-1"), nil)
+		reviews.EXPECT().Open(gomock.Any(), models.ReviewRequest{UserId: 1, Reason: "inconclusive fake check: document is too blurry to read"}, "provider:fake").
+			Return(&models.ReviewCaseDetail{ReviewCase: &models.ReviewCase{CaseId: 1, UserId: 1, Status: models.ReviewOpen}}, nil)
+		res, err := checks.Poll(ctx, 1)
+		Expect(err).To(BeNil())
+		Expect(res.State).To(Equal(models.VerificationPending))
+	})
+
+	Context("Provider registry", func() {
+		registry := func(defaultProvider string, enabled bool) (*verification.ProviderRegistry, error) {
+			config := mock_config.NewMockConfigProvider(mockCtrl)
+			config.EXPECT().GetString("verification.default-provider").Return(defaultProvider)
+			config.EXPECT().GetBool("verification.providers.fake.enabled").Return(enabled)
+			return verification.NewProviderRegistry(config, fake)
+		}
+
+		It("fails when the default provider isn't enabled", func() {
+			_, err := registry("fake", false)
+			Expect(err).ToNot(BeNil())
+		})
+
+		It("starts without any provider", func() {
+			providers, err := registry("", false)
+			Expect(err).To(BeNil())
+			Expect(providers.Names()).To(BeEmpty())
+			_, err = providers.Get("")
+			Expect(errorx.IsOfType(err, errx.Validation)).To(BeTrue())
+		})
+	})
+
+	Context("Callbacks", func() {
+		body := []byte(`{"reference":"fake-1-1","status":"verified","assurance_level":"high"}`)
+
+		It("verifies the user with a signed outcome", func(ctx SpecContext) {
+			start(ctx, "jane+pending@mail.com")
+			verificationRepo.EXPECT().GetByEvidence(gomock.Any(), "fake:fake-1-1").Return(status(models.VerificationPending, "fake:fake-1-1").Verification, nil)
+			service.EXPECT().Get(gomock.Any(), 1).Return(status(models.VerificationPending, "fake:fake-1-1"), nil)
+			service.EXPECT().Transition(gomock.Any(), 1, models.VerificationTransition{
+				State:          models.VerificationVerified,
+				Method:         models.MethodDocument,
+				AssuranceLevel: models.AssuranceHigh,
+				EvidenceRef:    "fake:fake-1-1",
+			}, "provider:fake").Return(status(models.VerificationVerified, "fake:fake-1-1"), nil)
+			header := http.Header{}
+			header.Set(verification.FakeSignatureHeader, fake.Sign(body))
+			res, err := checks.Callback(ctx, "fake", header, body)
+			Expect(err).To(BeNil())
+			Expect(res.State).To(Equal(models.VerificationVerified))
+		})
+
+		It("ignores outcomes redelivered after the check settled", func(ctx SpecContext) {
+			verificationRepo.EXPECT().GetByEvidence(gomock.Any(), "fake:fake-1-1").Return(status(models.VerificationVerified, "fake:fake-1-1").Verification, nil)
+			service.EXPECT().Get(gomock.Any(), 1).Return(status(models.VerificationVerified, "fake:fake-1-1"), nil)
+			header := http.Header{}
+			header.Set(verification.FakeSignatureHeader, fake.Sign(body))
+			res, err := checks.Callback(ctx, "fake", header, body)
+			Expect(err).To(BeNil())
+			Expect(res.State).To(Equal(models.VerificationVerified))
+		})
+
+		It("refuses callbacks with a wrong signature", func(ctx SpecContext) {
+			header := http.Header{}
+			header.Set(verification.FakeSignatureHeader, fake.Sign([]byte(`{}`)))
+			_, err := checks.Callback(ctx, "fake", header, body)
+			Expect(errorx.IsOfType(err, errx.Unauthorized)).To(BeTrue())
+		})
+
+		It("refuses callbacks of unknown providers", func(ctx SpecContext) {
+			_, err := checks.Callback(ctx, "acme", http.Header{}, body)
+			Expect(errorx.IsOfType(err, errx.NotFound)).To(BeTrue())
+		})
+	})
+
+	It("lets tests decide the outcome of a started check", func(ctx SpecContext) {
+		start(ctx, "jane+pending@mail.com")
+		Expect(fake.Decide(verification.Result{Reference: "fake-1-1", Status: models.VerificationVerified, AssuranceLevel: models.AssuranceLow})).To(Succeed())
+		result, err := fake.Poll(ctx, "fake-1-1")
+		Expect(err).To(BeNil())
+		Expect(result.AssuranceLevel).To(Equal(models.AssuranceLow))
+		Expect(errorx.IsOfType(fake.Decide(verification.Result{Reference: "fake-1-9"}), errx.NotFound)).To(BeTrue())
+	})
+})
@@ -0,0 +1,162 @@
+package verification_test
+
+import (
+	"errors"
+	"time"
+
+	"github.com/golang/mock/gomock"
+	"github.com/joomcode/errorx"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	errx "github.com/rhuandantas/verifymy-test/internal/errors"
+	"github.com/rhuandantas/verifymy-test/internal/models"
+	"github.com/rhuandantas/verifymy-test/internal/storage"
+	"github.com/rhuandantas/verifymy-test/internal/verification"
+	mock_config "github.com/rhuandantas/verifymy-test/test/mock/config"
+	mock_log "github.com/rhuandantas/verifymy-test/test/mock/log"
+	mock_repo "github.com/rhuandantas/verifymy-test/test/mock/repo"
+	mock_storage "github.com/rhuandantas/verifymy-test/test/mock/storage"
+	mock_verification "github.com/rhuandantas/verifymy-test/test/mock/verification"
+)
+
+var _ = Describe("Test verification documents", func() {
+	var (
+		mockCtrl     *gomock.Controller
+		service      *mock_verification.MockService
+		documentRepo *mock_repo.MockDocumentRepo
+		blobs        *mock_storage.MockBlobStore
+		envelope     *mock_storage.MockEnvelope
+		logger       *mock_log.MockSimpleLogger
+		documents    verification.Documents
+	)
+
+	png := append([]byte("\x89PNG\r\n\x1a\n"), make([]byte, 24)...)
+
+	BeforeEach(func() {
+		mockCtrl = gomock.NewController(GinkgoT())
+		service = mock_verification.NewMockService(mockCtrl)
+		documentRepo = mock_repo.NewMockDocumentRepo(mockCtrl)
+		blobs = mock_storage.NewMockBlobStore(mockCtrl)
+		envelope = mock_storage.NewMockEnvelope(mockCtrl)
+		logger = mock_log.NewMockSimpleLogger(mockCtrl)
+		logger.EXPECT().Infof(gomock.Any(), gomock.Any()).AnyTimes()
+		config := mock_config.NewMockConfigProvider(mockCtrl)
+		config.EXPECT().GetInt("verification.documents.max-bytes").Return(64)
+		config.EXPECT().GetStringSlice("verification.documents.allowed-types").Return(nil)
+		config.EXPECT().GetInt("verification.documents.retention-days").Return(7)
+		documents = verification.NewDocumentStore(config, service, documentRepo, blobs, envelope, logger)
+	})
+
+	started := func() {
+		service.EXPECT().Get(gomock.Any(), 1).Return(&models.VerificationStatus{Verification: &models.Verification{UserId: 1, State: models.VerificationPending}}, nil)
+	}
+
+	Context("Upload a document", func() {
+		It("encrypted under a key of the user, expiring after the retention", func(ctx SpecContext) {
+			started()
+			var key string
+			envelope.EXPECT().Seal(png, gomock.Any()).DoAndReturn(func(_, aad []byte) (*storage.Sealed, error) {
+				key = string(aad)
+				Expect(key).To(HavePrefix("documents/1/"))
+				return &storage.Sealed{Ciphertext: []byte("sealed"), WrappedKey: []byte("wrapped"), KeyId: "k1"}, nil
+			})
+			blobs.EXPECT().Put(gomock.Any(), gomock.Any(), []byte("sealed")).DoAndReturn(func(_ interface{}, blobKey string, _ []byte) error {
+				Expect(blobKey).To(Equal(key))
+				return nil
+			})
+			documentRepo.EXPECT().Create(gomock.Any(), gomock.Any()).DoAndReturn(func(_ interface{}, document models.VerificationDocument) (*models.VerificationDocument, error) {
+				Expect(document.BlobKey).To(Equal(key))
+				Expect(document.WrappedKey).To(Equal([]byte("wrapped")))
+				Expect(document.ContentType).To(Equal("image/png"))
+				Expect(document.Size).To(Equal(int64(len(png))))
+				Expect(document.UploadedBy).To(Equal("partner:partner-a"))
+				Expect(document.ExpiresAt).To(BeTemporally("~", time.Now().Add(7*24*time.Hour), time.Minute))
+				document.DocumentId = 5
+				return &document, nil
+			})
+			document, err := documents.Upload(ctx, 1, png, "partner:partner-a")
+			Expect(err).To(BeNil())
+			Expect(document.EvidenceRef()).To(Equal("document:5"))
+		})
+
+		It("only for a started verification", func(ctx SpecContext) {
+			service.EXPECT().Get(gomock.Any(), 1).Return(&models.VerificationStatus{Verification: &models.Verification{UserId: 1, State: models.VerificationUnverified}}, nil)
+			_, err := documents.Upload(ctx, 1, png, "partner:partner-a")
+			Expect(errorx.IsOfType(err, errx.InvalidTransition)).To(BeTrue())
+		})
+
+		It("refusing empty and too large documents", func(ctx SpecContext) {
+			service.EXPECT().Get(gomock.Any(), 1).Return(&models.VerificationStatus{Verification: &models.Verification{UserId: 1, State: models.VerificationPending}}, nil).Times(2)
+			_, err := documents.Upload(ctx, 1, nil, "partner:partner-a")
+			Expect(errx.FieldErrors(errorx.Cast(err))).To(ConsistOf(HaveField("Rule", "required")))
+			_, err = documents.Upload(ctx, 1, append(png, make([]byte, 64)...), "partner:partner-a")
+			Expect(errx.FieldErrors(errorx.Cast(err))).To(ConsistOf(HaveField("Rule", "max")))
+		})
+
+		It("refusing types not allowed, whatever the caller says", func(ctx SpecContext) {
+			started()
+			_, err := documents.Upload(ctx, 1, []byte("<html><script>alert(1)</script></html>"), "partner:partner-a")
+			Expect(errorx.IsOfType(err, errx.UnsupportedMedia)).To(BeTrue())
+		})
+
+		It("deleting the blob when the record can't be stored", func(ctx SpecContext) {
+			started()
+			envelope.EXPECT().Seal(gomock.Any(), gomock.Any()).Return(&storage.Sealed{Ciphertext: []byte("sealed")}, nil)
+			blobs.EXPECT().Put(gomock.Any(), gomock.Any(), gomock.Any()).Return(nil)
+			documentRepo.EXPECT().Create(gomock.Any(), gomock.Any()).Return(nil, errors.New("mock error"))
+			blobs.EXPECT().Delete(gomock.Any(), gomock.Any()).Return(nil)
+			_, err := documents.Upload(ctx, 1, png, "partner:partner-a")
+			Expect(err).ToNot(BeNil())
+		})
+	})
+
+	It("opens a document of the user, decrypting it", func(ctx SpecContext) {
+		document := &models.VerificationDocument{DocumentId: 5, UserId: 1, BlobKey: "documents/1/a", WrappedKey: []byte("wrapped"), KeyId: "k1"}
+		documentRepo.EXPECT().Get(gomock.Any(), 1, 5).Return(document, nil)
+		blobs.EXPECT().Get(gomock.Any(), "documents/1/a").Return([]byte("sealed"), nil)
+		envelope.EXPECT().Open(storage.Sealed{Ciphertext: []byte("sealed"), WrappedKey: []byte("wrapped"), KeyId: "k1"}, []byte("documents/1/a")).Return(png, nil)
+		_, content, err := documents.Open(ctx, 1, 5)
+		Expect(err).To(BeNil())
+		Expect(content).To(Equal(png))
+	})
+
+	It("purges expired documents in batches, blob before record", func(ctx SpecContext) {
+		now := time.Now()
+		batch := make([]*models.VerificationDocument, 100)
+		for i := range batch {
+			batch[i] = &models.VerificationDocument{DocumentId: i + 1, BlobKey: "documents/1/a"}
+		}
+		gomock.InOrder(
+			documentRepo.EXPECT().Expired(gomock.Any(), now, 100).Return(batch, nil),
+			documentRepo.EXPECT().Expired(gomock.Any(), now, 100).Return([]*models.VerificationDocument{{DocumentId: 101, BlobKey: "documents/2/b"}}, nil),
+		)
+		blobs.EXPECT().Delete(gomock.Any(), "documents/1/a").Return(nil).Times(100)
+		blobs.EXPECT().Delete(gomock.Any(), "documents/2/b").Return(errx.NotFound.New("blob documents/2/b not found"))
+		documentRepo.EXPECT().Delete(gomock.Any(), gomock.Any()).Return(nil).Times(101)
+		purged, err := documents.Purge(ctx, now)
+		Expect(err).To(BeNil())
+		Expect(purged).To(Equal(101))
+	})
+
+	It("deletes every document of a user, blob before record", func(ctx SpecContext) {
+		documentRepo.EXPECT().List(gomock.Any(), 1).Return([]*models.VerificationDocument{
+			{DocumentId: 5, UserId: 1, BlobKey: "documents/1/a"},
+			{DocumentId: 6, UserId: 1, BlobKey: "documents/1/b"},
+		}, nil)
+		gomock.InOrder(
+			blobs.EXPECT().Delete(gomock.Any(), "documents/1/a").Return(nil),
+			documentRepo.EXPECT().Delete(gomock.Any(), 5).Return(nil),
+			blobs.EXPECT().Delete(gomock.Any(), "documents/1/b").Return(nil),
+			documentRepo.EXPECT().Delete(gomock.Any(), 6).Return(nil),
+		)
+		deleted, err := documents.DeleteAll(ctx, 1, "system")
+		Expect(err).To(BeNil())
+		Expect(deleted).To(Equal(2))
+	})
+
+	It("keeps the record when its blob can't be deleted", func(ctx SpecContext) {
+		documentRepo.EXPECT().Get(gomock.Any(), 1, 5).Return(&models.VerificationDocument{DocumentId: 5, BlobKey: "documents/1/a"}, nil)
+		blobs.EXPECT().Delete(gomock.Any(), "documents/1/a").Return(errors.New("mock error"))
+		Expect(documents.Delete(ctx, 1, 5, "partner:partner-a")).ToNot(Succeed())
+	})
+})
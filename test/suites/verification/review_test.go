@@ -0,0 +1,187 @@
+package verification_test
+
+import (
+	"github.com/golang/mock/gomock"
+	"github.com/joomcode/errorx"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	errx "github.com/rhuandantas/verifymy-test/internal/errors"
+	"github.com/rhuandantas/verifymy-test/internal/models"
+	"github.com/rhuandantas/verifymy-test/internal/repo"
+	"github.com/rhuandantas/verifymy-test/internal/verification"
+	mock_config "github.com/rhuandantas/verifymy-test/test/mock/config"
+	mock_log "github.com/rhuandantas/verifymy-test/test/mock/log"
+	mock_repo "github.com/rhuandantas/verifymy-test/test/mock/repo"
+	mock_verification "github.com/rhuandantas/verifymy-test/test/mock/verification"
+	"time"
+)
+
+var _ = Describe("Test review queue", func() {
+	var (
+		mockCtrl   *gomock.Controller
+		service    *mock_verification.MockService
+		reviewRepo *mock_repo.MockReviewRepo
+		reviews    verification.Reviews
+	)
+
+	BeforeEach(func() {
+		mockCtrl = gomock.NewController(GinkgoT())
+		service = mock_verification.NewMockService(mockCtrl)
+		reviewRepo = mock_repo.NewMockReviewRepo(mockCtrl)
+		logger := mock_log.NewMockSimpleLogger(mockCtrl)
+		logger.EXPECT().Infof(gomock.Any(), gomock.Any()).AnyTimes()
+		config := mock_config.NewMockConfigProvider(mockCtrl)
+		config.EXPECT().GetInt("verification.review.sla-hours").Return(0)
+		config.EXPECT().GetInt("verification.review.claim-minutes").Return(30)
+		reviews = verification.NewReviewQueue(config, service, reviewRepo, logger)
+		reviewRepo.EXPECT().Notes(gomock.Any(), gomock.Any()).Return([]*models.ReviewNote{}, nil).AnyTimes()
+	})
+
+	status := func(state string) *models.VerificationStatus {
+		return &models.VerificationStatus{Verification: &models.Verification{UserId: 1, State: state}}
+	}
+
+	updated := func() {
+		reviewRepo.EXPECT().Update(gomock.Any(), gomock.Any()).DoAndReturn(func(_ interface{}, reviewCase models.ReviewCase) (*models.ReviewCase, error) {
+			reviewCase.Version++
+			return &reviewCase, nil
+		})
+	}
+
+	claimed := func(reviewer string, until time.Time) *models.ReviewCase {
+		return &models.ReviewCase{CaseId: 7, UserId: 1, Status: models.ReviewClaimed, Reviewer: reviewer, ClaimExpiresAt: &until, DueAt: time.Now().Add(time.Hour), Version: 2}
+	}
+
+	Context("Open a case", func() {
+		BeforeEach(func() {
+			reviewRepo.EXPECT().GetUndecided(gomock.Any(), 1).Return(nil, errx.NotFound.New("User 1 has no review case under way")).AnyTimes()
+		})
+
+		It("due a day later by default, keeping a pending verification as it is", func(ctx SpecContext) {
+			service.EXPECT().Get(gomock.Any(), 1).Return(status(models.VerificationPending), nil)
+			reviewRepo.EXPECT().Create(gomock.Any(), gomock.Any()).DoAndReturn(func(_ interface{}, reviewCase models.ReviewCase) (*models.ReviewCase, error) {
+				Expect(reviewCase.DueAt).To(BeTemporally("~", time.Now().Add(24*time.Hour), time.Minute))
+				Expect(reviewCase.Status).To(Equal(models.ReviewOpen))
+				reviewCase.CaseId = 7
+				return &reviewCase, nil
+			})
+			res, err := reviews.Open(ctx, models.ReviewRequest{UserId: 1, Reason: "inconclusive document"}, "provider:fake")
+			Expect(err).To(BeNil())
+			Expect(res.CaseId).To(Equal(7))
+			Expect(res.OpenedBy).To(Equal("provider:fake"))
+			Expect(res.Overdue).To(BeFalse())
+		})
+
+		It("moving a disputed rejection back to pending", func(ctx SpecContext) {
+			service.EXPECT().Get(gomock.Any(), 1).Return(status(models.VerificationRejected), nil)
+			reviewRepo.EXPECT().Create(gomock.Any(), gomock.Any()).DoAndReturn(func(_ interface{}, reviewCase models.ReviewCase) (*models.ReviewCase, error) {
+				reviewCase.CaseId = 7
+				return &reviewCase, nil
+			})
+			service.EXPECT().Transition(gomock.Any(), 1, models.VerificationTransition{
+				State:       models.VerificationPending,
+				Method:      models.MethodManual,
+				EvidenceRef: "review:7",
+				Reason:      "user disputes the rejection",
+			}, "support@mail.com").Return(status(models.VerificationPending), nil)
+			_, err := reviews.Open(ctx, models.ReviewRequest{UserId: 1, Reason: "user disputes the rejection"}, "support@mail.com")
+			Expect(err).To(BeNil())
+		})
+
+		It("unless the user is verified", func(ctx SpecContext) {
+			service.EXPECT().Get(gomock.Any(), 1).Return(status(models.VerificationVerified), nil)
+			_, err := reviews.Open(ctx, models.ReviewRequest{UserId: 1, Reason: "why not"}, "support@mail.com")
+			Expect(errorx.IsOfType(err, errx.InvalidTransition)).To(BeTrue())
+		})
+	})
+
+	It("refuses a second case under way for the user", func(ctx SpecContext) {
+		reviewRepo.EXPECT().GetUndecided(gomock.Any(), 1).Return(&models.ReviewCase{CaseId: 7, UserId: 1, Status: models.ReviewOpen}, nil)
+		_, err := reviews.Open(ctx, models.ReviewRequest{UserId: 1, Reason: "again"}, "support@mail.com")
+		Expect(errorx.IsOfType(err, errx.Conflict)).To(BeTrue())
+	})
+
+	It("flags the cases past their due date", func(ctx SpecContext) {
+		reviewRepo.EXPECT().List(gomock.Any(), gomock.Any(), 50).Return([]*models.ReviewCase{
+			{CaseId: 1, Status: models.ReviewOpen, DueAt: time.Now().Add(-time.Minute)},
+			{CaseId: 2, Status: models.ReviewApproved, DueAt: time.Now().Add(-time.Minute)},
+			{CaseId: 3, Status: models.ReviewClaimed, DueAt: time.Now().Add(time.Minute)},
+		}, nil)
+		cases, err := reviews.List(ctx, repo.NewQuery(), 50)
+		Expect(err).To(BeNil())
+		Expect([]bool{cases[0].Overdue, cases[1].Overdue, cases[2].Overdue}).To(Equal([]bool{true, false, false}))
+	})
+
+	Context("Claim a case", func() {
+		It("that is open", func(ctx SpecContext) {
+			reviewRepo.EXPECT().Get(gomock.Any(), 7).Return(&models.ReviewCase{CaseId: 7, UserId: 1, Status: models.ReviewOpen, Version: 1}, nil)
+			updated()
+			res, err := reviews.Claim(ctx, 7, "ann@mail.com")
+			Expect(err).To(BeNil())
+			Expect(res.Status).To(Equal(models.ReviewClaimed))
+			Expect(res.Reviewer).To(Equal("ann@mail.com"))
+			Expect(*res.ClaimExpiresAt).To(BeTemporally("~", time.Now().Add(30*time.Minute), time.Minute))
+		})
+
+		It("while another reviewer's claim lasts", func(ctx SpecContext) {
+			reviewRepo.EXPECT().Get(gomock.Any(), 7).Return(claimed("bob@mail.com", time.Now().Add(time.Minute)), nil)
+			_, err := reviews.Claim(ctx, 7, "ann@mail.com")
+			Expect(errorx.IsOfType(err, errx.Conflict)).To(BeTrue())
+		})
+
+		It("once another reviewer's claim ran out", func(ctx SpecContext) {
+			reviewRepo.EXPECT().Get(gomock.Any(), 7).Return(claimed("bob@mail.com", time.Now().Add(-time.Minute)), nil)
+			updated()
+			res, err := reviews.Claim(ctx, 7, "ann@mail.com")
+			Expect(err).To(BeNil())
+			Expect(res.Reviewer).To(Equal("ann@mail.com"))
+		})
+	})
+
+	Context("Decide a case", func() {
+		It("approving the verification on behalf of the reviewer", func(ctx SpecContext) {
+			reviewRepo.EXPECT().Get(gomock.Any(), 7).Return(claimed("ann@mail.com", time.Now().Add(time.Minute)), nil)
+			service.EXPECT().Transition(gomock.Any(), 1, models.VerificationTransition{
+				State:          models.VerificationVerified,
+				Method:         models.MethodManual,
+				AssuranceLevel: models.AssuranceSubstantial,
+				EvidenceRef:    "review:7",
+				Reason:         "passport checked by hand",
+			}, "ann@mail.com").Return(status(models.VerificationVerified), nil)
+			updated()
+			res, err := reviews.Decide(ctx, 7, "ann@mail.com", models.ReviewDecision{
+				Decision: models.ReviewApproved, AssuranceLevel: models.AssuranceSubstantial, Reason: "passport checked by hand",
+			})
+			Expect(err).To(BeNil())
+			Expect(res.Status).To(Equal(models.ReviewApproved))
+			Expect(res.DecidedAt).ToNot(BeNil())
+			Expect(res.DecisionReason).To(Equal("passport checked by hand"))
+		})
+
+		It("rejecting the verification", func(ctx SpecContext) {
+			reviewRepo.EXPECT().Get(gomock.Any(), 7).Return(claimed("ann@mail.com", time.Now().Add(-time.Minute)), nil)
+			service.EXPECT().Transition(gomock.Any(), 1, gomock.Any(), "ann@mail.com").
+				DoAndReturn(func(_ interface{}, _ int, transition models.VerificationTransition, _ string) (*models.VerificationStatus, error) {
+					Expect(transition.State).To(Equal(models.VerificationRejected))
+					Expect(transition.Reason).To(Equal("photo doesn't match"))
+					return status(models.VerificationRejected), nil
+				})
+			updated()
+			res, err := reviews.Decide(ctx, 7, "ann@mail.com", models.ReviewDecision{Decision: models.ReviewRejected, Reason: "photo doesn't match"})
+			Expect(err).To(BeNil())
+			Expect(res.Status).To(Equal(models.ReviewRejected))
+		})
+
+		It("only by the reviewer who claimed it", func(ctx SpecContext) {
+			reviewRepo.EXPECT().Get(gomock.Any(), 7).Return(claimed("bob@mail.com", time.Now().Add(time.Minute)), nil)
+			_, err := reviews.Decide(ctx, 7, "ann@mail.com", models.ReviewDecision{Decision: models.ReviewRejected, Reason: "no"})
+			Expect(errorx.IsOfType(err, errx.Forbidden)).To(BeTrue())
+		})
+
+		It("only once", func(ctx SpecContext) {
+			reviewRepo.EXPECT().Get(gomock.Any(), 7).Return(&models.ReviewCase{CaseId: 7, Status: models.ReviewApproved, Reviewer: "ann@mail.com"}, nil)
+			_, err := reviews.Decide(ctx, 7, "ann@mail.com", models.ReviewDecision{Decision: models.ReviewRejected, Reason: "changed my mind"})
+			Expect(errorx.IsOfType(err, errx.InvalidTransition)).To(BeTrue())
+		})
+	})
+})
@@ -0,0 +1,129 @@
+package verification_test
+
+import (
+	"github.com/golang/mock/gomock"
+	"github.com/joomcode/errorx"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	errx "github.com/rhuandantas/verifymy-test/internal/errors"
+	"github.com/rhuandantas/verifymy-test/internal/models"
+	"github.com/rhuandantas/verifymy-test/internal/verification"
+	mock_config "github.com/rhuandantas/verifymy-test/test/mock/config"
+	mock_log "github.com/rhuandantas/verifymy-test/test/mock/log"
+	mock_repo "github.com/rhuandantas/verifymy-test/test/mock/repo"
+	"time"
+)
+
+var _ = Describe("Test verification service", func() {
+	var (
+		mockCtrl         *gomock.Controller
+		userRepo         *mock_repo.MockUserRepo
+		verificationRepo *mock_repo.MockVerificationRepo
+		logger           *mock_log.MockSimpleLogger
+		service          verification.Service
+	)
+
+	BeforeEach(func() {
+		mockCtrl = gomock.NewController(GinkgoT())
+		userRepo = mock_repo.NewMockUserRepo(mockCtrl)
+		verificationRepo = mock_repo.NewMockVerificationRepo(mockCtrl)
+		logger = mock_log.NewMockSimpleLogger(mockCtrl)
+		config := mock_config.NewMockConfigProvider(mockCtrl)
+		config.EXPECT().GetInt("verification.validity-days").Return(30)
+		service = verification.NewVerificationService(config, userRepo, verificationRepo, logger)
+		userRepo.EXPECT().GetByID(gomock.Any(), 1, "user_id").Return(&models.User{UserId: 1}, nil).AnyTimes()
+	})
+
+	saved := func(history ...*models.VerificationEvent) {
+		verificationRepo.EXPECT().Save(gomock.Any(), gomock.Any(), gomock.Any()).
+			DoAndReturn(func(_ interface{}, v models.Verification, event models.VerificationEvent) (*models.Verification, error) {
+				v.Version++
+				history = append(history, &event)
+				return &v, nil
+			})
+		verificationRepo.EXPECT().History(gomock.Any(), 1).DoAndReturn(func(_ interface{}, _ int) ([]*models.VerificationEvent, error) {
+			return history, nil
+		})
+	}
+
+	It("reports users that never started as unverified", func(ctx SpecContext) {
+		verificationRepo.EXPECT().Get(gomock.Any(), 1).Return(nil, errx.NotFound.New("User 1 has no verification"))
+		verificationRepo.EXPECT().History(gomock.Any(), 1).Return([]*models.VerificationEvent{}, nil)
+		status, err := service.Get(ctx, 1)
+		Expect(err).To(BeNil())
+		Expect(status.State).To(Equal(models.VerificationUnverified))
+		Expect(status.History).To(BeEmpty())
+	})
+
+	It("fails for users that don't exist", func(ctx SpecContext) {
+		userRepo.EXPECT().GetByID(gomock.Any(), 2, "user_id").Return(nil, errx.NotFound.New("User not found with id 2"))
+		_, err := service.Get(ctx, 2)
+		Expect(errorx.IsOfType(err, errx.NotFound)).To(BeTrue())
+	})
+
+	It("starts a verification and records the transition", func(ctx SpecContext) {
+		verificationRepo.EXPECT().Get(gomock.Any(), 1).Return(nil, errx.NotFound.New("User 1 has no verification"))
+		saved()
+		status, err := service.Transition(ctx, 1, models.VerificationTransition{State: models.VerificationPending, Method: models.MethodDocument}, "admin")
+		Expect(err).To(BeNil())
+		Expect(status.State).To(Equal(models.VerificationPending))
+		Expect(status.RequestedAt).ToNot(BeNil())
+		Expect(status.History).To(HaveLen(1))
+		Expect(*status.History[0]).To(Equal(models.VerificationEvent{
+			UserId: 1,
+			From:   models.VerificationUnverified,
+			To:     models.VerificationPending,
+			Method: models.MethodDocument,
+			Actor:  "admin",
+		}))
+	})
+
+	It("verifies a pending verification until it expires", func(ctx SpecContext) {
+		dateOfBirth := models.NewDate(2000, time.May, 4)
+		verificationRepo.EXPECT().Get(gomock.Any(), 1).Return(&models.Verification{UserId: 1, State: models.VerificationPending, Method: models.MethodDocument, Version: 1}, nil)
+		userRepo.EXPECT().GetByID(gomock.Any(), 1, "date_of_birth", "timezone").Return(&models.User{UserId: 1, DateOfBirth: &dateOfBirth, Timezone: "Europe/Lisbon"}, nil)
+		saved()
+		status, err := service.Transition(ctx, 1, models.VerificationTransition{
+			State:          models.VerificationVerified,
+			AssuranceLevel: models.AssuranceHigh,
+			EvidenceRef:    "doc-123",
+		}, "admin")
+		Expect(err).To(BeNil())
+		Expect(status.DateOfBirth).To(Equal(&dateOfBirth))
+		Expect(status.Timezone).To(Equal("Europe/Lisbon"))
+		Expect(status.Method).To(Equal(models.MethodDocument))
+		Expect(status.AssuranceLevel).To(Equal(models.AssuranceHigh))
+		Expect(status.ExpiresAt.Sub(*status.VerifiedAt)).To(Equal(30 * 24 * time.Hour))
+		Expect(status.Version).To(Equal(2))
+	})
+
+	It("refuses transitions the state doesn't lead to", func(ctx SpecContext) {
+		verificationRepo.EXPECT().Get(gomock.Any(), 1).Return(nil, errx.NotFound.New("User 1 has no verification"))
+		_, err := service.Transition(ctx, 1, models.VerificationTransition{State: models.VerificationVerified}, "admin")
+		Expect(errorx.IsOfType(err, errx.InvalidTransition)).To(BeTrue())
+		Expect(errorx.IsOfType(err, errx.Conflict)).To(BeTrue())
+	})
+
+	It("requires what the state needs", func(ctx SpecContext) {
+		verificationRepo.EXPECT().Get(gomock.Any(), 1).Return(&models.Verification{UserId: 1, State: models.VerificationPending, Version: 1}, nil)
+		_, err := service.Transition(ctx, 1, models.VerificationTransition{State: models.VerificationVerified}, "admin")
+		Expect(errorx.IsOfType(err, errx.Validation)).To(BeTrue())
+		fields := make([]string, 0)
+		for _, field := range errx.FieldErrors(errorx.Cast(err)) {
+			fields = append(fields, field.Field)
+		}
+		Expect(fields).To(ConsistOf("method", "assurance_level", "evidence_ref"))
+	})
+
+	It("expires verifications past their validity when read", func(ctx SpecContext) {
+		expiresAt := time.Now().Add(-time.Hour)
+		verificationRepo.EXPECT().Get(gomock.Any(), 1).Return(&models.Verification{UserId: 1, State: models.VerificationVerified, ExpiresAt: &expiresAt, Version: 2}, nil)
+		logger.EXPECT().Infof(gomock.Any(), gomock.Any())
+		saved()
+		status, err := service.Get(ctx, 1)
+		Expect(err).To(BeNil())
+		Expect(status.State).To(Equal(models.VerificationExpired))
+		Expect(*status.ExpiresAt).To(Equal(expiresAt))
+		Expect(status.History[0].Actor).To(Equal(verification.ActorSystem))
+	})
+})
@@ -6,24 +6,71 @@ package main
 
 import (
 	"github.com/google/wire"
+	"github.com/rhuandantas/verifymy-test/internal/clients"
 	"github.com/rhuandantas/verifymy-test/internal/config"
+	"github.com/rhuandantas/verifymy-test/internal/guardian"
+	"github.com/rhuandantas/verifymy-test/internal/i18n"
+	"github.com/rhuandantas/verifymy-test/internal/jobs"
 	"github.com/rhuandantas/verifymy-test/internal/log"
 	"github.com/rhuandantas/verifymy-test/internal/repo"
+	"github.com/rhuandantas/verifymy-test/internal/rules"
+	"github.com/rhuandantas/verifymy-test/internal/search"
 	"github.com/rhuandantas/verifymy-test/internal/server"
 	"github.com/rhuandantas/verifymy-test/internal/server/handlers"
 	"github.com/rhuandantas/verifymy-test/internal/server/middlewares/auth"
+	"github.com/rhuandantas/verifymy-test/internal/storage"
 	"github.com/rhuandantas/verifymy-test/internal/util"
+	"github.com/rhuandantas/verifymy-test/internal/verification"
 )
 
 func InitializeWebServer() (*server.HttpServer, error) {
 	wire.Build(config.NewLocalConfigProvider,
+		i18n.NewCatalogTranslator,
 		util.NewCustomValidator,
+		util.NewPasswordPolicy,
 		log.NewLogger,
 		repo.NewMysqlORMConn,
 		auth.NewJwtToken,
+		auth.NewMemoryNonceCache,
+		auth.NewHmacSignature,
+		auth.NewEcdsaAttester,
 		repo.NewUserRepo,
+		repo.NewAddressRepo,
+		repo.NewVerificationRepo,
+		repo.NewReviewRepo,
+		repo.NewGuardianRepo,
+		repo.NewDocumentRepo,
+		repo.NewClientRepo,
+		repo.NewUsageRepo,
+		storage.NewBlobStore,
+		storage.NewAesEnvelope,
+		clients.NewClientRegistry,
+		clients.NewMemoryMeter,
+		search.NewMysqlUserSearcher,
+		verification.NewVerificationService,
+		verification.NewReviewQueue,
+		verification.NewFakeProvider,
+		verification.NewProviderRegistry,
+		verification.NewProviderChecks,
+		verification.NewDocumentStore,
+		rules.NewFileEngine,
+		guardian.NewConsentService,
 		handlers.NewUserHandler,
+		handlers.NewSearchHandler,
+		handlers.NewAddressHandler,
+		handlers.NewVerificationHandler,
+		handlers.NewAttestationHandler,
+		handlers.NewEligibilityHandler,
+		handlers.NewReviewHandler,
+		handlers.NewGuardianHandler,
+		handlers.NewDocumentHandler,
+		handlers.NewClientHandler,
 		handlers.NewHealthCheck,
+		jobs.NewUserPurge,
+		jobs.NewRulesReload,
+		jobs.NewDocumentPurge,
+		jobs.NewUsageFlush,
+		jobs.NewScheduler,
 		server.NewAPIServer)
 	return &server.HttpServer{}, nil
 }